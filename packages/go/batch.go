@@ -0,0 +1,272 @@
+package humanattestation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultMaxBatchSize is the batch size used when a VA does not advertise
+// WellKnown.MaxBatchSize
+const DefaultMaxBatchSize = 100
+
+// RevocationState distinguishes the outcomes of a revocation status lookup
+type RevocationState string
+
+const (
+	RevocationStateValid   RevocationState = "valid"   // Known to the VA and not revoked
+	RevocationStateRevoked RevocationState = "revoked" // Known to the VA and revoked
+	RevocationStateUnknown RevocationState = "unknown" // Not known to the VA, or not a valid ID
+)
+
+// RevocationStatus is the status of one ID in a batch revocation response
+type RevocationStatus struct {
+	ID      string           `json:"id"`
+	Valid   bool             `json:"valid"`
+	Revoked bool             `json:"revoked,omitempty"`
+	Reason  RevocationReason `json:"reason,omitempty"`
+	Error   string           `json:"error,omitempty"` // "not_found" or "invalid_format" for unknown IDs
+}
+
+// State classifies the status, telling unknown IDs apart from valid ones
+func (s RevocationStatus) State() RevocationState {
+	switch {
+	case s.Revoked:
+		return RevocationStateRevoked
+	case s.Valid:
+		return RevocationStateValid
+	default:
+		return RevocationStateUnknown
+	}
+}
+
+// batchRequest and batchResponse are the wire forms of POST /api/v1/verify/batch
+type batchRequest struct {
+	IDs []string `json:"ids"`
+}
+
+type batchResponse struct {
+	Results []RevocationStatus `json:"results,omitempty"`
+	Error   string             `json:"error,omitempty"`
+}
+
+// CheckRevocationBatch looks up the revocation status of many claims from one
+// issuer via POST /api/v1/verify/batch, split into chunks of the size the
+// issuer advertises in its well-known document (default DefaultMaxBatchSize).
+//
+// Chunks are sent one after another. If some fail, the statuses from the
+// others are still returned along with an error describing the failures, so
+// callers can retry only the IDs missing from the map. IDs that are not
+// well-formed are reported as unknown without being sent.
+func CheckRevocationBatch(ctx context.Context, issuerDomain string, ids []string, opts VerifyOptions) (map[string]RevocationStatus, error) {
+	opts = opts.metered()
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	url, err := opts.endpoint(issuerDomain, "/api/v1/verify/batch")
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make(map[string]RevocationStatus, len(ids))
+	seen := make(map[string]bool, len(ids))
+	var pending []string
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if opts.validateID(id) != nil {
+			statuses[id] = RevocationStatus{ID: id, Error: "invalid_format"}
+			continue
+		}
+		pending = append(pending, id)
+	}
+	if len(pending) == 0 {
+		return statuses, nil
+	}
+
+	chunkSize := DefaultMaxBatchSize
+	if wellKnown, _, err := fetchPublicKeys(ctx, issuerDomain, opts); err == nil && wellKnown.MaxBatchSize > 0 {
+		chunkSize = wellKnown.MaxBatchSize
+	}
+
+	var errs []error
+	for start := 0; start < len(pending); start += chunkSize {
+		chunk := pending[start:min(start+chunkSize, len(pending))]
+		results, err := postBatch(ctx, url, chunk, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("batch of %d IDs starting at %s: %w", len(chunk), chunk[0], err))
+			continue
+		}
+		requested := make(map[string]bool, len(chunk))
+		for _, id := range chunk {
+			requested[id] = true
+		}
+		for _, r := range results {
+			// Ignore anything the VA volunteers beyond what was asked
+			if requested[r.ID] {
+				statuses[r.ID] = r
+			}
+		}
+	}
+	if err := opts.meter.Err(); err != nil {
+		return statuses, err
+	}
+	return statuses, errors.Join(errs...)
+}
+
+// postBatch sends one chunk of IDs to the batch endpoint
+func postBatch(ctx context.Context, url string, ids []string, opts VerifyOptions) ([]RevocationStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	body, err := json.Marshal(batchRequest{IDs: ids})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch batch status: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := opts.meter.readAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch batch status: HTTP %d", resp.StatusCode)
+	}
+
+	var br batchResponse
+	if err := json.Unmarshal(respBody, &br); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return br.Results, nil
+}
+
+// BatchHandlerOptions configures NewBatchVerifyHandler
+type BatchHandlerOptions struct {
+	// MaxBatchSize caps the IDs per request (default: DefaultMaxBatchSize).
+	// Publish the same value as WellKnown.MaxBatchSize.
+	MaxBatchSize int
+	// IDsPerSecond limits how many IDs the handler looks up per second
+	// across all clients (default: unlimited). Requests over the limit get
+	// 429 Too Many Requests with a Retry-After header.
+	IDsPerSecond float64
+}
+
+// NewBatchVerifyHandler returns an http.Handler serving POST
+// /api/v1/verify/batch, which reports the revocation status of up to
+// MaxBatchSize IDs per request from lookup
+func NewBatchVerifyHandler(lookup ClaimLookup, opts BatchHandlerOptions) http.Handler {
+	if opts.MaxBatchSize <= 0 {
+		opts.MaxBatchSize = DefaultMaxBatchSize
+	}
+	var limiter *tokenBucket
+	if opts.IDsPerSecond > 0 {
+		// A full batch must always fit in the bucket
+		limiter = newTokenBucket(opts.IDsPerSecond, float64(opts.MaxBatchSize))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req batchRequest
+		// Bound the body: IDs are short, so allow generous room per ID
+		r.Body = http.MaxBytesReader(w, r.Body, int64(opts.MaxBatchSize)*64+1024)
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeVerifyJSON(w, http.StatusBadRequest, batchResponse{Error: "invalid_request"})
+			return
+		}
+		if len(req.IDs) > opts.MaxBatchSize {
+			writeVerifyJSON(w, http.StatusRequestEntityTooLarge, batchResponse{Error: "batch_too_large"})
+			return
+		}
+
+		if limiter != nil {
+			if wait := limiter.take(float64(len(req.IDs))); wait > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeVerifyJSON(w, http.StatusTooManyRequests, batchResponse{Error: "rate_limited"})
+				return
+			}
+		}
+
+		results := make([]RevocationStatus, 0, len(req.IDs))
+		for _, id := range req.IDs {
+			status := RevocationStatus{ID: id}
+			if ValidateID(id, IDOptions{AllowChecked: true}) != nil {
+				status.Error = "invalid_format"
+				results = append(results, status)
+				continue
+			}
+			resp, err := lookup.LookupClaim(r.Context(), id)
+			if err != nil {
+				http.Error(w, "internal error", http.StatusInternalServerError)
+				return
+			}
+			switch {
+			case resp == nil:
+				status.Error = "not_found"
+			case resp.Revoked:
+				status.Revoked, status.Reason = true, resp.RevocationReason
+			default:
+				status.Valid = resp.Valid
+			}
+			results = append(results, status)
+		}
+		writeVerifyJSON(w, http.StatusOK, batchResponse{Results: results})
+	})
+}
+
+// tokenBucket is a minimal token bucket rate limiter
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64 // Tokens added per second
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// take removes n tokens and returns 0, or returns how long until n tokens
+// are available without removing any
+func (b *tokenBucket) take(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= n {
+		b.tokens -= n
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
@@ -0,0 +1,169 @@
+package humanattestation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// batchVA serves a well-known document and the batch endpoint
+type batchVA struct {
+	*httptest.Server
+	requests atomic.Int32
+}
+
+// newBatchVA serves the claims in responses. failChunk, if non-zero, makes
+// that batch request fail.
+func newBatchVA(t *testing.T, maxBatchSize int, responses map[string]*VerificationResponse, failChunk int32) *batchVA {
+	t.Helper()
+	va := &batchVA{}
+	lookup := ClaimLookupFunc(func(ctx context.Context, hapID string) (*VerificationResponse, error) {
+		return responses[hapID], nil
+	})
+	handler := NewBatchVerifyHandler(lookup, BatchHandlerOptions{MaxBatchSize: maxBatchSize})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/hap.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(WellKnown{Issuer: strings.TrimPrefix(va.URL, "https://"), MaxBatchSize: maxBatchSize})
+	})
+	mux.HandleFunc("/api/v1/verify/batch", func(w http.ResponseWriter, r *http.Request) {
+		if va.requests.Add(1) == failChunk {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+	va.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(func() {
+		va.Close()
+		FlushKeyCache(strings.TrimPrefix(va.URL, "https://"))
+	})
+	return va
+}
+
+func (va *batchVA) options() VerifyOptions {
+	opts := DefaultVerifyOptions()
+	opts.HTTPClient = va.Client()
+	return opts
+}
+
+// batchIDs generates n IDs; every third is revoked and every fifth unknown
+func batchIDs(t *testing.T, n int) ([]string, map[string]*VerificationResponse) {
+	t.Helper()
+	ids := make([]string, n)
+	responses := make(map[string]*VerificationResponse)
+	for i := range ids {
+		id, err := GenerateID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[i] = id
+		switch {
+		case i%5 == 0:
+			// Unknown to the VA
+		case i%3 == 0:
+			responses[id] = &VerificationResponse{ID: id, Revoked: true, RevocationReason: RevocationFraud}
+		default:
+			responses[id] = &VerificationResponse{ID: id, Valid: true}
+		}
+	}
+	return ids, responses
+}
+
+func TestCheckRevocationBatch(t *testing.T) {
+	ids, responses := batchIDs(t, 250)
+	va := newBatchVA(t, 100, responses, 0)
+
+	statuses, err := CheckRevocationBatch(context.Background(), strings.TrimPrefix(va.URL, "https://"), append(ids, "not-an-id", ids[0]), va.options())
+	if err != nil {
+		t.Fatalf("CheckRevocationBatch() error = %v", err)
+	}
+	if got := va.requests.Load(); got != 3 {
+		t.Errorf("batch requests = %d, want 3 chunks of at most 100", got)
+	}
+	if len(statuses) != 251 {
+		t.Errorf("got %d statuses, want 251", len(statuses))
+	}
+
+	for i, id := range ids {
+		want := RevocationStateValid
+		switch {
+		case i%5 == 0:
+			want = RevocationStateUnknown
+		case i%3 == 0:
+			want = RevocationStateRevoked
+		}
+		if got := statuses[id].State(); got != want {
+			t.Errorf("ids[%d] state = %s, want %s", i, got, want)
+		}
+	}
+	if s := statuses[ids[3]]; s.Reason != RevocationFraud {
+		t.Errorf("revoked reason = %q, want fraud", s.Reason)
+	}
+	if s := statuses[ids[0]]; s.Error != "not_found" {
+		t.Errorf("unknown ID error = %q, want not_found", s.Error)
+	}
+	if s := statuses["not-an-id"]; s.State() != RevocationStateUnknown || s.Error != "invalid_format" {
+		t.Errorf("malformed ID status = %+v", s)
+	}
+}
+
+func TestCheckRevocationBatchPartialFailure(t *testing.T) {
+	ids, responses := batchIDs(t, 30)
+	va := newBatchVA(t, 10, responses, 2)
+
+	statuses, err := CheckRevocationBatch(context.Background(), strings.TrimPrefix(va.URL, "https://"), ids, va.options())
+	if err == nil || !strings.Contains(err.Error(), "HTTP 503") {
+		t.Errorf("CheckRevocationBatch() error = %v, want the failed chunk reported", err)
+	}
+	if len(statuses) != 20 {
+		t.Errorf("got %d statuses, want 20 from the chunks that succeeded", len(statuses))
+	}
+	for _, id := range ids[10:20] {
+		if _, ok := statuses[id]; ok {
+			t.Errorf("status for %s from the failed chunk", id)
+		}
+	}
+}
+
+func TestBatchVerifyHandlerLimits(t *testing.T) {
+	ids, responses := batchIDs(t, 6)
+	lookup := ClaimLookupFunc(func(ctx context.Context, hapID string) (*VerificationResponse, error) {
+		return responses[hapID], nil
+	})
+	handler := NewBatchVerifyHandler(lookup, BatchHandlerOptions{MaxBatchSize: 5, IDsPerSecond: 0.001})
+
+	post := func(ids []string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(batchRequest{IDs: ids})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/verify/batch", bytes.NewReader(body)))
+		return rec
+	}
+
+	if rec := post(ids); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized batch status = %d, want 413", rec.Code)
+	}
+	if rec := post(ids[:5]); rec.Code != http.StatusOK {
+		t.Errorf("first batch status = %d, want 200", rec.Code)
+	}
+	rec := post(ids[:1])
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("rate limited status = %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/verify/batch", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want 405", rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/verify/batch", strings.NewReader("{")))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("malformed body status = %d, want 400", rec.Code)
+	}
+}
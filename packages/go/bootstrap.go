@@ -0,0 +1,56 @@
+package humanattestation
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Thumbprint returns the key's RFC 7638 JWK thumbprint: the base64url SHA-256
+// of its required members in lexicographic order. It identifies the key
+// material, so it makes a kid that can never be reused for another key.
+func (k JWK) Thumbprint() string {
+	// Members are fixed and sorted; only x varies and is already base64url
+	canonical := fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, k.Crv, k.Kty, k.X)
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// BuildWellKnown validates a well-known document for issuer with the given
+// keys and returns it serialized, ready to serve at /.well-known/hap.json
+func BuildWellKnown(issuer string, keys ...JWK) ([]byte, error) {
+	wk := WellKnown{Issuer: issuer, Keys: keys}
+	if err := ValidateWellKnown(wk); err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(wk)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize well-known document: %w", err)
+	}
+	return body, nil
+}
+
+// Bootstrap sets up a new VA in one call: it generates a claims signing key,
+// uses its thumbprint as the kid, and builds the well-known document
+// publishing it. Store priv as a secret and serve wellKnownJSON as is.
+func Bootstrap(issuer string) (priv ed25519.PrivateKey, kid string, wellKnownJSON []byte, err error) {
+	if err := ValidateDomain(issuer); err != nil {
+		return nil, "", nil, fmt.Errorf("invalid issuer: %w", err)
+	}
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	jwk := ExportPublicKeyJWKWithUse(pub, "", KeyUseClaims)
+	jwk.Kid = jwk.Thumbprint()
+	jwk.Created = &Timestamp{time.Now().UTC().Truncate(time.Second)}
+	wellKnownJSON, err = BuildWellKnown(issuer, jwk)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return priv, jwk.Kid, wellKnownJSON, nil
+}
@@ -0,0 +1,52 @@
+package humanattestation
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestJWKThumbprint(t *testing.T) {
+	// RFC 8037 Appendix A.3
+	jwk := JWK{Kty: "OKP", Crv: "Ed25519", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo", Kid: "ignored", Use: KeyUseClaims}
+	if got, want := jwk.Thumbprint(), "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"; got != want {
+		t.Errorf("Thumbprint() = %s, want %s", got, want)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	priv, kid, wellKnownJSON, err := Bootstrap("ballista.jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wk WellKnown
+	if err := json.Unmarshal(wellKnownJSON, &wk); err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateWellKnown(wk); err != nil {
+		t.Errorf("ValidateWellKnown() = %v", err)
+	}
+	if wk.Issuer != "ballista.jobs" || len(wk.Keys) != 1 || wk.Keys[0].Kid != kid || kid != wk.Keys[0].Thumbprint() {
+		t.Errorf("well-known document = %s, kid %s", wellKnownJSON, kid)
+	}
+
+	claim := testClaim(t)
+	jws, err := SignClaim(claim, priv, kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := verifyJWSSignature(jws, wk.Keys); !result.Valid {
+		t.Errorf("claim signed with bootstrapped key does not verify: %s", result.Error)
+	}
+	compact, err := SignCompact(claim, priv, CompactOptions{Version: CompactVersionV2, Kid: kid})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := VerifyCompact(compact, wk.Keys); !result.Valid {
+		t.Errorf("compact signed with bootstrapped key does not verify: %s", result.Error)
+	}
+
+	if _, _, _, err := Bootstrap("not a domain"); err == nil {
+		t.Error("expected error for invalid issuer")
+	}
+}
@@ -0,0 +1,110 @@
+package humanattestation
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrWorkBudgetExceeded is returned when verifying an input would exceed its WorkBudget
+var ErrWorkBudgetExceeded = errors.New("work budget exceeded")
+
+// WorkBudget caps the total work spent verifying one untrusted input,
+// across every key fetch, signature check and response read in the call.
+// Zero fields are unlimited.
+type WorkBudget struct {
+	// MaxKeyFetches limits well-known document fetches
+	MaxKeyFetches int `json:"maxKeyFetches,omitempty"`
+	// MaxSignatureAttempts limits signature verifications, including keys tried
+	// and rejected while searching for the signer
+	MaxSignatureAttempts int `json:"maxSignatureAttempts,omitempty"`
+	// MaxBytes limits the total size of HTTP response bodies read
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+}
+
+// workMeter tracks work spent against a WorkBudget. A nil meter is unlimited.
+type workMeter struct {
+	mu                sync.Mutex
+	budget            WorkBudget
+	keyFetches        int
+	signatureAttempts int
+	bytes             int64
+	err               error
+}
+
+// metered returns opts with a work meter attached if a budget is set and no
+// meter is attached yet. Calls made with the returned options share the meter.
+func (o VerifyOptions) metered() VerifyOptions {
+	if o.Budget != nil && o.meter == nil {
+		o.meter = &workMeter{budget: *o.Budget}
+	}
+	return o
+}
+
+// exceeded records and returns a budget error
+func (m *workMeter) exceeded(format string, args ...interface{}) error {
+	if m.err == nil {
+		m.err = fmt.Errorf("%w: %s", ErrWorkBudgetExceeded, fmt.Sprintf(format, args...))
+	}
+	return m.err
+}
+
+// keyFetch spends one key fetch
+func (m *workMeter) keyFetch() error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.keyFetches++
+	if m.budget.MaxKeyFetches > 0 && m.keyFetches > m.budget.MaxKeyFetches {
+		return m.exceeded("more than %d key fetches", m.budget.MaxKeyFetches)
+	}
+	return nil
+}
+
+// signatureAttempt spends one signature verification
+func (m *workMeter) signatureAttempt() error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.signatureAttempts++
+	if m.budget.MaxSignatureAttempts > 0 && m.signatureAttempts > m.budget.MaxSignatureAttempts {
+		return m.exceeded("more than %d signature attempts", m.budget.MaxSignatureAttempts)
+	}
+	return nil
+}
+
+// readAll reads r, spending the bytes read and stopping once the budget is exhausted
+func (m *workMeter) readAll(r io.Reader) ([]byte, error) {
+	if m == nil || m.budget.MaxBytes <= 0 {
+		return io.ReadAll(r)
+	}
+
+	m.mu.Lock()
+	remaining := m.budget.MaxBytes - m.bytes
+	m.mu.Unlock()
+
+	data, err := io.ReadAll(io.LimitReader(r, remaining+1))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bytes += int64(len(data))
+	if m.bytes > m.budget.MaxBytes {
+		return nil, m.exceeded("more than %d bytes read", m.budget.MaxBytes)
+	}
+	return data, err
+}
+
+// Err returns the budget error recorded by the meter, if any
+func (m *workMeter) Err() error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
@@ -0,0 +1,100 @@
+package humanattestation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestWorkBudgetSignatureAttempts(t *testing.T) {
+	va := newTestVA(t)
+	var priv ed25519.PrivateKey
+	for i := 0; i < 20; i++ {
+		priv = va.AddKey(t, fmt.Sprintf("key_%03d", i), KeyUseClaims)
+	}
+	claim := testClaim(t)
+	claim.Iss = va.Issuer()
+
+	// Version 1 strings carry no kid, so every published key is tried until
+	// the signer (published last) is reached
+	compact, err := SignCompact(claim, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	opts := va.Options()
+	opts.Budget = &WorkBudget{MaxSignatureAttempts: 5}
+	if _, err := VerifyAnything(ctx, compact, []string{va.Issuer()}, opts); !errors.Is(err, ErrWorkBudgetExceeded) {
+		t.Errorf("VerifyAnything() error = %v, want ErrWorkBudgetExceeded", err)
+	}
+
+	opts.Budget = &WorkBudget{MaxSignatureAttempts: 20}
+	result, err := VerifyAnything(ctx, compact, []string{va.Issuer()}, opts)
+	if err != nil || !result.Valid {
+		t.Errorf("VerifyAnything() = %+v, %v, want valid within budget", result, err)
+	}
+}
+
+func TestWorkBudgetVerifyCompact(t *testing.T) {
+	var keys []JWK
+	var priv ed25519.PrivateKey
+	for i := 0; i < 20; i++ {
+		var jwk JWK
+		priv, jwk = testKeys(t, fmt.Sprintf("key_%03d", i))
+		keys = append(keys, jwk)
+	}
+	compact, err := SignCompact(testClaim(t), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := VerifyCompact(compact, keys, VerifyOptions{Budget: &WorkBudget{MaxSignatureAttempts: 5}})
+	if result.Valid || !errors.Is(result.Err, ErrWorkBudgetExceeded) || result.FailureReason != FailureBudgetExceeded {
+		t.Errorf("VerifyCompact() = %+v, want ErrWorkBudgetExceeded", result)
+	}
+	if result := VerifyCompact(compact, keys, VerifyOptions{Budget: &WorkBudget{MaxSignatureAttempts: 20}}); !result.Valid {
+		t.Errorf("VerifyCompact() = %+v, want valid within budget", result)
+	}
+	if result := VerifyCompact(compact, keys); !result.Valid {
+		t.Errorf("VerifyCompact() without options = %+v, want valid", result)
+	}
+}
+
+func TestWorkBudgetBytes(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	claim, _ := va.Issue(t, priv, "claims_001")
+
+	ctx := context.Background()
+	opts := va.Options()
+	opts.Budget = &WorkBudget{MaxBytes: 64}
+	if _, err := VerifyClaim(ctx, claim.ID, va.Issuer(), opts); !errors.Is(err, ErrWorkBudgetExceeded) {
+		t.Errorf("VerifyClaim() error = %v, want ErrWorkBudgetExceeded", err)
+	}
+}
+
+func TestWorkBudgetPerCall(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	claim, _ := va.Issue(t, priv, "claims_001")
+
+	ctx := context.Background()
+	opts := va.Options()
+	opts.Budget = &WorkBudget{MaxKeyFetches: 1, MaxSignatureAttempts: 1}
+
+	// The budget applies to each call separately
+	for i := 0; i < 3; i++ {
+		got, err := VerifyClaim(ctx, claim.ID, va.Issuer(), opts)
+		if err != nil || got == nil {
+			t.Fatalf("call %d: VerifyClaim() = %v, %v", i, got, err)
+		}
+	}
+
+	opts.Budget = &WorkBudget{MaxKeyFetches: 0, MaxSignatureAttempts: 0}
+	if _, err := VerifyClaim(ctx, claim.ID, va.Issuer(), opts); err != nil {
+		t.Errorf("zero budget should be unlimited: %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package humanattestation
+
+import (
+	"crypto/x509"
+	"strings"
+)
+
+// MatchMode controls how a recipient domain is compared with another name
+type MatchMode int
+
+const (
+	// MatchExact requires the names to be the same domain. A wildcard
+	// certificate name such as *.acme.com matches exactly one extra label.
+	MatchExact MatchMode = iota
+	// MatchSubdomain also accepts names under the recipient domain, so a
+	// certificate for mail.acme.com matches a claim for acme.com.
+	MatchSubdomain
+)
+
+// IsClaimForCertificate checks if the claim's recipient domain matches one of
+// the certificate's DNS subject alternative names. The certificate itself is
+// not verified; callers must have already validated its chain.
+func IsClaimForCertificate(claim *Claim, cert *x509.Certificate, mode MatchMode) bool {
+	if claim == nil || cert == nil || claim.To.Domain == "" {
+		return false
+	}
+	recipient, err := hostToASCII(claim.To.Domain)
+	if err != nil {
+		return false
+	}
+	for _, san := range cert.DNSNames {
+		if matchDomain(recipient, san, mode) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDomain reports whether name matches the ASCII recipient domain under mode
+func matchDomain(recipient, name string, mode MatchMode) bool {
+	name, err := hostToASCII(strings.TrimSuffix(name, "."))
+	if err != nil {
+		return false
+	}
+
+	if rest, ok := strings.CutPrefix(name, "*."); ok {
+		// *.acme.com covers one label under acme.com, never acme.com itself
+		if _, parent, found := strings.Cut(recipient, "."); found && parent == rest {
+			return true
+		}
+		return mode == MatchSubdomain && strings.HasSuffix(rest, "."+recipient)
+	}
+
+	if name == recipient {
+		return true
+	}
+	return mode == MatchSubdomain && strings.HasSuffix(name, "."+recipient)
+}
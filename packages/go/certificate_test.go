@@ -0,0 +1,56 @@
+package humanattestation
+
+import (
+	"crypto/x509"
+	"testing"
+)
+
+func TestIsClaimForCertificate(t *testing.T) {
+	claim := testClaim(t) // recipient acme.com
+
+	tests := []struct {
+		name     string
+		dnsNames []string
+		mode     MatchMode
+		want     bool
+	}{
+		{"matching SAN", []string{"www.example.org", "acme.com"}, MatchExact, true},
+		{"matching SAN, other case", []string{"ACME.com."}, MatchExact, true},
+		{"no matching SAN", []string{"acme.org", "notacme.com"}, MatchExact, false},
+		{"no SANs", nil, MatchExact, false},
+		{"subdomain, exact", []string{"mail.acme.com"}, MatchExact, false},
+		{"subdomain, subdomain mode", []string{"mail.acme.com"}, MatchSubdomain, true},
+		{"lookalike suffix", []string{"evilacme.com"}, MatchSubdomain, false},
+		{"wildcard under recipient, exact", []string{"*.acme.com"}, MatchExact, false},
+		{"wildcard under recipient, subdomain mode", []string{"*.eu.acme.com"}, MatchSubdomain, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert := &x509.Certificate{DNSNames: tt.dnsNames}
+			if got := IsClaimForCertificate(claim, cert, tt.mode); got != tt.want {
+				t.Errorf("IsClaimForCertificate(%v) = %v, want %v", tt.dnsNames, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsClaimForCertificateIDN(t *testing.T) {
+	claim := testClaim(t)
+	claim.To.Domain = "bücher.example"
+
+	cert := &x509.Certificate{DNSNames: []string{"xn--bcher-kva.example"}}
+	if !IsClaimForCertificate(claim, cert, MatchExact) {
+		t.Error("punycode SAN did not match Unicode recipient")
+	}
+}
+
+func TestIsClaimForCertificateWildcard(t *testing.T) {
+	claim := testClaim(t)
+	claim.To.Domain = "jobs.acme.com"
+
+	cert := &x509.Certificate{DNSNames: []string{"*.acme.com"}}
+	if !IsClaimForCertificate(claim, cert, MatchExact) {
+		t.Error("wildcard SAN did not match recipient one label below it")
+	}
+}
@@ -0,0 +1,89 @@
+package humanattestation
+
+import (
+	"sync"
+	"time"
+)
+
+// maxClaimCacheEntries bounds a ClaimCache; when it is full an arbitrary
+// entry is evicted
+const maxClaimCacheEntries = 10000
+
+// ClaimCache holds verification responses fetched by FetchClaim, keyed by
+// issuer and HAP ID, along with the ETag and Last-Modified the VA sent.
+// Fresh responses are reused without a request. Stale ones are revalidated
+// with If-None-Match or If-Modified-Since, and a 304 Not Modified renews
+// them without downloading or parsing the response again. It is safe for
+// concurrent use.
+//
+// FetchClaim only caches responses when VerifyOptions.ClaimCache is set.
+type ClaimCache struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*claimCacheEntry
+}
+
+// claimCacheEntry is an immutable cached response; renewing one replaces it
+type claimCacheEntry struct {
+	resp       *VerificationResponse
+	validators cacheValidators
+	expires    time.Time
+}
+
+// NewClaimCache returns an empty cache. ttl is how long a response is reused
+// without asking the VA; zero revalidates on every fetch, which costs a round
+// trip but sees revocations as soon as the VA does.
+func NewClaimCache(ttl time.Duration) *ClaimCache {
+	return &ClaimCache{ttl: ttl, entries: make(map[string]*claimCacheEntry)}
+}
+
+// Flush discards every cached response
+func (c *ClaimCache) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]*claimCacheEntry)
+}
+
+// claimCacheKey is the cache key of a HAP ID at an ASCII issuer host
+func claimCacheKey(host, hapID string) string {
+	return host + "/" + hapID
+}
+
+func (c *ClaimCache) lookup(key string) *claimCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[key]
+}
+
+// store caches resp, which must not be modified afterwards
+func (c *ClaimCache) store(key string, resp *VerificationResponse, validators cacheValidators, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxClaimCacheEntries {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[key] = &claimCacheEntry{resp: resp, validators: validators, expires: now.Add(c.ttl)}
+}
+
+// cloneVerificationResponse returns a copy of resp that shares nothing a
+// caller might modify
+func cloneVerificationResponse(resp *VerificationResponse) *VerificationResponse {
+	c := *resp
+	if resp.Claim != nil {
+		claim := *resp.Claim
+		if claim.Cost != nil {
+			cost := *claim.Cost
+			claim.Cost = &cost
+		}
+		c.Claim = &claim
+	}
+	if resp.RevokedAt != nil {
+		revokedAt := *resp.RevokedAt
+		c.RevokedAt = &revokedAt
+	}
+	c.Warnings = append([]string(nil), resp.Warnings...)
+	return &c
+}
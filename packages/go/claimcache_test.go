@@ -0,0 +1,136 @@
+package humanattestation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingWriter counts the body bytes a handler writes
+type countingWriter struct {
+	http.ResponseWriter
+	n *atomic.Int64
+}
+
+func (w countingWriter) Write(b []byte) (int, error) {
+	w.n.Add(int64(len(b)))
+	return w.ResponseWriter.Write(b)
+}
+
+// claimVA serves one claim through NewVerifyHandler, counting requests and
+// response body bytes
+type claimVA struct {
+	*httptest.Server
+	requests atomic.Int32
+	bytes    atomic.Int64
+	mu       sync.Mutex
+	resp     *VerificationResponse
+}
+
+func newClaimVA(t *testing.T, claim *Claim) *claimVA {
+	t.Helper()
+	va := &claimVA{resp: &VerificationResponse{Valid: true, ID: claim.ID, Claim: claim}}
+	handler := NewVerifyHandler(ClaimLookupFunc(func(_ context.Context, id string) (*VerificationResponse, error) {
+		va.mu.Lock()
+		defer va.mu.Unlock()
+		if id != claim.ID {
+			return nil, nil
+		}
+		return va.resp, nil
+	}))
+	va.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		va.requests.Add(1)
+		handler.ServeHTTP(countingWriter{w, &va.bytes}, r)
+	}))
+	t.Cleanup(va.Close)
+	return va
+}
+
+func (va *claimVA) revoke(claim *Claim) {
+	va.mu.Lock()
+	defer va.mu.Unlock()
+	va.resp = &VerificationResponse{ID: claim.ID, Revoked: true, RevocationReason: RevocationUserRequest}
+}
+
+func TestClaimCacheConditionalFetch(t *testing.T) {
+	claim := testClaim(t)
+	va := newClaimVA(t, claim)
+	issuer := strings.TrimPrefix(va.URL, "https://")
+	opts := DefaultVerifyOptions()
+	opts.HTTPClient = va.Client()
+	opts.ClaimCache = NewClaimCache(0)
+	ctx := context.Background()
+
+	resp, err := FetchClaim(ctx, claim.ID, issuer, opts)
+	if err != nil || !resp.Valid {
+		t.Fatalf("FetchClaim() = %+v, %v", resp, err)
+	}
+	full := va.bytes.Load()
+
+	// Callers may modify what they get without touching the cache
+	resp.Claim.To.Name = "Someone Else"
+	for i := 0; i < 10; i++ {
+		resp, err := FetchClaim(ctx, claim.ID, issuer, opts)
+		if err != nil || !resp.Valid || resp.Claim.To.Name != claim.To.Name {
+			t.Fatalf("FetchClaim() = %+v, %v", resp, err)
+		}
+	}
+	if n := va.requests.Load(); n != 11 {
+		t.Errorf("requests = %d, want 11", n)
+	}
+	if n := va.bytes.Load(); n != full {
+		t.Errorf("bytes transferred = %d, want %d from the first fetch only", n, full)
+	}
+
+	// Revoking the claim changes its ETag
+	va.revoke(claim)
+	resp, err = FetchClaim(ctx, claim.ID, issuer, opts)
+	if err != nil || resp.Valid || !resp.Revoked {
+		t.Fatalf("FetchClaim() after revocation = %+v, %v", resp, err)
+	}
+	if va.bytes.Load() == full {
+		t.Error("revoked response was not downloaded")
+	}
+}
+
+func TestClaimCacheTTL(t *testing.T) {
+	claim := testClaim(t)
+	va := newClaimVA(t, claim)
+	issuer := strings.TrimPrefix(va.URL, "https://")
+	now := time.Now()
+	opts := DefaultVerifyOptions()
+	opts.HTTPClient = va.Client()
+	opts.Clock = func() time.Time { return now }
+	opts.ClaimCache = NewClaimCache(time.Minute)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := FetchClaim(ctx, claim.ID, issuer, opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := va.requests.Load(); n != 1 {
+		t.Errorf("requests within TTL = %d, want 1", n)
+	}
+
+	// A 304 renews the entry for another TTL
+	full := va.bytes.Load()
+	now = now.Add(time.Minute + time.Second)
+	FetchClaim(ctx, claim.ID, issuer, opts)
+	now = now.Add(30 * time.Second)
+	FetchClaim(ctx, claim.ID, issuer, opts)
+	if n, b := va.requests.Load(), va.bytes.Load(); n != 2 || b != full {
+		t.Errorf("after TTL: %d requests and %d bytes, want 2 and %d", n, b, full)
+	}
+
+	opts.ClaimCache.Flush()
+	FetchClaim(ctx, claim.ID, issuer, opts)
+	if n := va.requests.Load(); n != 3 {
+		t.Errorf("requests after flush = %d, want 3", n)
+	}
+}
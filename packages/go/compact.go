@@ -1,12 +1,16 @@
 package humanattestation
 
 import (
+	"context"
 	"crypto/ed25519"
 	"encoding/base64"
 	"fmt"
 	"net/url"
+	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,11 +20,22 @@ func encodeCompactField(value string) string {
 	return strings.ReplaceAll(encoded, ".", "%2E")
 }
 
-// decodeCompactField decodes a compact format field
+// decodeCompactField decodes a version 1 compact field. Decoding is lenient
+// for compatibility; see compactFieldWarning.
 func decodeCompactField(value string) (string, error) {
 	return url.QueryUnescape(value)
 }
 
+// compactFieldWarning reports a version 1 field that decodes but is not
+// spelled the way encodeCompactField would spell it, meaning stricter
+// verifiers may read it differently
+func compactFieldWarning(name, raw, decoded string) string {
+	if encodeCompactField(decoded) == raw {
+		return ""
+	}
+	return fmt.Sprintf("%s field %q is not canonically encoded", name, raw)
+}
+
 // base64urlEncode encodes bytes to base64url without padding
 func base64urlEncode(data []byte) string {
 	return base64.RawURLEncoding.EncodeToString(data)
@@ -33,7 +48,7 @@ func base64urlDecode(data string) ([]byte, error) {
 
 // isoToUnix converts ISO 8601 timestamp to Unix epoch seconds
 func isoToUnix(iso string) (int64, error) {
-	t, err := time.Parse(time.RFC3339, iso)
+	t, err := ParseTimestamp(iso)
 	if err != nil {
 		return 0, err
 	}
@@ -77,14 +92,18 @@ func EncodeCompact(claim *Claim, signature []byte) (string, error) {
 }
 
 // DecodeCompact decodes a compact format string into claim and signature
+// Version 1 and version 2 strings are both accepted; the version is detected from the prefix.
 func DecodeCompact(compact string) (*DecodedCompact, error) {
-	if !IsValidCompact(compact) {
-		return nil, fmt.Errorf("invalid HAP Compact format")
+	if strings.HasPrefix(compact, "HAP"+CompactVersionV2+".") {
+		return decodeCompactV2(compact)
 	}
 
 	parts := strings.Split(compact, ".")
-	if len(parts) != 9 {
-		return nil, fmt.Errorf("invalid HAP Compact format: expected 9 fields, got %d", len(parts))
+	if parts[0] == "HAP"+CompactVersion && len(parts) != 9 {
+		return nil, fieldCountError(parts, CompactVersion)
+	}
+	if !CompactRegex.MatchString(compact) {
+		return nil, fmt.Errorf("invalid HAP Compact format")
 	}
 
 	version := parts[0]
@@ -111,11 +130,25 @@ func DecodeCompact(compact string) (*DecodedCompact, error) {
 		return nil, fmt.Errorf("failed to decode domain: %w", err)
 	}
 
-	iss, err := decodeCompactField(encodedIss)
+	iss, err := decodeIssuerField(encodedIss, decodeCompactField)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode issuer: %w", err)
 	}
 
+	var warnings []string
+	for _, f := range []struct{ name, raw, decoded string }{
+		{"name", encodedName, name},
+		{"domain", encodedDomain, domain},
+		{"issuer", encodedIss, iss},
+	} {
+		if f.name == "issuer" && strings.HasPrefix(f.raw, issuerAliasPrefix) {
+			continue
+		}
+		if w := compactFieldWarning(f.name, f.raw, f.decoded); w != "" {
+			warnings = append(warnings, w)
+		}
+	}
+
 	atUnix, err := strconv.ParseInt(atUnixStr, 10, 64)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse 'at' timestamp: %w", err)
@@ -153,21 +186,185 @@ func DecodeCompact(compact string) (*DecodedCompact, error) {
 	if exp != "" {
 		claim.Exp = exp
 	}
+	// Timestamps too large to format as RFC 3339 do not parse back
+	if err := ValidateClaim(claim); err != nil {
+		return nil, err
+	}
+	if err := checkCompactTimeRange(atUnix, expUnix); err != nil {
+		return nil, err
+	}
 
 	return &DecodedCompact{
+		Version:   CompactVersion,
 		Claim:     claim,
 		Signature: signature,
+		Warnings:  warnings,
 	}, nil
 }
 
-// IsValidCompact validates if a string is a valid HAP Compact format
+// IsValidCompact validates if a string is a valid HAP Compact format (version 1 or 2)
 func IsValidCompact(compact string) bool {
-	return CompactRegex.MatchString(compact)
+	return CompactRegex.MatchString(compact) || CompactV2Regex.MatchString(compact)
+}
+
+// compactIDRegex is the ID grammar of CompactRegex and CompactV2Regex, which
+// also admits test and checksummed IDs
+var compactIDRegex = regexp.MustCompile(`^hap_[a-zA-Z0-9_]+$`)
+
+// ValidateCompactStructure cheaply checks a compact string's structure before
+// any cryptographic verification: a known version, the right field count for
+// it, an ID CompactRegex accepts (test and checksummed IDs included),
+// non-negative integer timestamps and a signature that decodes to exactly
+// ed25519.SignatureSize bytes. Passing does not mean the signature
+// is valid.
+func ValidateCompactStructure(compact string) error {
+	parts := strings.Split(compact, ".")
+
+	var version string
+	var wantFields, atField, expField int
+	switch parts[0] {
+	case "HAP" + CompactVersion:
+		version, wantFields, atField, expField = CompactVersion, 9, 5, 6
+	case "HAP" + CompactVersionV2:
+		version, wantFields, atField, expField = CompactVersionV2, 11, 6, 7
+	default:
+		return fmt.Errorf("unsupported compact version: %q", parts[0])
+	}
+	if len(parts) != wantFields {
+		return fieldCountError(parts, version)
+	}
+
+	if !compactIDRegex.MatchString(parts[1]) {
+		return fmt.Errorf("invalid HAP ID: %q", parts[1])
+	}
+	if !isDecimal(parts[atField]) {
+		return fmt.Errorf("'at' timestamp is not a non-negative integer: %q", parts[atField])
+	}
+	// Version 2 leaves exp empty when the claim does not expire
+	if !isDecimal(parts[expField]) && !(parts[0] == "HAP"+CompactVersionV2 && parts[expField] == "") {
+		return fmt.Errorf("'exp' timestamp is not a non-negative integer: %q", parts[expField])
+	}
+
+	signature, err := base64urlDecode(parts[len(parts)-1])
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	if len(signature) != ed25519.SignatureSize {
+		return fmt.Errorf("signature must be %d bytes, got %d", ed25519.SignatureSize, len(signature))
+	}
+	return nil
+}
+
+// isDecimal reports whether s is a non-empty string of ASCII digits that fits in an int64
+func isDecimal(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	_, err := strconv.ParseInt(s, 10, 64)
+	return err == nil
+}
+
+// compactTextFields are the fields encoders must escape '.' in
+var compactTextFields = map[string]bool{
+	"method": true, "tier": true, "name": true, "domain": true, "issuer": true, "kid": true,
+}
+
+// fieldCountError explains a compact string with the wrong number of fields.
+// Text fields escape '.', so extra fields usually mean an encoder left a dot
+// unescaped; the error names the text fields which, rejoined, leave every
+// fixed-format field (ID, timestamps, signature) where it belongs, narrowed
+// to the domain or issuer when only one of those rejoins into a hostname.
+func fieldCountError(parts []string, version string) error {
+	names := append(append([]string{}, compactFieldNames[version]...), "signature")
+	err := fmt.Errorf("invalid HAP Compact format: expected %d fields, got %d", len(names), len(parts))
+	extra := len(parts) - len(names)
+	if extra <= 0 {
+		return err
+	}
+
+	var suspects, hosts []string
+	for i, name := range names {
+		if !compactTextFields[name] {
+			continue
+		}
+		field := strings.Join(parts[i:i+extra+1], ".")
+		rejoined := append(append([]string{}, parts[:i]...), field)
+		rejoined = append(rejoined, parts[i+extra+1:]...)
+		if !compactLayoutPlausible(rejoined, names) {
+			continue
+		}
+		suspects = append(suspects, name)
+		if (name == "domain" || name == "issuer") && isPlainHostname(field) {
+			hosts = append(hosts, name)
+		}
+	}
+
+	// Hostnames are where dots turn up, so prefer a lone hostname suspect
+	if len(hosts) == 1 {
+		suspects = hosts
+	}
+
+	switch len(suspects) {
+	case 0:
+		return err
+	case 1:
+		return fmt.Errorf("%w: the %s field likely contains an unescaped \".\"", err, suspects[0])
+	default:
+		return fmt.Errorf("%w: one of the %s fields likely contains an unescaped \".\"", err, strings.Join(suspects, ", "))
+	}
+}
+
+// isPlainHostname reports whether s is made only of the characters an
+// ASCII hostname (with optional port) is spelled with
+func isPlainHostname(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == ':') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// compactLayoutPlausible reports whether each fixed-format field in parts is
+// well-formed
+func compactLayoutPlausible(parts, names []string) bool {
+	for i, name := range names {
+		switch name {
+		case "id":
+			if !compactIDRegex.MatchString(parts[i]) {
+				return false
+			}
+		case "at":
+			if !isDecimal(parts[i]) {
+				return false
+			}
+		case "exp":
+			if parts[i] != "" && !isDecimal(parts[i]) {
+				return false
+			}
+		case "signature":
+			if sig, err := base64urlDecode(parts[i]); err != nil || len(sig) != ed25519.SignatureSize {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 // BuildCompactPayload builds the compact payload (everything before the signature)
 // This is what gets signed.
 func BuildCompactPayload(claim *Claim) (string, error) {
+	return buildCompactPayload(claim, encodeCompactField(claim.Iss))
+}
+
+// buildCompactPayload builds the compact payload with a pre-encoded issuer field
+func buildCompactPayload(claim *Claim, issField string) (string, error) {
 	atUnix, err := isoToUnix(claim.At)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse 'at' timestamp: %w", err)
@@ -189,27 +386,231 @@ func BuildCompactPayload(claim *Claim) (string, error) {
 		encodeCompactField(claim.To.Domain),
 		strconv.FormatInt(atUnix, 10),
 		strconv.FormatInt(expUnix, 10),
-		encodeCompactField(claim.Iss),
+		issField,
 	}
 
 	return strings.Join(fields, "."), nil
 }
 
+// CompactOptions configures compact signing
+type CompactOptions struct {
+	// Version selects the compact format version (default: CompactVersion).
+	// The default will change to CompactVersionV2 in a future release.
+	Version string
+	// Kid is the key ID hint embedded in version 2 strings
+	Kid string
+}
+
 // SignCompact signs a claim and returns it in compact format
-func SignCompact(claim *Claim, privateKey ed25519.PrivateKey) (string, error) {
-	payload, err := BuildCompactPayload(claim)
+func SignCompact(claim *Claim, privateKey ed25519.PrivateKey, opts ...CompactOptions) (string, error) {
+	var opt CompactOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	var payload string
+	var err error
+	switch opt.Version {
+	case "", CompactVersion:
+		payload, err = BuildCompactPayload(claim)
+	case CompactVersionV2:
+		payload, err = BuildCompactPayloadV2(claim, opt.Kid)
+	default:
+		return "", fmt.Errorf("unsupported compact version: %s", opt.Version)
+	}
 	if err != nil {
 		return "", err
 	}
 
-	signature := ed25519.Sign(privateKey, []byte(payload))
+	signature := ed25519.Sign(privateKey, CompactSigningInput(payload))
 	return payload + "." + base64urlEncode(signature), nil
 }
 
-// VerifyCompact verifies a compact format string using provided public keys
-func VerifyCompact(compact string, publicKeys []JWK) *CompactVerificationResult {
+// SignCompactBatchCtx signs many claims concurrently and returns their compact
+// strings in input order.
+//
+// progress, if non-nil, is called periodically from a single goroutine with the
+// number of claims signed so far. If ctx is cancelled before every claim is
+// signed, the partial result is returned (unsigned entries are empty) together
+// with an error wrapping the context error.
+func SignCompactBatchCtx(ctx context.Context, claims []*Claim, privateKey ed25519.PrivateKey, progress func(done, total int)) ([]string, error) {
+	total := len(claims)
+	results := make([]string, total)
+	if total == 0 {
+		return results, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type signed struct {
+		index   int
+		compact string
+		err     error
+	}
+
+	jobs := make(chan int)
+	out := make(chan signed)
+
+	go func() {
+		defer close(jobs)
+		for i := range claims {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > total {
+		workers = total
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					return
+				}
+				compact, err := SignCompact(claims[i], privateKey)
+				select {
+				case out <- signed{index: i, compact: compact, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	// Report roughly every 1% so huge batches don't flood the callback
+	step := total / 100
+	if step < 1 {
+		step = 1
+	}
+
+	done := 0
+	var signErr error
+	for r := range out {
+		if r.err != nil {
+			if signErr == nil {
+				signErr = fmt.Errorf("failed to sign claim %d: %w", r.index, r.err)
+				cancel()
+			}
+			continue
+		}
+		results[r.index] = r.compact
+		done++
+		if progress != nil && (done%step == 0 || done == total) {
+			progress(done, total)
+		}
+	}
+
+	if signErr != nil {
+		return results, signErr
+	}
+	if done < total {
+		return results, fmt.Errorf("batch signing aborted after %d of %d claims: %w", done, total, ctx.Err())
+	}
+
+	return results, nil
+}
+
+// VerifyCompact verifies a compact format string using provided public keys.
+// Only the signature is checked; use VerifyCompactStrict to also check
+// expiry, recipient, issuer and revocation. Failed results carry a
+// FailureReason; FailureExpired and FailureNotYetValid are only reported by
+// VerifyCompactStrict.
+//
+// Of the options, only Budget applies: each key tried counts as a signature
+// attempt, and a result that would exceed MaxSignatureAttempts fails with
+// ErrWorkBudgetExceeded and FailureBudgetExceeded.
+func VerifyCompact(compact string, publicKeys []JWK, opts ...VerifyOptions) *CompactVerificationResult {
+	var meter *workMeter
+	if len(opts) > 0 {
+		meter = opts[0].metered().meter
+	}
+	return verifyCompactStatic(compact, keysNewestFirst(publicKeys), decodeKeys(publicKeys), meter)
+}
+
+// VerifyCompactBatch verifies many compact strings against the same keys on
+// concurrency goroutines (default: GOMAXPROCS) and returns their results in
+// input order. Each result is what VerifyCompact would return. The keys are
+// decoded once for the whole batch.
+func VerifyCompactBatch(compacts []string, publicKeys []JWK, concurrency int) []*CompactVerificationResult {
+	results := make([]*CompactVerificationResult, len(compacts))
+	if len(compacts) == 0 {
+		return results
+	}
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	concurrency = min(concurrency, len(compacts))
+
+	ordered, decoded := keysNewestFirst(publicKeys), decodeKeys(publicKeys)
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = verifyCompactStatic(compacts[i], ordered, decoded, nil)
+			}
+		}()
+	}
+	for i := range compacts {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// verifyCompactStatic is VerifyCompact with keys already ordered and decoded
+func verifyCompactStatic(compact string, ordered []JWK, decoded decodedKeys, meter *workMeter) *CompactVerificationResult {
+	result := verifyCompactDecoded(compact, ordered, decoded, meter)
+	result.KeyProvenance = &KeyProvenance{Source: KeySourceStatic}
+	result.ChecksPerformed = signatureOnlyChecks(result)
+	return result
+}
+
+// decodedKeys maps the x of each JWK to its decoded public key, so keys can
+// be decoded once for many verifications
+type decodedKeys map[string]ed25519.PublicKey
+
+// decodeKeys decodes keys, leaving out those that are not valid Ed25519
+// public keys (see decodePublicKey)
+func decodeKeys(keys []JWK) decodedKeys {
+	decoded := make(decodedKeys, len(keys))
+	for _, jwk := range keys {
+		if publicKey, err := decodePublicKey(jwk); err == nil {
+			decoded[jwk.X] = publicKey
+		}
+	}
+	return decoded
+}
+
+// verifyCompact verifies a compact string against the given keys, spending
+// one signature attempt from meter per key tried
+func verifyCompact(compact string, publicKeys []JWK, meter *workMeter) *CompactVerificationResult {
+	return verifyCompactDecoded(compact, keysNewestFirst(publicKeys), decodeKeys(publicKeys), meter)
+}
+
+// verifyCompactDecoded is verifyCompact with publicKeys already ordered
+// newest first and decoded
+func verifyCompactDecoded(compact string, publicKeys []JWK, decoded decodedKeys, meter *workMeter) *CompactVerificationResult {
 	if !IsValidCompact(compact) {
-		return &CompactVerificationResult{Valid: false, Error: "Invalid compact format"}
+		return compactFailure(newVerificationError(ErrInvalidFormat, nil, "Invalid compact format"), FailureDecodeError)
 	}
 
 	// Split to get payload and signature
@@ -219,30 +620,108 @@ func VerifyCompact(compact string, publicKeys []JWK) *CompactVerificationResult
 
 	signature, err := base64urlDecode(sigB64)
 	if err != nil {
-		return &CompactVerificationResult{Valid: false, Error: fmt.Sprintf("failed to decode signature: %v", err)}
+		return compactFailure(newVerificationError(ErrInvalidFormat, err, "failed to decode signature: %v", err), FailureDecodeError)
+	}
+
+	// Try the newest key first, unless a version 2 kid hint names the key
+	if strings.HasPrefix(compact, "HAP"+CompactVersionV2+".") {
+		fields := strings.Split(payload, ".")
+		if kid, err := decodeCompactFieldV2(fields[9]); err == nil && kid != "" {
+			publicKeys = keysWithKidFirst(publicKeys, kid)
+		}
 	}
 
 	// Try each public key
+	wrongUse := false
+	tried := 0
 	for _, jwk := range publicKeys {
-		xBytes, err := base64.RawURLEncoding.DecodeString(jwk.X)
-		if err != nil {
+		publicKey, ok := decoded[jwk.X]
+		if !ok {
 			continue
 		}
 
-		publicKey := ed25519.PublicKey(xBytes)
+		if err := meter.signatureAttempt(); err != nil {
+			return compactFailure(err, FailureBudgetExceeded)
+		}
+		tried++
 
 		// Verify signature
-		if ed25519.Verify(publicKey, []byte(payload), signature) {
+		if ed25519.Verify(publicKey, CompactSigningInput(payload), signature) {
+			if !jwk.AllowsUse(KeyUseClaims) {
+				wrongUse = true
+				continue
+			}
+
 			// Signature is valid, decode the claim
 			decoded, err := DecodeCompact(compact)
 			if err != nil {
-				return &CompactVerificationResult{Valid: false, Error: fmt.Sprintf("failed to decode claim: %v", err)}
+				return compactFailure(newVerificationError(ErrInvalidFormat, err, "failed to decode claim: %v", err), FailureDecodeError)
+			}
+			result := &CompactVerificationResult{Valid: true, Claim: decoded.Claim}
+			if warning := olderKeyWarning(publicKeys, jwk.Kid); warning != "" {
+				result.Warnings = append(result.Warnings, warning)
 			}
-			return &CompactVerificationResult{Valid: true, Claim: decoded.Claim}
+			return result
 		}
 	}
 
-	return &CompactVerificationResult{Valid: false, Error: "Signature verification failed"}
+	if wrongUse {
+		return compactFailure(ErrWrongKeyUse, FailureNoKeys)
+	}
+	if tried == 0 {
+		return compactFailure(newVerificationError(ErrKeyNotFound, nil, "no usable public keys"), FailureNoKeys)
+	}
+
+	return compactFailure(newVerificationError(ErrSignatureInvalid, nil, "Signature verification failed"), FailureSignatureMismatch)
+}
+
+// compactFailure returns an invalid result for err
+func compactFailure(err error, reason FailureReason) *CompactVerificationResult {
+	return &CompactVerificationResult{Valid: false, Error: err.Error(), Err: err, FailureReason: reason}
+}
+
+// compactFieldNames names the payload fields of each compact version in order
+var compactFieldNames = map[string][]string{
+	CompactVersion:   {"version", "id", "method", "name", "domain", "at", "exp", "issuer"},
+	CompactVersionV2: {"version", "id", "method", "tier", "name", "domain", "at", "exp", "issuer", "kid"},
+}
+
+// CompactFieldSizes returns the encoded byte length each field contributes to
+// the claim's compact string, keyed by field name (e.g. "name", "domain",
+// "issuer", "signature"). Delimiters are not counted. UIs can use it to tell
+// senders which field to shorten to keep QR codes scannable. Returns nil if
+// the claim cannot be encoded.
+func CompactFieldSizes(claim *Claim, opts ...CompactOptions) map[string]int {
+	var opt CompactOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	var payload string
+	var err error
+	version := opt.Version
+	switch version {
+	case "", CompactVersion:
+		version = CompactVersion
+		payload, err = BuildCompactPayload(claim)
+	case CompactVersionV2:
+		payload, err = BuildCompactPayloadV2(claim, opt.Kid)
+	default:
+		return nil
+	}
+	if err != nil {
+		return nil
+	}
+
+	names := compactFieldNames[version]
+	parts := strings.Split(payload, ".")
+	sizes := make(map[string]int, len(names)+1)
+	for i, name := range names {
+		sizes[name] = len(parts[i])
+	}
+	sizes["signature"] = base64.RawURLEncoding.EncodedLen(ed25519.SignatureSize)
+
+	return sizes
 }
 
 // GenerateVerificationURL generates a verification URL with embedded compact claim
@@ -250,7 +729,8 @@ func GenerateVerificationURL(baseURL string, compact string) string {
 	return baseURL + "?c=" + url.QueryEscape(compact)
 }
 
-// ExtractCompactFromURL extracts compact claim from a verification URL
+// ExtractCompactFromURL extracts compact claim from a verification URL,
+// repairing copy-paste damage with SanitizeCompactInput
 func ExtractCompactFromURL(urlStr string) string {
 	parsed, err := url.Parse(urlStr)
 	if err != nil {
@@ -258,9 +738,43 @@ func ExtractCompactFromURL(urlStr string) string {
 	}
 
 	compact := parsed.Query().Get("c")
-	if compact != "" && IsValidCompact(compact) {
+	if compact == "" {
+		return ""
+	}
+	if IsValidCompact(compact) {
 		return compact
 	}
+	if sanitized, _, err := SanitizeCompactInput(compact); err == nil {
+		return sanitized
+	}
 
 	return ""
 }
+
+// ExtractCompactsFromURL extracts every valid compact claim from a URL
+// carrying several c parameters (?c=...&c=...), skipping invalid values
+func ExtractCompactsFromURL(urlStr string) []string {
+	parsed, err := url.Parse(urlStr)
+	if err != nil {
+		return nil
+	}
+
+	var compacts []string
+	for _, compact := range parsed.Query()["c"] {
+		if IsValidCompact(compact) {
+			compacts = append(compacts, compact)
+		}
+	}
+	return compacts
+}
+
+// VerifyCompactsFromURL verifies every valid compact claim in a URL, in the
+// order they appear
+func VerifyCompactsFromURL(urlStr string, publicKeys []JWK) []*CompactVerificationResult {
+	compacts := ExtractCompactsFromURL(urlStr)
+	results := make([]*CompactVerificationResult, len(compacts))
+	for i, compact := range compacts {
+		results[i] = VerifyCompact(compact, publicKeys)
+	}
+	return results
+}
@@ -0,0 +1,322 @@
+package humanattestation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// allChecks lists every check in the order they are reported
+var allChecks = []CheckName{CheckSignature, CheckNotExpired, CheckRecipientDomain, CheckTrustedIssuer, CheckNotSelfIssued, CheckNotRevoked}
+
+// RevocationChecker reports whether a claim has been revoked
+type RevocationChecker func(ctx context.Context, claim *Claim) (revoked bool, err error)
+
+// VARevocationChecker returns a RevocationChecker that asks the claim's
+// issuer through its verification API
+func VARevocationChecker(opts VerifyOptions) RevocationChecker {
+	return func(ctx context.Context, claim *Claim) (bool, error) {
+		resp, err := FetchClaim(ctx, claim.ID, claim.Iss, opts)
+		if err != nil {
+			return false, err
+		}
+		if resp.Revoked {
+			return true, nil
+		}
+		if !resp.Valid {
+			return false, fmt.Errorf("issuer does not recognize claim: %s", resp.Error)
+		}
+		return false, nil
+	}
+}
+
+// StrictRequirements lists the checks VerifyCompactStrict must pass. Checks
+// that are not required are skipped.
+type StrictRequirements struct {
+	// Signature requires a valid signature from one of Keys
+	Signature bool
+	// Keys to verify the signature with. If empty, they are fetched from the
+	// claim's issuer, which must then be one of TrustedIssuers: with neither
+	// set the signature check fails.
+	Keys []JWK
+	// NotExpired requires the claim to be in effect at Now (default: the
+	// options' Clock): unexpired and not issued in the future, each within
+	// the options' Leeway
+	NotExpired bool
+	Now        time.Time
+	// RecipientDomain, if set, requires the claim to target this domain
+	RecipientDomain string
+	// TrustedIssuers, if set, requires the claim's issuer to be one of these
+	TrustedIssuers []string
+	// RevocationChecker, if set, requires the claim to not be revoked
+	RevocationChecker RevocationChecker
+	// SenderDomain, if set, is the domain the sender says they write from.
+	// A claim whose issuer shares its registrable domain is self-issued: the
+	// sender may be running the VA that vouches for them.
+	SenderDomain string
+	// SelfIssuedFromRecipient also counts claims issued from
+	// RecipientDomain's registrable domain as self-issued
+	SelfIssuedFromRecipient bool
+	// RejectSelfIssued fails self-issued claims. Otherwise they pass with
+	// WarningSelfIssued.
+	RejectSelfIssued bool
+}
+
+// WarningSelfIssued is the warning VerifyCompactStrict adds to a
+// self-issued claim it accepts
+const WarningSelfIssued = "self-issued claim"
+
+// VerifyCompactStrict verifies a compact string against every check req
+// requires and reports the status of each. Valid is true only when at least
+// one check was required and every required check passed.
+//
+// Unlike VerifyCompact, which only checks the signature, this is the
+// function to use when accepting a claim from an untrusted sender.
+func VerifyCompactStrict(ctx context.Context, compact string, req StrictRequirements, opts VerifyOptions) *CompactVerificationResult {
+	opts = opts.metered()
+	checks := newCheckSet()
+
+	decoded, err := DecodeCompact(compact)
+	if err != nil {
+		checks.fail(CheckSignature, fmt.Sprintf("failed to decode compact: %v", err))
+		checks.because(FailureDecodeError)
+		checks.causedBy(err)
+		return checks.result(nil, nil)
+	}
+	claim := decoded.Claim
+
+	if len(req.TrustedIssuers) > 0 {
+		trusted := false
+		for _, iss := range req.TrustedIssuers {
+			if sameDomain(claim.Iss, iss) {
+				trusted = true
+				break
+			}
+		}
+		if trusted {
+			checks.pass(CheckTrustedIssuer)
+		} else {
+			checks.fail(CheckTrustedIssuer, fmt.Sprintf("issuer %s is not trusted", claim.Iss))
+			checks.causedBy(ErrIssuerMismatch)
+		}
+	}
+
+	var provenance *KeyProvenance
+	var warnings []string
+	if req.Signature {
+		keys := req.Keys
+		provenance = &KeyProvenance{Source: KeySourceStatic}
+		switch {
+		case len(keys) > 0:
+		case len(req.TrustedIssuers) == 0:
+			// The claim names its own issuer, so its keys alone prove nothing
+			err := newVerificationError(ErrKeyNotFound, nil, "signature requires Keys or TrustedIssuers")
+			checks.fail(CheckSignature, err.Error())
+			checks.because(FailureNoKeys)
+			checks.causedBy(err)
+		case checks.failed(CheckTrustedIssuer):
+			checks.skip(CheckSignature, "issuer is not trusted")
+		default:
+			wellKnown, p, err := fetchPublicKeys(ctx, claim.Iss, opts)
+			if err == nil && len(wellKnown.Keys) == 0 {
+				err = newVerificationError(ErrKeyNotFound, nil, "issuer %s publishes no keys", claim.Iss)
+			}
+			if err != nil {
+				checks.fail(CheckSignature, err.Error())
+				checks.because(FailureNoKeys)
+				checks.causedBy(err)
+			} else {
+				keys, provenance = wellKnown.Keys, p.at(opts.now())
+			}
+		}
+		if len(keys) > 0 {
+			if r := verifyCompact(compact, keys, opts.meter); r.Valid {
+				checks.pass(CheckSignature)
+				warnings = r.Warnings
+			} else {
+				checks.fail(CheckSignature, r.Error)
+				checks.because(r.FailureReason)
+				checks.causedBy(r.Err)
+			}
+		}
+	}
+
+	if req.NotExpired {
+		now := req.Now
+		if now.IsZero() {
+			now = opts.now()
+		}
+		switch _, err := ParseTimestamp(claim.Exp); {
+		case claim.Exp != "" && err != nil:
+			checks.fail(CheckNotExpired, err.Error())
+			checks.causedBy(err)
+		case IsClaimExpiredWithLeeway(claim, now, opts.Leeway):
+			checks.fail(CheckNotExpired, fmt.Sprintf("expired at %s", claim.Exp))
+			checks.because(FailureExpired)
+		case IsClaimNotYetValid(claim, now, opts.Leeway):
+			checks.fail(CheckNotExpired, fmt.Sprintf("issued in the future at %s", claim.At))
+			checks.because(FailureNotYetValid)
+		default:
+			checks.pass(CheckNotExpired)
+		}
+	}
+
+	if req.RecipientDomain != "" {
+		if IsClaimForRecipient(claim, req.RecipientDomain) {
+			checks.pass(CheckRecipientDomain)
+		} else {
+			checks.fail(CheckRecipientDomain, fmt.Sprintf("claim is for %s", claim.To.Domain))
+		}
+	}
+
+	var selfIssued []string
+	if req.SenderDomain != "" {
+		selfIssued = append(selfIssued, req.SenderDomain)
+	}
+	if req.SelfIssuedFromRecipient && req.RecipientDomain != "" {
+		selfIssued = append(selfIssued, req.RecipientDomain)
+	}
+	var selfIssuedBy string
+	for _, domain := range selfIssued {
+		if IsSelfIssued(claim, domain) {
+			selfIssuedBy = domain
+			break
+		}
+	}
+	if req.RejectSelfIssued {
+		if selfIssuedBy != "" {
+			checks.fail(CheckNotSelfIssued, fmt.Sprintf("issuer %s belongs to %s", claim.Iss, selfIssuedBy))
+			checks.causedBy(ErrSelfIssued)
+		} else {
+			checks.pass(CheckNotSelfIssued)
+		}
+	}
+
+	if req.RevocationChecker != nil {
+		// Only ask the issuer about claims that are otherwise acceptable
+		if checks.anyFailed() {
+			checks.skip(CheckNotRevoked, "earlier check failed")
+		} else if revoked, err := req.RevocationChecker(ctx, claim); err != nil {
+			checks.fail(CheckNotRevoked, err.Error())
+			checks.causedBy(err)
+		} else if revoked {
+			checks.fail(CheckNotRevoked, "claim has been revoked")
+			checks.causedBy(ErrRevoked)
+		} else {
+			checks.pass(CheckNotRevoked)
+		}
+	}
+
+	if err := opts.meter.Err(); err != nil {
+		return checks.result(nil, err)
+	}
+
+	result := checks.result(claim, nil)
+	if result.Valid && req.Signature {
+		result.KeyProvenance = provenance
+		result.Warnings = warnings
+	}
+	if result.Valid && selfIssuedBy != "" {
+		result.Warnings = append(result.Warnings, WarningSelfIssued)
+	}
+	return result
+}
+
+// checkSet accumulates check results
+type checkSet struct {
+	status map[CheckName]CheckResult
+	reason FailureReason
+	cause  error
+}
+
+func newCheckSet() *checkSet {
+	return &checkSet{status: make(map[CheckName]CheckResult)}
+}
+
+func (c *checkSet) pass(name CheckName) {
+	c.status[name] = CheckResult{Name: name, Status: CheckPassed}
+}
+
+func (c *checkSet) fail(name CheckName, detail string) {
+	c.status[name] = CheckResult{Name: name, Status: CheckFailed, Detail: detail}
+}
+
+func (c *checkSet) skip(name CheckName, detail string) {
+	c.status[name] = CheckResult{Name: name, Status: CheckSkipped, Detail: detail}
+}
+
+// because records why verification failed; the first reason recorded wins
+func (c *checkSet) because(reason FailureReason) {
+	if c.reason == "" {
+		c.reason = reason
+	}
+}
+
+// causedBy records the error behind a failed check; the first one recorded
+// wins
+func (c *checkSet) causedBy(err error) {
+	if c.cause == nil {
+		c.cause = err
+	}
+}
+
+func (c *checkSet) failed(name CheckName) bool {
+	return c.status[name].Status == CheckFailed
+}
+
+func (c *checkSet) anyFailed() bool {
+	for _, r := range c.status {
+		if r.Status == CheckFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// result builds the verification result. It is valid when at least one check
+// was required and none failed or were skipped; a non-nil err overrides the
+// error.
+func (c *checkSet) result(claim *Claim, err error) *CompactVerificationResult {
+	checks := make([]CheckResult, 0, len(allChecks))
+	var problems []string
+	for _, name := range allChecks {
+		r, required := c.status[name]
+		if !required {
+			r = CheckResult{Name: name, Status: CheckSkipped, Detail: "not required"}
+		} else if r.Status != CheckPassed {
+			problems = append(problems, fmt.Sprintf("%s: %s", name, r.Detail))
+		}
+		checks = append(checks, r)
+	}
+
+	if err == nil && len(c.status) == 0 {
+		err = errNoChecksRequired
+	}
+	if err == nil && len(problems) > 0 {
+		err = &verificationError{msg: strings.Join(problems, "; "), sentinel: reasonErrors[c.reason], cause: c.cause}
+	}
+	if err != nil {
+		return &CompactVerificationResult{Valid: false, Error: err.Error(), Err: err, ChecksPerformed: checks, FailureReason: c.reason}
+	}
+	return &CompactVerificationResult{Valid: true, Claim: claim, ChecksPerformed: checks}
+}
+
+var errNoChecksRequired = errors.New("no checks required")
+
+// signatureOnlyChecks reports a signature check outcome with every other check skipped
+func signatureOnlyChecks(result *CompactVerificationResult) []CheckResult {
+	checks := make([]CheckResult, 0, len(allChecks))
+	for _, name := range allChecks {
+		r := CheckResult{Name: name, Status: CheckSkipped, Detail: "not performed by VerifyCompact"}
+		if name == CheckSignature {
+			r = CheckResult{Name: name, Status: CheckPassed}
+			if !result.Valid {
+				r = CheckResult{Name: name, Status: CheckFailed, Detail: result.Error}
+			}
+		}
+		checks = append(checks, r)
+	}
+	return checks
+}
@@ -0,0 +1,321 @@
+package humanattestation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyCompactStrict(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	_, otherJWK := testKeys(t, "key_002")
+	claim := testClaim(t)
+	compact, err := SignCompact(claim, priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notRevoked := func(context.Context, *Claim) (bool, error) { return false, nil }
+	revoked := func(context.Context, *Claim) (bool, error) { return true, nil }
+	unreachable := func(context.Context, *Claim) (bool, error) { return false, errors.New("unreachable") }
+
+	tests := []struct {
+		name   string
+		req    StrictRequirements
+		valid  bool
+		status map[CheckName]CheckStatus
+	}{
+		{
+			name:  "nothing required",
+			req:   StrictRequirements{},
+			valid: false,
+		},
+		{
+			name:   "signature only",
+			req:    StrictRequirements{Signature: true, Keys: []JWK{jwk}},
+			valid:  true,
+			status: map[CheckName]CheckStatus{CheckSignature: CheckPassed, CheckNotExpired: CheckSkipped},
+		},
+		{
+			name:   "wrong key",
+			req:    StrictRequirements{Signature: true, Keys: []JWK{otherJWK}},
+			status: map[CheckName]CheckStatus{CheckSignature: CheckFailed},
+		},
+		{
+			name: "all checks pass",
+			req: StrictRequirements{
+				Signature:         true,
+				Keys:              []JWK{jwk},
+				NotExpired:        true,
+				RecipientDomain:   "acme.com",
+				TrustedIssuers:    []string{"ballista.jobs"},
+				RevocationChecker: notRevoked,
+			},
+			valid: true,
+			status: map[CheckName]CheckStatus{
+				CheckSignature:       CheckPassed,
+				CheckNotExpired:      CheckPassed,
+				CheckRecipientDomain: CheckPassed,
+				CheckTrustedIssuer:   CheckPassed,
+				CheckNotRevoked:      CheckPassed,
+			},
+		},
+		{
+			name:   "expired",
+			req:    StrictRequirements{Signature: true, Keys: []JWK{jwk}, NotExpired: true, Now: time.Now().AddDate(3, 0, 0)},
+			status: map[CheckName]CheckStatus{CheckSignature: CheckPassed, CheckNotExpired: CheckFailed},
+		},
+		{
+			name:   "wrong recipient",
+			req:    StrictRequirements{Signature: true, Keys: []JWK{jwk}, RecipientDomain: "other.com"},
+			status: map[CheckName]CheckStatus{CheckRecipientDomain: CheckFailed},
+		},
+		{
+			name:   "untrusted issuer",
+			req:    StrictRequirements{TrustedIssuers: []string{"other-va.example"}},
+			status: map[CheckName]CheckStatus{CheckTrustedIssuer: CheckFailed, CheckSignature: CheckSkipped},
+		},
+		{
+			name:   "untrusted issuer skips key fetch",
+			req:    StrictRequirements{Signature: true, TrustedIssuers: []string{"other-va.example"}},
+			status: map[CheckName]CheckStatus{CheckTrustedIssuer: CheckFailed, CheckSignature: CheckSkipped},
+		},
+		{
+			name:   "revoked",
+			req:    StrictRequirements{Signature: true, Keys: []JWK{jwk}, RevocationChecker: revoked},
+			status: map[CheckName]CheckStatus{CheckSignature: CheckPassed, CheckNotRevoked: CheckFailed},
+		},
+		{
+			name:   "revocation unknown",
+			req:    StrictRequirements{Signature: true, Keys: []JWK{jwk}, RevocationChecker: unreachable},
+			status: map[CheckName]CheckStatus{CheckNotRevoked: CheckFailed},
+		},
+		{
+			name:   "revocation skipped after bad signature",
+			req:    StrictRequirements{Signature: true, Keys: []JWK{otherJWK}, RevocationChecker: unreachable},
+			status: map[CheckName]CheckStatus{CheckSignature: CheckFailed, CheckNotRevoked: CheckSkipped},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := VerifyCompactStrict(context.Background(), compact, tt.req, VerifyOptions{})
+			if result.Valid != tt.valid {
+				t.Fatalf("Valid = %v, want %v (error: %s)", result.Valid, tt.valid, result.Error)
+			}
+			if tt.valid && (result.Claim == nil || result.Claim.ID != claim.ID) {
+				t.Errorf("Claim = %+v, want %s", result.Claim, claim.ID)
+			}
+			if !tt.valid && (result.Claim != nil || result.Error == "") {
+				t.Errorf("invalid result has claim %v, error %q", result.Claim, result.Error)
+			}
+			if len(result.ChecksPerformed) != len(allChecks) {
+				t.Fatalf("got %d checks, want %d", len(result.ChecksPerformed), len(allChecks))
+			}
+			for _, c := range result.ChecksPerformed {
+				if want, ok := tt.status[c.Name]; ok && c.Status != want {
+					t.Errorf("%s = %s (%s), want %s", c.Name, c.Status, c.Detail, want)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyCompactStrictAgainstVA(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+	claim := testClaim(t)
+	claim.Iss = va.Issuer()
+	compact, err := SignCompact(claim, priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	va.SetResponse(VerificationResponse{Valid: true, ID: claim.ID, Claim: claim, Issuer: va.Issuer()})
+
+	req := StrictRequirements{
+		Signature:         true,
+		TrustedIssuers:    []string{va.Issuer()},
+		RevocationChecker: VARevocationChecker(va.Options()),
+	}
+	result := VerifyCompactStrict(context.Background(), compact, req, va.Options())
+	if !result.Valid {
+		t.Fatalf("expected valid, got %s", result.Error)
+	}
+	if result.KeyProvenance == nil || result.KeyProvenance.Source != KeySourceNetwork {
+		t.Errorf("KeyProvenance = %+v, want network", result.KeyProvenance)
+	}
+
+	va.Revoke(claim.ID, RevocationFraud)
+	result = VerifyCompactStrict(context.Background(), compact, req, va.Options())
+	if result.Valid {
+		t.Fatal("revoked claim verified")
+	}
+}
+
+func TestVerifyCompactStrictSignatureNeedsKeys(t *testing.T) {
+	va := newTestVA(t)
+	priv, _ := testKeys(t, "key_001")
+	claim := testClaim(t)
+	claim.Iss = va.Issuer()
+	compact, err := SignCompact(claim, priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := va.Options()
+	opts.KeyCacheTTL = -1
+
+	tests := []struct {
+		name    string
+		req     StrictRequirements
+		fetches int32
+	}{
+		// Keys from the claim's own issuer would let any self-hosted issuer pass
+		{"no keys or trusted issuers", StrictRequirements{Signature: true, NotExpired: true}, 0},
+		// The VA is trusted but publishes an empty key set
+		{"issuer publishes no keys", StrictRequirements{Signature: true, TrustedIssuers: []string{va.Issuer()}}, 1},
+	}
+	for _, tt := range tests {
+		before := va.keyFetches.Load()
+		result := VerifyCompactStrict(context.Background(), compact, tt.req, opts)
+		if result.Valid || result.FailureReason != FailureNoKeys || !errors.Is(result.Err, ErrKeyNotFound) {
+			t.Errorf("%s: Valid = %v, FailureReason = %q, Err = %v", tt.name, result.Valid, result.FailureReason, result.Err)
+		}
+		for _, c := range result.ChecksPerformed {
+			if c.Name == CheckSignature && c.Status != CheckFailed {
+				t.Errorf("%s: signature check = %s (%s), want failed", tt.name, c.Status, c.Detail)
+			}
+		}
+		if n := va.keyFetches.Load() - before; n != tt.fetches {
+			t.Errorf("%s: key fetches = %d, want %d", tt.name, n, tt.fetches)
+		}
+	}
+}
+
+func TestVerifyCompactReportsChecks(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	compact, _ := SignCompact(testClaim(t), priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+
+	result := VerifyCompact(compact, []JWK{jwk})
+	for _, c := range result.ChecksPerformed {
+		want := CheckSkipped
+		if c.Name == CheckSignature {
+			want = CheckPassed
+		}
+		if c.Status != want {
+			t.Errorf("%s = %s, want %s", c.Name, c.Status, want)
+		}
+	}
+}
+
+func TestVerifyCompactStrictFailureReason(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	_, otherJWK := testKeys(t, "key_002")
+	compact, err := SignCompact(testClaim(t), priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// testClaim expires after two years
+	later := time.Now().AddDate(3, 0, 0)
+
+	tests := []struct {
+		name    string
+		compact string
+		req     StrictRequirements
+		want    FailureReason
+	}{
+		{"valid", compact, StrictRequirements{Signature: true, Keys: []JWK{jwk}, NotExpired: true}, ""},
+		{"expired", compact, StrictRequirements{Signature: true, Keys: []JWK{jwk}, NotExpired: true, Now: later}, FailureExpired},
+		{"mismatch reported before expiry", compact, StrictRequirements{Signature: true, Keys: []JWK{otherJWK}, NotExpired: true, Now: later}, FailureSignatureMismatch},
+		{"malformed", "HAP2.garbage", StrictRequirements{Signature: true, Keys: []JWK{jwk}}, FailureDecodeError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := VerifyCompactStrict(context.Background(), tt.compact, tt.req, VerifyOptions{})
+			if result.FailureReason != tt.want {
+				t.Errorf("FailureReason = %q, want %q (error %q)", result.FailureReason, tt.want, result.Error)
+			}
+		})
+	}
+}
+
+func TestVerifyCompactStrictExpiryBoundary(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	claim := testClaim(t)
+	compact, err := SignCompact(claim, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, err := ParseTimestamp(claim.Exp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A claim is still in effect at exactly exp, as IsClaimExpired has it
+	tests := []struct {
+		now    time.Time
+		leeway time.Duration
+		want   FailureReason
+	}{
+		{exp, 0, ""},
+		{exp.Add(time.Second), 0, FailureExpired},
+		{exp.Add(time.Minute), time.Minute, ""},
+		{exp.Add(time.Minute + time.Second), time.Minute, FailureExpired},
+	}
+	for _, tt := range tests {
+		req := StrictRequirements{Signature: true, Keys: []JWK{jwk}, NotExpired: true, Now: tt.now}
+		result := VerifyCompactStrict(context.Background(), compact, req, VerifyOptions{Leeway: tt.leeway})
+		if result.FailureReason != tt.want {
+			t.Errorf("now = exp+%s, leeway %s: FailureReason = %q, want %q", tt.now.Sub(exp), tt.leeway, result.FailureReason, tt.want)
+		}
+	}
+}
+
+func TestVerifyCompactStrictSelfIssued(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	sign := func(issuer string) string {
+		claim := testClaim(t)
+		claim.Iss = issuer
+		compact, err := SignCompact(claim, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return compact
+	}
+	fromSender, fromRecipient := sign("hap.globex.co.uk"), sign("va.acme.com")
+	keys := []JWK{jwk}
+
+	tests := []struct {
+		name    string
+		compact string
+		req     StrictRequirements
+		valid   bool
+		status  CheckStatus
+		warning bool
+	}{
+		{"sender VA, warned", fromSender, StrictRequirements{Signature: true, Keys: keys, SenderDomain: "globex.co.uk"}, true, CheckSkipped, true},
+		{"sender VA, rejected", fromSender, StrictRequirements{Signature: true, Keys: keys, SenderDomain: "globex.co.uk", RejectSelfIssued: true}, false, CheckFailed, false},
+		{"unrelated sender", fromSender, StrictRequirements{Signature: true, Keys: keys, SenderDomain: "initech.co.uk", RejectSelfIssued: true}, true, CheckPassed, false},
+		{"recipient VA, not counted", fromRecipient, StrictRequirements{Signature: true, Keys: keys, RecipientDomain: "acme.com", RejectSelfIssued: true}, true, CheckPassed, false},
+		{"recipient VA, rejected", fromRecipient, StrictRequirements{Signature: true, Keys: keys, RecipientDomain: "acme.com", SelfIssuedFromRecipient: true, RejectSelfIssued: true}, false, CheckFailed, false},
+	}
+	for _, tt := range tests {
+		result := VerifyCompactStrict(context.Background(), tt.compact, tt.req, VerifyOptions{})
+		if result.Valid != tt.valid {
+			t.Errorf("%s: Valid = %v, want %v (error: %s)", tt.name, result.Valid, tt.valid, result.Error)
+			continue
+		}
+		if !tt.valid && !errors.Is(result.Err, ErrSelfIssued) {
+			t.Errorf("%s: Err = %v, want ErrSelfIssued", tt.name, result.Err)
+		}
+		for _, c := range result.ChecksPerformed {
+			if c.Name == CheckNotSelfIssued && c.Status != tt.status {
+				t.Errorf("%s: %s = %s (%s), want %s", tt.name, c.Name, c.Status, c.Detail, tt.status)
+			}
+		}
+		warned := len(result.Warnings) > 0 && result.Warnings[len(result.Warnings)-1] == WarningSelfIssued
+		if warned != tt.warning {
+			t.Errorf("%s: Warnings = %q", tt.name, result.Warnings)
+		}
+	}
+}
@@ -0,0 +1,548 @@
+package humanattestation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testClaim(t testing.TB) *Claim {
+	t.Helper()
+	claim, err := CreateClaim(CreateClaimParams{
+		Method:        "ba_priority_mail",
+		Description:   "Priority mail packet with handwritten cover letter",
+		RecipientName: "Acme Corp",
+		Domain:        "acme.com",
+		Issuer:        "ballista.jobs",
+		ExpiresInDays: 730,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claim
+}
+
+func testKeys(t testing.TB, kid string) (ed25519.PrivateKey, JWK) {
+	t.Helper()
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv, ExportPublicKeyJWK(pub, kid)
+}
+
+func TestSignCompactBatchCtxReportsProgress(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	claims := make([]*Claim, 250)
+	for i := range claims {
+		claims[i] = testClaim(t)
+	}
+
+	var calls, last int
+	results, err := SignCompactBatchCtx(context.Background(), claims, priv, func(done, total int) {
+		if total != len(claims) {
+			t.Errorf("total = %d, want %d", total, len(claims))
+		}
+		if done <= last {
+			t.Errorf("progress went backwards: %d after %d", done, last)
+		}
+		calls++
+		last = done
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls == 0 || last != len(claims) {
+		t.Errorf("progress calls = %d, last = %d; want final report of %d", calls, last, len(claims))
+	}
+
+	for i, compact := range results {
+		result := VerifyCompact(compact, []JWK{jwk})
+		if !result.Valid {
+			t.Fatalf("result %d invalid: %s", i, result.Error)
+		}
+		if result.Claim.ID != claims[i].ID {
+			t.Fatalf("result %d out of order: got %s, want %s", i, result.Claim.ID, claims[i].ID)
+		}
+	}
+}
+
+func TestSignCompactBatchCtxCancellation(t *testing.T) {
+	priv, _ := testKeys(t, "key_001")
+	claims := make([]*Claim, 5000)
+	claim := testClaim(t)
+	for i := range claims {
+		claims[i] = claim
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var reported int
+	results, err := SignCompactBatchCtx(ctx, claims, priv, func(done, total int) {
+		reported = done
+		cancel()
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if len(results) != len(claims) {
+		t.Fatalf("len(results) = %d, want %d", len(results), len(claims))
+	}
+
+	signed := 0
+	for _, r := range results {
+		if r != "" {
+			signed++
+		}
+	}
+	if signed == len(claims) {
+		t.Error("cancellation did not stop work")
+	}
+	if signed < reported {
+		t.Errorf("partial result has %d claims, progress reported %d", signed, reported)
+	}
+}
+
+func TestVerifyCompactBatch(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	_, other := testKeys(t, "key_002")
+	keys := []JWK{other, jwk}
+	compacts := make([]string, 100)
+	for i := range compacts {
+		compact, err := SignCompact(testClaim(t), priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch i % 10 {
+		case 3:
+			compact = compact[:len(compact)-4] + "AAAA"
+		case 7:
+			compact = "not a compact claim"
+		}
+		compacts[i] = compact
+	}
+
+	for _, concurrency := range []int{0, 1, 8, 1000} {
+		results := VerifyCompactBatch(compacts, keys, concurrency)
+		if len(results) != len(compacts) {
+			t.Fatalf("concurrency %d: %d results, want %d", concurrency, len(results), len(compacts))
+		}
+		for i, result := range results {
+			want := VerifyCompact(compacts[i], keys)
+			if result.Valid != want.Valid || result.Error != want.Error || result.FailureReason != want.FailureReason {
+				t.Fatalf("concurrency %d: result %d = %+v, want %+v", concurrency, i, result, want)
+			}
+			if result.Valid && !strings.Contains(compacts[i], result.Claim.ID) {
+				t.Fatalf("concurrency %d: result %d out of order: %s", concurrency, i, result.Claim.ID)
+			}
+			if result.KeyProvenance == nil || result.KeyProvenance.Source != KeySourceStatic {
+				t.Errorf("concurrency %d: result %d provenance = %+v", concurrency, i, result.KeyProvenance)
+			}
+		}
+	}
+
+	if results := VerifyCompactBatch(nil, keys, 4); len(results) != 0 {
+		t.Errorf("empty batch returned %d results", len(results))
+	}
+}
+
+func TestVerifyCompactMalformedKeys(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	short := jwk
+	short.X = base64urlEncode(make([]byte, 31))
+	wrongType := jwk
+	wrongType.Kty = "EC"
+	compact, err := SignCompact(testClaim(t), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A 31-byte key would make ed25519.Verify panic, killing a batch worker
+	for _, bad := range []JWK{short, wrongType} {
+		if result := VerifyCompact(compact, []JWK{bad}); result.Valid {
+			t.Errorf("VerifyCompact() with %s/%s key %q = valid", bad.Kty, bad.Crv, bad.X)
+		}
+		results := VerifyCompactBatch([]string{compact, compact}, []JWK{bad, jwk}, 2)
+		for i, result := range results {
+			if !result.Valid {
+				t.Errorf("VerifyCompactBatch() result %d = %+v, want valid with the good key", i, result)
+			}
+		}
+		if _, err := lookupKey([]JWK{bad}, bad.Kid, KeyUseClaims); !errors.Is(err, ErrNotEd25519Key) {
+			t.Errorf("lookupKey() error = %v, want ErrNotEd25519Key", err)
+		}
+	}
+}
+
+// BenchmarkVerifyCompactBatch compares verifying 1000 claims one at a time
+// with VerifyCompactBatch. Claims are signed with the oldest of four keys,
+// so each verification tries every key.
+func BenchmarkVerifyCompactBatch(b *testing.B) {
+	var keys []JWK
+	var priv ed25519.PrivateKey
+	for i := 0; i < 4; i++ {
+		var jwk JWK
+		priv, jwk = testKeys(b, fmt.Sprintf("key_%03d", i))
+		created := Timestamp{time.Now().Add(time.Duration(-i) * time.Hour)}
+		jwk.Created = &created
+		keys = append(keys, jwk)
+	}
+	compacts := make([]string, 1000)
+	for i := range compacts {
+		compact, err := SignCompact(testClaim(b), priv)
+		if err != nil {
+			b.Fatal(err)
+		}
+		compacts[i] = compact
+	}
+
+	b.Run("loop", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, compact := range compacts {
+				VerifyCompact(compact, keys)
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			VerifyCompactBatch(compacts, keys, 0)
+		}
+	})
+}
+
+func TestVerifyCompactRejectsWrongKeyUse(t *testing.T) {
+	claimsPriv, claimsPub, _ := GenerateKeyPair()
+	webhooksPriv, webhooksPub, _ := GenerateKeyPair()
+	keys := []JWK{
+		ExportPublicKeyJWKWithUse(claimsPub, "claims_001", KeyUseClaims),
+		ExportPublicKeyJWKWithUse(webhooksPub, "webhooks_001", KeyUseWebhooks),
+	}
+	claim := testClaim(t)
+
+	compact, err := SignCompact(claim, claimsPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := VerifyCompact(compact, keys); !result.Valid {
+		t.Errorf("claims key: expected valid, got %s", result.Error)
+	}
+
+	compact, err = SignCompact(claim, webhooksPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := VerifyCompact(compact, keys)
+	if result.Valid || result.Error != ErrWrongKeyUse.Error() {
+		t.Errorf("webhooks key: valid=%v error=%q, want %v", result.Valid, result.Error, ErrWrongKeyUse)
+	}
+}
+
+func TestCompactIssuerAlias(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	claim := testClaim(t)
+
+	if err := RegisterIssuerAlias("bj", claim.Iss); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		issuerAliasesMu.Lock()
+		delete(issuerAliases, "bj")
+		issuerAliasesMu.Unlock()
+	})
+
+	compact, err := SignCompactWithIssuerAlias(claim, priv, "bj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := SignCompact(claim, priv)
+	if len(compact) >= len(plain) {
+		t.Errorf("aliased compact (%d bytes) not shorter than plain (%d bytes)", len(compact), len(plain))
+	}
+
+	result := VerifyCompact(compact, []JWK{jwk})
+	if !result.Valid {
+		t.Fatalf("expected valid, got %s", result.Error)
+	}
+	if result.Claim.Iss != claim.Iss {
+		t.Errorf("Iss = %q, want %q", result.Claim.Iss, claim.Iss)
+	}
+}
+
+func TestDecodeCompactUnknownIssuerAlias(t *testing.T) {
+	priv, _ := testKeys(t, "key_001")
+	claim := testClaim(t)
+
+	if err := RegisterIssuerAlias("tmp", claim.Iss); err != nil {
+		t.Fatal(err)
+	}
+	compact, err := SignCompactWithIssuerAlias(claim, priv, "tmp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerAliasesMu.Lock()
+	delete(issuerAliases, "tmp")
+	issuerAliasesMu.Unlock()
+
+	if _, err := DecodeCompact(compact); !errors.Is(err, ErrUnknownIssuerAlias) {
+		t.Errorf("err = %v, want ErrUnknownIssuerAlias", err)
+	}
+}
+
+func TestVerifyCompactKeyProvenance(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	compact, _ := SignCompact(testClaim(t), priv)
+
+	result := VerifyCompact(compact, []JWK{jwk})
+	if result.KeyProvenance == nil || result.KeyProvenance.Source != KeySourceStatic {
+		t.Errorf("provenance = %+v, want static", result.KeyProvenance)
+	}
+}
+
+func TestCompactFieldSizes(t *testing.T) {
+	claim := testClaim(t)
+	claim.To.Name = strings.Repeat("Very Long Recipient Organization Name ", 5)
+
+	for _, opts := range []CompactOptions{{}, {Version: CompactVersionV2, Kid: "key_001"}} {
+		sizes := CompactFieldSizes(claim, opts)
+		if sizes == nil {
+			t.Fatal("expected sizes")
+		}
+
+		largest := ""
+		for field, size := range sizes {
+			if largest == "" || size > sizes[largest] {
+				largest = field
+			}
+		}
+		if largest != "name" {
+			t.Errorf("version %q: dominant field = %s (%v), want name", opts.Version, largest, sizes)
+		}
+
+		priv, _ := testKeys(t, "key_001")
+		compact, _ := SignCompact(claim, priv, opts)
+		total := strings.Count(compact, ".")
+		for _, size := range sizes {
+			total += size
+		}
+		if total != len(compact) {
+			t.Errorf("version %q: sizes sum to %d, compact is %d bytes", opts.Version, total, len(compact))
+		}
+	}
+}
+
+func TestVerifyCompactsFromURL(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	opts := CompactOptions{Version: CompactVersionV2, Kid: "key_001"}
+	first, err := SignCompact(testClaim(t), priv, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := SignCompact(testClaim(t), priv, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := "https://ballista.jobs/v?c=" + url.QueryEscape(first) + "&c=not-a-compact&c=" + url.QueryEscape(second)
+
+	compacts := ExtractCompactsFromURL(u)
+	if len(compacts) != 2 || compacts[0] != first || compacts[1] != second {
+		t.Fatalf("ExtractCompactsFromURL() = %q, want the two valid compacts", compacts)
+	}
+
+	results := VerifyCompactsFromURL(u, []JWK{jwk})
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	for i, r := range results {
+		if !r.Valid {
+			t.Errorf("result %d invalid: %s", i, r.Error)
+		}
+	}
+}
+
+func TestValidateCompactStructure(t *testing.T) {
+	priv, _ := testKeys(t, "key_001")
+	v2, err := SignCompact(testClaim(t), priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1, err := SignCompact(testClaim(t), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testID := testClaim(t)
+	testID.ID = "hap_test_abcd1234"
+	testV1, err := SignCompact(testID, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked := testClaim(t)
+	if checked.ID, err = GenerateIDChecked(); err != nil {
+		t.Fatal(err)
+	}
+	checkedV2, err := SignCompact(checked, priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, valid := range []string{v1, v2, testV1, checkedV2} {
+		if err := ValidateCompactStructure(valid); err != nil {
+			t.Errorf("ValidateCompactStructure(%s) = %v", valid, err)
+		}
+	}
+
+	parts := strings.Split(v2, ".")
+	with := func(i int, value string) string {
+		p := append([]string(nil), parts...)
+		p[i] = value
+		return strings.Join(p, ".")
+	}
+	shortSig := base64urlEncode(make([]byte, 63))
+
+	tests := []struct {
+		name, compact, want string
+	}{
+		{"unknown version", with(0, "HAP9"), "unsupported compact version"},
+		{"missing field", strings.Join(parts[:10], "."), "expected 11 fields"},
+		{"extra field", v2 + ".x", "expected 11 fields"},
+		{"v1 field count", strings.Join(strings.Split(v1, ".")[:8], "."), "expected 9 fields"},
+		{"bad ID", with(1, "ext_abc123xyz456"), "invalid HAP ID"},
+		{"negative at", with(6, "-1"), "'at' timestamp"},
+		{"non-numeric exp", with(7, "soon"), "'exp' timestamp"},
+		{"signature not base64url", with(10, "not+base64"), "failed to decode signature"},
+		{"short signature", with(10, shortSig), "signature must be 64 bytes, got 63"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCompactStructure(tt.compact)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ValidateCompactStructure() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyCompactFailureReason(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	_, otherJWK := testKeys(t, "key_002")
+	compact, err := SignCompact(testClaim(t), priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	webhookJWK := jwk
+	webhookJWK.Use = KeyUseWebhooks
+	lastDot := strings.LastIndex(compact, ".")
+
+	tests := []struct {
+		name    string
+		compact string
+		keys    []JWK
+		want    FailureReason
+	}{
+		{"valid", compact, []JWK{jwk}, ""},
+		{"no keys", compact, nil, FailureNoKeys},
+		{"undecodable key", compact, []JWK{{Kid: "key_001", X: "!!!"}}, FailureNoKeys},
+		{"wrong key use", compact, []JWK{webhookJWK}, FailureNoKeys},
+		{"malformed", "HAP2.garbage", []JWK{jwk}, FailureDecodeError},
+		{"undecodable signature", compact[:lastDot+1] + "A", []JWK{jwk}, FailureDecodeError},
+		{"other key", compact, []JWK{otherJWK}, FailureSignatureMismatch},
+		{"tampered", strings.Replace(compact, "acme%2Ecom", "acme%2Eorg", 1), []JWK{jwk}, FailureSignatureMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := VerifyCompact(tt.compact, tt.keys)
+			if result.FailureReason != tt.want {
+				t.Errorf("FailureReason = %q, want %q (error %q)", result.FailureReason, tt.want, result.Error)
+			}
+			if result.Valid != (tt.want == "") {
+				t.Errorf("Valid = %v with FailureReason %q", result.Valid, result.FailureReason)
+			}
+			if !result.Valid && result.Error == "" {
+				t.Error("failed result has no Error message")
+			}
+		})
+	}
+}
+
+func TestDecodeCompactUnescapedDot(t *testing.T) {
+	priv, _ := testKeys(t, "key_001")
+	v1, err := SignCompact(testClaim(t), priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := SignCompact(testClaim(t), priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, compact, want string
+	}{
+		{"v1 issuer", strings.Replace(v1, "ballista%2Ejobs", "ballista.jobs", 1), "the issuer field likely contains an unescaped"},
+		{"v2 issuer", strings.Replace(v2, "ballista%2Ejobs", "ballista.jobs", 1), "the issuer field likely contains an unescaped"},
+		{"v2 domain", strings.Replace(v2, "acme%2Ecom", "acme.com", 1), "the domain field likely contains an unescaped"},
+		{"v1 domain", strings.Replace(v1, "acme%2Ecom", "acme.com", 1), "the domain field likely contains an unescaped"},
+		// Adjacent non-hostname fields cannot be told apart
+		{"v2 method", strings.Replace(v2, "ba_priority_mail", "ba.priority_mail", 1), "one of the method, tier, name, domain fields"},
+		{"two extra fields", strings.Replace(v2, "ballista%2Ejobs", "www.ballista.jobs", 1), "expected 11 fields, got 13: the issuer field"},
+		{"too few fields", v2[:strings.LastIndex(v2, ".")], "expected 11 fields, got 10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeCompact(tt.compact)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("DecodeCompact() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	// ValidateCompactStructure gives the same diagnosis
+	mangled := strings.Replace(v1, "ballista%2Ejobs", "ballista.jobs", 1)
+	if err := ValidateCompactStructure(mangled); err == nil || !strings.Contains(err.Error(), "issuer field") {
+		t.Errorf("ValidateCompactStructure() = %v, want issuer diagnosis", err)
+	}
+}
+
+func TestDecodeCompactTimeRange(t *testing.T) {
+	priv, _ := testKeys(t, "key_001")
+	tests := []struct {
+		name    string
+		at, exp string
+		wantErr bool
+	}{
+		{"valid range", "2026-01-15T10:30:00Z", "2028-01-15T10:30:00Z", false},
+		{"inverted range", "2026-01-15T10:30:00Z", "2025-01-15T10:30:00Z", true},
+		{"exp equal to at", "2026-01-15T10:30:00Z", "2026-01-15T10:30:00Z", true},
+		{"zero exp", "2026-01-15T10:30:00Z", "", false},
+	}
+	for _, tt := range tests {
+		for _, version := range []string{CompactVersion, CompactVersionV2} {
+			t.Run(tt.name+"/v"+version, func(t *testing.T) {
+				claim := testClaim(t)
+				claim.At, claim.Exp = tt.at, tt.exp
+				compact, err := SignCompact(claim, priv, CompactOptions{Version: version})
+				if err != nil {
+					t.Fatal(err)
+				}
+				decoded, err := DecodeCompact(compact)
+				if tt.wantErr {
+					if !errors.Is(err, ErrInvalidTimeRange) {
+						t.Errorf("DecodeCompact() error = %v, want ErrInvalidTimeRange", err)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("DecodeCompact() error = %v", err)
+				}
+				if decoded.Claim.Exp != tt.exp {
+					t.Errorf("Exp = %q, want %q", decoded.Claim.Exp, tt.exp)
+				}
+			})
+		}
+	}
+}
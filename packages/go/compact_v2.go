@@ -0,0 +1,299 @@
+package humanattestation
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// HAP Compact version 2 layout (11 fields):
+//
+//	HAP2.{id}.{method}.{tier}.{to_name}.{to_domain}.{at}.{exp}.{iss}.{kid}.{signature}
+//
+// Differences from version 1:
+//   - every text field is percent-encoded per RFC 3986, so spaces are "%20"
+//     rather than "+" (matching encodeURIComponent in the JS SDK)
+//   - the method is encoded like the other text fields
+//   - tier is included (empty when absent)
+//   - exp is empty rather than "0" when the claim does not expire
+//   - a kid hint names the signing key so verifiers can skip trial verification
+//   - text fields are decoded strictly: only the encoding encodeCompactFieldV2
+//     emits is accepted, so every field has exactly one valid spelling
+//   - the signature covers the payload prefixed with CompactV2SigningContext,
+//     so a signature the same key made for another protocol cannot pass as
+//     a claim
+
+const upperHex = "0123456789ABCDEF"
+
+// CompactV2SigningContext separates version 2 compact signatures from
+// anything else an issuer's key signs. It is prepended to the payload to
+// form the signed bytes; it never appears in the compact string.
+const CompactV2SigningContext = "hap-compact-v2\x00"
+
+// CompactSigningInput returns the bytes a compact payload's signature
+// covers: a version 2 payload prefixed with CompactV2SigningContext, or a
+// version 1 payload as is. Issuers signing with an external signer sign
+// these bytes, not the payload from BuildCompactPayloadV2.
+func CompactSigningInput(payload string) []byte {
+	if strings.HasPrefix(payload, "HAP"+CompactVersionV2+".") {
+		return []byte(CompactV2SigningContext + payload)
+	}
+	return []byte(payload)
+}
+
+// encodeCompactFieldV2 percent-encodes everything except RFC 3986 unreserved
+// characters, and also encodes '.' since it is the field delimiter
+func encodeCompactFieldV2(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if isCompactUnreserved(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(upperHex[c>>4])
+		b.WriteByte(upperHex[c&0x0F])
+	}
+	return b.String()
+}
+
+// isCompactUnreserved reports whether c is emitted unescaped in version 2 fields
+func isCompactUnreserved(c byte) bool {
+	return 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '_' || c == '~'
+}
+
+// ErrNonCanonicalEncoding is returned when a version 2 compact field is not
+// encoded exactly as encodeCompactFieldV2 would encode it
+var ErrNonCanonicalEncoding = errors.New("non-canonical compact field encoding")
+
+// decodeCompactFieldV2 decodes a version 2 compact field, accepting only the
+// grammar encodeCompactFieldV2 produces:
+//
+//	field   = *( unreserved / escape )
+//	escape  = "%" HEXDIG HEXDIG   ; uppercase only, never an unreserved byte
+//
+// and the decoded bytes must be valid UTF-8. Lowercase hex, truncated escapes,
+// escaped unreserved characters and any other byte are rejected with their
+// offset, so two verifiers can never decode the same field differently.
+func decodeCompactFieldV2(value string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if isCompactUnreserved(c) {
+			b.WriteByte(c)
+			continue
+		}
+		if c != '%' {
+			return "", fmt.Errorf("%w: unexpected %q at offset %d", ErrNonCanonicalEncoding, c, i)
+		}
+		if i+2 >= len(value) {
+			return "", fmt.Errorf("%w: truncated escape at offset %d", ErrNonCanonicalEncoding, i)
+		}
+		hi, lo := upperHexValue(value[i+1]), upperHexValue(value[i+2])
+		if hi < 0 || lo < 0 {
+			return "", fmt.Errorf("%w: invalid escape %q at offset %d", ErrNonCanonicalEncoding, value[i:i+3], i)
+		}
+		decoded := byte(hi<<4 | lo)
+		if isCompactUnreserved(decoded) {
+			return "", fmt.Errorf("%w: escaped unreserved character %q at offset %d", ErrNonCanonicalEncoding, decoded, i)
+		}
+		b.WriteByte(decoded)
+		i += 2
+	}
+	if !utf8.ValidString(b.String()) {
+		return "", fmt.Errorf("%w: decoded field is not valid UTF-8", ErrNonCanonicalEncoding)
+	}
+	return b.String(), nil
+}
+
+// upperHexValue returns the value of an uppercase hex digit, or -1
+func upperHexValue(c byte) int {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0')
+	case 'A' <= c && c <= 'F':
+		return int(c-'A') + 10
+	}
+	return -1
+}
+
+// BuildCompactPayloadV2 builds the version 2 compact payload (everything
+// before the signature). Sign CompactSigningInput(payload), not the payload.
+func BuildCompactPayloadV2(claim *Claim, kid string) (string, error) {
+	return buildCompactPayloadV2(claim, encodeCompactFieldV2(claim.Iss), kid)
+}
+
+// buildCompactPayloadV2 builds the version 2 payload with a pre-encoded issuer field
+func buildCompactPayloadV2(claim *Claim, issField, kid string) (string, error) {
+	atUnix, err := isoToUnix(claim.At)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse 'at' timestamp: %w", err)
+	}
+
+	expField := ""
+	if claim.Exp != "" {
+		expUnix, err := isoToUnix(claim.Exp)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse 'exp' timestamp: %w", err)
+		}
+		expField = strconv.FormatInt(expUnix, 10)
+	}
+
+	fields := []string{
+		"HAP" + CompactVersionV2,
+		claim.ID,
+		encodeCompactFieldV2(claim.Method),
+		encodeCompactFieldV2(claim.Tier),
+		encodeCompactFieldV2(claim.To.Name),
+		encodeCompactFieldV2(claim.To.Domain),
+		strconv.FormatInt(atUnix, 10),
+		expField,
+		issField,
+		encodeCompactFieldV2(kid),
+	}
+
+	return strings.Join(fields, "."), nil
+}
+
+// EncodeCompactV2 encodes a HAP claim, signature and kid hint into version 2 compact format
+func EncodeCompactV2(claim *Claim, signature []byte, kid string) (string, error) {
+	payload, err := BuildCompactPayloadV2(claim, kid)
+	if err != nil {
+		return "", err
+	}
+	return payload + "." + base64urlEncode(signature), nil
+}
+
+// decodeCompactV2 decodes a version 2 compact string
+func decodeCompactV2(compact string) (*DecodedCompact, error) {
+	parts := strings.Split(compact, ".")
+	if len(parts) != 11 {
+		return nil, fieldCountError(parts, CompactVersionV2)
+	}
+	if !CompactV2Regex.MatchString(compact) {
+		return nil, fmt.Errorf("invalid HAP Compact format")
+	}
+
+	decoded := make(map[string]string, 5)
+	for _, f := range []struct {
+		name  string
+		value string
+	}{
+		{"method", parts[2]},
+		{"tier", parts[3]},
+		{"name", parts[4]},
+		{"domain", parts[5]},
+		{"kid", parts[9]},
+	} {
+		v, err := decodeCompactFieldV2(f.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", f.name, err)
+		}
+		decoded[f.name] = v
+	}
+
+	iss, err := decodeIssuerField(parts[8], decodeCompactFieldV2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode issuer: %w", err)
+	}
+
+	atUnix, err := strconv.ParseInt(parts[6], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse 'at' timestamp: %w", err)
+	}
+
+	var exp string
+	var expUnix int64
+	if parts[7] != "" {
+		expUnix, err = strconv.ParseInt(parts[7], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse 'exp' timestamp: %w", err)
+		}
+		exp = unixToISO(expUnix)
+	}
+
+	signature, err := base64urlDecode(parts[10])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	claim := &Claim{
+		V:      Version,
+		ID:     parts[1],
+		Method: decoded["method"],
+		To: ClaimTarget{
+			Name:   decoded["name"],
+			Domain: decoded["domain"],
+		},
+		At:   unixToISO(atUnix),
+		Exp:  exp,
+		Iss:  iss,
+		Tier: decoded["tier"],
+	}
+	if err := ValidateClaim(claim); err != nil {
+		return nil, err
+	}
+	if err := checkCompactTimeRange(atUnix, expUnix); err != nil {
+		return nil, err
+	}
+
+	return &DecodedCompact{
+		Version:   CompactVersionV2,
+		Claim:     claim,
+		Kid:       decoded["kid"],
+		Signature: signature,
+	}, nil
+}
+
+// keysWithKidFirst returns keys reordered so the key matching kid is tried first
+func keysWithKidFirst(keys []JWK, kid string) []JWK {
+	ordered := make([]JWK, 0, len(keys))
+	for _, k := range keys {
+		if k.Kid == kid {
+			ordered = append(ordered, k)
+		}
+	}
+	for _, k := range keys {
+		if k.Kid != kid {
+			ordered = append(ordered, k)
+		}
+	}
+	return ordered
+}
+
+// PreferredCompactVersion picks the newest compact version a VA's audience
+// can verify. Capabilities may be given as "1"/"2" or "HAP1"/"HAP2"; with no
+// recognised capabilities version 1 is assumed.
+func PreferredCompactVersion(verifierCapabilities []string) string {
+	for _, c := range verifierCapabilities {
+		if strings.TrimPrefix(c, "HAP") == CompactVersionV2 {
+			return CompactVersionV2
+		}
+	}
+	return CompactVersion
+}
+
+// UpgradeCompact re-signs a version 1 compact string as version 2.
+// The original signature must verify under the given private key's public key.
+// Version 1 strings carry no tier, so the upgraded string has none either.
+func UpgradeCompact(compact string, privateKey ed25519.PrivateKey, kid string) (string, error) {
+	if !CompactRegex.MatchString(compact) {
+		return "", fmt.Errorf("not a version 1 compact string")
+	}
+
+	publicKey, ok := privateKey.Public().(ed25519.PublicKey)
+	if !ok {
+		return "", fmt.Errorf("invalid private key")
+	}
+	result := VerifyCompact(compact, []JWK{ExportPublicKeyJWK(publicKey, kid)})
+	if !result.Valid {
+		return "", fmt.Errorf("failed to verify compact string: %s", result.Error)
+	}
+
+	return SignCompact(result.Claim, privateKey, CompactOptions{Version: CompactVersionV2, Kid: kid})
+}
@@ -0,0 +1,352 @@
+package humanattestation
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"errors"
+	"flag"
+	"math/rand"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// goldenKey is a fixed key so golden compact strings are reproducible
+var goldenKey = ed25519.NewKeyFromSeed([]byte("hap-golden-test-key-seed-32bytes"))
+
+// goldenClaims covers the encoding edge cases pinned by the golden files
+var goldenClaims = []*Claim{
+	{
+		V: Version, ID: "hap_abc123xyz456", Method: "ba_priority_mail",
+		To: ClaimTarget{Name: "Acme Corp", Domain: "acme.com"},
+		At: "2026-01-19T06:00:00Z", Exp: "2028-01-19T06:00:00Z", Iss: "ballista.jobs",
+	},
+	{
+		V: Version, ID: "hap_abc123xyz457", Method: "ba_priority_mail", Tier: "gold",
+		To: ClaimTarget{Name: "Acme Corp"},
+		At: "2026-01-19T06:00:00Z", Iss: "ballista.jobs",
+	},
+	{
+		V: Version, ID: "hap_abc123xyz458", Method: "xva_verified+mail",
+		To: ClaimTarget{Name: "C++ & Sons, Inc. (Zürich)", Domain: "sons.example.co.uk"},
+		At: "2026-01-19T06:00:00Z", Exp: "2026-02-19T06:00:00Z", Iss: "example-va.com",
+	},
+	{
+		V: Version, ID: "hap_test_abcd1234", Method: "physical_mail", Tier: "standard plus",
+		To: ClaimTarget{Name: "100% Real~Name_-", Domain: "xn--mnchen-3ya.de"},
+		At: "2026-01-19T06:00:00Z", Iss: "va.example",
+	},
+}
+
+func checkGolden(t *testing.T, name string, got []string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, []byte(strings.Join(got, "\n")+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create)", err)
+	}
+	defer f.Close()
+
+	var want []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		want = append(want, scanner.Text())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s mismatch:\ngot:\n%s\nwant:\n%s", name, strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCompactGolden(t *testing.T) {
+	var v1, v2 []string
+	for _, claim := range goldenClaims {
+		c1, err := SignCompact(claim, goldenKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c2, err := SignCompact(claim, goldenKey, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		v1 = append(v1, c1)
+		v2 = append(v2, c2)
+	}
+	checkGolden(t, "compact_v1.golden", v1)
+	checkGolden(t, "compact_v2.golden", v2)
+}
+
+func TestCompactGoldenVerifies(t *testing.T) {
+	jwk := ExportPublicKeyJWK(goldenKey.Public().(ed25519.PublicKey), "key_001")
+
+	for _, name := range []string{"compact_v1.golden", "compact_v2.golden"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		for i, compact := range lines {
+			result := VerifyCompact(compact, []JWK{jwk})
+			if !result.Valid {
+				t.Errorf("%s line %d: %s", name, i+1, result.Error)
+				continue
+			}
+
+			want := *goldenClaims[i]
+			if strings.HasPrefix(compact, "HAP1.") {
+				want.Tier = "" // not carried in version 1
+			}
+			if !reflect.DeepEqual(result.Claim, &want) {
+				t.Errorf("%s line %d: decoded %+v, want %+v", name, i+1, result.Claim, want)
+			}
+		}
+	}
+}
+
+func TestCompactV2Encoding(t *testing.T) {
+	compact, err := SignCompact(goldenClaims[2], goldenKey, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(compact, "+") {
+		t.Errorf("version 2 must not emit '+': %s", compact)
+	}
+	if !strings.Contains(compact, "C%2B%2B%20%26%20Sons") {
+		t.Errorf("expected RFC 3986 encoding of name: %s", compact)
+	}
+
+	decoded, err := DecodeCompact(compact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Version != CompactVersionV2 || decoded.Kid != "key_001" {
+		t.Errorf("version=%q kid=%q", decoded.Version, decoded.Kid)
+	}
+
+	noExp, _ := SignCompact(goldenClaims[1], goldenKey, CompactOptions{Version: CompactVersionV2})
+	if parts := strings.Split(noExp, "."); parts[7] != "" {
+		t.Errorf("exp field = %q, want empty", parts[7])
+	}
+}
+
+func TestUpgradeCompact(t *testing.T) {
+	v1, err := SignCompact(goldenClaims[0], goldenKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v2, err := UpgradeCompact(v1, goldenKey, "key_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(v2, "HAP2.") {
+		t.Fatalf("expected version 2 string, got %s", v2)
+	}
+
+	jwk := ExportPublicKeyJWK(goldenKey.Public().(ed25519.PublicKey), "key_001")
+	r1 := VerifyCompact(v1, []JWK{jwk})
+	r2 := VerifyCompact(v2, []JWK{jwk})
+	if !r2.Valid || !reflect.DeepEqual(r1.Claim, r2.Claim) {
+		t.Errorf("upgraded claim differs: %+v vs %+v (%s)", r1.Claim, r2.Claim, r2.Error)
+	}
+
+	otherPriv, _ := testKeys(t, "other")
+	if _, err := UpgradeCompact(v1, otherPriv, "other"); err == nil {
+		t.Error("expected error re-signing a claim not signed by the given key")
+	}
+}
+
+func TestCompactV2SigningContext(t *testing.T) {
+	jwk := ExportPublicKeyJWK(goldenKey.Public().(ed25519.PublicKey), "key_001")
+	keys := []JWK{jwk}
+	claim := goldenClaims[0]
+
+	// An external signer signing CompactSigningInput produces a valid string
+	payload, err := BuildCompactPayloadV2(claim, "key_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := EncodeCompactV2(claim, ed25519.Sign(goldenKey, CompactSigningInput(payload)), "key_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := VerifyCompact(signed, keys); !r.Valid {
+		t.Fatalf("VerifyCompact() with context = %s", r.Error)
+	}
+
+	// A signature over the bare payload, as another protocol might make, is refused
+	bare := payload + "." + base64urlEncode(ed25519.Sign(goldenKey, []byte(payload)))
+	if r := VerifyCompact(bare, keys); r.Valid || !errors.Is(r.Err, ErrSignatureInvalid) {
+		t.Errorf("VerifyCompact() without context = %v, %v", r.Valid, r.Err)
+	}
+
+	// Version 1 signatures stay bare, and one made with the context fails
+	v1Payload, err := BuildCompactPayload(claim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := VerifyCompact(v1Payload+"."+base64urlEncode(ed25519.Sign(goldenKey, []byte(v1Payload))), keys); !r.Valid {
+		t.Errorf("VerifyCompact() for version 1 = %s", r.Error)
+	}
+	withContext := ed25519.Sign(goldenKey, []byte(CompactV2SigningContext+v1Payload))
+	if r := VerifyCompact(v1Payload+"."+base64urlEncode(withContext), keys); r.Valid {
+		t.Error("version 1 string signed with the version 2 context verified")
+	}
+
+	// Neither version's signature carries over to the other
+	v1, err := SignCompact(claim, goldenKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2, err := SignCompact(claim, goldenKey, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := func(c string) string { return c[strings.LastIndex(c, ".")+1:] }
+	if r := VerifyCompact(payload+"."+sig(v1), keys); r.Valid {
+		t.Error("version 1 signature verified as version 2")
+	}
+	if r := VerifyCompact(v1Payload+"."+sig(v2), keys); r.Valid {
+		t.Error("version 2 signature verified as version 1")
+	}
+}
+
+func TestPreferredCompactVersion(t *testing.T) {
+	tests := []struct {
+		caps []string
+		want string
+	}{
+		{nil, CompactVersion},
+		{[]string{"1"}, CompactVersion},
+		{[]string{"HAP1", "HAP2"}, CompactVersionV2},
+		{[]string{"2"}, CompactVersionV2},
+	}
+	for _, tt := range tests {
+		if got := PreferredCompactVersion(tt.caps); got != tt.want {
+			t.Errorf("PreferredCompactVersion(%v) = %q, want %q", tt.caps, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeCompactFieldV2Strict(t *testing.T) {
+	valid := map[string]string{
+		"":                   "",
+		"Acme_Corp-1~":       "Acme_Corp-1~",
+		"Acme%20Corp":        "Acme Corp",
+		"C%2B%2B%2E":         "C++.",
+		"Caf%C3%A9":          "Café",
+		"%E5%B1%B1%E7%94%B0": "山田",
+	}
+	for in, want := range valid {
+		got, err := decodeCompactFieldV2(in)
+		if err != nil || got != want {
+			t.Errorf("decodeCompactFieldV2(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+
+	invalid := map[string]string{
+		"Acme%2ECorp%2e": "offset 11", // lowercase hex
+		"Acme+Corp":      "offset 4",  // '+' is never emitted
+		"Acme%":          "offset 4",  // bare '%'
+		"Acme%2":         "offset 4",  // truncated escape
+		"%41cme":         "offset 0",  // escaped unreserved character
+		"Acme%G1":        "offset 4",  // not hex
+		"Caf%C3":         "UTF-8",     // truncated multi-byte sequence
+		"Acme Corp":      "offset 4",  // raw space
+	}
+	for in, want := range invalid {
+		_, err := decodeCompactFieldV2(in)
+		if !errors.Is(err, ErrNonCanonicalEncoding) || !strings.Contains(err.Error(), want) {
+			t.Errorf("decodeCompactFieldV2(%q) error = %v, want %s", in, err, want)
+		}
+	}
+}
+
+func TestDecodeCompactV1Warnings(t *testing.T) {
+	priv, _ := testKeys(t, "key_001")
+	claim := testClaim(t)
+	claim.To.Name = "Acme Corp"
+	compact, err := SignCompact(claim, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeCompact(compact)
+	if err != nil || len(decoded.Warnings) != 0 {
+		t.Fatalf("canonical v1 compact: warnings %v, err %v", decoded.Warnings, err)
+	}
+
+	// Non-canonical escapes still decode in version 1, with a warning
+	lenient := strings.Replace(compact, "Acme+Corp", "Acme%20Corp", 1)
+	decoded, err = DecodeCompact(lenient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Claim.To.Name != "Acme Corp" || len(decoded.Warnings) != 1 {
+		t.Errorf("lenient v1 compact: name %q, warnings %v", decoded.Claim.To.Name, decoded.Warnings)
+	}
+}
+
+// checkStrictAgainstLenient asserts the strict version 2 decoder never
+// silently disagrees with a lenient percent-decoder: it either rejects the
+// input or returns exactly what the lenient decoder does, and it accepts
+// exactly the canonical encodings of valid UTF-8 strings.
+func checkStrictAgainstLenient(t *testing.T, in string) {
+	strict, strictErr := decodeCompactFieldV2(in)
+	lenient, lenientErr := url.PathUnescape(in)
+
+	if strictErr == nil {
+		if lenientErr != nil || strict != lenient {
+			t.Fatalf("%q: strict %q, lenient %q (%v)", in, strict, lenient, lenientErr)
+		}
+		if encodeCompactFieldV2(strict) != in {
+			t.Fatalf("%q: strict accepted a non-canonical encoding", in)
+		}
+		return
+	}
+	if lenientErr == nil && utf8.ValidString(lenient) && encodeCompactFieldV2(lenient) == in {
+		t.Fatalf("%q: strict rejected a canonical encoding: %v", in, strictErr)
+	}
+}
+
+func TestDecodeCompactFieldV2Differential(t *testing.T) {
+	const alphabet = "%%%AaFf09+-_~. Zé\xff"
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		b := make([]byte, rng.Intn(10))
+		for j := range b {
+			b[j] = alphabet[rng.Intn(len(alphabet))]
+		}
+		checkStrictAgainstLenient(t, string(b))
+	}
+
+	// Every encoded string round-trips
+	for i := 0; i < 2000; i++ {
+		runes := make([]rune, rng.Intn(8))
+		for j := range runes {
+			runes[j] = rune(rng.Intn(0x3000))
+		}
+		s := string(runes)
+		if got, err := decodeCompactFieldV2(encodeCompactFieldV2(s)); err != nil || got != s {
+			t.Fatalf("round trip of %q = %q, %v", s, got, err)
+		}
+	}
+}
+
+func FuzzDecodeCompactFieldV2(f *testing.F) {
+	for _, seed := range []string{"", "Acme%20Corp", "%2e", "%41", "+", "%", "%C3%A9", "%FF"} {
+		f.Add(seed)
+	}
+	f.Fuzz(checkStrictAgainstLenient)
+}
@@ -0,0 +1,321 @@
+package humanattestation
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// These tests exercise every stateful type from many goroutines doing mixed
+// reads and writes. They check results, but exist mainly for go test -race.
+
+const raceGoroutines = 64
+
+// hammer runs fn on raceGoroutines goroutines released at once, passing
+// each its index
+func hammer(fn func(i int)) {
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < raceGoroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			fn(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+}
+
+func TestConcurrentVerification(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+	claim, jws := va.Issue(t, priv, "key_001")
+	_, jwk := testKeys(t, "key_002")
+	compactPriv, compactJWK := testKeys(t, "key_003")
+	compact, err := SignCompact(testClaim(t), compactPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One options value and client shared by every goroutine, as in a server
+	opts := va.Options()
+	opts.KeyCache = NewPublicKeyCache(time.Minute)
+	opts.ClaimCache = NewClaimCache(time.Second)
+	ctx := context.Background()
+
+	hammer(func(i int) {
+		switch i % 8 {
+		case 0:
+			if got, err := VerifyClaim(ctx, claim.ID, va.Issuer(), opts); err != nil || got == nil {
+				t.Errorf("VerifyClaim() = %v, %v", got, err)
+			}
+		case 1:
+			if r, err := VerifySignature(ctx, jws, va.Issuer(), opts); err != nil || !r.Valid {
+				t.Errorf("VerifySignature() = %+v, %v", r, err)
+			}
+		case 2:
+			if resp, err := FetchClaim(ctx, claim.ID, va.Issuer(), opts); err != nil || !resp.Valid {
+				t.Errorf("FetchClaim() = %+v, %v", resp, err)
+			} else {
+				// Callers own what they get back
+				resp.Claim.To.Name = fmt.Sprint(i)
+				resp.Warnings = append(resp.Warnings, "mine")
+			}
+		case 3:
+			if wk, err := FetchPublicKeys(ctx, va.Issuer(), opts); err != nil {
+				t.Errorf("FetchPublicKeys() error = %v", err)
+			} else {
+				wk.Keys = append(wk.Keys, jwk)
+			}
+		case 4:
+			opts.KeyCache.Flush(va.Issuer())
+			opts.ClaimCache.Flush()
+		case 5:
+			r := VerifyCompactStrict(ctx, compact, StrictRequirements{Signature: true, Keys: []JWK{compactJWK}, NotExpired: true}, opts)
+			if !r.Valid {
+				t.Errorf("VerifyCompactStrict() = %s", r.Error)
+			}
+		case 6:
+			for _, r := range VerifyCompactBatch([]string{compact, compact, compact}, []JWK{jwk, compactJWK}, 2) {
+				if !r.Valid {
+					t.Errorf("VerifyCompactBatch() = %s", r.Error)
+				}
+			}
+		case 7:
+			if _, err := BuildFullResponse(ctx, claim.ID, va.Issuer(), opts); err != nil {
+				t.Errorf("BuildFullResponse() error = %v", err)
+			}
+		}
+	})
+}
+
+func TestConcurrentWellKnownStore(t *testing.T) {
+	_, jwk := testKeys(t, "key_000")
+	store, err := NewWellKnownStore(WellKnown{Issuer: "ballista.jobs", Keys: []JWK{jwk}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hammer(func(i int) {
+		switch i % 4 {
+		case 0:
+			_, next := testKeys(t, fmt.Sprintf("key_%03d", i+1))
+			err := store.Update(func(wk *WellKnown) error {
+				wk.Keys = append(wk.Keys, next)
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Update() error = %v", err)
+			}
+		case 1:
+			if wk := store.Current(); len(wk.Keys) == 0 || wk.Keys[0].Kid != "key_000" {
+				t.Errorf("Current() = %+v", wk)
+			}
+		case 2:
+			rec := httptest.NewRecorder()
+			store.ServeHTTP(rec, httptest.NewRequest("GET", "/.well-known/hap.json", nil))
+			var wk WellKnown
+			if err := json.Unmarshal(rec.Body.Bytes(), &wk); err != nil || rec.Header().Get("ETag") == "" {
+				t.Errorf("ServeHTTP() = %d %s, %v", rec.Code, rec.Body, err)
+			}
+		case 3:
+			store.ETag()
+			store.Updates()
+		}
+	})
+
+	if n, keys := store.Updates(), len(store.Current().Keys); n != raceGoroutines/4 || keys != raceGoroutines/4+1 {
+		t.Errorf("after concurrent updates: %d updates, %d keys", n, keys)
+	}
+}
+
+func TestConcurrentRecordSet(t *testing.T) {
+	set := NewRecordSet(RecordSetOptions{})
+	claims := make([]*Claim, raceGoroutines)
+	for i := range claims {
+		claims[i] = testClaim(t)
+		claims[i].Method = fmt.Sprintf("method_%d", i%5)
+	}
+
+	hammer(func(i int) {
+		claim := claims[i]
+		if added, err := set.Add(claim); err != nil || !added {
+			t.Errorf("Add() = %v, %v", added, err)
+		}
+		if !set.Contains(claim.ID) {
+			t.Errorf("Contains(%s) = false after Add", claim.ID)
+		}
+		if r, ok := set.Get(claim.ID); !ok || set.Method(r) != claim.Method || set.Issuer(r) != claim.Iss {
+			t.Errorf("Get(%s) = %+v, %v", claim.ID, r, ok)
+		}
+		set.Range(func(r CompactRecord) bool {
+			set.Domain(r)
+			return true
+		})
+		set.Len()
+		if i%2 == 0 {
+			set.Remove(claim.ID)
+		}
+	})
+
+	if n := set.Len(); n != raceGoroutines/2 {
+		t.Errorf("Len() = %d, want %d", n, raceGoroutines/2)
+	}
+}
+
+func TestConcurrentStringTable(t *testing.T) {
+	table := NewStringTable(1000)
+	hammer(func(i int) {
+		s := fmt.Sprintf("value_%d", i%10)
+		idx, err := table.Intern(s)
+		if err != nil {
+			t.Errorf("Intern(%s) error = %v", s, err)
+			return
+		}
+		if got := table.Lookup(idx); got != s {
+			t.Errorf("Lookup(%d) = %q, want %q", idx, got, s)
+		}
+		table.Len()
+	})
+	if n := table.Len(); n != 10 {
+		t.Errorf("Len() = %d, want 10", n)
+	}
+}
+
+func TestConcurrentRecheck(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+	var responses []*VerificationResponse
+	for i := 0; i < 8; i++ {
+		claim, jws := va.Issue(t, priv, "key_001")
+		responses = append(responses, &VerificationResponse{Valid: true, ID: claim.ID, Claim: claim, JWS: jws})
+	}
+
+	stores := map[string]RecheckStore{
+		"memory": NewMemoryRecheckStore(),
+		"file":   NewFileRecheckStore(filepath.Join(t.TempDir(), "rechecks.json")),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			scheduler := NewRecheckScheduler(store, func(RecheckEntry, *VerificationResponse, *VerificationResponse) {}, RecheckOptions{
+				VerifyOptions: va.Options(),
+				Concurrency:   4,
+			})
+			ctx := context.Background()
+			hammer(func(i int) {
+				if i%2 == 0 {
+					if err := scheduler.Track(va.Issuer(), responses[i%len(responses)], time.Now().Add(-time.Second)); err != nil {
+						t.Errorf("Track() error = %v", err)
+					}
+					return
+				}
+				if _, err := scheduler.RunDue(ctx); err != nil {
+					t.Errorf("RunDue() error = %v", err)
+				}
+			})
+			if _, err := scheduler.RunDue(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if entries, err := store.List(); err != nil || len(entries) != 0 {
+				t.Errorf("after RunDue: %d entries pending, %v", len(entries), err)
+			}
+		})
+	}
+}
+
+func TestConcurrentTransports(t *testing.T) {
+	claim := testClaim(t)
+	va := newClaimVA(t, claim)
+	issuer := va.URL[len("https://"):]
+	path := filepath.Join(t.TempDir(), "trace.json")
+	recorder := NewRecordingTransport(path, va.Client().Transport)
+	opts := DefaultVerifyOptions()
+	opts.HTTPClient = &http.Client{Transport: recorder}
+	ctx := context.Background()
+
+	hammer(func(i int) {
+		if resp, err := FetchClaim(ctx, claim.ID, issuer, opts); err != nil || !resp.Valid {
+			t.Errorf("recorded FetchClaim() = %+v, %v", resp, err)
+		}
+		recorder.Exchanges()
+	})
+	if n := len(recorder.Exchanges()); n != raceGoroutines {
+		t.Fatalf("recorded %d exchanges, want %d", n, raceGoroutines)
+	}
+
+	replay, err := NewReplayTransport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.HTTPClient = &http.Client{Transport: replay}
+	hammer(func(i int) {
+		if resp, err := FetchClaim(ctx, claim.ID, issuer, opts); err != nil || !resp.Valid {
+			t.Errorf("replayed FetchClaim() = %+v, %v", resp, err)
+		}
+	})
+}
+
+func TestConcurrentHandlers(t *testing.T) {
+	claim := testClaim(t)
+	lookup := ClaimLookupFunc(func(_ context.Context, id string) (*VerificationResponse, error) {
+		if id != claim.ID {
+			return nil, nil
+		}
+		return &VerificationResponse{Valid: true, ID: claim.ID, Claim: claim}, nil
+	})
+	verify := NewVerifyHandler(lookup)
+	batch := NewBatchVerifyHandler(lookup, BatchHandlerOptions{IDsPerSecond: 1e6})
+	body, _ := json.Marshal(batchRequest{IDs: []string{claim.ID}})
+
+	hammer(func(i int) {
+		rec := httptest.NewRecorder()
+		if i%2 == 0 {
+			verify.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/verify/"+claim.ID, nil))
+		} else {
+			batch.ServeHTTP(rec, httptest.NewRequest("POST", "/api/v1/verify/batch", bytes.NewReader(body)))
+		}
+		if rec.Code != http.StatusOK {
+			t.Errorf("status = %d: %s", rec.Code, rec.Body)
+		}
+	})
+}
+
+func TestConcurrentRegistries(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	claim := testClaim(t)
+	jws, err := SignClaim(claim, priv, "key_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var notices sync.Map
+	SetDeprecationHandler(func(n DeprecationNotice) { notices.Store(n.Caller, n) })
+	defer SetDeprecationHandler(nil)
+
+	hammer(func(i int) {
+		switch i % 4 {
+		case 0:
+			if err := RegisterIssuerAlias(fmt.Sprintf("race%d", i), "ballista.jobs"); err != nil {
+				t.Errorf("RegisterIssuerAlias() error = %v", err)
+			}
+		case 1:
+			ResolveIssuerAlias(fmt.Sprintf("race%d", i-1))
+		case 2:
+			RegisterVersionShim("0.0-race", func(payload []byte) ([]byte, error) { return payload, nil })
+			if r := verifyJWS(jws, claim.Iss, []JWK{jwk}); !r.Valid {
+				t.Errorf("verifyJWS() = %s", r.Error)
+			}
+		case 3:
+			ExportPublicKeyJWK(priv.Public().(ed25519.PublicKey), "key_001")
+		}
+	})
+}
@@ -0,0 +1,446 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	humanattestation "github.com/Blue-Scroll/hap/packages/go"
+)
+
+// maxResponseBytes bounds the responses the raw checks read
+const maxResponseBytes = 1 << 20
+
+// runner holds the state of one RunConformance call
+type runner struct {
+	cfg    ConformanceConfig
+	client *http.Client
+	report *ConformanceReport
+
+	// Error responses, inspected again by the hygiene check
+	notFound      *response
+	invalidFormat *response
+}
+
+// response is a raw HTTP response with its body read
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (r *runner) add(name CheckName, status Status, detail, remediation string) {
+	if status == StatusPass || status == StatusSkip {
+		remediation = ""
+	}
+	r.report.Checks = append(r.report.Checks, Check{Name: name, Status: status, Detail: detail, Remediation: remediation})
+}
+
+// verifyOptions returns options routing the SDK's requests to the VA under
+// test. The key cache is disabled so every check sees the VA's current state.
+func (r *runner) verifyOptions() humanattestation.VerifyOptions {
+	return humanattestation.VerifyOptions{
+		HTTPClient:      r.client,
+		Timeout:         r.cfg.Timeout,
+		AllowCheckedIDs: true,
+		KeyCacheTTL:     -1,
+	}
+}
+
+// do makes a raw request to the VA under test
+func (r *runner) do(ctx context.Context, method, path string, header http.Header) (*response, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, "https://"+r.cfg.Issuer+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return &response{status: resp.StatusCode, header: resp.Header, body: body}, nil
+}
+
+// isJSON reports whether the response declares a JSON content type
+func (resp *response) isJSON() bool {
+	mediaType, _, err := mime.ParseMediaType(resp.header.Get("Content-Type"))
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+func (r *runner) run(ctx context.Context) {
+	resp, wk := r.checkWellKnownFetch(ctx)
+	r.checkWellKnownKeys(wk)
+	r.checkSDKKeyFetch(ctx)
+	r.checkCacheHeaders(resp)
+	r.checkConditionalRequests(ctx, resp)
+	r.checkVerifyValid(ctx)
+	r.checkVerifyRevoked(ctx)
+	r.checkVerifyExpired(ctx)
+	r.checkVerifyNotFound(ctx)
+	r.checkVerifyInvalidFormat(ctx)
+	r.checkErrorHygiene(ctx)
+}
+
+func (r *runner) checkWellKnownFetch(ctx context.Context) (*response, *humanattestation.WellKnown) {
+	const fix = "Serve the well-known document as JSON with HTTP 200 at /.well-known/hap.json (SPEC.md section 5.1)."
+	resp, err := r.do(ctx, "GET", "/.well-known/hap.json", nil)
+	if err != nil {
+		r.add(CheckWellKnownFetch, StatusFail, err.Error(), fix)
+		return nil, nil
+	}
+	if resp.status != http.StatusOK {
+		r.add(CheckWellKnownFetch, StatusFail, fmt.Sprintf("HTTP %d", resp.status), fix)
+		return nil, nil
+	}
+	var wk humanattestation.WellKnown
+	if err := json.Unmarshal(resp.body, &wk); err != nil {
+		r.add(CheckWellKnownFetch, StatusFail, fmt.Sprintf("invalid JSON: %v", err), fix)
+		return resp, nil
+	}
+	if !resp.isJSON() {
+		r.add(CheckWellKnownFetch, StatusWarn, fmt.Sprintf("Content-Type %q", resp.header.Get("Content-Type")),
+			"Serve the well-known document with Content-Type: application/json.")
+		return resp, &wk
+	}
+	r.add(CheckWellKnownFetch, StatusPass, "", "")
+	return resp, &wk
+}
+
+func (r *runner) checkWellKnownKeys(wk *humanattestation.WellKnown) {
+	if wk == nil {
+		r.add(CheckWellKnownKeys, StatusSkip, "well-known document unavailable", "")
+		return
+	}
+	if err := humanattestation.ValidateWellKnown(*wk); err != nil {
+		r.add(CheckWellKnownKeys, StatusFail, err.Error(),
+			"Publish at least one Ed25519 key (kty OKP, crv Ed25519) with a unique kid and use \"claims\" or no use.")
+		return
+	}
+	if !sameIssuer(wk.Issuer, r.cfg.Issuer) {
+		r.add(CheckWellKnownKeys, StatusFail, fmt.Sprintf("issuer %q, want %q", wk.Issuer, r.cfg.Issuer),
+			"Set issuer to the VA's domain, the same value as the iss of its claims.")
+		return
+	}
+	r.add(CheckWellKnownKeys, StatusPass, fmt.Sprintf("keys published: %d", len(wk.Keys)), "")
+}
+
+func (r *runner) checkSDKKeyFetch(ctx context.Context) {
+	if _, err := humanattestation.FetchPublicKeys(ctx, r.cfg.Issuer, r.verifyOptions()); err != nil {
+		r.add(CheckSDKKeyFetch, StatusFail, err.Error(),
+			"The SDK could not load the VA's keys; fix the well_known_fetch and well_known_keys checks first.")
+		return
+	}
+	r.add(CheckSDKKeyFetch, StatusPass, "", "")
+}
+
+func (r *runner) checkCacheHeaders(resp *response) {
+	const fix = "Serve the well-known document with Cache-Control: public, max-age=300 (or longer) so verifiers can cache keys."
+	if resp == nil {
+		r.add(CheckCacheHeaders, StatusSkip, "well-known document unavailable", "")
+		return
+	}
+	cacheControl := strings.Join(resp.header.Values("Cache-Control"), ", ")
+	if cacheControl == "" {
+		r.add(CheckCacheHeaders, StatusWarn, "no Cache-Control header", fix)
+		return
+	}
+	maxAge := int64(-1)
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, arg, _ := strings.Cut(strings.ToLower(strings.TrimSpace(directive)), "=")
+		switch name {
+		case "no-store", "no-cache":
+			r.add(CheckCacheHeaders, StatusWarn, "Cache-Control prevents caching: "+cacheControl, fix)
+			return
+		case "max-age":
+			if n, err := strconv.ParseInt(strings.Trim(arg, `"`), 10, 64); err == nil {
+				maxAge = n
+			}
+		}
+	}
+	if maxAge <= 0 {
+		r.add(CheckCacheHeaders, StatusWarn, "no positive max-age: "+cacheControl, fix)
+		return
+	}
+	r.add(CheckCacheHeaders, StatusPass, cacheControl, "")
+}
+
+func (r *runner) checkConditionalRequests(ctx context.Context, resp *response) {
+	const fix = "Send an ETag with the well-known document and answer a matching If-None-Match with 304 Not Modified."
+	if resp == nil {
+		r.add(CheckConditionalRequests, StatusSkip, "well-known document unavailable", "")
+		return
+	}
+	header := http.Header{}
+	switch etag, lastModified := resp.header.Get("ETag"), resp.header.Get("Last-Modified"); {
+	case etag != "":
+		header.Set("If-None-Match", etag)
+	case lastModified != "":
+		header.Set("If-Modified-Since", lastModified)
+	default:
+		r.add(CheckConditionalRequests, StatusWarn, "no ETag or Last-Modified", fix)
+		return
+	}
+	revalidated, err := r.do(ctx, "GET", "/.well-known/hap.json", header)
+	switch {
+	case err != nil:
+		r.add(CheckConditionalRequests, StatusFail, err.Error(), fix)
+	case revalidated.status == http.StatusNotModified:
+		r.add(CheckConditionalRequests, StatusPass, "", "")
+	case revalidated.status == http.StatusOK:
+		r.add(CheckConditionalRequests, StatusWarn, "conditional request for an unchanged document returned HTTP 200", fix)
+	default:
+		r.add(CheckConditionalRequests, StatusFail, fmt.Sprintf("conditional request returned HTTP %d", revalidated.status), fix)
+	}
+}
+
+// fetchClaim fetches id through the raw API and the SDK. It records a
+// failure for name and returns false if either fails or the status is not
+// wantStatus.
+func (r *runner) fetchClaim(ctx context.Context, name CheckName, id string, wantStatus int, fix string) (*humanattestation.VerificationResponse, bool) {
+	raw, err := r.do(ctx, "GET", "/api/v1/verify/"+url.PathEscape(id), nil)
+	if err != nil {
+		r.add(name, StatusFail, err.Error(), fix)
+		return nil, false
+	}
+	if raw.status != wantStatus {
+		r.add(name, StatusFail, fmt.Sprintf("HTTP %d, want %d", raw.status, wantStatus), fix)
+		return nil, false
+	}
+	vr, err := humanattestation.FetchClaim(ctx, id, r.cfg.Issuer, r.verifyOptions())
+	if err != nil {
+		r.add(name, StatusFail, err.Error(), fix)
+		return nil, false
+	}
+	return vr, true
+}
+
+// verifySigned checks vr's JWS with the SDK and returns the signed claim. It
+// records a failure for name and returns nil if verification fails.
+func (r *runner) verifySigned(ctx context.Context, name CheckName, id string, vr *humanattestation.VerificationResponse) *humanattestation.Claim {
+	if vr.Claim == nil || vr.JWS == "" {
+		r.add(name, StatusFail, "response has no claim or jws",
+			"Include the claim and the JWS it was signed as in the verification response.")
+		return nil
+	}
+	sig, err := humanattestation.VerifySignature(ctx, vr.JWS, r.cfg.Issuer, r.verifyOptions())
+	if err == nil && !sig.Valid {
+		err = fmt.Errorf("%s", sig.Error)
+	}
+	if err != nil {
+		r.add(name, StatusFail, err.Error(),
+			"Sign claims with EdDSA using a key published in the well-known document, with its kid in the JWS header.")
+		return nil
+	}
+	if sig.Claim.ID != id {
+		r.add(name, StatusFail, fmt.Sprintf("signed claim has id %q, want %q", sig.Claim.ID, id),
+			"Serve the JWS of the requested claim.")
+		return nil
+	}
+	return sig.Claim
+}
+
+func (r *runner) checkVerifyValid(ctx context.Context) {
+	id := r.cfg.KnownValidID
+	if id == "" {
+		r.add(CheckVerifyValid, StatusSkip, "no KnownValidID configured", "")
+		return
+	}
+	vr, ok := r.fetchClaim(ctx, CheckVerifyValid, id, http.StatusOK, "Serve active claims with HTTP 200 (SPEC.md section 5.2).")
+	if !ok {
+		return
+	}
+	if !vr.Valid {
+		r.add(CheckVerifyValid, StatusFail, fmt.Sprintf("valid is false (error %q)", vr.Error), "Return \"valid\": true for active claims.")
+		return
+	}
+	signed := r.verifySigned(ctx, CheckVerifyValid, id, vr)
+	if signed == nil {
+		return
+	}
+
+	var warnings []string
+	if humanattestation.IsClaimExpired(signed) {
+		warnings = append(warnings, "KnownValidID names an expired claim")
+	}
+	if a, b := mustJSON(vr.Claim), mustJSON(signed); !bytes.Equal(a, b) {
+		warnings = append(warnings, "claim field differs from the signed claim")
+	}
+	warnings = append(warnings, vr.Warnings...)
+	if len(warnings) > 0 {
+		r.add(CheckVerifyValid, StatusWarn, strings.Join(warnings, "; "),
+			"Serve the signed claim unchanged, issuer set to the VA's domain and verifyUrl an HTTPS URL on it.")
+		return
+	}
+	r.add(CheckVerifyValid, StatusPass, "", "")
+}
+
+func (r *runner) checkVerifyRevoked(ctx context.Context) {
+	id := r.cfg.KnownRevokedID
+	if id == "" {
+		r.add(CheckVerifyRevoked, StatusSkip, "no KnownRevokedID configured", "")
+		return
+	}
+	const fix = "Serve revoked claims with HTTP 200, \"valid\": false and \"revoked\": true (SPEC.md section 5.2)."
+	vr, ok := r.fetchClaim(ctx, CheckVerifyRevoked, id, http.StatusOK, fix)
+	if !ok {
+		return
+	}
+	if vr.Valid || !vr.Revoked {
+		r.add(CheckVerifyRevoked, StatusFail, fmt.Sprintf("valid %t, revoked %t", vr.Valid, vr.Revoked), fix)
+		return
+	}
+
+	var warnings []string
+	switch vr.RevocationReason {
+	case humanattestation.RevocationFraud, humanattestation.RevocationError,
+		humanattestation.RevocationLegal, humanattestation.RevocationUserRequest:
+	default:
+		warnings = append(warnings, fmt.Sprintf("unknown revocationReason %q", vr.RevocationReason))
+	}
+	if vr.RevokedAt == nil {
+		warnings = append(warnings, "no revokedAt")
+	}
+	if len(warnings) > 0 {
+		r.add(CheckVerifyRevoked, StatusWarn, strings.Join(warnings, "; "),
+			"Include revokedAt and a revocationReason of fraud, error, legal or user_request.")
+		return
+	}
+	r.add(CheckVerifyRevoked, StatusPass, "", "")
+}
+
+func (r *runner) checkVerifyExpired(ctx context.Context) {
+	id := r.cfg.KnownExpiredID
+	if id == "" {
+		r.add(CheckVerifyExpired, StatusSkip, "no KnownExpiredID configured", "")
+		return
+	}
+	vr, ok := r.fetchClaim(ctx, CheckVerifyExpired, id, http.StatusOK,
+		"Keep serving expired claims with HTTP 200 so verifiers can report them as expired rather than unknown.")
+	if !ok {
+		return
+	}
+	signed := r.verifySigned(ctx, CheckVerifyExpired, id, vr)
+	if signed == nil {
+		return
+	}
+	if !humanattestation.IsClaimExpired(signed) {
+		r.add(CheckVerifyExpired, StatusFail, fmt.Sprintf("claim exp %q has not passed", signed.Exp),
+			"Configure KnownExpiredID with a claim whose exp has passed.")
+		return
+	}
+	r.add(CheckVerifyExpired, StatusPass, "", "")
+}
+
+// checkErrorResponse checks that id gets the given status and error code
+func (r *runner) checkErrorResponse(ctx context.Context, name CheckName, id string, status int, code string) *response {
+	fix := fmt.Sprintf("Answer with HTTP %d and {\"valid\": false, \"error\": %q} (SPEC.md section 5.2).", status, code)
+	resp, err := r.do(ctx, "GET", "/api/v1/verify/"+url.PathEscape(id), nil)
+	if err != nil {
+		r.add(name, StatusFail, err.Error(), fix)
+		return nil
+	}
+	var vr humanattestation.VerificationResponse
+	switch {
+	case resp.status != status:
+		r.add(name, StatusFail, fmt.Sprintf("HTTP %d, want %d", resp.status, status), fix)
+	case json.Unmarshal(resp.body, &vr) != nil:
+		r.add(name, StatusFail, "response body is not JSON", fix)
+	case vr.Valid || vr.Error != code:
+		r.add(name, StatusFail, fmt.Sprintf("valid %t, error %q", vr.Valid, vr.Error), fix)
+	default:
+		r.add(name, StatusPass, "", "")
+	}
+	return resp
+}
+
+func (r *runner) checkVerifyNotFound(ctx context.Context) {
+	// A freshly generated ID is well-formed but unknown to the VA
+	id, err := humanattestation.GenerateID()
+	if err != nil {
+		r.add(CheckVerifyNotFound, StatusFail, err.Error(), "")
+		return
+	}
+	r.notFound = r.checkErrorResponse(ctx, CheckVerifyNotFound, id, http.StatusNotFound, "not_found")
+}
+
+func (r *runner) checkVerifyInvalidFormat(ctx context.Context) {
+	r.invalidFormat = r.checkErrorResponse(ctx, CheckVerifyInvalidFormat, "hap_short", http.StatusBadRequest, "invalid_format")
+}
+
+// checkErrorHygiene checks that error responses are JSON and that hostile
+// requests are refused rather than crashing the VA
+func (r *runner) checkErrorHygiene(ctx context.Context) {
+	var failures, warnings []string
+	for _, resp := range []*response{r.notFound, r.invalidFormat} {
+		if resp != nil && resp.status < 500 && !resp.isJSON() {
+			warnings = append(warnings, fmt.Sprintf("HTTP %d error response has Content-Type %q", resp.status, resp.header.Get("Content-Type")))
+		}
+	}
+
+	id, _ := humanattestation.GenerateID()
+	probes := []struct {
+		method, path, want string
+	}{
+		{"POST", "/api/v1/verify/" + id, "a 4xx refusal"},
+		{"GET", "/api/v1/verify/hap_" + strings.Repeat("a", 4096), "HTTP 400"},
+		{"GET", "/api/v1/verify/" + url.PathEscape("hap_<script>"), "HTTP 400"},
+	}
+	for _, p := range probes {
+		resp, err := r.do(ctx, p.method, p.path, nil)
+		label := p.method + " " + p.path
+		if len(label) > 60 {
+			label = label[:60] + "..."
+		}
+		switch {
+		case err != nil:
+			failures = append(failures, err.Error())
+		case resp.status >= 500:
+			failures = append(failures, fmt.Sprintf("%s returned HTTP %d, want %s", label, resp.status, p.want))
+		case resp.status < 400:
+			warnings = append(warnings, fmt.Sprintf("%s returned HTTP %d, want %s", label, resp.status, p.want))
+		}
+	}
+
+	const fix = "Refuse unsupported methods with 405 and malformed IDs with 400, and serve error bodies as application/json; never answer client errors with 5xx."
+	switch {
+	case len(failures) > 0:
+		r.add(CheckErrorHygiene, StatusFail, strings.Join(append(failures, warnings...), "; "), fix)
+	case len(warnings) > 0:
+		r.add(CheckErrorHygiene, StatusWarn, strings.Join(warnings, "; "), fix)
+	default:
+		r.add(CheckErrorHygiene, StatusPass, "", "")
+	}
+}
+
+// sameIssuer compares issuers case-insensitively after IDNA conversion
+func sameIssuer(a, b string) bool {
+	if strings.EqualFold(a, b) {
+		return true
+	}
+	asciiA, errA := humanattestation.DomainToASCII(a)
+	asciiB, errB := humanattestation.DomainToASCII(b)
+	return errA == nil && errB == nil && asciiA == asciiB
+}
+
+func mustJSON(v any) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
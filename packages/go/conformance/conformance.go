@@ -0,0 +1,179 @@
+// Package conformance checks that a Verification Authority can be consumed
+// by the Go SDK. It is meant for VAs implemented in other stacks: point
+// RunConformance at a deployment and it exercises the well-known document,
+// the verification API and their HTTP behavior, reporting each check as
+// pass, warn or fail with a hint on how to fix it.
+//
+// Checks go through the SDK's own fetch and verification code, so passing
+// them means the SDK can verify the VA's claims.
+package conformance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	humanattestation "github.com/Blue-Scroll/hap/packages/go"
+)
+
+// CheckName identifies one check in a ConformanceReport
+type CheckName string
+
+const (
+	CheckWellKnownFetch      CheckName = "well_known_fetch"
+	CheckWellKnownKeys       CheckName = "well_known_keys"
+	CheckSDKKeyFetch         CheckName = "sdk_key_fetch"
+	CheckCacheHeaders        CheckName = "cache_headers"
+	CheckConditionalRequests CheckName = "conditional_requests"
+	CheckVerifyValid         CheckName = "verify_valid"
+	CheckVerifyRevoked       CheckName = "verify_revoked"
+	CheckVerifyExpired       CheckName = "verify_expired"
+	CheckVerifyNotFound      CheckName = "verify_not_found"
+	CheckVerifyInvalidFormat CheckName = "verify_invalid_format"
+	CheckErrorHygiene        CheckName = "error_hygiene"
+)
+
+// Status is the outcome of a check. Warnings do not fail a report.
+type Status string
+
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Check is the outcome of one conformance check
+type Check struct {
+	Name        CheckName `json:"name"`
+	Status      Status    `json:"status"`
+	Detail      string    `json:"detail,omitempty"`
+	Remediation string    `json:"remediation,omitempty"` // How to fix a warning or failure
+}
+
+// ConformanceConfig describes the VA under test. The known IDs must name
+// claims the VA serves in the given state; checks whose ID is empty are
+// skipped.
+type ConformanceConfig struct {
+	// Issuer is the VA's domain, as it appears in the iss of its claims
+	Issuer string
+	// BaseURL is where the VA is reachable, such as a staging or local
+	// deployment (default: "https://" + Issuer). Requests the SDK makes to
+	// the issuer are sent here instead.
+	BaseURL string
+
+	KnownValidID   string
+	KnownRevokedID string
+	KnownExpiredID string
+
+	// HTTPClient is used for all requests (default: http.DefaultClient)
+	HTTPClient *http.Client
+	// Timeout bounds each request (default: humanattestation.DefaultTimeout)
+	Timeout time.Duration
+}
+
+// ConformanceReport is the result of RunConformance
+type ConformanceReport struct {
+	Issuer    string    `json:"issuer"`
+	BaseURL   string    `json:"baseUrl"`
+	Passed    bool      `json:"passed"` // No check failed
+	CheckedAt time.Time `json:"checkedAt"`
+	Checks    []Check   `json:"checks"`
+}
+
+// Check returns the check with the given name, or nil if it was not run
+func (r *ConformanceReport) Check(name CheckName) *Check {
+	for i := range r.Checks {
+		if r.Checks[i].Name == name {
+			return &r.Checks[i]
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes the report as indented JSON
+func (r *ConformanceReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// RunConformance runs every check against the VA described by cfg. Failed
+// checks are recorded in the report; an error is only returned when cfg
+// itself is invalid.
+func RunConformance(ctx context.Context, cfg ConformanceConfig) (*ConformanceReport, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("conformance: issuer is required")
+	}
+	if _, err := humanattestation.DomainToASCII(hostOnly(cfg.Issuer)); err != nil {
+		return nil, fmt.Errorf("conformance: invalid issuer: %w", err)
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = "https://" + cfg.Issuer
+	}
+	base, err := url.Parse(strings.TrimSuffix(cfg.BaseURL, "/"))
+	if err != nil || (base.Scheme != "https" && base.Scheme != "http") || base.Host == "" {
+		return nil, fmt.Errorf("conformance: invalid base URL %q", cfg.BaseURL)
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = humanattestation.DefaultTimeout
+	}
+
+	r := &runner{
+		cfg:    cfg,
+		client: redirectClient(cfg.HTTPClient, base),
+		report: &ConformanceReport{Issuer: cfg.Issuer, BaseURL: base.String(), CheckedAt: time.Now().UTC()},
+	}
+	r.run(ctx)
+
+	r.report.Passed = true
+	for _, c := range r.report.Checks {
+		if c.Status == StatusFail {
+			r.report.Passed = false
+		}
+	}
+	return r.report, nil
+}
+
+// redirectClient returns a copy of client whose requests for any host are
+// sent to base instead, so the SDK's issuer URLs reach the VA under test
+func redirectClient(client *http.Client, base *url.URL) *http.Client {
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	redirected := *client
+	redirected.Transport = roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = base.Scheme
+		req.URL.Host = base.Host
+		req.URL.Path = base.Path + req.URL.Path
+		req.URL.RawPath = ""
+		req.Host = ""
+		return transport.RoundTrip(req)
+	})
+	return &redirected
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// hostOnly strips a port from an issuer such as "localhost:8443"
+func hostOnly(issuer string) string {
+	if i := strings.LastIndexByte(issuer, ':'); i >= 0 && !strings.Contains(issuer[i:], "]") {
+		return issuer[:i]
+	}
+	return issuer
+}
@@ -0,0 +1,213 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	humanattestation "github.com/Blue-Scroll/hap/packages/go"
+)
+
+const testIssuer = "va.example"
+
+// testVA is a VA built from the SDK's own handlers, serving one valid, one
+// revoked and one expired claim
+type testVA struct {
+	*httptest.Server
+	cfg    ConformanceConfig
+	claims map[string]*humanattestation.VerificationResponse
+}
+
+func newTestVA(t *testing.T, wrap func(http.Handler) http.Handler) *testVA {
+	t.Helper()
+	priv, pub, err := humanattestation.GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := humanattestation.NewWellKnownStore(humanattestation.WellKnown{
+		Issuer: testIssuer,
+		Keys:   []humanattestation.JWK{humanattestation.ExportPublicKeyJWKWithUse(pub, "va_key_001", humanattestation.KeyUseClaims)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	va := &testVA{claims: make(map[string]*humanattestation.VerificationResponse)}
+	issue := func(exp time.Time) *humanattestation.VerificationResponse {
+		claim, err := humanattestation.CreateClaim(humanattestation.CreateClaimParams{
+			Method:        "xva_verified_mail",
+			Description:   "Verified mail packet",
+			RecipientName: "Acme Corp",
+			Domain:        "acme.com",
+			Issuer:        testIssuer,
+			ExpiresInDays: 730,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !exp.IsZero() {
+			claim.At = exp.Add(-time.Hour).UTC().Format(time.RFC3339)
+			claim.Exp = exp.UTC().Format(time.RFC3339)
+		}
+		jws, err := humanattestation.SignClaim(claim, priv, "va_key_001")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp := &humanattestation.VerificationResponse{Valid: true, ID: claim.ID, Claim: claim, JWS: jws, Issuer: testIssuer}
+		va.claims[claim.ID] = resp
+		return resp
+	}
+	valid := issue(time.Time{})
+	expired := issue(time.Now().Add(-time.Hour))
+	revoked := issue(time.Time{})
+	*revoked = humanattestation.VerificationResponse{
+		ID:               revoked.ID,
+		Revoked:          true,
+		RevocationReason: humanattestation.RevocationUserRequest,
+		RevokedAt:        &humanattestation.Timestamp{Time: time.Now().UTC().Truncate(time.Second)},
+		Issuer:           testIssuer,
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/.well-known/hap.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		store.ServeHTTP(w, r)
+	}))
+	mux.Handle("/api/v1/verify/", humanattestation.NewVerifyHandler(humanattestation.ClaimLookupFunc(
+		func(_ context.Context, hapID string) (*humanattestation.VerificationResponse, error) {
+			return va.claims[hapID], nil
+		})))
+	var handler http.Handler = mux
+	if wrap != nil {
+		handler = wrap(mux)
+	}
+
+	va.Server = httptest.NewTLSServer(handler)
+	t.Cleanup(va.Close)
+	va.cfg = ConformanceConfig{
+		Issuer:         testIssuer,
+		BaseURL:        va.URL,
+		KnownValidID:   valid.ID,
+		KnownRevokedID: revoked.ID,
+		KnownExpiredID: expired.ID,
+		HTTPClient:     va.Client(),
+	}
+	return va
+}
+
+func TestRunConformancePasses(t *testing.T) {
+	va := newTestVA(t, nil)
+	report, err := RunConformance(context.Background(), va.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range report.Checks {
+		if c.Status != StatusPass {
+			t.Errorf("%s = %s: %s", c.Name, c.Status, c.Detail)
+		}
+	}
+	if !report.Passed || len(report.Checks) != 11 {
+		t.Errorf("Passed = %t with %d checks", report.Passed, len(report.Checks))
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded ConformanceReport
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("report JSON does not round-trip: %v", err)
+	}
+	if decoded.Issuer != testIssuer || len(decoded.Checks) != len(report.Checks) {
+		t.Errorf("decoded report = %+v", decoded)
+	}
+}
+
+func TestRunConformanceSkipsUnconfiguredIDs(t *testing.T) {
+	va := newTestVA(t, nil)
+	cfg := va.cfg
+	cfg.KnownRevokedID, cfg.KnownExpiredID = "", ""
+	report, err := RunConformance(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []CheckName{CheckVerifyRevoked, CheckVerifyExpired} {
+		if c := report.Check(name); c == nil || c.Status != StatusSkip {
+			t.Errorf("%s = %+v, want skip", name, c)
+		}
+	}
+	if !report.Passed {
+		t.Error("skipped checks failed the report")
+	}
+}
+
+func TestRunConformanceFindsProblems(t *testing.T) {
+	var validID string
+	va := newTestVA(t, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/.well-known/hap.json":
+				// Not cacheable and no validators
+				w.Header().Set("Cache-Control", "no-store")
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(humanattestation.WellKnown{
+					Issuer: testIssuer,
+					Keys:   []humanattestation.JWK{{Kty: "OKP", Crv: "Ed25519", Kid: "va_key_001", X: "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}},
+				})
+			case "/api/v1/verify/" + validID:
+				next.ServeHTTP(w, r)
+			default:
+				// Everything else crashes
+				http.Error(w, "internal error", http.StatusInternalServerError)
+			}
+		})
+	})
+	validID = va.cfg.KnownValidID
+
+	report, err := RunConformance(context.Background(), va.cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Passed {
+		t.Error("report passed")
+	}
+	want := map[CheckName]Status{
+		CheckWellKnownFetch:      StatusPass,
+		CheckWellKnownKeys:       StatusPass,
+		CheckSDKKeyFetch:         StatusPass,
+		CheckCacheHeaders:        StatusWarn,
+		CheckConditionalRequests: StatusWarn,
+		CheckVerifyValid:         StatusFail, // Signed with a key that is not published
+		CheckVerifyRevoked:       StatusFail,
+		CheckVerifyExpired:       StatusFail,
+		CheckVerifyNotFound:      StatusFail,
+		CheckVerifyInvalidFormat: StatusFail,
+		CheckErrorHygiene:        StatusFail,
+	}
+	for name, status := range want {
+		c := report.Check(name)
+		if c == nil || c.Status != status {
+			t.Errorf("%s = %+v, want %s", name, c, status)
+			continue
+		}
+		if status != StatusPass && c.Remediation == "" {
+			t.Errorf("%s has no remediation", name)
+		}
+	}
+}
+
+func TestRunConformanceConfig(t *testing.T) {
+	for _, cfg := range []ConformanceConfig{
+		{},
+		{Issuer: "not a domain"},
+		{Issuer: testIssuer, BaseURL: "ftp://va.example"},
+	} {
+		if _, err := RunConformance(context.Background(), cfg); err == nil {
+			t.Errorf("RunConformance(%+v) succeeded", cfg)
+		}
+	}
+}
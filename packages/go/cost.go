@@ -0,0 +1,148 @@
+package humanattestation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// ErrUnknownCurrency is returned for currency codes missing from the ISO 4217 table
+var ErrUnknownCurrency = errors.New("unknown currency")
+
+// currencyExponents maps active ISO 4217 codes to their number of minor unit
+// digits, e.g. USD 2 (cents), JPY 0, KWD 3 (fils)
+var currencyExponents = func() map[string]int {
+	m := make(map[string]int)
+	for _, code := range strings.Fields(`
+		AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BMD BND BOB BOV
+		BRL BSD BTN BWP BYN BZD CAD CDF CHE CHF CHW CNY COP COU CRC CUP CVE CZK
+		DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD GTQ GYD HKD HNL
+		HTG HUF IDR ILS INR IRR JMD KES KGS KHR KPW KYD KZT LAK LBP LKR LRD LSL
+		MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MXV MYR MZN NAD NGN NIO
+		NOK NPR NZD PAB PEN PGK PHP PKR PLN QAR RON RSD RUB SAR SBD SCR SDG SEK
+		SGD SHP SLE SOS SRD SSP STN SVC SYP SZL THB TJS TMT TOP TRY TTD TWD TZS
+		UAH USD USN UYU UZS VED VES WST XCD XCG YER ZAR ZMW ZWG`) {
+		m[code] = 2
+	}
+	for _, code := range strings.Fields(`
+		BIF CLP DJF GNF ISK JPY KMF KRW PYG RWF UGX UYI VND VUV XAF XOF XPF`) {
+		m[code] = 0
+	}
+	for _, code := range strings.Fields(`BHD IQD JOD KWD LYD OMR TND`) {
+		m[code] = 3
+	}
+	m["CLF"] = 4
+	m["UYW"] = 4
+	return m
+}()
+
+// CurrencyExponent returns the number of minor unit digits of an ISO 4217
+// currency code, and false if the code is unknown
+func CurrencyExponent(currency string) (int, bool) {
+	exp, ok := currencyExponents[currency]
+	return exp, ok
+}
+
+// ValidateClaimCost checks that a cost uses a known ISO 4217 currency code
+// and a non-negative amount
+func ValidateClaimCost(cost ClaimCost) error {
+	if _, ok := CurrencyExponent(cost.Currency); !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownCurrency, cost.Currency)
+	}
+	if cost.Amount < 0 {
+		return fmt.Errorf("cost amount must not be negative: %d", cost.Amount)
+	}
+	return nil
+}
+
+// CostToDecimalString formats a cost's amount in major units using its
+// currency's minor unit exponent, e.g. 1500 USD as "15.00", 1500 JPY as "1500"
+// and 1500 KWD as "1.500". Amounts in unknown currencies are returned as-is.
+func CostToDecimalString(c ClaimCost) string {
+	exp, ok := CurrencyExponent(c.Currency)
+	if !ok || exp == 0 {
+		return strconv.Itoa(c.Amount)
+	}
+
+	sign := ""
+	digits := strconv.FormatInt(int64(c.Amount), 10)
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+// ParseCostDecimal parses an amount in major units, such as "15.00" or "15",
+// into a ClaimCost in the currency's smallest unit. It rejects unknown
+// currencies and more fractional digits than the currency has.
+func ParseCostDecimal(s, currency string) (ClaimCost, error) {
+	exp, ok := CurrencyExponent(currency)
+	if !ok {
+		return ClaimCost{}, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+
+	whole, frac, hasPoint := strings.Cut(s, ".")
+	if whole == "" || (hasPoint && frac == "") || !isDecimal(whole) || (frac != "" && !isDecimal(frac)) {
+		return ClaimCost{}, fmt.Errorf("invalid decimal amount: %q", s)
+	}
+	if len(frac) > exp {
+		return ClaimCost{}, fmt.Errorf("%s allows at most %d decimal places: %q", currency, exp, s)
+	}
+
+	amount, err := strconv.ParseInt(whole+frac+strings.Repeat("0", exp-len(frac)), 10, strconv.IntSize)
+	if err != nil {
+		return ClaimCost{}, fmt.Errorf("amount out of range: %q", s)
+	}
+	return ClaimCost{Amount: int(amount), Currency: currency}, nil
+}
+
+// ExchangeRateFunc returns how many major units of currency to one major unit
+// of currency from is worth. The package has no built-in rates.
+type ExchangeRateFunc func(from, to string) (float64, error)
+
+// MeetsMinCost reports whether cost is at least min, converting cost into
+// min's currency with rate when they differ. A nil cost never meets the
+// minimum. rate may be nil when both costs use the same currency.
+func MeetsMinCost(cost *ClaimCost, min ClaimCost, rate ExchangeRateFunc) (bool, error) {
+	if cost == nil {
+		return false, nil
+	}
+	if cost.Currency == min.Currency {
+		return cost.Amount >= min.Amount, nil
+	}
+
+	fromExp, ok := CurrencyExponent(cost.Currency)
+	if !ok {
+		return false, fmt.Errorf("%w: %q", ErrUnknownCurrency, cost.Currency)
+	}
+	toExp, ok := CurrencyExponent(min.Currency)
+	if !ok {
+		return false, fmt.Errorf("%w: %q", ErrUnknownCurrency, min.Currency)
+	}
+	if rate == nil {
+		return false, fmt.Errorf("no exchange rate for %s to %s", cost.Currency, min.Currency)
+	}
+
+	r, err := rate(cost.Currency, min.Currency)
+	if err != nil {
+		return false, fmt.Errorf("failed to get exchange rate for %s to %s: %w", cost.Currency, min.Currency, err)
+	}
+	if r <= 0 || math.IsInf(r, 0) || math.IsNaN(r) {
+		return false, fmt.Errorf("invalid exchange rate for %s to %s: %v", cost.Currency, min.Currency, r)
+	}
+
+	// Compare in major units with exact rational arithmetic
+	converted := new(big.Rat).Mul(majorUnits(cost.Amount, fromExp), new(big.Rat).SetFloat64(r))
+	return converted.Cmp(majorUnits(min.Amount, toExp)) >= 0, nil
+}
+
+// majorUnits converts an amount in minor units to major units
+func majorUnits(amount, exp int) *big.Rat {
+	return new(big.Rat).SetFrac(big.NewInt(int64(amount)), new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil))
+}
@@ -0,0 +1,135 @@
+package humanattestation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCostDecimalRoundTrip(t *testing.T) {
+	tests := []struct {
+		cost    ClaimCost
+		decimal string
+	}{
+		{ClaimCost{Amount: 1500, Currency: "USD"}, "15.00"},
+		{ClaimCost{Amount: 5, Currency: "USD"}, "0.05"},
+		{ClaimCost{Amount: 0, Currency: "USD"}, "0.00"},
+		{ClaimCost{Amount: 1500, Currency: "JPY"}, "1500"},
+		{ClaimCost{Amount: 1500, Currency: "KWD"}, "1.500"},
+		{ClaimCost{Amount: 7, Currency: "KWD"}, "0.007"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.cost.Currency+" "+tt.decimal, func(t *testing.T) {
+			if got := CostToDecimalString(tt.cost); got != tt.decimal {
+				t.Errorf("CostToDecimalString() = %q, want %q", got, tt.decimal)
+			}
+			got, err := ParseCostDecimal(tt.decimal, tt.cost.Currency)
+			if err != nil {
+				t.Fatalf("ParseCostDecimal() error = %v", err)
+			}
+			if got != tt.cost {
+				t.Errorf("ParseCostDecimal() = %+v, want %+v", got, tt.cost)
+			}
+		})
+	}
+}
+
+func TestCostDecimalUnknownCurrency(t *testing.T) {
+	cost := ClaimCost{Amount: 1500, Currency: "XYZ"}
+	if got := CostToDecimalString(cost); got != "1500" {
+		t.Errorf("CostToDecimalString() = %q, want raw amount", got)
+	}
+	if _, err := ParseCostDecimal("1500", "XYZ"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("ParseCostDecimal() error = %v, want ErrUnknownCurrency", err)
+	}
+}
+
+func TestParseCostDecimalRejects(t *testing.T) {
+	tests := []struct{ s, currency string }{
+		{"15.001", "USD"},
+		{"15.5", "JPY"},
+		{"1.0001", "KWD"},
+		{"", "USD"},
+		{".50", "USD"},
+		{"15.", "USD"},
+		{"-1.00", "USD"},
+		{"1,000.00", "USD"},
+		{"99999999999999999999", "JPY"},
+	}
+	for _, tt := range tests {
+		if got, err := ParseCostDecimal(tt.s, tt.currency); err == nil {
+			t.Errorf("ParseCostDecimal(%q, %s) = %+v, want error", tt.s, tt.currency, got)
+		}
+	}
+
+	// Fewer decimal places than the currency has are padded
+	got, err := ParseCostDecimal("15.5", "USD")
+	if err != nil || got.Amount != 1550 {
+		t.Errorf("ParseCostDecimal(15.5) = %+v, %v, want 1550", got, err)
+	}
+}
+
+func TestCreateClaimValidatesCurrency(t *testing.T) {
+	params := CreateClaimParams{Method: "m", Issuer: "ballista.jobs", Cost: &ClaimCost{Amount: 100, Currency: "usd"}}
+	if _, err := CreateClaim(params); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("CreateClaim() error = %v, want ErrUnknownCurrency", err)
+	}
+	params.Cost = &ClaimCost{Amount: -1, Currency: "USD"}
+	if _, err := CreateClaim(params); err == nil {
+		t.Error("CreateClaim() accepted a negative cost")
+	}
+	params.Cost = &ClaimCost{Amount: 100, Currency: "JPY"}
+	if _, err := CreateClaim(params); err != nil {
+		t.Errorf("CreateClaim() error = %v", err)
+	}
+}
+
+func TestMeetsMinCost(t *testing.T) {
+	fiveDollars := ClaimCost{Amount: 500, Currency: "USD"}
+	rates := func(from, to string) (float64, error) {
+		switch from + to {
+		case "JPYUSD":
+			return 0.01, nil
+		case "KWDUSD":
+			return 3.25, nil
+		}
+		return 0, errors.New("no rate")
+	}
+
+	tests := []struct {
+		name string
+		cost *ClaimCost
+		want bool
+	}{
+		{"nil cost", nil, false},
+		{"same currency above", &ClaimCost{Amount: 1500, Currency: "USD"}, true},
+		{"same currency equal", &ClaimCost{Amount: 500, Currency: "USD"}, true},
+		{"same currency below", &ClaimCost{Amount: 499, Currency: "USD"}, false},
+		// 500 yen at 0.01 is exactly $5; yen have no minor units
+		{"JPY equal", &ClaimCost{Amount: 500, Currency: "JPY"}, true},
+		{"JPY below", &ClaimCost{Amount: 499, Currency: "JPY"}, false},
+		// 1.500 KWD at 3.25 is $4.875; 1.540 KWD is $5.005
+		{"KWD below", &ClaimCost{Amount: 1500, Currency: "KWD"}, false},
+		{"KWD above", &ClaimCost{Amount: 1540, Currency: "KWD"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MeetsMinCost(tt.cost, fiveDollars, rates)
+			if err != nil {
+				t.Fatalf("MeetsMinCost() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("MeetsMinCost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := MeetsMinCost(&ClaimCost{Amount: 100, Currency: "EUR"}, fiveDollars, rates); err == nil {
+		t.Error("MeetsMinCost() without a rate = nil error")
+	}
+	if _, err := MeetsMinCost(&ClaimCost{Amount: 100, Currency: "EUR"}, fiveDollars, nil); err == nil {
+		t.Error("MeetsMinCost() with nil rate func = nil error")
+	}
+	if _, err := MeetsMinCost(&ClaimCost{Amount: 100, Currency: "XYZ"}, fiveDollars, rates); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("MeetsMinCost() error = %v, want ErrUnknownCurrency", err)
+	}
+}
@@ -0,0 +1,63 @@
+package humanattestation
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// DeprecationNotice describes a call to a deprecated API
+type DeprecationNotice struct {
+	API         string // Deprecated function that was called
+	Replacement string // API to migrate to
+	Caller      string // file:line of the call site
+}
+
+// String formats the notice for logging
+func (n DeprecationNotice) String() string {
+	return fmt.Sprintf("%s: %s is deprecated, use %s instead", n.Caller, n.API, n.Replacement)
+}
+
+var (
+	deprecationMu      sync.Mutex
+	deprecationHandler func(DeprecationNotice)
+	deprecationSeen    map[uintptr]bool
+)
+
+// SetDeprecationHandler installs fn to receive a notice the first time each
+// call site uses a deprecated API. Notices are off by default; passing nil
+// turns them off again. Installing a handler resets which call sites have
+// been reported.
+//
+// fn is called synchronously from the deprecated function, outside any
+// lock, so it may be called from several goroutines at once and may itself
+// call SetDeprecationHandler.
+func SetDeprecationHandler(fn func(DeprecationNotice)) {
+	deprecationMu.Lock()
+	defer deprecationMu.Unlock()
+	deprecationHandler = fn
+	deprecationSeen = make(map[uintptr]bool)
+}
+
+// deprecated reports a call to api from the caller of the deprecated function
+func deprecated(api, replacement string) {
+	deprecationMu.Lock()
+	handler := deprecationHandler
+	// Skip runtime.Callers, deprecated and the deprecated function itself
+	var pcs [1]uintptr
+	report := handler != nil && runtime.Callers(3, pcs[:]) > 0 && !deprecationSeen[pcs[0]]
+	if report {
+		deprecationSeen[pcs[0]] = true
+	}
+	deprecationMu.Unlock()
+	if !report {
+		return
+	}
+
+	frame, _ := runtime.CallersFrames(pcs[:]).Next()
+	handler(DeprecationNotice{
+		API:         api,
+		Replacement: replacement,
+		Caller:      fmt.Sprintf("%s:%d", frame.File, frame.Line),
+	})
+}
@@ -0,0 +1,150 @@
+package humanattestation
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// legacyAPI stands in for a deprecated function
+func legacyAPI() {
+	deprecated("legacyAPI", "currentAPI")
+}
+
+// recordDeprecations installs a handler collecting notices for the rest of the test
+func recordDeprecations(t *testing.T) func() []DeprecationNotice {
+	t.Helper()
+	var mu sync.Mutex
+	var notices []DeprecationNotice
+	SetDeprecationHandler(func(n DeprecationNotice) {
+		mu.Lock()
+		defer mu.Unlock()
+		notices = append(notices, n)
+	})
+	t.Cleanup(func() { SetDeprecationHandler(nil) })
+	return func() []DeprecationNotice {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]DeprecationNotice(nil), notices...)
+	}
+}
+
+func TestDeprecationNoticeOncePerCallSite(t *testing.T) {
+	notices := recordDeprecations(t)
+
+	for i := 0; i < 3; i++ {
+		legacyAPI()
+	}
+	legacyAPI()
+
+	got := notices()
+	if len(got) != 2 {
+		t.Fatalf("got %d notices, want 2 (one per call site): %v", len(got), got)
+	}
+	for _, n := range got {
+		if n.API != "legacyAPI" || n.Replacement != "currentAPI" {
+			t.Errorf("notice = %+v", n)
+		}
+		if !strings.Contains(n.Caller, "deprecation_test.go:") {
+			t.Errorf("notice caller = %q, want this file", n.Caller)
+		}
+	}
+	if got[0].Caller == got[1].Caller {
+		t.Errorf("distinct call sites reported the same caller %q", got[0].Caller)
+	}
+}
+
+func TestDeprecationNoticeCurrentAPIs(t *testing.T) {
+	notices := recordDeprecations(t)
+	claim := testClaim(t)
+	priv, pub, _ := GenerateKeyPair()
+
+	// Version 1 stays the default for now, so none of these is deprecated
+	if _, err := SignCompact(claim, priv); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BuildCompactPayload(claim); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EncodeCompact(claim, make([]byte, 64)); err != nil {
+		t.Fatal(err)
+	}
+	ExportPublicKeyJWK(pub, "key_001")
+	if _, err := SignCompactBatchCtx(context.Background(), []*Claim{claim, claim}, priv, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := notices(); len(got) != 0 {
+		t.Errorf("notices = %v, want none", got)
+	}
+}
+
+func TestDeprecationNoticeVerifySignatureOption(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+	claim, _ := va.Issue(t, priv, "key_001")
+	notices := recordDeprecations(t)
+
+	opts := va.Options()
+	if _, err := VerifyClaim(context.Background(), claim.ID, va.Issuer(), opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := notices(); len(got) != 0 {
+		t.Fatalf("notices without VerifySignature = %v, want none", got)
+	}
+
+	opts.VerifySignature = true
+	if _, err := VerifyClaim(context.Background(), claim.ID, va.Issuer(), opts); err != nil {
+		t.Fatal(err)
+	}
+	got := notices()
+	if len(got) != 1 {
+		t.Fatalf("got %d notices, want 1: %v", len(got), got)
+	}
+	if got[0].API != "VerifyOptions.VerifySignature" || got[0].Replacement != "VerifyOptions.SkipSignatureVerification" {
+		t.Errorf("notice = %+v", got[0])
+	}
+	if !strings.Contains(got[0].Caller, "deprecation_test.go:") {
+		t.Errorf("notice caller = %q, want this file", got[0].Caller)
+	}
+}
+
+func TestDeprecationNoticeConcurrent(t *testing.T) {
+	notices := recordDeprecations(t)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			legacyAPI()
+		}()
+	}
+	wg.Wait()
+
+	if got := notices(); len(got) != 1 {
+		t.Errorf("got %d notices from one call site, want 1", len(got))
+	}
+}
+
+func TestDeprecationHandlerDefaultOff(t *testing.T) {
+	// Must not panic or report without a handler installed
+	legacyAPI()
+}
+
+func TestDeprecationHandlerReentrant(t *testing.T) {
+	calls := 0
+	SetDeprecationHandler(func(DeprecationNotice) {
+		calls++
+		// Would deadlock if the handler ran under the lock
+		SetDeprecationHandler(nil)
+	})
+	t.Cleanup(func() { SetDeprecationHandler(nil) })
+
+	legacyAPI()
+	legacyAPI()
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
@@ -0,0 +1,63 @@
+package humanattestation
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// deterministicIssuedAt is the issue time of every deterministic claim
+var deterministicIssuedAt = time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// DeterministicTestKey derives the signing key and its JWK used by
+// DeterministicCompact for seed.
+//
+// For testing only: anyone who knows the seed can sign claims with this key.
+func DeterministicTestKey(seed []byte) (ed25519.PrivateKey, JWK) {
+	keySeed := deriveFromSeed("hap-deterministic-key", seed)
+	priv := ed25519.NewKeyFromSeed(keySeed[:])
+	kid := "test_" + hex.EncodeToString(keySeed[:4])
+	return priv, ExportPublicKeyJWKWithUse(priv.Public().(ed25519.PublicKey), kid, KeyUseClaims)
+}
+
+// DeterministicCompact creates and signs a claim whose key, ID and timestamps
+// are all derived from seed, so the same seed and params always produce a
+// byte-identical version 2 compact string. Use DeterministicTestKey to get
+// the matching public key.
+//
+// For testing only, e.g. golden files in interop suites. The claim is issued
+// at a fixed time in the past and the signing key is derivable from the seed,
+// so the output must never be accepted in production.
+func DeterministicCompact(seed []byte, params CreateClaimParams) (string, error) {
+	claim, err := CreateClaim(params)
+	if err != nil {
+		return "", err
+	}
+
+	idBytes := deriveFromSeed("hap-deterministic-id", seed)
+	suffix := make([]byte, 12)
+	for i := range suffix {
+		suffix[i] = IDChars[int(idBytes[i])%len(IDChars)]
+	}
+	claim.ID = "hap_" + string(suffix)
+
+	claim.At = deterministicIssuedAt.Format(time.RFC3339)
+	if params.ExpiresInDays > 0 {
+		claim.Exp = deterministicIssuedAt.AddDate(0, 0, params.ExpiresInDays).Format(time.RFC3339)
+	}
+
+	priv, jwk := DeterministicTestKey(seed)
+	return SignCompact(claim, priv, CompactOptions{Version: CompactVersionV2, Kid: jwk.Kid})
+}
+
+// deriveFromSeed hashes seed under a label so each derived value is independent
+func deriveFromSeed(label string, seed []byte) [32]byte {
+	h := sha256.New()
+	h.Write([]byte(label))
+	h.Write([]byte{0})
+	h.Write(seed)
+	var out [32]byte
+	copy(out[:], h.Sum(nil))
+	return out
+}
@@ -0,0 +1,38 @@
+package humanattestation
+
+import "testing"
+
+func TestDeterministicCompact(t *testing.T) {
+	params := CreateClaimParams{
+		Method:        "ba_priority_mail",
+		RecipientName: "Acme Corp",
+		Domain:        "acme.com",
+		Issuer:        "ballista.jobs",
+		ExpiresInDays: 730,
+	}
+
+	first, err := DeterministicCompact([]byte("golden-seed"), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := DeterministicCompact([]byte("golden-seed"), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("same seed produced different compact strings:\n%s\n%s", first, second)
+	}
+
+	other, err := DeterministicCompact([]byte("other-seed"), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == first {
+		t.Error("different seeds produced the same compact string")
+	}
+
+	_, jwk := DeterministicTestKey([]byte("golden-seed"))
+	if result := VerifyCompact(first, []JWK{jwk}); !result.Valid {
+		t.Errorf("deterministic compact does not verify: %s", result.Error)
+	}
+}
@@ -0,0 +1,221 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// issuerRecordPrefix is the DNS label under which issuer records are published
+const issuerRecordPrefix = "_hap."
+
+// issuerRecordVersion tags TXT records that name a HAP issuer
+const issuerRecordVersion = "v=HAP1"
+
+// TXTResolver looks up DNS TXT records. *net.Resolver implements it.
+type TXTResolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// DiscoverIssuer looks up the authoritative issuer domain for hint from a
+// TXT record at _hap.<hint> of the form "v=HAP1; iss=<domain>". Other TXT
+// records at that name are ignored. If no HAP record exists, hint itself is
+// returned.
+func DiscoverIssuer(ctx context.Context, hint string, opts ...VerifyOptions) (string, error) {
+	var opt VerifyOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	host, err := hostToASCII(hint)
+	if err != nil {
+		return "", err
+	}
+	records, err := opt.resolver().LookupTXT(ctx, issuerRecordPrefix+host)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return hint, nil
+		}
+		return "", fmt.Errorf("failed to look up issuer record: %w", err)
+	}
+
+	issuer := ""
+	for _, record := range records {
+		iss, ok := parseIssuerRecord(record)
+		if !ok {
+			continue
+		}
+		if iss == "" {
+			return "", fmt.Errorf("issuer record for %s missing iss", hint)
+		}
+		if issuer != "" && iss != issuer {
+			return "", fmt.Errorf("conflicting issuer records for %s", hint)
+		}
+		issuer = iss
+	}
+	if issuer == "" {
+		return hint, nil
+	}
+
+	if err := ValidateDomain(issuer); err != nil {
+		return "", fmt.Errorf("invalid issuer record for %s: %w", hint, err)
+	}
+	return issuer, nil
+}
+
+// parseIssuerRecord extracts the issuer from a "v=HAP1; iss=<domain>" record,
+// reporting false if the record is not a HAP record
+func parseIssuerRecord(record string) (string, bool) {
+	tags, ok := parseRecordTags(record)
+	return tags["iss"], ok
+}
+
+// parseRecordTags returns the tags of a "v=HAP1; <key>=<value>; ..."
+// record, keeping the first value of a repeated key, and reports false if
+// the record is not a HAP record
+func parseRecordTags(record string) (map[string]string, bool) {
+	tags := strings.Split(record, ";")
+	if strings.TrimSpace(tags[0]) != issuerRecordVersion {
+		return nil, false
+	}
+	values := make(map[string]string)
+	for _, tag := range tags[1:] {
+		key, value, ok := strings.Cut(strings.TrimSpace(tag), "=")
+		key = strings.TrimSpace(key)
+		if _, seen := values[key]; ok && !seen {
+			values[key] = strings.TrimSpace(value)
+		}
+	}
+	return values, true
+}
+
+// resolver returns the options' Resolver, or net.DefaultResolver
+func (o VerifyOptions) resolver() TXTResolver {
+	if o.Resolver != nil {
+		return o.Resolver
+	}
+	return net.DefaultResolver
+}
+
+// fetchKeysFromDNS reads an issuer's keys from a TXT record at
+// _hap.<issuer> of the form "v=HAP1; keys=<base64url JSON key set>" or
+// "v=HAP1; keys-url=<HTTPS URL of a key set>", for issuers that cannot
+// serve a well-known document. A key set is a well-known document, of which
+// only keys is required. The keys-url must be on the issuer's host or a
+// subdomain of it.
+func fetchKeysFromDNS(ctx context.Context, issuerDomain string, opts VerifyOptions) (*wellKnownFetch, error) {
+	host, err := hostToASCII(issuerDomain)
+	if err != nil {
+		return nil, err
+	}
+	name := issuerRecordPrefix + host
+	records, err := opts.resolver().LookupTXT(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, fmt.Errorf("failed to fetch public keys: HTTP 404 and no DNS key record at %s", name)
+		}
+		return nil, fmt.Errorf("failed to look up DNS key record: %w", err)
+	}
+
+	var inline, keysURL string
+	found := 0
+	for _, record := range records {
+		tags, ok := parseRecordTags(record)
+		if !ok || tags["keys"] == "" && tags["keys-url"] == "" {
+			continue
+		}
+		inline, keysURL = tags["keys"], tags["keys-url"]
+		found++
+	}
+	switch {
+	case found == 0:
+		return nil, fmt.Errorf("failed to fetch public keys: HTTP 404 and no DNS key record at %s", name)
+	case found > 1:
+		return nil, fmt.Errorf("conflicting DNS key records at %s", name)
+	case inline != "" && keysURL != "":
+		return nil, fmt.Errorf("DNS key record at %s has both keys and keys-url", name)
+	}
+
+	fetchedAt := opts.now()
+	fetched := &wellKnownFetch{provenance: &KeyProvenance{Source: KeySourceDNSFallback, URL: "dns:" + name, FetchedAt: &fetchedAt}}
+	var data []byte
+	if keysURL != "" {
+		fetched.provenance.URL = keysURL
+		data, err = fetchKeySet(ctx, keysURL, host, opts)
+	} else {
+		data, err = base64urlDecode(inline)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS key record at %s: %w", name, err)
+	}
+
+	var wellKnown WellKnown
+	if err := json.Unmarshal(data, &wellKnown); err != nil {
+		return nil, fmt.Errorf("invalid DNS key record at %s: failed to parse key set: %w", name, err)
+	}
+	if len(wellKnown.Keys) == 0 {
+		return nil, fmt.Errorf("invalid DNS key record at %s: no keys", name)
+	}
+	if wellKnown.Issuer == "" {
+		wellKnown.Issuer = issuerDomain
+	} else if !sameDomain(wellKnown.Issuer, issuerDomain) {
+		return nil, fmt.Errorf("DNS key record at %s names issuer %s", name, wellKnown.Issuer)
+	}
+	wellKnown.Discovery = KeySourceDNSFallback
+	fetched.wellKnown = &wellKnown
+	return fetched, nil
+}
+
+// fetchKeySet fetches the key set a DNS key record for the ASCII issuer host
+// points to. Anyone who can spoof the record could name any URL, so only
+// HTTPS URLs on the issuer's own domain are fetched, whatever
+// AllowInsecureHTTP says.
+func fetchKeySet(ctx context.Context, keysURL, host string, opts VerifyOptions) ([]byte, error) {
+	u, err := url.Parse(keysURL)
+	if err != nil || u.Host == "" || u.Scheme != "https" {
+		return nil, fmt.Errorf("keys-url %q is not an HTTPS URL", keysURL)
+	}
+	issuer, _, err := net.SplitHostPort(host)
+	if err != nil {
+		issuer = host
+	}
+	keysHost, err := hostToASCII(u.Hostname())
+	if err != nil || keysHost != issuer && !strings.HasSuffix(keysHost, "."+issuer) {
+		return nil, fmt.Errorf("keys-url %q is not on the issuer's domain %s", keysURL, issuer)
+	}
+	if err := opts.meter.keyFetch(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", keysURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch key set: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch key set: HTTP %d", resp.StatusCode)
+	}
+	return opts.meter.readAll(resp.Body)
+}
+
+// VerifyWithDiscovery discovers the issuer for hint with DiscoverIssuer and
+// then verifies the claim with VerifyClaim
+func VerifyWithDiscovery(ctx context.Context, hapID, hint string, opts ...VerifyOptions) (*Claim, error) {
+	issuer, err := DiscoverIssuer(ctx, hint, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return VerifyClaim(ctx, hapID, issuer, opts...)
+}
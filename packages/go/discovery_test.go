@@ -0,0 +1,227 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeResolver serves TXT records from a map, reporting missing names as not found
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	records, ok := r[name]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+// failingResolver fails every lookup with a temporary error
+type failingResolver struct{}
+
+func (failingResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+}
+
+func TestDiscoverIssuer(t *testing.T) {
+	resolver := fakeResolver{
+		"_hap.acme.com":     {"v=spf1 -all", "v=HAP1; iss=ballista.jobs"},
+		"_hap.other.com":    {"v=spf1 -all"},
+		"_hap.conflict.com": {"v=HAP1; iss=ballista.jobs", "v=HAP1; iss=evil.example"},
+		"_hap.noiss.com":    {"v=HAP1"},
+		"_hap.invalid.com":  {"v=HAP1; iss=https://ballista.jobs/"},
+	}
+	opts := VerifyOptions{Resolver: resolver}
+	ctx := context.Background()
+
+	tests := []struct {
+		hint    string
+		want    string
+		wantErr bool
+	}{
+		{hint: "acme.com", want: "ballista.jobs"},
+		{hint: "other.com", want: "other.com"},
+		{hint: "missing.com", want: "missing.com"},
+		{hint: "conflict.com", wantErr: true},
+		{hint: "noiss.com", wantErr: true},
+		{hint: "invalid.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hint, func(t *testing.T) {
+			got, err := DiscoverIssuer(ctx, tt.hint, opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DiscoverIssuer() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("DiscoverIssuer() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var dnsErr *net.DNSError
+	if _, err := DiscoverIssuer(ctx, "acme.com", VerifyOptions{Resolver: failingResolver{}}); !errors.As(err, &dnsErr) {
+		t.Errorf("DiscoverIssuer() error = %v, want DNS error", err)
+	}
+}
+
+func TestVerifyWithDiscoveryFallback(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	claim, _ := va.Issue(t, priv, "claims_001")
+
+	opts := va.Options()
+	opts.Resolver = fakeResolver{}
+
+	got, err := VerifyWithDiscovery(context.Background(), claim.ID, va.Issuer(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.ID != claim.ID {
+		t.Errorf("VerifyWithDiscovery() = %+v, want claim %s", got, claim.ID)
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestDNSKeyDiscovery(t *testing.T) {
+	_, jwk := testKeys(t, "claims_001")
+	keySet, err := json.Marshal(WellKnown{Keys: []JWK{jwk}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keys.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(keySet)
+	})
+	mux.HandleFunc("/hosted/.well-known/hap.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(WellKnown{Issuer: "hosted.example", Keys: []JWK{jwk}})
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	inline := "v=HAP1; keys=" + base64urlEncode(keySet)
+	resolver := fakeResolver{
+		"_hap.inline.example":   {"v=spf1 -all", inline},
+		"_hap.url.example":      {"v=HAP1; keys-url=https://url.example/keys.json"},
+		"_hap.sub.example":      {"v=HAP1; keys-url=https://keys.sub.example/keys.json"},
+		"_hap.conflict.example": {inline, "v=HAP1; keys-url=https://conflict.example/keys.json"},
+		"_hap.both.example":     {inline + "; keys-url=https://both.example/keys.json"},
+		"_hap.issonly.example":  {"v=HAP1; iss=ballista.jobs"},
+		"_hap.hosted.example":   {inline},
+		"_hap.offsite.example":  {"v=HAP1; keys-url=https://elsewhere.example/keys.json"},
+		"_hap.suffix.example":   {"v=HAP1; keys-url=https://notsuffix.example/keys.json"},
+		"_hap.plain.example":    {"v=HAP1; keys-url=http://plain.example/keys.json"},
+	}
+	// Every request reaches srv, whatever host it names
+	toServer := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r = r.Clone(r.Context())
+		r.URL.Scheme, r.URL.Host = "http", strings.TrimPrefix(srv.URL, "http://")
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+	opts := VerifyOptions{
+		HTTPClient:         toServer,
+		Resolver:           resolver,
+		EnableDNSDiscovery: true,
+		AllowInsecureHTTP:  true,
+		KeyCacheTTL:        -1,
+		IssuerBaseURL: func(issuer string) string {
+			if issuer == "hosted.example" {
+				return srv.URL + "/hosted"
+			}
+			return srv.URL
+		},
+	}
+	ctx := context.Background()
+
+	tests := []struct {
+		issuer  string
+		source  KeySource
+		url     string
+		wantErr bool
+	}{
+		{"inline.example", KeySourceDNSFallback, "dns:_hap.inline.example", false},
+		{"url.example", KeySourceDNSFallback, "https://url.example/keys.json", false},
+		{"sub.example", KeySourceDNSFallback, "https://keys.sub.example/keys.json", false},
+		{"hosted.example", KeySourceNetwork, srv.URL + "/hosted/.well-known/hap.json", false},
+		{"conflict.example", "", "", true},
+		{"both.example", "", "", true},
+		{"issonly.example", "", "", true},
+		{"missing.example", "", "", true},
+		// A keys-url must be HTTPS on the issuer's domain, even with AllowInsecureHTTP
+		{"offsite.example", "", "", true},
+		{"suffix.example", "", "", true},
+		{"plain.example", "", "", true},
+	}
+	for _, tt := range tests {
+		wellKnown, provenance, err := fetchPublicKeys(ctx, tt.issuer, opts)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: fetchPublicKeys() = %+v, want error", tt.issuer, wellKnown)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: fetchPublicKeys() error = %v", tt.issuer, err)
+			continue
+		}
+		if wellKnown.Discovery != tt.source || provenance.Source != tt.source || provenance.URL != tt.url {
+			t.Errorf("%s: discovery = %q, provenance = %+v, want %q from %s", tt.issuer, wellKnown.Discovery, provenance, tt.source, tt.url)
+		}
+		if wellKnown.Issuer != tt.issuer || len(wellKnown.Keys) != 1 || wellKnown.Keys[0].X != jwk.X {
+			t.Errorf("%s: well-known = %+v", tt.issuer, wellKnown)
+		}
+	}
+
+	// Off by default: a missing well-known document is an error
+	opts.EnableDNSDiscovery = false
+	if _, err := FetchPublicKeys(ctx, "inline.example", opts); err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("FetchPublicKeys() without EnableDNSDiscovery: error = %v, want HTTP 404", err)
+	}
+
+}
+
+func TestDNSKeysCachedSeparately(t *testing.T) {
+	_, jwk := testKeys(t, "claims_001")
+	keySet, err := json.Marshal(WellKnown{Keys: []JWK{jwk}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notFound := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody, Request: r}, nil
+	})}
+	cache := NewPublicKeyCache(time.Hour)
+	dnsOpts := VerifyOptions{
+		HTTPClient:         notFound,
+		Resolver:           fakeResolver{"_hap.inline.example": {"v=HAP1; keys=" + base64urlEncode(keySet)}},
+		EnableDNSDiscovery: true,
+		KeyCache:           cache,
+	}
+	ctx := context.Background()
+
+	if _, provenance, err := fetchPublicKeys(ctx, "inline.example", dnsOpts); err != nil || provenance.Source != KeySourceDNSFallback {
+		t.Fatalf("fetchPublicKeys() = %+v, %v", provenance, err)
+	}
+	// Served from the cache, the keys still say they came from DNS
+	if _, provenance, err := fetchPublicKeys(ctx, "inline.example", dnsOpts); err != nil || provenance.Source != KeySourceDNSFallback {
+		t.Errorf("cached fetchPublicKeys() = %+v, %v, want DNS provenance", provenance, err)
+	}
+
+	// A caller that did not enable DNS discovery never gets them
+	plain := VerifyOptions{HTTPClient: notFound, KeyCache: cache}
+	if wk, err := FetchPublicKeys(ctx, "inline.example", plain); err == nil || !strings.Contains(err.Error(), "HTTP 404") {
+		t.Errorf("FetchPublicKeys() without EnableDNSDiscovery = %+v, %v, want HTTP 404", wk, err)
+	}
+}
@@ -0,0 +1,222 @@
+package humanattestation
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// domainProfile validates domains under IDNA 2008 lookup rules with STD3
+// (letter-digit-hyphen) label restrictions and DNS length limits
+var domainProfile = idna.New(
+	idna.MapForLookup(),
+	idna.BidiRule(),
+	idna.ValidateLabels(true),
+	idna.VerifyDNSLength(true),
+	idna.StrictDomainName(true),
+)
+
+// ValidateDomain checks that s is a bare, publicly meaningful domain name
+// suitable for a claim recipient: no scheme, path, port or whitespace, at least
+// two labels, RFC 1035 label syntax and length limits. Internationalized
+// domains are accepted in Unicode or punycode (xn--) form.
+func ValidateDomain(s string) error {
+	if s == "" {
+		return errors.New("domain is empty")
+	}
+	if strings.Contains(s, "://") {
+		return fmt.Errorf("domain %q must not include a scheme", s)
+	}
+	if strings.ContainsAny(s, "/?#") {
+		return fmt.Errorf("domain %q must not include a path", s)
+	}
+	if strings.Contains(s, ":") {
+		return fmt.Errorf("domain %q must not include a port", s)
+	}
+	if strings.IndexFunc(s, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("domain %q must not contain whitespace", s)
+	}
+	if strings.HasSuffix(s, ".") {
+		return fmt.Errorf("domain %q must not have a trailing dot", s)
+	}
+	if net.ParseIP(s) != nil {
+		return fmt.Errorf("domain %q is an IP address", s)
+	}
+
+	ascii, err := domainProfile.ToASCII(s)
+	if err != nil {
+		return fmt.Errorf("invalid domain %q: %w", s, err)
+	}
+	if !strings.Contains(ascii, ".") {
+		return fmt.Errorf("domain %q is a single label", s)
+	}
+
+	// UTS 46 mapping accepts symbols (including emoji) that IDNA 2008 disallows
+	unicodeForm, err := domainProfile.ToUnicode(ascii)
+	if err != nil {
+		return fmt.Errorf("invalid domain %q: %w", s, err)
+	}
+	if strings.IndexFunc(unicodeForm, unicode.IsSymbol) >= 0 {
+		return fmt.Errorf("domain %q contains symbols not permitted in IDNA 2008", s)
+	}
+	if err := checkLabelScripts(unicodeForm); err != nil {
+		return fmt.Errorf("invalid domain %q: %w", s, err)
+	}
+
+	return nil
+}
+
+// DomainToASCII converts a domain to the lowercase ASCII (punycode) form used
+// on the network and for comparisons. Labels mixing scripts, a common
+// spoofing technique, are rejected.
+func DomainToASCII(s string) (string, error) {
+	ascii, err := domainProfile.ToASCII(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain %q: %w", s, err)
+	}
+	unicodeForm, err := domainProfile.ToUnicode(ascii)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain %q: %w", s, err)
+	}
+	if err := checkLabelScripts(unicodeForm); err != nil {
+		return "", fmt.Errorf("invalid domain %q: %w", s, err)
+	}
+	return ascii, nil
+}
+
+// DomainToUnicode converts a domain to its lowercase Unicode form for display
+func DomainToUnicode(s string) (string, error) {
+	ascii, err := DomainToASCII(s)
+	if err != nil {
+		return "", err
+	}
+	return domainProfile.ToUnicode(ascii)
+}
+
+// allowedScriptSets are the script combinations a single label may mix,
+// following the "highly restrictive" profile of Unicode TS 39
+var allowedScriptSets = [][]string{
+	{"Latin", "Han", "Hiragana", "Katakana"},
+	{"Latin", "Han", "Bopomofo"},
+	{"Latin", "Han", "Hangul"},
+}
+
+// checkLabelScripts rejects labels whose letters come from more than one
+// script, except for the combinations customary in East Asian names
+func checkLabelScripts(domain string) error {
+	for _, label := range strings.Split(domain, ".") {
+		scripts := labelScripts(label)
+		if len(scripts) <= 1 || scriptsAllowed(scripts) {
+			continue
+		}
+		names := make([]string, 0, len(scripts))
+		for name := range scripts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("label %q mixes scripts %s", label, strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// labelScripts returns the scripts used by a label, ignoring the Common and
+// Inherited scripts shared by digits, hyphens and combining marks
+func labelScripts(label string) map[string]bool {
+	scripts := make(map[string]bool)
+	for _, r := range label {
+		if r < utf8.RuneSelf && !unicode.IsLetter(r) {
+			continue
+		}
+		for name, table := range unicode.Scripts {
+			if name == "Common" || name == "Inherited" {
+				continue
+			}
+			if unicode.Is(table, r) {
+				scripts[name] = true
+				break
+			}
+		}
+	}
+	return scripts
+}
+
+func scriptsAllowed(scripts map[string]bool) bool {
+	for _, set := range allowedScriptSets {
+		ok := true
+		for name := range scripts {
+			if !slices.Contains(set, name) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// hostToASCII converts an issuer or recipient host, which may carry a port,
+// to the form used on the network. ASCII hosts are only lowercased, so IP
+// addresses and ports pass through unchanged.
+func hostToASCII(host string) (string, error) {
+	if isASCII(host) {
+		return strings.ToLower(host), nil
+	}
+	name, port := host, ""
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		name, port = host[:i], host[i:]
+	}
+	ascii, err := DomainToASCII(name)
+	if err != nil {
+		return "", err
+	}
+	return ascii + port, nil
+}
+
+// sameDomain reports whether two domains are equal once converted to ASCII.
+// Domains that cannot be converted only equal themselves.
+func sameDomain(a, b string) bool {
+	if a == b {
+		return true
+	}
+	asciiA, err := hostToASCII(a)
+	if err != nil {
+		return false
+	}
+	asciiB, err := hostToASCII(b)
+	if err != nil {
+		return false
+	}
+	return asciiA == asciiB
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateClaimTarget checks the recipient domain of a claim that has already
+// been issued. Problems are returned as warnings rather than errors, since
+// such claims still verify cryptographically; recipients matching on domain
+// should treat them with suspicion.
+func ValidateClaimTarget(claim *Claim) []string {
+	if claim.To.Domain == "" {
+		return nil
+	}
+	if err := ValidateDomain(claim.To.Domain); err != nil {
+		return []string{err.Error()}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package humanattestation
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateDomain(t *testing.T) {
+	tests := []struct {
+		domain string
+		valid  bool
+	}{
+		{"acme.com", true},
+		{"jobs.acme.co.uk", true},
+		{"Acme.COM", true},
+		{"xn--mnchen-3ya.de", true}, // punycode for münchen.de
+		{"münchen.de", true},
+		{"my-company.io", true},
+		{"", false},
+		{"acme .com", false},
+		{"https://acme.com", false},
+		{"acme.com/jobs", false},
+		{"acme.com:443", false},
+		{"acme.com.", false}, // trailing dot
+		{"localhost", false}, // single label
+		{"192.168.0.1", false},
+		{"-acme.com", false},
+		{"acme-.com", false},
+		{"acme..com", false},
+		{"acme_corp.com", false},
+		{"xn--zz.com", false},  // invalid punycode
+		{"💩.la", false},        // emoji not permitted under IDNA 2008
+		{"xn--ls8h.la", false}, // same emoji domain in punycode form
+		{strings.Repeat("a", 64) + ".com", false},
+	}
+
+	for _, tt := range tests {
+		err := ValidateDomain(tt.domain)
+		if (err == nil) != tt.valid {
+			t.Errorf("ValidateDomain(%q) error = %v, want valid=%v", tt.domain, err, tt.valid)
+		}
+	}
+}
+
+func TestCreateClaimValidatesDomain(t *testing.T) {
+	params := CreateClaimParams{
+		Method:        "physical_mail",
+		Description:   "Mail",
+		RecipientName: "Acme Corp",
+		Domain:        "https://acme.com",
+		Issuer:        "va.example",
+	}
+	if _, err := CreateClaim(params); err == nil {
+		t.Error("expected error for domain with scheme")
+	}
+
+	params.Domain = "intranet"
+	params.SkipDomainValidation = true
+	if _, err := CreateClaim(params); err != nil {
+		t.Errorf("unexpected error with SkipDomainValidation: %v", err)
+	}
+}
+
+func TestValidateClaimTarget(t *testing.T) {
+	if w := ValidateClaimTarget(&Claim{To: ClaimTarget{Name: "Acme", Domain: "acme.com"}}); len(w) != 0 {
+		t.Errorf("unexpected warnings: %v", w)
+	}
+	if w := ValidateClaimTarget(&Claim{To: ClaimTarget{Name: "Acme", Domain: "localhost"}}); len(w) != 1 {
+		t.Errorf("expected one warning, got %v", w)
+	}
+}
+
+// idnFixtures are shared with the other SDKs to keep IDN handling consistent
+type idnFixtures struct {
+	Valid []struct {
+		Input        string `json:"input"`
+		ASCII        string `json:"ascii"`
+		Unicode      string `json:"unicode"`
+		CompactField string `json:"compactField"`
+	} `json:"valid"`
+	Invalid []struct {
+		Input  string `json:"input"`
+		Reason string `json:"reason"`
+	} `json:"invalid"`
+}
+
+func loadIDNFixtures(t *testing.T) idnFixtures {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "idn_domains.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixtures idnFixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		t.Fatal(err)
+	}
+	return fixtures
+}
+
+func TestIDNFixtures(t *testing.T) {
+	fixtures := loadIDNFixtures(t)
+
+	for _, f := range fixtures.Valid {
+		t.Run(f.Input, func(t *testing.T) {
+			ascii, err := DomainToASCII(f.Input)
+			if err != nil || ascii != f.ASCII {
+				t.Errorf("DomainToASCII() = %q, %v, want %q", ascii, err, f.ASCII)
+			}
+			unicodeForm, err := DomainToUnicode(f.Input)
+			if err != nil || unicodeForm != f.Unicode {
+				t.Errorf("DomainToUnicode() = %q, %v, want %q", unicodeForm, err, f.Unicode)
+			}
+			if got := encodeCompactField(f.Input); got != f.CompactField {
+				t.Errorf("encodeCompactField() = %q, want %q", got, f.CompactField)
+			}
+			if got := encodeCompactFieldV2(f.Input); got != f.CompactField {
+				t.Errorf("encodeCompactFieldV2() = %q, want %q", got, f.CompactField)
+			}
+			if err := ValidateDomain(f.Input); err != nil {
+				t.Errorf("ValidateDomain() error = %v", err)
+			}
+		})
+	}
+
+	for _, f := range fixtures.Invalid {
+		t.Run(f.Input, func(t *testing.T) {
+			if ascii, err := DomainToASCII(f.Input); err == nil {
+				t.Errorf("DomainToASCII() = %q, want error (%s)", ascii, f.Reason)
+			}
+			if err := ValidateDomain(f.Input); err == nil {
+				t.Errorf("ValidateDomain() accepted %s", f.Reason)
+			}
+		})
+	}
+}
+
+func TestIDNClaimRoundTrip(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+
+	for _, f := range loadIDNFixtures(t).Valid {
+		t.Run(f.Input, func(t *testing.T) {
+			claim := testClaim(t)
+			claim.To.Domain = f.Input
+
+			for _, version := range []string{CompactVersion, CompactVersionV2} {
+				compact, err := SignCompact(claim, priv, CompactOptions{Version: version})
+				if err != nil {
+					t.Fatal(err)
+				}
+				result := VerifyCompact(compact, []JWK{jwk})
+				if !result.Valid {
+					t.Fatalf("v%s: VerifyCompact() error = %s", version, result.Error)
+				}
+				// The signed form is preserved exactly
+				if result.Claim.To.Domain != f.Input {
+					t.Errorf("v%s: To.Domain = %q, want %q", version, result.Claim.To.Domain, f.Input)
+				}
+			}
+
+			for _, recipient := range []string{f.ASCII, f.Unicode, f.Input} {
+				if !IsClaimForRecipient(claim, recipient) {
+					t.Errorf("IsClaimForRecipient(%q) = false", recipient)
+				}
+			}
+		})
+	}
+
+	claim := testClaim(t)
+	claim.To.Domain = "münchen-jobs.de"
+	if IsClaimForRecipient(claim, "xn--mnchen-jobs-4ib.de") {
+		t.Error("IsClaimForRecipient matched a different punycode domain")
+	}
+}
+
+func TestHostToASCII(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Ballista.Jobs", "ballista.jobs"},
+		{"127.0.0.1:8443", "127.0.0.1:8443"},
+		{"münchen-jobs.de", "xn--mnchen-jobs-thb.de"},
+		{"münchen-jobs.de:8443", "xn--mnchen-jobs-thb.de:8443"},
+	}
+	for _, tt := range tests {
+		if got, err := hostToASCII(tt.in); err != nil || got != tt.want {
+			t.Errorf("hostToASCII(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := hostToASCII("pаypal.com"); err == nil {
+		t.Error("hostToASCII accepted a mixed-script host")
+	}
+}
@@ -0,0 +1,67 @@
+package humanattestation
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// EstimateSignerDrift returns how far a claim's "at" timestamp is ahead of the
+// time it was received (at - receivedAt). A positive result means the signer's
+// clock appears to be ahead of the verifier's; delivery delay makes individual
+// samples skew negative, so look at the aggregate from many claims.
+func EstimateSignerDrift(claim *Claim, receivedAt time.Time) (time.Duration, error) {
+	at, err := ParseTimestamp(claim.At)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse 'at' timestamp: %w", err)
+	}
+	return at.Sub(receivedAt), nil
+}
+
+// DriftSample pairs a claim with the time it was received
+type DriftSample struct {
+	Claim      *Claim
+	ReceivedAt time.Time
+}
+
+// DriftSummary aggregates signer drift observed for one issuer
+type DriftSummary struct {
+	Issuer  string
+	Samples int
+	Min     time.Duration
+	Max     time.Duration
+	Median  time.Duration
+}
+
+// IsSystematic reports whether the issuer's clock is consistently ahead of the
+// verifier's by more than tolerance. Because delivery delay only ever pulls
+// samples negative, a minimum above tolerance cannot be explained by transit.
+func (s DriftSummary) IsSystematic(tolerance time.Duration) bool {
+	return s.Samples > 0 && s.Min > tolerance
+}
+
+// SummarizeSignerDrift groups samples by issuer and summarizes the drift of
+// each. Samples whose "at" timestamp cannot be parsed are skipped.
+func SummarizeSignerDrift(samples []DriftSample) map[string]DriftSummary {
+	byIssuer := make(map[string][]time.Duration)
+	for _, s := range samples {
+		drift, err := EstimateSignerDrift(s.Claim, s.ReceivedAt)
+		if err != nil {
+			continue
+		}
+		byIssuer[s.Claim.Iss] = append(byIssuer[s.Claim.Iss], drift)
+	}
+
+	summaries := make(map[string]DriftSummary, len(byIssuer))
+	for issuer, drifts := range byIssuer {
+		sort.Slice(drifts, func(i, j int) bool { return drifts[i] < drifts[j] })
+		summaries[issuer] = DriftSummary{
+			Issuer:  issuer,
+			Samples: len(drifts),
+			Min:     drifts[0],
+			Max:     drifts[len(drifts)-1],
+			Median:  drifts[len(drifts)/2],
+		}
+	}
+	return summaries
+}
@@ -0,0 +1,55 @@
+package humanattestation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateSignerDrift(t *testing.T) {
+	claim := &Claim{At: "2026-01-19T06:00:00Z", Iss: "va.example"}
+	at := time.Date(2026, 1, 19, 6, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		receivedAt time.Time
+		want       time.Duration
+	}{
+		{"signer ahead", at.Add(-90 * time.Second), 90 * time.Second},
+		{"signer behind", at.Add(2 * time.Minute), -2 * time.Minute},
+	}
+	for _, tt := range tests {
+		got, err := EstimateSignerDrift(claim, tt.receivedAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: drift = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := EstimateSignerDrift(&Claim{At: "garbage"}, at); err == nil {
+		t.Error("expected error for unparseable 'at'")
+	}
+}
+
+func TestSummarizeSignerDrift(t *testing.T) {
+	at := time.Date(2026, 1, 19, 6, 0, 0, 0, time.UTC)
+	claim := func(iss string) *Claim { return &Claim{At: at.Format(time.RFC3339), Iss: iss} }
+
+	samples := []DriftSample{
+		{claim("fast.example"), at.Add(-5 * time.Minute)},
+		{claim("fast.example"), at.Add(-4 * time.Minute)},
+		{claim("fast.example"), at.Add(-6 * time.Minute)},
+		{claim("ok.example"), at.Add(3 * time.Second)},
+		{claim("ok.example"), at.Add(-1 * time.Second)},
+	}
+
+	summaries := SummarizeSignerDrift(samples)
+	fast := summaries["fast.example"]
+	if fast.Samples != 3 || fast.Median != 5*time.Minute || !fast.IsSystematic(time.Minute) {
+		t.Errorf("fast.example summary = %+v", fast)
+	}
+	if ok := summaries["ok.example"]; ok.IsSystematic(time.Minute) {
+		t.Errorf("ok.example flagged as systematic drift: %+v", ok)
+	}
+}
@@ -0,0 +1,133 @@
+package humanattestation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+// EmailClaimHeader is the message header carrying a compact claim
+const EmailClaimHeader = "HAP-Claim"
+
+// ErrNoClaimHeader is returned by VerifyEmailMessage for messages without a
+// HAP-Claim header
+var ErrNoClaimHeader = errors.New("no " + EmailClaimHeader + " header")
+
+// VerifyEmailMessage verifies the compact claim in the HAP-Claim header of a
+// raw RFC 5322 message. The claim's issuer must be one of trustedIssuers:
+// anyone can send a message, so the issuer it names is not contacted
+// otherwise. The signature is checked against the issuer's keys, the VA is
+// asked whether the claim was revoked, and the claim's recipient domain must
+// match a To or Cc address. Claims carry no sender identity, so the From
+// domain is aligned with the issuer instead: a claim whose issuer belongs to
+// a From domain is self-issued (see IsSelfIssued) and rejected, as the
+// sender controls the VA vouching for it.
+//
+// It returns the verified claim, ErrNoClaimHeader if the message has no
+// claim, or an error describing why the claim was rejected; an issuer that
+// is not trusted matches ErrIssuerMismatch and one belonging to the sender
+// matches ErrSelfIssued.
+func VerifyEmailMessage(ctx context.Context, raw []byte, trustedIssuers []string, opts VerifyOptions) (*Claim, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+
+	value := msg.Header.Get(EmailClaimHeader)
+	if value == "" {
+		return nil, ErrNoClaimHeader
+	}
+	// Long headers are folded; compact strings contain no whitespace
+	compact := strings.Join(strings.Fields(value), "")
+	if !IsValidCompact(compact) {
+		return nil, fmt.Errorf("%s header does not contain a compact claim", EmailClaimHeader)
+	}
+
+	decoded, err := DecodeCompact(compact)
+	if err != nil {
+		return nil, fmt.Errorf("invalid claim: %w", err)
+	}
+	issuer := ""
+	for _, iss := range trustedIssuers {
+		if sameDomain(decoded.Claim.Iss, iss) {
+			issuer = iss
+			break
+		}
+	}
+	if issuer == "" {
+		return nil, newVerificationError(ErrIssuerMismatch, nil, "issuer %s is not trusted", decoded.Claim.Iss)
+	}
+	senders, err := msg.Header.AddressList("From")
+	if errors.Is(err, mail.ErrHeaderNotPresent) {
+		return nil, errors.New("message has no From header")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse From header: %w", err)
+	}
+	for _, addr := range senders {
+		if domain := addressDomain(addr.Address); IsSelfIssued(decoded.Claim, domain) {
+			return nil, newVerificationError(ErrSelfIssued, nil, "issuer %s belongs to sender %s", decoded.Claim.Iss, domain)
+		}
+	}
+
+	opts = opts.metered()
+	result, err := verifySniffed(ctx, InputCompact, compact, []string{issuer}, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Valid {
+		return nil, fmt.Errorf("invalid claim: %s", result.Error)
+	}
+	claim := result.Claim
+
+	resp, err := FetchClaim(ctx, claim.ID, claim.Iss, opts)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Revoked {
+		return nil, fmt.Errorf("claim revoked: %s", resp.RevocationReason)
+	}
+	if !resp.Valid {
+		return nil, fmt.Errorf("claim not valid at issuer: %s", resp.Error)
+	}
+	if err := opts.meter.Err(); err != nil {
+		return nil, err
+	}
+
+	if !isClaimForMessage(claim, msg.Header) {
+		return nil, fmt.Errorf("claim is for %s, not a recipient of this message", claim.To.Domain)
+	}
+	return claim, nil
+}
+
+// isClaimForMessage reports whether the claim's recipient domain matches an
+// address in the To or Cc headers. Claims without a domain match any message.
+func isClaimForMessage(claim *Claim, header mail.Header) bool {
+	if claim.To.Domain == "" {
+		return true
+	}
+	for _, name := range []string{"To", "Cc"} {
+		addrs, err := header.AddressList(name)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if domain := addressDomain(addr.Address); domain != "" && IsClaimForRecipient(claim, domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// addressDomain returns the domain of an email address, or "" if it has none
+func addressDomain(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return ""
+	}
+	return address[at+1:]
+}
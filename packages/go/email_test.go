@@ -0,0 +1,128 @@
+package humanattestation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testEmail(headers ...string) []byte {
+	return []byte(strings.Join(append(headers, "Subject: Application", "", "Hello."), "\r\n"))
+}
+
+func TestVerifyEmailMessage(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	claim, _ := va.Issue(t, priv, "claims_001")
+	compact, err := SignCompact(claim, priv, CompactOptions{Version: CompactVersionV2, Kid: "claims_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trusted := []string{va.Issuer()}
+	ctx := context.Background()
+
+	t.Run("valid header", func(t *testing.T) {
+		raw := testEmail(
+			"From: Jane Doe <jane@example.org>",
+			"To: Hiring <jobs@acme.com>",
+			EmailClaimHeader+": "+compact,
+		)
+		got, err := VerifyEmailMessage(ctx, raw, trusted, va.Options())
+		if err != nil {
+			t.Fatalf("VerifyEmailMessage() error = %v", err)
+		}
+		if got.ID != claim.ID {
+			t.Errorf("VerifyEmailMessage() claim ID = %s, want %s", got.ID, claim.ID)
+		}
+	})
+
+	t.Run("folded header", func(t *testing.T) {
+		half := len(compact) / 2
+		raw := testEmail(
+			"From: jane@example.org",
+			"Cc: jobs@acme.com",
+			fmt.Sprintf("%s: %s\r\n %s", EmailClaimHeader, compact[:half], compact[half:]),
+		)
+		if _, err := VerifyEmailMessage(ctx, raw, trusted, va.Options()); err != nil {
+			t.Errorf("VerifyEmailMessage() error = %v", err)
+		}
+	})
+
+	t.Run("missing header", func(t *testing.T) {
+		raw := testEmail("From: jane@example.org", "To: jobs@acme.com")
+		if _, err := VerifyEmailMessage(ctx, raw, trusted, va.Options()); !errors.Is(err, ErrNoClaimHeader) {
+			t.Errorf("VerifyEmailMessage() error = %v, want ErrNoClaimHeader", err)
+		}
+	})
+
+	t.Run("wrong recipient", func(t *testing.T) {
+		raw := testEmail("From: jane@example.org", "To: jobs@other.example", EmailClaimHeader+": "+compact)
+		if _, err := VerifyEmailMessage(ctx, raw, trusted, va.Options()); err == nil {
+			t.Error("VerifyEmailMessage() accepted a claim for another recipient")
+		}
+	})
+
+	t.Run("untrusted issuer", func(t *testing.T) {
+		raw := testEmail("From: jane@example.org", "To: jobs@acme.com", EmailClaimHeader+": "+compact)
+		fetches := va.keyFetches.Load()
+		for _, issuers := range [][]string{nil, {"ballista.jobs"}} {
+			if _, err := VerifyEmailMessage(ctx, raw, issuers, va.Options()); !errors.Is(err, ErrIssuerMismatch) {
+				t.Errorf("VerifyEmailMessage(%v) error = %v, want ErrIssuerMismatch", issuers, err)
+			}
+		}
+		// The issuer the message names is never contacted
+		if n := va.keyFetches.Load(); n != fetches {
+			t.Errorf("keys fetched %d times for an untrusted issuer", n-fetches)
+		}
+	})
+
+	t.Run("tampered claim", func(t *testing.T) {
+		raw := testEmail("From: jane@example.org", "To: jobs@acme.com", EmailClaimHeader+": "+strings.Replace(compact, "acme%2Ecom", "acme%2Eorg", 1))
+		_, err := VerifyEmailMessage(ctx, raw, trusted, va.Options())
+		if err == nil || !strings.Contains(err.Error(), "invalid claim") {
+			t.Errorf("VerifyEmailMessage() error = %v, want invalid claim", err)
+		}
+	})
+
+	t.Run("missing From", func(t *testing.T) {
+		raw := testEmail("To: jobs@acme.com", EmailClaimHeader+": "+compact)
+		if _, err := VerifyEmailMessage(ctx, raw, trusted, va.Options()); err == nil || !strings.Contains(err.Error(), "From") {
+			t.Errorf("VerifyEmailMessage() error = %v, want missing From", err)
+		}
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		va.Revoke(claim.ID, RevocationFraud)
+		raw := testEmail("From: jane@example.org", "To: jobs@acme.com", EmailClaimHeader+": "+compact)
+		_, err := VerifyEmailMessage(ctx, raw, trusted, va.Options())
+		if err == nil || !strings.Contains(err.Error(), "revoked") {
+			t.Errorf("VerifyEmailMessage() error = %v, want revoked", err)
+		}
+	})
+}
+
+func TestVerifyEmailMessageSelfIssued(t *testing.T) {
+	va := newTestVA(t)
+	priv, _ := testKeys(t, "claims_001")
+	claim := testClaim(t)
+	claim.Iss = "va.example.org"
+	compact, err := SignCompact(claim, priv, CompactOptions{Version: CompactVersionV2, Kid: "claims_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := va.Options()
+	opts.IssuerBaseURL = func(string) string { return va.URL }
+
+	for _, from := range []string{"jane@example.org", "Jane <jane@mail.example.org>", "bob@other.example, jane@example.org"} {
+		raw := testEmail("From: "+from, "To: jobs@acme.com", EmailClaimHeader+": "+compact)
+		if _, err := VerifyEmailMessage(context.Background(), raw, []string{"va.example.org"}, opts); !errors.Is(err, ErrSelfIssued) {
+			t.Errorf("VerifyEmailMessage() from %s error = %v, want ErrSelfIssued", from, err)
+		}
+	}
+	// Rejected before asking the issuer anything
+	if n := va.keyFetches.Load(); n != 0 {
+		t.Errorf("keys fetched %d times for a self-issued claim", n)
+	}
+}
@@ -0,0 +1,42 @@
+package humanattestation
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrInsecureEndpoint is returned when IssuerBaseURL gives a plain HTTP URL
+// and AllowInsecureHTTP is not set
+var ErrInsecureEndpoint = errors.New("insecure issuer endpoint")
+
+// endpoint returns the URL of path, which starts with a slash, on the VA of
+// issuerDomain
+func (o VerifyOptions) endpoint(issuerDomain, path string) (string, error) {
+	var base string
+	if o.IssuerBaseURL != nil {
+		base = o.IssuerBaseURL(issuerDomain)
+	}
+	if base == "" {
+		host, err := hostToASCII(issuerDomain)
+		if err != nil {
+			return "", err
+		}
+		return "https://" + host + path, nil
+	}
+
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", fmt.Errorf("invalid base URL for %s: %w", issuerDomain, err)
+	}
+	switch {
+	case u.Host == "" || u.RawQuery != "" || u.Fragment != "":
+		return "", fmt.Errorf("invalid base URL for %s: %s", issuerDomain, base)
+	case u.Scheme == "http" && !o.AllowInsecureHTTP:
+		return "", fmt.Errorf("%w: %s (set AllowInsecureHTTP to permit it)", ErrInsecureEndpoint, base)
+	case u.Scheme != "https" && u.Scheme != "http":
+		return "", fmt.Errorf("invalid base URL for %s: unsupported scheme %q", issuerDomain, u.Scheme)
+	}
+	return strings.TrimSuffix(u.String(), "/") + path, nil
+}
@@ -0,0 +1,150 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		insecure bool
+		want     string
+		wantErr  error
+	}{
+		{"default", "", false, "https://ballista.jobs/.well-known/hap.json", nil},
+		{"https", "https://localhost:8443", false, "https://localhost:8443/.well-known/hap.json", nil},
+		{"prefix", "https://localhost:8443/va/", false, "https://localhost:8443/va/.well-known/hap.json", nil},
+		{"http refused", "http://localhost:8080", false, "", ErrInsecureEndpoint},
+		{"http allowed", "http://localhost:8080", true, "http://localhost:8080/.well-known/hap.json", nil},
+		{"other scheme", "ftp://localhost", true, "", nil},
+		{"no host", "localhost:8080", true, "", nil},
+	}
+	for _, tt := range tests {
+		opts := VerifyOptions{
+			IssuerBaseURL:     func(string) string { return tt.base },
+			AllowInsecureHTTP: tt.insecure,
+		}
+		got, err := opts.endpoint("ballista.jobs", "/.well-known/hap.json")
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("%s: endpoint() = %q, want an error", tt.name, got)
+		case tt.want != "" && (err != nil || got != tt.want):
+			t.Errorf("%s: endpoint() = %q, %v, want %q", tt.name, got, err, tt.want)
+		case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestIssuerBaseURLLocalHTTP(t *testing.T) {
+	claim := testClaim(t)
+	_, jwk := testKeys(t, "claims_001")
+	var requests atomic.Int32
+	verify := NewVerifyHandler(ClaimLookupFunc(func(_ context.Context, id string) (*VerificationResponse, error) {
+		if id != claim.ID {
+			return nil, nil
+		}
+		return &VerificationResponse{Valid: true, ID: claim.ID, Claim: claim}, nil
+	}))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/hap.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(WellKnown{Issuer: claim.Iss, Keys: []JWK{jwk}})
+	})
+	mux.Handle("/api/v1/verify/", verify)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		mux.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	opts := DefaultVerifyOptions()
+	opts.KeyCache = NewPublicKeyCache(time.Minute)
+	opts.IssuerBaseURL = func(issuer string) string {
+		if issuer == claim.Iss {
+			return srv.URL
+		}
+		return ""
+	}
+	ctx := context.Background()
+
+	if _, err := FetchPublicKeys(ctx, claim.Iss, opts); !errors.Is(err, ErrInsecureEndpoint) {
+		t.Errorf("FetchPublicKeys() without AllowInsecureHTTP: error = %v, want ErrInsecureEndpoint", err)
+	}
+	if _, err := FetchClaim(ctx, claim.ID, claim.Iss, opts); !errors.Is(err, ErrInsecureEndpoint) {
+		t.Errorf("FetchClaim() without AllowInsecureHTTP: error = %v, want ErrInsecureEndpoint", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("requests without AllowInsecureHTTP = %d, want 0", n)
+	}
+
+	opts.AllowInsecureHTTP = true
+	wellKnown, err := FetchPublicKeys(ctx, claim.Iss, opts)
+	if err != nil || len(wellKnown.Keys) != 1 || wellKnown.Keys[0].Kid != "claims_001" {
+		t.Fatalf("FetchPublicKeys() = %+v, %v", wellKnown, err)
+	}
+	resp, err := FetchClaim(ctx, claim.ID, claim.Iss, opts)
+	if err != nil || !resp.Valid || resp.Claim.ID != claim.ID {
+		t.Fatalf("FetchClaim() = %+v, %v", resp, err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+}
+
+func TestIssuerBaseURLKeysNotShared(t *testing.T) {
+	_, devJWK := testKeys(t, "dev_001")
+	_, prodJWK := testKeys(t, "prod_001")
+	dev := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(WellKnown{Issuer: "ballista.jobs", Keys: []JWK{devJWK}})
+	}))
+	defer dev.Close()
+	var prodFetches atomic.Int32
+	prodClient := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		prodFetches.Add(1)
+		if r.URL.String() != "https://ballista.jobs/.well-known/hap.json" {
+			t.Errorf("production fetch went to %s", r.URL)
+		}
+		rec := httptest.NewRecorder()
+		json.NewEncoder(rec).Encode(WellKnown{Issuer: "ballista.jobs", Keys: []JWK{prodJWK}})
+		return rec.Result(), nil
+	})}
+
+	// One cache shared by a development caller and a production caller, as
+	// the process-wide cache is
+	cache := NewPublicKeyCache(time.Hour)
+	ctx := context.Background()
+	devOpts := VerifyOptions{
+		KeyCache:          cache,
+		IssuerBaseURL:     func(string) string { return dev.URL },
+		AllowInsecureHTTP: true,
+	}
+	if wk, err := FetchPublicKeys(ctx, "ballista.jobs", devOpts); err != nil || wk.Keys[0].Kid != "dev_001" {
+		t.Fatalf("development FetchPublicKeys() = %+v, %v", wk, err)
+	}
+
+	prodOpts := VerifyOptions{KeyCache: cache, HTTPClient: prodClient}
+	wk, err := FetchPublicKeys(ctx, "ballista.jobs", prodOpts)
+	if err != nil || len(wk.Keys) != 1 || wk.Keys[0].Kid != "prod_001" {
+		t.Fatalf("production FetchPublicKeys() = %+v, %v, want the issuer's own keys", wk, err)
+	}
+	if n := prodFetches.Load(); n != 1 {
+		t.Errorf("production fetches = %d, want 1", n)
+	}
+
+	// Both entries are cached, and flushing the issuer drops both
+	if wk, err := FetchPublicKeys(ctx, "ballista.jobs", devOpts); err != nil || wk.Keys[0].Kid != "dev_001" {
+		t.Errorf("development FetchPublicKeys() after production = %+v, %v", wk, err)
+	}
+	cache.Flush("ballista.jobs")
+	if _, err := FetchPublicKeys(ctx, "ballista.jobs", prodOpts); err != nil || prodFetches.Load() != 2 {
+		t.Errorf("after Flush: %d production fetches, %v", prodFetches.Load(), err)
+	}
+}
@@ -0,0 +1,57 @@
+package humanattestation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors reported in the Err field of verification results. Test them with
+// errors.Is; the Error strings of results are unchanged and not meant to be
+// matched.
+var (
+	ErrKeyNotFound      = errors.New("key not found")
+	ErrSignatureInvalid = errors.New("signature verification failed")
+	ErrIssuerMismatch   = errors.New("issuer mismatch")
+	ErrExpired          = errors.New("claim expired")
+	ErrNotYetValid      = errors.New("claim not yet valid")
+	ErrRevoked          = errors.New("claim revoked")
+	ErrInvalidFormat    = errors.New("invalid claim format")
+	ErrSelfIssued       = errors.New("claim is self-issued")
+)
+
+// reasonErrors maps each FailureReason to the error it is reported with
+var reasonErrors = map[FailureReason]error{
+	FailureNoKeys:            ErrKeyNotFound,
+	FailureDecodeError:       ErrInvalidFormat,
+	FailureSignatureMismatch: ErrSignatureInvalid,
+	FailureExpired:           ErrExpired,
+	FailureNotYetValid:       ErrNotYetValid,
+	FailureBudgetExceeded:    ErrWorkBudgetExceeded,
+}
+
+// verificationError is a result's Err. It keeps the message results have
+// always carried in Error, matches its sentinel with errors.Is, and unwraps
+// to the error that caused it, if any.
+type verificationError struct {
+	msg      string
+	sentinel error
+	cause    error
+}
+
+// newVerificationError returns an error matching sentinel, wrapping cause,
+// with the given message
+func newVerificationError(sentinel, cause error, format string, args ...any) error {
+	return &verificationError{msg: fmt.Sprintf(format, args...), sentinel: sentinel, cause: cause}
+}
+
+func (e *verificationError) Error() string {
+	return e.msg
+}
+
+func (e *verificationError) Is(target error) bool {
+	return e.sentinel != nil && target == e.sentinel
+}
+
+func (e *verificationError) Unwrap() error {
+	return e.cause
+}
@@ -0,0 +1,100 @@
+package humanattestation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultErrors(t *testing.T) {
+	claim := testClaim(t)
+	priv, jwk := testKeys(t, "claims_001")
+	_, otherJWK := testKeys(t, "claims_001")
+	keys := []JWK{jwk}
+
+	jws, err := SignClaim(claim, priv, "claims_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	compact, err := SignCompact(claim, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := *claim
+	expired.At = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	expired.Exp = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	expiredCompact, err := SignCompact(&expired, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"jws key not found", verifyJWS(jws, claim.Iss, nil).Err, ErrKeyNotFound},
+		{"jws signature", verifyJWS(jws, claim.Iss, []JWK{otherJWK}).Err, ErrSignatureInvalid},
+		{"jws issuer", verifyJWS(jws, "other.example", keys).Err, ErrIssuerMismatch},
+		{"jws format", verifyJWS("not a jws", claim.Iss, keys).Err, ErrInvalidFormat},
+		{"compact key not found", VerifyCompact(compact, nil).Err, ErrKeyNotFound},
+		{"compact signature", VerifyCompact(compact, []JWK{otherJWK}).Err, ErrSignatureInvalid},
+		{"compact format", VerifyCompact("HAP1.nope", keys).Err, ErrInvalidFormat},
+		{"strict expired", VerifyCompactStrict(context.Background(), expiredCompact, StrictRequirements{Signature: true, Keys: keys, NotExpired: true}, VerifyOptions{}).Err, ErrExpired},
+		{"strict issuer", VerifyCompactStrict(context.Background(), compact, StrictRequirements{TrustedIssuers: []string{"other.example"}}, VerifyOptions{}).Err, ErrIssuerMismatch},
+		{"strict revoked", VerifyCompactStrict(context.Background(), compact, StrictRequirements{
+			RevocationChecker: func(context.Context, *Claim) (bool, error) { return true, nil },
+		}, VerifyOptions{}).Err, ErrRevoked},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: Err = %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+}
+
+func TestResultErrorsKeepMessages(t *testing.T) {
+	claim := testClaim(t)
+	priv, jwk := testKeys(t, "claims_001")
+	jws, err := SignClaim(claim, priv, "claims_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := verifyJWS(jws, claim.Iss, nil)
+	if result.Error != "key not found: claims_001" || result.Err.Error() != result.Error {
+		t.Errorf("Error = %q, Err = %q", result.Error, result.Err)
+	}
+	if result := verifyJWS(jws, claim.Iss, []JWK{jwk}); result.Err != nil {
+		t.Errorf("valid result has Err %v", result.Err)
+	}
+}
+
+func TestResultErrorsUnwrap(t *testing.T) {
+	result := verifyJWS("not a jws", "ballista.jobs", nil)
+	if !strings.HasPrefix(result.Error, "failed to parse JWS: ") {
+		t.Errorf("Error = %q", result.Error)
+	}
+	cause := errors.Unwrap(result.Err)
+	if cause == nil || !errors.Is(result.Err, cause) {
+		t.Fatalf("errors.Unwrap(%v) = %v", result.Err, cause)
+	}
+	if result.Error != "failed to parse JWS: "+cause.Error() {
+		t.Errorf("cause = %v, want the JWS parse error", cause)
+	}
+
+	// Sentinels behind the cause still match
+	claim := testClaim(t)
+	claim.At = "yesterday"
+	priv, jwk := testKeys(t, "claims_001")
+	jws, err := SignClaim(claim, priv, "claims_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result = verifyJWS(jws, claim.Iss, []JWK{jwk})
+	if !errors.Is(result.Err, ErrInvalidFormat) || !errors.Is(result.Err, ErrMalformedTimestamp) {
+		t.Errorf("Err = %v, want ErrInvalidFormat wrapping ErrMalformedTimestamp", result.Err)
+	}
+}
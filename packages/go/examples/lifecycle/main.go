@@ -0,0 +1,140 @@
+// Command lifecycle runs a minimal VA and a minimal recipient in one process
+// and walks a claim through issue, delivery, verification, revocation and
+// re-verification.
+//
+//	go run ./examples/lifecycle
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+
+	humanattestation "github.com/Blue-Scroll/hap/packages/go"
+)
+
+// demo is a running VA and recipient
+type demo struct {
+	va        *exampleVA
+	vaServer  *httptest.Server
+	recipient *httptest.Server
+	audit     *auditLog
+}
+
+// startDemo starts the VA over TLS and the recipient in front of it. The VA's
+// issuer domain is its host:port.
+func startDemo(recipientDomain string) (*demo, error) {
+	vaServer := httptest.NewUnstartedServer(nil)
+	vaServer.StartTLS()
+	issuer := strings.TrimPrefix(vaServer.URL, "https://")
+
+	va, err := newExampleVA(issuer)
+	if err != nil {
+		vaServer.Close()
+		return nil, err
+	}
+	vaServer.Config.Handler = va.Handler()
+
+	opts := humanattestation.DefaultVerifyOptions()
+	opts.HTTPClient = vaServer.Client()
+	audit := &auditLog{}
+	policy := recipientPolicy{Domain: recipientDomain, TrustedIssuers: []string{issuer}}
+
+	mux := http.NewServeMux()
+	mux.Handle("/apply", requireClaim(policy, audit, opts, http.HandlerFunc(applicationHandler)))
+
+	return &demo{va: va, vaServer: vaServer, recipient: httptest.NewServer(mux), audit: audit}, nil
+}
+
+func (d *demo) Close() {
+	d.recipient.Close()
+	d.vaServer.Close()
+}
+
+// issue asks the VA to sign a claim, as a sender would after completing the
+// verification method
+func (d *demo) issue(req issueRequest) (*issueResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.vaServer.Client().Post(d.vaServer.URL+"/api/v1/claims", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("issue failed: HTTP %d: %s", resp.StatusCode, msg)
+	}
+	var issued issueResponse
+	if err := json.NewDecoder(resp.Body).Decode(&issued); err != nil {
+		return nil, err
+	}
+	return &issued, nil
+}
+
+// deliver presents a claim to the recipient, returning its status and reply
+func (d *demo) deliver(claim string) (int, string, error) {
+	req, err := http.NewRequest(http.MethodPost, d.recipient.URL+"/apply", nil)
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set(ClaimHeader, claim)
+	req.Header.Set(IssuerHeader, d.va.issuer)
+	resp, err := d.recipient.Client().Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, strings.TrimSpace(string(body)), err
+}
+
+func main() {
+	d, err := startDemo("acme.com")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer d.Close()
+
+	issued, err := d.issue(issueRequest{
+		Method:        "physical_mail",
+		Description:   "Priority mail packet with handwritten cover letter",
+		RecipientName: "Acme Corp",
+		Domain:        "acme.com",
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("issued   %s\n", issued.ID)
+
+	status, body, err := d.deliver(issued.ID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("deliver  %d %s\n", status, body)
+
+	if err := d.va.Revoke(issued.ID, humanattestation.RevocationUserRequest); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("revoked  %s\n", issued.ID)
+
+	status, body, err = d.deliver(issued.ID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("deliver  %d %s\n", status, body)
+
+	fmt.Println("audit log:")
+	enc := json.NewEncoder(os.Stdout)
+	for _, e := range d.audit.Entries() {
+		enc.Encode(e)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	humanattestation "github.com/Blue-Scroll/hap/packages/go"
+)
+
+func TestLifecycle(t *testing.T) {
+	d, err := startDemo("acme.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+
+	// Issue
+	issued, err := d.issue(issueRequest{
+		Method:        "physical_mail",
+		Description:   "Priority mail packet",
+		RecipientName: "Acme Corp",
+		Domain:        "acme.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !humanattestation.IsValidID(issued.ID) {
+		t.Fatalf("issued invalid ID %q", issued.ID)
+	}
+
+	// Deliver and verify, by ID and as the signed JWS
+	for _, claim := range []string{issued.ID, issued.JWS} {
+		status, body, err := d.deliver(claim)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status != http.StatusOK || !strings.Contains(body, issued.ID) {
+			t.Fatalf("deliver = %d %q, want accepted", status, body)
+		}
+	}
+
+	// A claim for someone else is rejected by the recipient's policy
+	other, err := d.issue(issueRequest{Method: "physical_mail", RecipientName: "Other", Domain: "other.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, body, _ := d.deliver(other.ID); status != http.StatusForbidden || !strings.Contains(body, "other.com") {
+		t.Errorf("deliver other recipient's claim = %d %q, want 403", status, body)
+	}
+
+	// Revoke and re-verify
+	if err := d.va.Revoke(issued.ID, humanattestation.RevocationFraud); err != nil {
+		t.Fatal(err)
+	}
+	status, body, err := d.deliver(issued.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != http.StatusForbidden || !strings.Contains(body, "revoked") {
+		t.Errorf("deliver revoked claim = %d %q, want 403 revoked", status, body)
+	}
+
+	// The VA's published document and minimal view agree with the lifecycle
+	opts := humanattestation.VerifyOptions{HTTPClient: d.vaServer.Client()}
+	if _, err := humanattestation.FetchPublicKeys(context.Background(), d.va.issuer, opts); err != nil {
+		t.Errorf("FetchPublicKeys() error = %v", err)
+	}
+	minimal, err := humanattestation.FetchClaimMinimal(context.Background(), issued.ID, d.va.issuer, opts)
+	if err != nil || !minimal.Revoked {
+		t.Errorf("FetchClaimMinimal() = %+v, %v, want revoked", minimal, err)
+	}
+
+	entries := d.audit.Entries()
+	var accepted, rejected int
+	for _, e := range entries {
+		if e.Accepted {
+			accepted++
+		} else {
+			rejected++
+		}
+		if e.ClaimID == "" || e.Issuer != d.va.issuer {
+			t.Errorf("audit entry missing claim or issuer: %+v", e)
+		}
+	}
+	if accepted != 2 || rejected != 2 {
+		t.Errorf("audit log has %d accepted, %d rejected, want 2 and 2: %+v", accepted, rejected, entries)
+	}
+}
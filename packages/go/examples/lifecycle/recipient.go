@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	humanattestation "github.com/Blue-Scroll/hap/packages/go"
+)
+
+// ClaimHeader carries the sender's claim: a HAP ID, verification URL,
+// compact string or JWS
+const ClaimHeader = "HAP-Claim"
+
+// IssuerHeader names the VA that issued a bare HAP ID
+const IssuerHeader = "HAP-Issuer"
+
+// recipientPolicy decides which claims a recipient accepts
+type recipientPolicy struct {
+	Domain         string
+	TrustedIssuers []string
+}
+
+// check applies the policy to a verified claim, returning why it was rejected
+func (p recipientPolicy) check(claim *humanattestation.Claim) string {
+	if !slices.Contains(p.TrustedIssuers, claim.Iss) {
+		return fmt.Sprintf("issuer %s is not trusted", claim.Iss)
+	}
+	if !humanattestation.IsClaimForRecipient(claim, p.Domain) {
+		return fmt.Sprintf("claim is for %s", claim.To.Domain)
+	}
+	if humanattestation.IsClaimExpired(claim) {
+		return "claim has expired"
+	}
+	return ""
+}
+
+// auditEntry records one accept or reject decision
+type auditEntry struct {
+	At       time.Time
+	ClaimID  string
+	Issuer   string
+	Accepted bool
+	Reason   string
+}
+
+// auditLog is an in-memory append-only audit log
+type auditLog struct {
+	mu      sync.Mutex
+	entries []auditEntry
+}
+
+func (l *auditLog) record(e auditEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, e)
+}
+
+// Entries returns a copy of the log
+func (l *auditLog) Entries() []auditEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.entries)
+}
+
+type claimContextKey struct{}
+
+// claimFromContext returns the claim accepted by requireClaim
+func claimFromContext(ctx context.Context) *humanattestation.Claim {
+	claim, _ := ctx.Value(claimContextKey{}).(*humanattestation.Claim)
+	return claim
+}
+
+// requireClaim is middleware that only lets requests with an acceptable HAP
+// claim through, recording every decision in audit
+func requireClaim(policy recipientPolicy, audit *auditLog, opts humanattestation.VerifyOptions, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reject := func(status int, entry auditEntry) {
+			entry.At = time.Now()
+			audit.record(entry)
+			http.Error(w, entry.Reason, status)
+		}
+
+		input := r.Header.Get(ClaimHeader)
+		if input == "" {
+			reject(http.StatusUnauthorized, auditEntry{Reason: "no claim presented"})
+			return
+		}
+
+		// A bare ID names no issuer, so the sender says which trusted VA
+		// issued it; any other input must come from one of them
+		issuers := policy.TrustedIssuers
+		if issuer := r.Header.Get(IssuerHeader); issuer != "" && slices.Contains(issuers, issuer) {
+			issuers = []string{issuer}
+		}
+		result, err := humanattestation.VerifyAnything(r.Context(), input, issuers, opts)
+		if err != nil {
+			reject(http.StatusBadGateway, auditEntry{Reason: err.Error()})
+			return
+		}
+
+		entry := auditEntry{Reason: result.Error, Issuer: r.Header.Get(IssuerHeader)}
+		if humanattestation.IsValidID(input) {
+			entry.ClaimID = input
+		}
+		if result.Claim != nil {
+			entry.ClaimID, entry.Issuer = result.Claim.ID, result.Claim.Iss
+		}
+		if !result.Valid {
+			reject(http.StatusForbidden, entry)
+			return
+		}
+		if reason := policy.check(result.Claim); reason != "" {
+			entry.Reason = reason
+			reject(http.StatusForbidden, entry)
+			return
+		}
+
+		entry.At, entry.Accepted = time.Now(), true
+		audit.record(entry)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), claimContextKey{}, result.Claim)))
+	})
+}
+
+// applicationHandler is the recipient's protected endpoint
+func applicationHandler(w http.ResponseWriter, r *http.Request) {
+	claim := claimFromContext(r.Context())
+	fmt.Fprintf(w, "application received with %s claim %s\n", claim.Method, claim.ID)
+}
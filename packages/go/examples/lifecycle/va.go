@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	humanattestation "github.com/Blue-Scroll/hap/packages/go"
+)
+
+// exampleVA is a minimal Verification Authority: it publishes its key,
+// signs claims on request and answers the verification API
+type exampleVA struct {
+	issuer    string
+	kid       string
+	key       ed25519.PrivateKey
+	wellKnown *humanattestation.WellKnownStore
+
+	mu     sync.Mutex
+	claims map[string]*humanattestation.VerificationResponse
+}
+
+// issueRequest is the body of POST /api/v1/claims
+type issueRequest struct {
+	Method        string `json:"method"`
+	Description   string `json:"description"`
+	RecipientName string `json:"recipientName"`
+	Domain        string `json:"domain"`
+}
+
+// issueResponse is returned to the sender after a claim is signed
+type issueResponse struct {
+	ID        string `json:"id"`
+	JWS       string `json:"jws"`
+	VerifyURL string `json:"verifyUrl"`
+}
+
+func newExampleVA(issuer string) (*exampleVA, error) {
+	priv, pub, err := humanattestation.GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	kid := "example_key_001"
+	store, err := humanattestation.NewWellKnownStore(humanattestation.WellKnown{
+		Issuer: issuer,
+		Keys:   []humanattestation.JWK{humanattestation.ExportPublicKeyJWKWithUse(pub, kid, humanattestation.KeyUseClaims)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &exampleVA{
+		issuer:    issuer,
+		kid:       kid,
+		key:       priv,
+		wellKnown: store,
+		claims:    make(map[string]*humanattestation.VerificationResponse),
+	}, nil
+}
+
+// Handler serves the well-known document, the verification API and the
+// claim signing endpoint
+func (va *exampleVA) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/.well-known/hap.json", va.wellKnown)
+	mux.Handle("/api/v1/verify/", humanattestation.NewVerifyHandler(humanattestation.ClaimLookupFunc(va.lookup)))
+	mux.HandleFunc("/api/v1/claims", va.issue)
+	return mux
+}
+
+func (va *exampleVA) lookup(_ context.Context, hapID string) (*humanattestation.VerificationResponse, error) {
+	va.mu.Lock()
+	defer va.mu.Unlock()
+	return va.claims[hapID], nil
+}
+
+// issue signs a claim once the sender's effort has been verified. A real VA
+// would take payment or check a mail delivery here.
+func (va *exampleVA) issue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req issueRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	claim, err := humanattestation.CreateClaim(humanattestation.CreateClaimParams{
+		Method:        req.Method,
+		Description:   req.Description,
+		RecipientName: req.RecipientName,
+		Domain:        req.Domain,
+		Issuer:        va.issuer,
+		ExpiresInDays: 365,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	jws, err := humanattestation.SignClaim(claim, va.key, va.kid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	verifyURL := fmt.Sprintf("https://%s/v/%s", va.issuer, claim.ID)
+	va.mu.Lock()
+	va.claims[claim.ID] = &humanattestation.VerificationResponse{
+		Valid:     true,
+		ID:        claim.ID,
+		Claim:     claim,
+		JWS:       jws,
+		Issuer:    va.issuer,
+		VerifyURL: verifyURL,
+	}
+	va.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(issueResponse{ID: claim.ID, JWS: jws, VerifyURL: verifyURL})
+}
+
+// Revoke withdraws a claim; the verification API reports it as revoked
+func (va *exampleVA) Revoke(hapID string, reason humanattestation.RevocationReason) error {
+	va.mu.Lock()
+	defer va.mu.Unlock()
+	if _, ok := va.claims[hapID]; !ok {
+		return fmt.Errorf("unknown claim: %s", hapID)
+	}
+	va.claims[hapID] = &humanattestation.VerificationResponse{
+		Valid:            false,
+		ID:               hapID,
+		Revoked:          true,
+		RevocationReason: reason,
+		RevokedAt:        &humanattestation.Timestamp{Time: time.Now().UTC()},
+		Issuer:           va.issuer,
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package humanattestation
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// extensionJWSType is the JWS typ header of signed extension requests
+const extensionJWSType = "hap-extension+jws"
+
+// DefaultMaxExtension is how far past a claim's current expiry
+// NewExtensionHandler extends it by default
+const DefaultMaxExtension = 90 * 24 * time.Hour
+
+// ErrExtensionRefused is returned by SubmitExtensionRequest when the VA
+// declines the request
+var ErrExtensionRefused = errors.New("extension refused")
+
+// ExtensionRequest asks a VA to keep a claim verifiable for longer. It is
+// signed by the claim's recipient with a key published at the recipient
+// domain's /.well-known/hap.json with KeyUseExtensions.
+type ExtensionRequest struct {
+	ID           string `json:"id"`
+	Domain       string `json:"domain"` // Recipient domain whose keys signed the request
+	RequestedExp string `json:"requestedExp"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// extensionBody is the wire form of POST /api/v1/extend/{hapId}
+type extensionBody struct {
+	JWS string `json:"jws"`
+}
+
+// SignExtensionRequest signs an extension request as a JWS
+func SignExtensionRequest(req *ExtensionRequest, privateKey ed25519.PrivateKey, kid string) (string, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize extension request: %w", err)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.EdDSA, Key: privateKey},
+		(&jose.SignerOptions{}).WithHeader("kid", kid).WithType(extensionJWSType),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create signer: %w", err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign extension request: %w", err)
+	}
+	return jws.CompactSerialize()
+}
+
+// SubmitExtensionRequest signs req and POSTs it to the issuer's
+// /api/v1/extend/{hapId}. On success it returns the extended claim after
+// verifying the VA's signature on it. Requests the VA declines return an
+// error wrapping ErrExtensionRefused with the VA's error code.
+func SubmitExtensionRequest(ctx context.Context, issuerDomain string, req *ExtensionRequest, privateKey ed25519.PrivateKey, kid string, opts VerifyOptions) (*Claim, error) {
+	if err := opts.validateID(req.ID); err != nil {
+		return nil, err
+	}
+	requestedExp, err := ParseTimestamp(req.RequestedExp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid requestedExp: %w", err)
+	}
+
+	opts = opts.metered()
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	url, err := opts.endpoint(issuerDomain, "/api/v1/extend/"+req.ID)
+	if err != nil {
+		return nil, err
+	}
+	jws, err := SignExtensionRequest(req, privateKey, kid)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(extensionBody{JWS: jws})
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize request: %w", err)
+	}
+
+	reqCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(reqCtx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := opts.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit extension request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := opts.meter.readAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	var result VerificationResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("failed to submit extension request: HTTP %d", resp.StatusCode)
+		}
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: HTTP %d: %s", ErrExtensionRefused, resp.StatusCode, result.Error)
+	}
+
+	sig, err := VerifySignature(ctx, result.JWS, issuerDomain, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !sig.Valid {
+		return nil, fmt.Errorf("extended claim signature invalid: %s", sig.Error)
+	}
+	if sig.Claim.ID != req.ID {
+		return nil, fmt.Errorf("extended claim ID mismatch: expected %s, got %s", req.ID, sig.Claim.ID)
+	}
+	exp, err := sig.Claim.ExpiresAt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse extended claim expiry: %w", err)
+	}
+	if !exp.IsZero() && exp.Before(requestedExp) {
+		return nil, fmt.Errorf("extended claim expires %s, before requested %s", sig.Claim.Exp, req.RequestedExp)
+	}
+	if err := opts.meter.Err(); err != nil {
+		return nil, err
+	}
+	return sig.Claim, nil
+}
+
+// ClaimExtender extends a claim's expiry on behalf of NewExtensionHandler.
+// It must persist the change, re-sign the claim and return the response the
+// verification API will serve for it from now on.
+type ClaimExtender interface {
+	ExtendClaim(ctx context.Context, claim *Claim, exp time.Time, req *ExtensionRequest) (*VerificationResponse, error)
+}
+
+// ClaimExtenderFunc adapts a function to a ClaimExtender
+type ClaimExtenderFunc func(ctx context.Context, claim *Claim, exp time.Time, req *ExtensionRequest) (*VerificationResponse, error)
+
+// ExtendClaim calls f
+func (f ClaimExtenderFunc) ExtendClaim(ctx context.Context, claim *Claim, exp time.Time, req *ExtensionRequest) (*VerificationResponse, error) {
+	return f(ctx, claim, exp, req)
+}
+
+// ExtensionHandlerOptions configures NewExtensionHandler
+type ExtensionHandlerOptions struct {
+	// MaxExtension caps how far past the claim's current expiry a request may
+	// ask for (default: DefaultMaxExtension)
+	MaxExtension time.Duration
+	// VerifyOptions is used to fetch recipients' keys
+	VerifyOptions VerifyOptions
+}
+
+// NewExtensionHandler returns an http.Handler serving POST
+// /api/v1/extend/{hapId}. It only accepts requests signed by the claim's
+// recipient domain for active, expiring claims, and only up to MaxExtension
+// past the current expiry. Accepted requests are passed to extender, and the
+// response it returns is served to the recipient.
+func NewExtensionHandler(lookup ClaimLookup, extender ClaimExtender, opts ExtensionHandlerOptions) http.Handler {
+	if opts.MaxExtension <= 0 {
+		opts.MaxExtension = DefaultMaxExtension
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		hapID := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if ValidateID(hapID, IDOptions{AllowChecked: true}) != nil {
+			writeVerifyJSON(w, http.StatusBadRequest, VerificationResponse{Error: "invalid_format"})
+			return
+		}
+
+		var body extensionBody
+		r.Body = http.MaxBytesReader(w, r.Body, 16<<10)
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeVerifyJSON(w, http.StatusBadRequest, VerificationResponse{Error: "invalid_request"})
+			return
+		}
+		jws, err := jose.ParseSigned(body.JWS, []jose.SignatureAlgorithm{jose.EdDSA})
+		if err != nil {
+			writeVerifyJSON(w, http.StatusBadRequest, VerificationResponse{Error: "invalid_request"})
+			return
+		}
+		header := jws.Signatures[0].Header
+		if typ, _ := header.ExtraHeaders[jose.HeaderType].(string); typ != extensionJWSType {
+			writeVerifyJSON(w, http.StatusBadRequest, VerificationResponse{Error: "invalid_request"})
+			return
+		}
+		// Only used to pick the recipient's keys; trusted after verification
+		var req ExtensionRequest
+		if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &req); err != nil || req.ID != hapID {
+			writeVerifyJSON(w, http.StatusBadRequest, VerificationResponse{Error: "invalid_request"})
+			return
+		}
+		requestedExp, err := ParseTimestamp(req.RequestedExp)
+		if err != nil {
+			writeVerifyJSON(w, http.StatusBadRequest, VerificationResponse{Error: "invalid_request"})
+			return
+		}
+
+		resp, err := lookup.LookupClaim(r.Context(), hapID)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if resp == nil || resp.Claim == nil {
+			writeVerifyJSON(w, http.StatusNotFound, VerificationResponse{Error: "not_found"})
+			return
+		}
+		claim := resp.Claim
+		if claim.To.Domain == "" || !sameDomain(req.Domain, claim.To.Domain) {
+			writeVerifyJSON(w, http.StatusForbidden, VerificationResponse{Error: "recipient_mismatch"})
+			return
+		}
+
+		if err := verifyExtensionRequest(r.Context(), jws, req.Domain, opts.VerifyOptions); err != nil {
+			writeVerifyJSON(w, http.StatusUnauthorized, VerificationResponse{Error: "invalid_signature"})
+			return
+		}
+
+		currentExp, err := claim.ExpiresAt()
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		switch {
+		case resp.Revoked || !resp.Valid || currentExp.IsZero() || !currentExp.After(time.Now()):
+			writeVerifyJSON(w, http.StatusConflict, VerificationResponse{Error: "not_extendable"})
+			return
+		case !requestedExp.After(currentExp):
+			writeVerifyJSON(w, http.StatusBadRequest, VerificationResponse{Error: "invalid_request"})
+			return
+		case requestedExp.Sub(currentExp) > opts.MaxExtension:
+			writeVerifyJSON(w, http.StatusForbidden, VerificationResponse{Error: "extension_too_long"})
+			return
+		}
+
+		extended, err := extender.ExtendClaim(r.Context(), claim, requestedExp, &req)
+		if err != nil || extended == nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		writeVerifyJSON(w, http.StatusOK, extended)
+	})
+}
+
+// verifyExtensionRequest checks an extension request's signature against
+// the recipient domain's keys allowed for KeyUseExtensions
+func verifyExtensionRequest(ctx context.Context, jws *jose.JSONWebSignature, domain string, opts VerifyOptions) error {
+	wellKnown, _, err := fetchPublicKeys(ctx, domain, opts)
+	if err != nil {
+		return err
+	}
+	publicKey, err := lookupKey(wellKnown.Keys, jws.Signatures[0].Header.KeyID, KeyUseExtensions)
+	if err != nil {
+		return err
+	}
+	if _, err := jws.Verify(publicKey); err != nil {
+		return fmt.Errorf("signature verification failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package humanattestation
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// extensionVA is a fake VA that serves its keys and the extension endpoint,
+// re-signing claims it extends
+type extensionVA struct {
+	*httptest.Server
+	priv ed25519.PrivateKey
+
+	mu        sync.Mutex
+	responses map[string]*VerificationResponse
+}
+
+func newExtensionVA(t *testing.T, opts ExtensionHandlerOptions) *extensionVA {
+	t.Helper()
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	va := &extensionVA{priv: priv, responses: make(map[string]*VerificationResponse)}
+
+	lookup := ClaimLookupFunc(func(ctx context.Context, hapID string) (*VerificationResponse, error) {
+		va.mu.Lock()
+		defer va.mu.Unlock()
+		return va.responses[hapID], nil
+	})
+	extender := ClaimExtenderFunc(func(ctx context.Context, claim *Claim, exp time.Time, req *ExtensionRequest) (*VerificationResponse, error) {
+		extended := *claim
+		extended.Exp = exp.UTC().Format(time.RFC3339)
+		return va.store(&extended)
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/hap.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(WellKnown{Issuer: va.Issuer(), Keys: []JWK{ExportPublicKeyJWKWithUse(pub, "claims_001", KeyUseClaims)}})
+	})
+	mux.Handle("/api/v1/extend/", NewExtensionHandler(lookup, extender, opts))
+
+	va.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(func() {
+		va.Close()
+		FlushKeyCache(va.Issuer())
+	})
+	return va
+}
+
+func (va *extensionVA) Issuer() string {
+	return strings.TrimPrefix(va.URL, "https://")
+}
+
+// store signs claim and serves it from the lookup
+func (va *extensionVA) store(claim *Claim) (*VerificationResponse, error) {
+	jws, err := SignClaim(claim, va.priv, "claims_001")
+	if err != nil {
+		return nil, err
+	}
+	resp := &VerificationResponse{Valid: true, ID: claim.ID, Claim: claim, JWS: jws, Issuer: va.Issuer()}
+	va.mu.Lock()
+	defer va.mu.Unlock()
+	va.responses[claim.ID] = resp
+	return resp, nil
+}
+
+func TestSubmitExtensionRequest(t *testing.T) {
+	recipient := newTestVA(t)
+	recipientKey := recipient.AddKey(t, "extensions_001", KeyUseExtensions)
+	va := newExtensionVA(t, ExtensionHandlerOptions{MaxExtension: 60 * 24 * time.Hour, VerifyOptions: recipient.Options()})
+
+	claim := testClaim(t)
+	claim.Iss = va.Issuer()
+	claim.To.Domain = recipient.Issuer()
+	if _, err := va.store(claim); err != nil {
+		t.Fatal(err)
+	}
+	currentExp, _ := claim.ExpiresAt()
+	requested := currentExp.Add(30 * 24 * time.Hour).Format(time.RFC3339)
+
+	ctx := context.Background()
+	opts := recipient.Options()
+	req := &ExtensionRequest{ID: claim.ID, Domain: recipient.Issuer(), RequestedExp: requested, Reason: "decision next month"}
+	extended, err := SubmitExtensionRequest(ctx, va.Issuer(), req, recipientKey, "extensions_001", opts)
+	if err != nil {
+		t.Fatalf("SubmitExtensionRequest() error = %v", err)
+	}
+	if extended.ID != claim.ID || extended.Exp != requested {
+		t.Errorf("extended claim = %s expiring %s, want %s expiring %s", extended.ID, extended.Exp, claim.ID, requested)
+	}
+
+	refused := func(name string, req *ExtensionRequest, key ed25519.PrivateKey, kid, wantCode string) {
+		t.Helper()
+		_, err := SubmitExtensionRequest(ctx, va.Issuer(), req, key, kid, opts)
+		if !errors.Is(err, ErrExtensionRefused) || !strings.Contains(err.Error(), wantCode) {
+			t.Errorf("%s: error = %v, want %s", name, err, wantCode)
+		}
+	}
+
+	tooLong := *req
+	tooLong.RequestedExp = currentExp.Add(365 * 24 * time.Hour).Format(time.RFC3339)
+	refused("beyond max extension", &tooLong, recipientKey, "extensions_001", "extension_too_long")
+
+	otherRecipient := *req
+	otherRecipient.Domain = "other.example"
+	refused("not the claim's recipient", &otherRecipient, recipientKey, "extensions_001", "recipient_mismatch")
+
+	strangerKey, _, _ := GenerateKeyPair()
+	refused("unknown signing key", req, strangerKey, "extensions_001", "invalid_signature")
+
+	// Keys published for other uses cannot sign extension requests
+	claimsKey := recipient.AddKey(t, "claims_001", KeyUseClaims)
+	refused("wrong key use", req, claimsKey, "claims_001", "invalid_signature")
+
+	unknown := *req
+	unknown.ID = "hap_zzzzzzzzzzzz"
+	refused("unknown claim", &unknown, recipientKey, "extensions_001", "not_found")
+}
+
+func TestExtensionHandlerRejectsMalformed(t *testing.T) {
+	handler := NewExtensionHandler(ClaimLookupFunc(func(ctx context.Context, hapID string) (*VerificationResponse, error) {
+		return nil, nil
+	}), nil, ExtensionHandlerOptions{})
+
+	post := func(path string, body any) int {
+		data, _ := json.Marshal(body)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("POST", path, bytes.NewReader(data)))
+		return rec.Code
+	}
+
+	priv, _, _ := GenerateKeyPair()
+	jws, _ := SignExtensionRequest(&ExtensionRequest{ID: "hap_abc123xyz456", Domain: "acme.com", RequestedExp: "2030-01-01T00:00:00Z"}, priv, "k")
+	claimJWS, _ := SignClaim(testClaim(t), priv, "k")
+
+	if code := post("/api/v1/extend/bogus", extensionBody{JWS: jws}); code != http.StatusBadRequest {
+		t.Errorf("invalid ID status = %d, want 400", code)
+	}
+	if code := post("/api/v1/extend/hap_zzzzzzzzzzzz", extensionBody{JWS: jws}); code != http.StatusBadRequest {
+		t.Errorf("path/payload ID mismatch status = %d, want 400", code)
+	}
+	if code := post("/api/v1/extend/"+testClaim(t).ID, extensionBody{JWS: claimJWS}); code != http.StatusBadRequest {
+		t.Errorf("claim JWS status = %d, want 400", code)
+	}
+	if code := post("/api/v1/extend/hap_abc123xyz456", extensionBody{JWS: jws}); code != http.StatusNotFound {
+		t.Errorf("unknown claim status = %d, want 404", code)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/api/v1/extend/hap_abc123xyz456", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want 405", rec.Code)
+	}
+}
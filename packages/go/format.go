@@ -0,0 +1,148 @@
+package humanattestation
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ANSI escape sequences used by FormatClaimText
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+	ansiRed   = "\x1b[31m"
+	ansiGreen = "\x1b[32m"
+)
+
+// FormatOptions configures FormatClaimText
+type FormatOptions struct {
+	// NoColor disables ANSI colors, for output that is not a terminal
+	NoColor bool
+	// Now is the time validity is judged against (default: time.Now())
+	Now time.Time
+}
+
+// FormatClaimText renders a claim as an aligned key/value block for CLI
+// output, with human-readable timestamps, effort dimensions and a summary of
+// whether the claim is currently within its validity window. It does not
+// verify the claim's signature.
+func FormatClaimText(claim *Claim, opts FormatOptions) string {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	type field struct{ label, value string }
+	fields := []field{
+		{"ID", claim.ID},
+		{"Version", claim.V},
+		{"Issuer", claim.Iss},
+		{"Method", claim.Method},
+	}
+	if claim.Tier != "" {
+		fields = append(fields, field{"Tier", claim.Tier})
+	}
+	if claim.Description != "" {
+		fields = append(fields, field{"Description", claim.Description})
+	}
+
+	to := claim.To.Name
+	if claim.To.Domain != "" {
+		to = fmt.Sprintf("%s (%s)", claim.To.Name, claim.To.Domain)
+	}
+	fields = append(fields,
+		field{"To", to},
+		field{"Issued", formatTimestamp(claim.At, now)},
+	)
+	if claim.Exp != "" {
+		fields = append(fields, field{"Expires", formatTimestamp(claim.Exp, now)})
+	}
+
+	if claim.Cost != nil {
+		fields = append(fields, field{"Cost", CostToDecimalString(*claim.Cost) + " " + claim.Cost.Currency})
+	}
+	if claim.Time != nil {
+		fields = append(fields, field{"Time", (time.Duration(*claim.Time) * time.Second).String()})
+	}
+	if claim.Physical != nil {
+		physical := "no"
+		if *claim.Physical {
+			physical = "yes"
+		}
+		fields = append(fields, field{"Physical", physical})
+	}
+	if claim.Energy != nil {
+		fields = append(fields, field{"Energy", fmt.Sprintf("%d kcal", *claim.Energy)})
+	}
+
+	status, ok := claimStatus(claim, now)
+	fields = append(fields, field{"Status", colorize(status, statusColor(ok), opts.NoColor)})
+
+	width := 0
+	for _, f := range fields {
+		if len(f.label) > width {
+			width = len(f.label)
+		}
+	}
+
+	var b strings.Builder
+	for _, f := range fields {
+		label := fmt.Sprintf("%-*s", width+1, f.label+":")
+		fmt.Fprintf(&b, "%s %s\n", colorize(label, ansiBold, opts.NoColor), f.value)
+	}
+	return b.String()
+}
+
+// formatTimestamp renders a claim timestamp in UTC with a relative age
+func formatTimestamp(value string, now time.Time) string {
+	t, err := ParseTimestamp(value)
+	if err != nil {
+		return value + " (invalid timestamp)"
+	}
+
+	d := t.Sub(now).Round(time.Second)
+	relative := "now"
+	switch {
+	case d > 0:
+		relative = "in " + d.String()
+	case d < 0:
+		relative = (-d).String() + " ago"
+	}
+	return fmt.Sprintf("%s (%s)", t.UTC().Format("2006-01-02 15:04:05 UTC"), relative)
+}
+
+// claimStatus summarizes whether a claim is within its validity window
+func claimStatus(claim *Claim, now time.Time) (string, bool) {
+	at, err := ParseTimestamp(claim.At)
+	if err != nil {
+		return "invalid issue time", false
+	}
+	if at.After(now) {
+		return "not yet valid", false
+	}
+	if claim.Exp == "" {
+		return "valid (no expiry)", true
+	}
+	exp, err := ParseTimestamp(claim.Exp)
+	if err != nil {
+		return "invalid expiry", false
+	}
+	if !exp.After(now) {
+		return "expired", false
+	}
+	return "valid", true
+}
+
+func statusColor(ok bool) string {
+	if ok {
+		return ansiGreen
+	}
+	return ansiRed
+}
+
+func colorize(s, color string, noColor bool) string {
+	if noColor {
+		return s
+	}
+	return color + s + ansiReset
+}
@@ -0,0 +1,106 @@
+package humanattestation
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatClaimText(t *testing.T) {
+	now := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	full := &Claim{
+		V:           Version,
+		ID:          "hap_abc123xyz456",
+		To:          ClaimTarget{Name: "Acme Corp", Domain: "acme.com"},
+		At:          "2026-01-15T10:00:00Z",
+		Exp:         "2026-02-15T10:00:00Z",
+		Iss:         "ballista.jobs",
+		Method:      "ba_priority_mail",
+		Description: "Priority mail packet",
+		Tier:        "express",
+		Cost:        &ClaimCost{Amount: 1500, Currency: "USD"},
+		Time:        IntPtr(1800),
+		Physical:    BoolPtr(true),
+		Energy:      IntPtr(250),
+	}
+	minimal := &Claim{
+		V:      Version,
+		ID:     "hap_minimal12345",
+		To:     ClaimTarget{Name: "Acme Corp"},
+		At:     "2026-01-15T10:00:00Z",
+		Iss:    "ballista.jobs",
+		Method: "ba_email",
+	}
+	expired := &Claim{
+		V:      Version,
+		ID:     "hap_expired12345",
+		To:     ClaimTarget{Name: "Acme Corp"},
+		At:     "2025-01-15T10:00:00Z",
+		Exp:    "2025-02-15T10:00:00Z",
+		Iss:    "ballista.jobs",
+		Method: "ba_email",
+	}
+
+	tests := []struct {
+		name    string
+		claim   *Claim
+		want    []string
+		notWant []string
+	}{
+		{
+			name:  "all dimensions",
+			claim: full,
+			want: []string{
+				"ID:          hap_abc123xyz456",
+				"Tier:        express",
+				"To:          Acme Corp (acme.com)",
+				"Issued:      2026-01-15 10:00:00 UTC (2h0m0s ago)",
+				"Expires:     2026-02-15 10:00:00 UTC (in 742h0m0s)",
+				"Cost:        15.00 USD",
+				"Time:        30m0s",
+				"Physical:    yes",
+				"Energy:      250 kcal",
+				"Status:      valid",
+			},
+		},
+		{
+			name:    "no optional fields",
+			claim:   minimal,
+			want:    []string{"ID:      hap_minimal12345", "To:      Acme Corp\n", "Status:  valid (no expiry)"},
+			notWant: []string{"Cost:", "Expires:", "Tier:", "Physical:"},
+		},
+		{
+			name:  "expired",
+			claim: expired,
+			want:  []string{"Status:  expired"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatClaimText(tt.claim, FormatOptions{NoColor: true, Now: now})
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("output missing %q:\n%s", w, got)
+				}
+			}
+			for _, nw := range tt.notWant {
+				if strings.Contains(got, nw) {
+					t.Errorf("output unexpectedly contains %q:\n%s", nw, got)
+				}
+			}
+			if strings.Contains(got, "\x1b[") {
+				t.Error("NoColor output contains ANSI escapes")
+			}
+		})
+	}
+}
+
+func TestFormatClaimTextColor(t *testing.T) {
+	claim := &Claim{ID: "hap_abc123xyz456", At: "2025-01-15T10:00:00Z", Exp: "2025-02-15T10:00:00Z"}
+	got := FormatClaimText(claim, FormatOptions{Now: time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)})
+	if !strings.Contains(got, ansiRed+"expired"+ansiReset) {
+		t.Errorf("expected red expired status, got %q", got)
+	}
+}
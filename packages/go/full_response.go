@@ -0,0 +1,90 @@
+package humanattestation
+
+import "context"
+
+// FullResponse bundles everything a thin client needs to know about a claim
+type FullResponse struct {
+	ID               string           `json:"id"`
+	Issuer           string           `json:"issuer"`
+	Valid            bool             `json:"valid"` // Signature verified, not revoked, not expired and not issued in the future
+	Claim            *Claim           `json:"claim,omitempty"`
+	JWS              string           `json:"jws,omitempty"`
+	SignatureValid   bool             `json:"signatureValid"`
+	Expired          bool             `json:"expired"`
+	Revoked          bool             `json:"revoked"`
+	RevocationReason RevocationReason `json:"revocationReason,omitempty"`
+	RevokedAt        *Timestamp       `json:"revokedAt,omitempty"`
+	IssuerKids       []string         `json:"issuerKids"` // Kids the issuer currently publishes
+	Error            string           `json:"error,omitempty"`
+}
+
+// BuildFullResponse fetches a claim from its VA, verifies its signature,
+// checks revocation and expiry, and assembles the results together with the
+// issuer's current key IDs, for gateways that answer thin clients in a single
+// payload. Errors are returned only when the VA cannot be reached; an invalid
+// claim is reported through Valid and Error.
+func BuildFullResponse(ctx context.Context, hapID, issuerDomain string, opts VerifyOptions) (*FullResponse, error) {
+	opts = opts.metered()
+
+	resp, err := FetchClaim(ctx, hapID, issuerDomain, opts)
+	if err != nil {
+		return nil, err
+	}
+	wellKnown, _, err := fetchPublicKeys(ctx, issuerDomain, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	full := &FullResponse{
+		ID:               hapID,
+		Issuer:           issuerDomain,
+		Claim:            resp.Claim,
+		JWS:              resp.JWS,
+		Revoked:          resp.Revoked,
+		RevocationReason: resp.RevocationReason,
+		RevokedAt:        resp.RevokedAt,
+		IssuerKids:       make([]string, 0, len(wellKnown.Keys)),
+		Error:            resp.Error,
+	}
+	for _, k := range wellKnown.Keys {
+		full.IssuerKids = append(full.IssuerKids, k.Kid)
+	}
+
+	if resp.Revoked {
+		full.Error = "revoked"
+		return full, nil
+	}
+	if !resp.Valid {
+		return full, nil
+	}
+
+	if resp.JWS == "" {
+		full.Error = "VA returned no signature"
+		return full, nil
+	}
+	if err := opts.meter.signatureAttempt(); err != nil {
+		return nil, err
+	}
+	sig := verifyJWS(resp.JWS, issuerDomain, wellKnown.Keys)
+	if !sig.Valid {
+		full.Error = sig.Error
+		return full, nil
+	}
+	// Report the signed claim rather than the VA's unsigned copy
+	full.SignatureValid, full.Claim = true, sig.Claim
+
+	// verifyJWS has already rejected claims whose at or exp does not parse
+	now := opts.now()
+	if IsClaimExpiredWithLeeway(sig.Claim, now, opts.Leeway) {
+		full.Expired = true
+		full.Error = "expired"
+		return full, nil
+	}
+	if IsClaimNotYetValid(sig.Claim, now, opts.Leeway) {
+		full.Error = "not_yet_valid"
+		return full, nil
+	}
+
+	full.Valid = true
+	return full, nil
+}
@@ -0,0 +1,124 @@
+package humanattestation
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestBuildFullResponse(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+	va.AddKey(t, "webhooks_001", KeyUseWebhooks)
+
+	valid, _ := va.Issue(t, priv, "key_001")
+	revoked, _ := va.Issue(t, priv, "key_001")
+	va.Revoke(revoked.ID, RevocationFraud)
+
+	expired := testClaim(t)
+	expired.Iss = va.Issuer()
+	expired.Exp = time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	jws, err := SignClaim(expired, priv, "key_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	va.SetResponse(VerificationResponse{Valid: true, ID: expired.ID, Claim: expired, JWS: jws, Issuer: va.Issuer()})
+
+	tests := []struct {
+		name                                    string
+		id                                      string
+		valid, signatureValid, expired, revoked bool
+	}{
+		{"valid", valid.ID, true, true, false, false},
+		{"revoked", revoked.ID, false, false, false, true},
+		{"expired", expired.ID, false, true, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			full, err := BuildFullResponse(context.Background(), tt.id, va.Issuer(), va.Options())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if full.Valid != tt.valid || full.SignatureValid != tt.signatureValid || full.Expired != tt.expired || full.Revoked != tt.revoked {
+				t.Errorf("got valid=%v signature=%v expired=%v revoked=%v (error %q)",
+					full.Valid, full.SignatureValid, full.Expired, full.Revoked, full.Error)
+			}
+			if !slices.Equal(full.IssuerKids, []string{"key_001", "webhooks_001"}) {
+				t.Errorf("IssuerKids = %v", full.IssuerKids)
+			}
+		})
+	}
+
+	full, _ := BuildFullResponse(context.Background(), revoked.ID, va.Issuer(), va.Options())
+	if full.RevocationReason != RevocationFraud || full.RevokedAt == nil {
+		t.Errorf("revocation details missing: %+v", full)
+	}
+}
+
+func TestBuildFullResponseLeeway(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+
+	// The issuer's clock runs a few seconds ahead of ours
+	claim := testClaim(t)
+	claim.Iss = va.Issuer()
+	claim.At = time.Now().Add(5 * time.Second).UTC().Format(time.RFC3339)
+	jws, err := SignClaim(claim, priv, "key_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	va.SetResponse(VerificationResponse{Valid: true, ID: claim.ID, Claim: claim, JWS: jws, Issuer: va.Issuer()})
+
+	full, err := BuildFullResponse(context.Background(), claim.ID, va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Valid || full.Error != "not_yet_valid" {
+		t.Errorf("without leeway: valid=%v error=%q", full.Valid, full.Error)
+	}
+
+	opts := va.Options()
+	opts.Leeway = time.Minute
+	full, err = BuildFullResponse(context.Background(), claim.ID, va.Issuer(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !full.Valid {
+		t.Errorf("with leeway: valid=%v error=%q", full.Valid, full.Error)
+	}
+}
+
+func TestBuildFullResponseExpiryBoundary(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+
+	exp := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	claim := testClaim(t)
+	claim.Iss = va.Issuer()
+	claim.At = exp.Add(-2 * time.Hour).Format(time.RFC3339)
+	claim.Exp = exp.Format(time.RFC3339)
+	jws, err := SignClaim(claim, priv, "key_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	va.SetResponse(VerificationResponse{Valid: true, ID: claim.ID, Claim: claim, JWS: jws, Issuer: va.Issuer()})
+
+	opts := va.Options()
+	for _, tt := range []struct {
+		now     time.Time
+		expired bool
+	}{
+		{exp, false},
+		{exp.Add(time.Second), true},
+	} {
+		opts.Clock = func() time.Time { return tt.now }
+		full, err := BuildFullResponse(context.Background(), claim.ID, va.Issuer(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if full.Expired != tt.expired || full.Valid == tt.expired {
+			t.Errorf("now = exp+%s: valid=%v expired=%v error=%q", tt.now.Sub(exp), full.Valid, full.Expired, full.Error)
+		}
+	}
+}
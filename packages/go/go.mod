@@ -2,6 +2,12 @@ module github.com/Blue-Scroll/hap/packages/go
 
 go 1.21
 
-require github.com/go-jose/go-jose/v4 v4.0.1
+require (
+	github.com/go-jose/go-jose/v4 v4.0.1
+	golang.org/x/net v0.21.0
+)
 
-require golang.org/x/crypto v0.19.0 // indirect
+require (
+	golang.org/x/crypto v0.19.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)
@@ -0,0 +1,102 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ClaimLookup finds the verification response a VA serves for a HAP ID.
+// It returns nil with no error when the ID is unknown. Handlers call it from
+// concurrent requests, and do not modify what it returns.
+type ClaimLookup interface {
+	LookupClaim(ctx context.Context, hapID string) (*VerificationResponse, error)
+}
+
+// ClaimLookupFunc adapts a function to a ClaimLookup
+type ClaimLookupFunc func(ctx context.Context, hapID string) (*VerificationResponse, error)
+
+// LookupClaim calls f
+func (f ClaimLookupFunc) LookupClaim(ctx context.Context, hapID string) (*VerificationResponse, error) {
+	return f(ctx, hapID)
+}
+
+// NewVerifyHandler returns an http.Handler serving a VA's verification API at
+// GET /api/v1/verify/{hapId}, with the status codes and error bodies the spec
+// requires. Requests with ?fields=minimal get only the fields FetchClaimMinimal
+// reads. Found claims carry a strong ETag, and requests whose If-None-Match
+// matches it get 304 Not Modified.
+func NewVerifyHandler(lookup ClaimLookup) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Accept IDs with check characters so VAs can issue them
+		hapID := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if ValidateID(hapID, IDOptions{AllowChecked: true}) != nil {
+			writeVerifyJSON(w, http.StatusBadRequest, VerificationResponse{Valid: false, Error: "invalid_format"})
+			return
+		}
+
+		resp, err := lookup.LookupClaim(r.Context(), hapID)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if resp == nil {
+			writeVerifyJSON(w, http.StatusNotFound, VerificationResponse{Valid: false, Error: "not_found"})
+			return
+		}
+
+		if r.URL.Query().Get("fields") == "minimal" {
+			minimal := minimalResponse{Valid: resp.Valid, Revoked: resp.Revoked}
+			if resp.Claim != nil {
+				minimal.To.Domain = resp.Claim.To.Domain
+				minimal.Exp = resp.Claim.Exp
+			}
+			writeTaggedVerifyJSON(w, r, minimal)
+			return
+		}
+		writeTaggedVerifyJSON(w, r, resp)
+	})
+}
+
+// minimalResponse is the wire form of a minimal-disclosure response
+type minimalResponse struct {
+	Valid   bool               `json:"valid"`
+	To      MinimalClaimTarget `json:"to"`
+	Exp     string             `json:"exp,omitempty"`
+	Revoked bool               `json:"revoked,omitempty"`
+}
+
+// writeTaggedVerifyJSON writes v with a strong ETag computed from its body,
+// or 304 Not Modified when the request's If-None-Match already has it. The
+// body covers the claim and its revocation state, so revoking a claim
+// changes its ETag.
+func writeTaggedVerifyJSON(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+	etag := etagForBody(body)
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+func writeVerifyJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,50 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerifyHandler(t *testing.T) {
+	claim := testClaim(t)
+	handler := NewVerifyHandler(ClaimLookupFunc(func(_ context.Context, id string) (*VerificationResponse, error) {
+		if id != claim.ID {
+			return nil, nil
+		}
+		return &VerificationResponse{Valid: true, ID: claim.ID, Claim: claim, Issuer: claim.Iss}, nil
+	}))
+
+	tests := []struct {
+		path   string
+		status int
+		want   string
+	}{
+		{"/api/v1/verify/" + claim.ID, http.StatusOK, ""},
+		{"/api/v1/verify/hap_000000000000", http.StatusNotFound, "not_found"},
+		{"/api/v1/verify/nope", http.StatusBadRequest, "invalid_format"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		var resp VerificationResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		if rec.Code != tt.status || resp.Error != tt.want {
+			t.Errorf("%s = %d %q, want %d %q", tt.path, rec.Code, resp.Error, tt.status, tt.want)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/verify/"+claim.ID+"?fields=minimal", nil))
+	var minimal map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &minimal); err != nil {
+		t.Fatal(err)
+	}
+	if _, leaked := minimal["claim"]; leaked || minimal["valid"] != true {
+		t.Errorf("minimal response = %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,98 @@
+// Package hapotel carries verified claims between services in OpenTelemetry
+// baggage, so services downstream of the one that verified a claim can route
+// on it. Only attributes that say nothing about the sender or recipient are
+// attached: the issuer, the verification method, the tier and the expiry.
+//
+// Baggage travels in a plain header that any caller can set. Trust it only
+// inside your own trust boundary and drop incoming baggage at the edge; to
+// pass a result where that is not possible, use the signed
+// humanattestation.ResultHeaderName header instead.
+package hapotel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	humanattestation "github.com/Blue-Scroll/hap/packages/go"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// Baggage keys set by WithVerifiedClaim
+const (
+	KeyVerified = "hap.verified"
+	KeyIssuer   = "hap.issuer"
+	KeyMethod   = "hap.method"
+	KeyTier     = "hap.tier"
+	KeyExpires  = "hap.exp"
+)
+
+// VerifiedClaim is the part of a verified claim carried in baggage
+type VerifiedClaim struct {
+	Issuer    string
+	Method    string
+	Tier      string    // Empty if the claim has no tier
+	ExpiresAt time.Time // Zero if the claim does not expire
+}
+
+// WithVerifiedClaim returns a copy of ctx whose baggage carries the
+// non-identifying attributes of claim, replacing any set before. Call it
+// only with a claim that passed verification.
+func WithVerifiedClaim(ctx context.Context, claim *humanattestation.Claim) (context.Context, error) {
+	exp, err := claim.ExpiresAt()
+	if err != nil {
+		return ctx, err
+	}
+	var expires string
+	if !exp.IsZero() {
+		expires = exp.UTC().Format(time.RFC3339)
+	}
+
+	bag := baggage.FromContext(ctx)
+	for _, kv := range [][2]string{
+		{KeyVerified, "true"},
+		{KeyIssuer, claim.Iss},
+		{KeyMethod, claim.Method},
+		{KeyTier, claim.Tier},
+		{KeyExpires, expires},
+	} {
+		if kv[1] == "" {
+			bag = bag.DeleteMember(kv[0])
+			continue
+		}
+		member, err := baggage.NewMemberRaw(kv[0], kv[1])
+		if err != nil {
+			return ctx, fmt.Errorf("failed to create baggage member %s: %w", kv[0], err)
+		}
+		if bag, err = bag.SetMember(member); err != nil {
+			return ctx, fmt.Errorf("failed to set baggage member %s: %w", kv[0], err)
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, bag), nil
+}
+
+// VerifiedClaimFromContext returns the claim attributes stored by
+// WithVerifiedClaim, including ones that arrived through baggage
+// propagation. It reports false if the baggage carries no verified claim.
+func VerifiedClaimFromContext(ctx context.Context) (*VerifiedClaim, bool) {
+	bag := baggage.FromContext(ctx)
+	if bag.Member(KeyVerified).Value() != "true" {
+		return nil, false
+	}
+	claim := &VerifiedClaim{
+		Issuer: bag.Member(KeyIssuer).Value(),
+		Method: bag.Member(KeyMethod).Value(),
+		Tier:   bag.Member(KeyTier).Value(),
+	}
+	if claim.Issuer == "" || claim.Method == "" {
+		return nil, false
+	}
+	if exp := bag.Member(KeyExpires).Value(); exp != "" {
+		t, err := time.Parse(time.RFC3339, exp)
+		if err != nil {
+			return nil, false
+		}
+		claim.ExpiresAt = t
+	}
+	return claim, true
+}
@@ -0,0 +1,104 @@
+package hapotel
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	humanattestation "github.com/Blue-Scroll/hap/packages/go"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+func testClaim(t *testing.T) *humanattestation.Claim {
+	t.Helper()
+	claim, err := humanattestation.CreateClaim(humanattestation.CreateClaimParams{
+		Method:        "ba_priority_mail",
+		Description:   "Priority mail packet",
+		RecipientName: "Jane Doe",
+		Domain:        "acme.com",
+		Tier:          "gold",
+		Issuer:        "ballista.jobs",
+		ExpiresInDays: 365,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claim
+}
+
+func TestVerifiedClaimRoundTrip(t *testing.T) {
+	claim := testClaim(t)
+	member, err := baggage.NewMember("tenant", "eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bag, err := baggage.New(member)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := WithVerifiedClaim(baggage.ContextWithBaggage(context.Background(), bag), claim)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Baggage crosses service hops as a header
+	header := http.Header{}
+	propagation.Baggage{}.Inject(ctx, propagation.HeaderCarrier(header))
+	downstream := propagation.Baggage{}.Extract(context.Background(), propagation.HeaderCarrier(header))
+
+	got, ok := VerifiedClaimFromContext(downstream)
+	if !ok {
+		t.Fatalf("no verified claim in %q", header.Get("baggage"))
+	}
+	exp, _ := claim.ExpiresAt()
+	want := VerifiedClaim{Issuer: "ballista.jobs", Method: "ba_priority_mail", Tier: "gold", ExpiresAt: exp.UTC()}
+	if *got != want {
+		t.Errorf("VerifiedClaimFromContext() = %+v, want %+v", *got, want)
+	}
+	if baggage.FromContext(downstream).Member("tenant").Value() != "eu" {
+		t.Error("existing baggage was dropped")
+	}
+	for _, pii := range []string{claim.ID, "Jane", "acme.com", "Priority"} {
+		if strings.Contains(header.Get("baggage"), pii) {
+			t.Errorf("baggage %q contains %q", header.Get("baggage"), pii)
+		}
+	}
+}
+
+func TestVerifiedClaimReplaced(t *testing.T) {
+	ctx, err := WithVerifiedClaim(context.Background(), testClaim(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	untiered := testClaim(t)
+	untiered.Tier, untiered.Exp = "", ""
+	if ctx, err = WithVerifiedClaim(ctx, untiered); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := VerifiedClaimFromContext(ctx)
+	if !ok || got.Tier != "" || !got.ExpiresAt.IsZero() {
+		t.Errorf("VerifiedClaimFromContext() = %+v, %t; want no tier or expiry", got, ok)
+	}
+}
+
+func TestVerifiedClaimMissing(t *testing.T) {
+	if _, ok := VerifiedClaimFromContext(context.Background()); ok {
+		t.Error("verified claim in empty context")
+	}
+	// Issuer and method without the verified marker are not a claim
+	bag, err := baggage.Parse(KeyIssuer + "=ballista.jobs," + KeyMethod + "=ba_priority_mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := VerifiedClaimFromContext(baggage.ContextWithBaggage(context.Background(), bag)); ok {
+		t.Error("unmarked baggage reported as a verified claim")
+	}
+
+	claim := testClaim(t)
+	claim.Exp = "soon"
+	if _, err := WithVerifiedClaim(context.Background(), claim); err == nil {
+		t.Errorf("WithVerifiedClaim() accepted exp %q", claim.Exp)
+	}
+}
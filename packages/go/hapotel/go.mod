@@ -0,0 +1,20 @@
+module github.com/Blue-Scroll/hap/packages/go/hapotel
+
+go 1.21
+
+require (
+	github.com/Blue-Scroll/hap/packages/go v0.4.4
+	go.opentelemetry.io/otel v1.28.0
+)
+
+require (
+	github.com/go-jose/go-jose/v4 v4.0.1 // indirect
+	go.opentelemetry.io/otel/trace v1.28.0 // indirect
+	golang.org/x/crypto v0.19.0 // indirect
+	golang.org/x/net v0.21.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)
+
+// Builds in this repository use the SDK next to this module. Modules that
+// depend on hapotel ignore this and get the version required above.
+replace github.com/Blue-Scroll/hap/packages/go => ../
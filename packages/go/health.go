@@ -0,0 +1,309 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults for HealthOptions
+const (
+	DefaultTLSExpiryWarning  = 14 * 24 * time.Hour
+	DefaultMaxClockSkew      = 5 * time.Minute
+	DefaultHealthConcurrency = 8
+)
+
+// HealthCheckName identifies one check in an IssuerHealthReport
+type HealthCheckName string
+
+const (
+	HealthWellKnown      HealthCheckName = "well_known"
+	HealthKeys           HealthCheckName = "keys"
+	HealthVerifyEndpoint HealthCheckName = "verify_endpoint"
+	HealthTLSCertificate HealthCheckName = "tls_certificate"
+	HealthCacheHeaders   HealthCheckName = "cache_headers"
+	HealthClock          HealthCheckName = "clock"
+)
+
+// HealthStatus is the outcome of a health check. Warnings do not make an
+// issuer unhealthy.
+type HealthStatus string
+
+const (
+	HealthOK      HealthStatus = "ok"
+	HealthWarn    HealthStatus = "warn"
+	HealthFail    HealthStatus = "fail"
+	HealthSkipped HealthStatus = "skipped"
+)
+
+// HealthCheck is the outcome of one check against an issuer
+type HealthCheck struct {
+	Name   HealthCheckName `json:"name"`
+	Status HealthStatus    `json:"status"`
+	Detail string          `json:"detail,omitempty"`
+}
+
+// IssuerHealthReport summarizes the health of one issuer
+type IssuerHealthReport struct {
+	Issuer             string        `json:"issuer"`
+	Healthy            bool          `json:"healthy"`
+	CheckedAt          time.Time     `json:"checkedAt"`
+	WellKnownLatencyMs int64         `json:"wellKnownLatencyMs"`
+	VerifyLatencyMs    int64         `json:"verifyLatencyMs"`
+	KeyCount           int           `json:"keyCount"`
+	TLSExpiresAt       *time.Time    `json:"tlsExpiresAt,omitempty"`
+	ClockSkewSeconds   float64       `json:"clockSkewSeconds"`
+	Checks             []HealthCheck `json:"checks"`
+}
+
+// IssuerHealthSummary aggregates the reports from CheckAllIssuers
+type IssuerHealthSummary struct {
+	Total     int                   `json:"total"`
+	Healthy   int                   `json:"healthy"`
+	Unhealthy int                   `json:"unhealthy"`
+	Reports   []*IssuerHealthReport `json:"reports"`
+}
+
+// HealthOptions configures CheckIssuerHealth
+type HealthOptions struct {
+	VerifyOptions
+	// TLSExpiryWarning warns when the certificate expires sooner than this
+	// (default: DefaultTLSExpiryWarning)
+	TLSExpiryWarning time.Duration
+	// MaxClockSkew fails the clock check when the VA's Date header differs
+	// from the local clock by more than this (default: DefaultMaxClockSkew)
+	MaxClockSkew time.Duration
+	// Concurrency bounds simultaneous checks in CheckAllIssuers
+	// (default: DefaultHealthConcurrency)
+	Concurrency int
+}
+
+// CheckIssuerHealth checks that an issuer is ready to serve verifications:
+// its well-known document is fetchable and valid, its verify endpoint answers
+// an unknown ID with a well-formed not-found response, its TLS certificate is
+// not about to expire, its responses are cacheable and its clock is sane.
+//
+// Failed checks are recorded in the report; an error is only returned when
+// the issuer domain itself is invalid.
+func CheckIssuerHealth(ctx context.Context, issuerDomain string, opts HealthOptions) (*IssuerHealthReport, error) {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.TLSExpiryWarning == 0 {
+		opts.TLSExpiryWarning = DefaultTLSExpiryWarning
+	}
+	if opts.MaxClockSkew == 0 {
+		opts.MaxClockSkew = DefaultMaxClockSkew
+	}
+
+	url, err := opts.endpoint(issuerDomain, "/.well-known/hap.json")
+	if err != nil {
+		return nil, err
+	}
+
+	report := &IssuerHealthReport{Issuer: issuerDomain, CheckedAt: time.Now().UTC()}
+	check := func(name HealthCheckName, status HealthStatus, detail string) {
+		report.Checks = append(report.Checks, HealthCheck{Name: name, Status: status, Detail: detail})
+	}
+
+	resp, body, latency, err := fetchWellKnownForHealth(ctx, url, opts.VerifyOptions)
+	report.WellKnownLatencyMs = latency.Milliseconds()
+	if err != nil {
+		check(HealthWellKnown, HealthFail, err.Error())
+		check(HealthKeys, HealthSkipped, "well-known document unavailable")
+	} else {
+		check(HealthWellKnown, HealthOK, "")
+
+		var wk WellKnown
+		err := json.Unmarshal(body, &wk)
+		if err != nil {
+			err = fmt.Errorf("failed to parse well-known document: %w", err)
+		} else if err = ValidateWellKnown(wk); err == nil && !sameDomain(wk.Issuer, issuerDomain) {
+			err = fmt.Errorf("well-known issuer %s does not match %s", wk.Issuer, issuerDomain)
+		}
+		report.KeyCount = len(wk.Keys)
+		if err != nil {
+			check(HealthKeys, HealthFail, err.Error())
+		} else {
+			check(HealthKeys, HealthOK, "")
+		}
+	}
+
+	// A freshly generated ID is well-formed but unknown to the VA
+	start := time.Now()
+	id, err := GenerateID()
+	var vr *VerificationResponse
+	if err == nil {
+		vr, err = FetchClaim(ctx, id, issuerDomain, opts.VerifyOptions)
+	}
+	report.VerifyLatencyMs = time.Since(start).Milliseconds()
+	switch {
+	case err != nil:
+		check(HealthVerifyEndpoint, HealthFail, err.Error())
+	case vr.Valid || vr.Error == "":
+		check(HealthVerifyEndpoint, HealthFail, "unknown ID did not produce a not-found response")
+	default:
+		check(HealthVerifyEndpoint, HealthOK, "")
+	}
+
+	if resp == nil {
+		check(HealthTLSCertificate, HealthSkipped, "well-known document unavailable")
+		check(HealthCacheHeaders, HealthSkipped, "well-known document unavailable")
+		check(HealthClock, HealthSkipped, "well-known document unavailable")
+	} else {
+		checkTLSExpiry(resp, opts.TLSExpiryWarning, report, check)
+		checkCacheHeaders(resp, check)
+		checkClock(resp, opts.MaxClockSkew, report, check)
+	}
+
+	report.Healthy = true
+	for _, c := range report.Checks {
+		if c.Status == HealthFail {
+			report.Healthy = false
+		}
+	}
+	return report, nil
+}
+
+// CheckAllIssuers runs CheckIssuerHealth for every issuer concurrently.
+// Reports are returned in the order of issuers; an issuer whose domain is
+// invalid gets an unhealthy report rather than aborting the run.
+func CheckAllIssuers(ctx context.Context, issuers []string, opts HealthOptions) *IssuerHealthSummary {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultHealthConcurrency
+	}
+
+	summary := &IssuerHealthSummary{Total: len(issuers), Reports: make([]*IssuerHealthReport, len(issuers))}
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, issuer := range issuers {
+		wg.Add(1)
+		go func(i int, issuer string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			report, err := CheckIssuerHealth(ctx, issuer, opts)
+			if err != nil {
+				report = &IssuerHealthReport{
+					Issuer:    issuer,
+					CheckedAt: time.Now().UTC(),
+					Checks:    []HealthCheck{{Name: HealthWellKnown, Status: HealthFail, Detail: err.Error()}},
+				}
+			}
+			summary.Reports[i] = report
+		}(i, issuer)
+	}
+	wg.Wait()
+
+	for _, r := range summary.Reports {
+		if r.Healthy {
+			summary.Healthy++
+		} else {
+			summary.Unhealthy++
+		}
+	}
+	return summary
+}
+
+// fetchWellKnownForHealth fetches the well-known document, keeping the
+// response so its headers and TLS state can be inspected
+func fetchWellKnownForHealth(ctx context.Context, url string, opts VerifyOptions) (*http.Response, []byte, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	start := time.Now()
+	resp, err := opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, time.Since(start), fmt.Errorf("failed to fetch well-known document: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := opts.metered().meter.readAll(resp.Body)
+	latency := time.Since(start)
+	if err != nil {
+		return resp, nil, latency, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil, latency, fmt.Errorf("failed to fetch well-known document: HTTP %d", resp.StatusCode)
+	}
+	return resp, body, latency, nil
+}
+
+func checkTLSExpiry(resp *http.Response, warning time.Duration, report *IssuerHealthReport, check func(HealthCheckName, HealthStatus, string)) {
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		check(HealthTLSCertificate, HealthSkipped, "no TLS certificate presented")
+		return
+	}
+	notAfter := resp.TLS.PeerCertificates[0].NotAfter.UTC()
+	report.TLSExpiresAt = &notAfter
+
+	remaining := time.Until(notAfter)
+	switch {
+	case remaining <= 0:
+		check(HealthTLSCertificate, HealthFail, fmt.Sprintf("certificate expired at %s", notAfter.Format(time.RFC3339)))
+	case remaining < warning:
+		check(HealthTLSCertificate, HealthWarn, fmt.Sprintf("certificate expires at %s", notAfter.Format(time.RFC3339)))
+	default:
+		check(HealthTLSCertificate, HealthOK, "")
+	}
+}
+
+// checkCacheHeaders warns when the well-known document is served with
+// Cache-Control directives that stop verifiers from caching keys
+func checkCacheHeaders(resp *http.Response, check func(HealthCheckName, HealthStatus, string)) {
+	var defeating []string
+	for _, value := range resp.Header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+			switch directive {
+			case "no-store", "no-cache", "max-age=0":
+				defeating = append(defeating, directive)
+			}
+		}
+	}
+	if len(defeating) > 0 {
+		check(HealthCacheHeaders, HealthWarn, "Cache-Control prevents caching: "+strings.Join(defeating, ", "))
+		return
+	}
+	check(HealthCacheHeaders, HealthOK, "")
+}
+
+// checkClock compares the VA's Date header with the local clock
+func checkClock(resp *http.Response, maxSkew time.Duration, report *IssuerHealthReport, check func(HealthCheckName, HealthStatus, string)) {
+	date := resp.Header.Get("Date")
+	if date == "" {
+		check(HealthClock, HealthSkipped, "no Date header")
+		return
+	}
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		check(HealthClock, HealthFail, fmt.Sprintf("invalid Date header: %v", err))
+		return
+	}
+
+	skew := serverTime.Sub(time.Now())
+	report.ClockSkewSeconds = skew.Seconds()
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxSkew {
+		check(HealthClock, HealthFail, fmt.Sprintf("clock differs from local time by %s", skew.Round(time.Second)))
+		return
+	}
+	check(HealthClock, HealthOK, "")
+}
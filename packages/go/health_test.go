@@ -0,0 +1,133 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func healthStatuses(report *IssuerHealthReport) map[HealthCheckName]HealthStatus {
+	statuses := make(map[HealthCheckName]HealthStatus, len(report.Checks))
+	for _, c := range report.Checks {
+		statuses[c.Name] = c.Status
+	}
+	return statuses
+}
+
+func TestCheckIssuerHealth(t *testing.T) {
+	tests := []struct {
+		name    string
+		faults  testVAFaults
+		noKeys  bool
+		opts    HealthOptions
+		healthy bool
+		want    map[HealthCheckName]HealthStatus
+	}{
+		{
+			name:    "healthy",
+			healthy: true,
+			want: map[HealthCheckName]HealthStatus{
+				HealthWellKnown:      HealthOK,
+				HealthKeys:           HealthOK,
+				HealthVerifyEndpoint: HealthOK,
+				HealthTLSCertificate: HealthOK,
+				HealthCacheHeaders:   HealthOK,
+				HealthClock:          HealthOK,
+			},
+		},
+		{
+			name:   "well-known unavailable",
+			faults: testVAFaults{WellKnownStatus: 503},
+			want:   map[HealthCheckName]HealthStatus{HealthWellKnown: HealthFail, HealthKeys: HealthSkipped},
+		},
+		{
+			name:   "no keys published",
+			noKeys: true,
+			want:   map[HealthCheckName]HealthStatus{HealthWellKnown: HealthOK, HealthKeys: HealthFail},
+		},
+		{
+			name:   "verify endpoint broken",
+			faults: testVAFaults{BrokenVerify: true},
+			want:   map[HealthCheckName]HealthStatus{HealthVerifyEndpoint: HealthFail},
+		},
+		{
+			name:    "cache defeated",
+			faults:  testVAFaults{CacheControl: "public, no-store"},
+			healthy: true,
+			want:    map[HealthCheckName]HealthStatus{HealthCacheHeaders: HealthWarn},
+		},
+		{
+			name:   "clock skew",
+			faults: testVAFaults{ClockOffset: -time.Hour},
+			want:   map[HealthCheckName]HealthStatus{HealthClock: HealthFail},
+		},
+		{
+			name:    "certificate expiring",
+			opts:    HealthOptions{TLSExpiryWarning: 200 * 365 * 24 * time.Hour},
+			healthy: true,
+			want:    map[HealthCheckName]HealthStatus{HealthTLSCertificate: HealthWarn},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			va := newTestVA(t)
+			if !tt.noKeys {
+				va.AddKey(t, "key_001", KeyUseClaims)
+			}
+			va.SetFaults(tt.faults)
+
+			opts := tt.opts
+			opts.VerifyOptions = va.Options()
+			report, err := CheckIssuerHealth(context.Background(), va.Issuer(), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Healthy != tt.healthy {
+				t.Errorf("Healthy = %v, want %v (checks: %+v)", report.Healthy, tt.healthy, report.Checks)
+			}
+			got := healthStatuses(report)
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("%s = %s, want %s", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckAllIssuers(t *testing.T) {
+	healthy := newTestVA(t)
+	healthy.AddKey(t, "key_001", KeyUseClaims)
+	down := newTestVA(t)
+	down.AddKey(t, "key_001", KeyUseClaims)
+	down.SetFaults(testVAFaults{WellKnownStatus: 500})
+
+	// Both fake VAs share the httptest root certificate
+	opts := HealthOptions{VerifyOptions: healthy.Options()}
+	summary := CheckAllIssuers(context.Background(), []string{healthy.Issuer(), down.Issuer(), "bad domain"}, opts)
+
+	if summary.Total != 3 || summary.Healthy != 1 || summary.Unhealthy != 2 {
+		t.Errorf("summary = %d total, %d healthy, %d unhealthy", summary.Total, summary.Healthy, summary.Unhealthy)
+	}
+	if summary.Reports[0].Issuer != healthy.Issuer() || !summary.Reports[0].Healthy {
+		t.Errorf("first report = %+v, want healthy %s", summary.Reports[0], healthy.Issuer())
+	}
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Reports []map[string]any `json:"reports"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"issuer", "healthy", "checkedAt", "wellKnownLatencyMs", "keyCount", "checks"} {
+		if _, ok := decoded.Reports[0][field]; !ok {
+			t.Errorf("report JSON missing %q", field)
+		}
+	}
+}
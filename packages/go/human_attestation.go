@@ -28,10 +28,24 @@
 //	    Physical:      humanattestation.BoolPtr(true),
 //	})
 //	jws, _ := humanattestation.SignClaim(claim, privateKey, "key_001")
+//
+// # Concurrency
+//
+// Functions in this package may be called from any number of goroutines.
+// A VerifyOptions may be shared between them as long as no goroutine
+// modifies it; its HTTPClient, Resolver, Clock and IssuerBaseURL are then
+// called concurrently and must be safe for that, as *http.Client and
+// *net.Resolver are. Stateful types (PublicKeyCache, ClaimCache,
+// WellKnownStore, RecordSet, StringTable, the recheck scheduler and stores,
+// and the recording and replay transports) are safe for concurrent use, as
+// are the handlers the New*Handler functions return. Values returned to
+// callers, such as claims and well-known documents, are their own copies
+// unless documented otherwise.
 package humanattestation
 
 import (
 	"regexp"
+	"time"
 )
 
 // Version is the current protocol version
@@ -40,6 +54,9 @@ const Version = "0.1"
 // CompactVersion is the compact format version
 const CompactVersion = "1"
 
+// CompactVersionV2 is the version 2 compact format version
+const CompactVersionV2 = "2"
+
 // IDRegex validates HAP ID format
 var IDRegex = regexp.MustCompile(`^hap_[a-zA-Z0-9]{12}$`)
 
@@ -49,6 +66,9 @@ var TestIDRegex = regexp.MustCompile(`^hap_test_[a-zA-Z0-9]{8}$`)
 // CompactRegex validates HAP Compact format (9 fields, no type)
 var CompactRegex = regexp.MustCompile(`^HAP1\.hap_[a-zA-Z0-9_]+\.[^.]+\.[^.]+\.[^.]*\.\d+\.\d+\.[^.]+\.[A-Za-z0-9_-]+$`)
 
+// CompactV2Regex validates HAP Compact version 2 format (11 fields: adds tier and kid, empty exp when none)
+var CompactV2Regex = regexp.MustCompile(`^HAP2\.hap_[a-zA-Z0-9_]+\.[A-Za-z0-9%_~-]+\.[A-Za-z0-9%_~-]*\.[A-Za-z0-9%_~-]+\.[A-Za-z0-9%_~-]*\.\d+\.(?:[1-9]\d*)?\.@?[A-Za-z0-9%_~-]+\.[A-Za-z0-9%_~-]*\.[A-Za-z0-9_-]+$`)
+
 // RevocationReason represents reasons for claim revocation
 type RevocationReason string
 
@@ -88,18 +108,51 @@ type Claim struct {
 	Energy      *int        `json:"energy,omitempty"` // kilocalories
 }
 
+// KeyUse restricts what a published key may be used to verify
+type KeyUse string
+
+const (
+	KeyUseClaims     KeyUse = "claims"
+	KeyUseWebhooks   KeyUse = "webhooks"
+	KeyUseReceipts   KeyUse = "receipts"
+	KeyUseStats      KeyUse = "stats"
+	KeyUseTokens     KeyUse = "tokens"
+	KeyUseExtensions KeyUse = "extensions" // Published by recipients, not VAs
+)
+
 // JWK represents a JWK public key for Ed25519
 type JWK struct {
-	Kid string `json:"kid"`
-	Kty string `json:"kty"`
-	Crv string `json:"crv"`
-	X   string `json:"x"`
+	Kid     string     `json:"kid"`
+	Kty     string     `json:"kty"`
+	Crv     string     `json:"crv"`
+	X       string     `json:"x"`
+	Use     KeyUse     `json:"use,omitempty"`     // Absent means KeyUseClaims
+	Created *Timestamp `json:"created,omitempty"` // When the key was introduced, for ordering during rotation
+}
+
+// AllowsUse reports whether the key may verify signatures for the given use.
+// Keys published without a use are accepted for any use for backwards compatibility.
+func (k JWK) AllowsUse(use KeyUse) bool {
+	return k.Use == "" || k.Use == use
+}
+
+// IssuerMethod describes a verification method a VA offers, so senders can
+// see typical cost and duration before starting
+type IssuerMethod struct {
+	Method                 string   `json:"method"`
+	TypicalCostCents       int      `json:"typicalCostCents,omitempty"`
+	TypicalDurationSeconds int      `json:"typicalDurationSeconds,omitempty"`
+	Tiers                  []string `json:"tiers,omitempty"`
+	Enabled                bool     `json:"enabled"`
 }
 
 // WellKnown represents the response from /.well-known/hap.json
 type WellKnown struct {
-	Issuer string `json:"issuer"`
-	Keys   []JWK  `json:"keys"`
+	Issuer       string         `json:"issuer"`
+	Keys         []JWK          `json:"keys"`
+	Methods      []IssuerMethod `json:"methods,omitempty"`
+	MaxBatchSize int            `json:"maxBatchSize,omitempty"` // Most IDs accepted per batch revocation request
+	Discovery    KeySource      `json:"-"`                      // How the SDK found the document: KeySourceNetwork or KeySourceDNSFallback
 }
 
 // VerificationResponse represents a response from the verification API
@@ -112,28 +165,106 @@ type VerificationResponse struct {
 	VerifyURL        string           `json:"verifyUrl,omitempty"`
 	Revoked          bool             `json:"revoked,omitempty"`
 	RevocationReason RevocationReason `json:"revocationReason,omitempty"`
-	RevokedAt        string           `json:"revokedAt,omitempty"`
+	RevokedAt        *Timestamp       `json:"revokedAt,omitempty"`
 	Error            string           `json:"error,omitempty"`
+	// Warnings lists advisory fields FetchClaim found inconsistent with the
+	// request; they are corrected or dropped before the response is returned
+	Warnings []string `json:"-"`
+}
+
+// KeySource identifies how the keys used for verification were obtained
+type KeySource string
+
+const (
+	KeySourceNetwork     KeySource = "network"      // Fetched from the issuer's well-known endpoint
+	KeySourceCache       KeySource = "cache"        // Served from a key cache
+	KeySourceStatic      KeySource = "static"       // Supplied directly by the caller
+	KeySourceDNSFallback KeySource = "dns-fallback" // Discovered via DNS when well-known was unavailable, even if since cached
+	KeySourceTrustBundle KeySource = "trust-bundle" // Loaded from a pre-distributed trust bundle
+)
+
+// KeyProvenance records where the keys used for a verification came from
+type KeyProvenance struct {
+	Source    KeySource     `json:"source"`
+	URL       string        `json:"url,omitempty"`
+	FetchedAt *time.Time    `json:"fetchedAt,omitempty"` // When the keys were fetched; nil for keys not fetched by the SDK
+	ETag      string        `json:"etag,omitempty"`
+	Age       time.Duration `json:"age"` // Age of the keys when they were used
 }
 
 // SignatureVerificationResult represents the result of signature verification
 type SignatureVerificationResult struct {
-	Valid bool
-	Claim *Claim
-	Error string
+	Valid         bool
+	Claim         *Claim
+	Error         string
+	Err           error // Why Valid is false; test with errors.Is
+	KeyProvenance *KeyProvenance
+	Warnings      []string // Non-fatal issues, e.g. an older key verified while a newer one is published
+	// KeyRefreshAttempted is set when the JWS named a kid missing from
+	// cached keys and the keys were refetched to look for it
+	KeyRefreshAttempted bool
 }
 
 // DecodedCompact represents a decoded compact format string
 type DecodedCompact struct {
+	Version   string // Compact format version ("1" or "2")
 	Claim     *Claim
+	Kid       string // Key ID hint (version 2 only)
 	Signature []byte
+	Warnings  []string // Non-fatal issues, e.g. non-canonical version 1 field encoding
 }
 
 // CompactVerificationResult represents the result of compact format verification
 type CompactVerificationResult struct {
-	Valid bool
-	Claim *Claim
-	Error string
+	Valid           bool
+	Claim           *Claim
+	Error           string
+	Err             error // Why Valid is false; test with errors.Is
+	KeyProvenance   *KeyProvenance
+	ChecksPerformed []CheckResult // Status of each check; those not attempted are CheckSkipped
+	FailureReason   FailureReason // Why Valid is false; empty when valid
+	Warnings        []string      // Non-fatal issues, e.g. an older key verified while a newer one is published
+}
+
+// FailureReason classifies why a compact verification failed, so callers can
+// react without parsing Error
+type FailureReason string
+
+const (
+	FailureNoKeys            FailureReason = "no_keys"            // No key usable for claims verified the signature
+	FailureDecodeError       FailureReason = "decode_error"       // Malformed compact string or signature
+	FailureSignatureMismatch FailureReason = "signature_mismatch" // Signature does not match any usable key
+	FailureExpired           FailureReason = "expired"            // Signature is valid but the claim has expired
+	FailureNotYetValid       FailureReason = "not_yet_valid"      // Signature is valid but the claim was issued in the future
+	FailureBudgetExceeded    FailureReason = "budget_exceeded"    // VerifyOptions.Budget ran out before a key verified the signature
+)
+
+// CheckName identifies a verification check
+type CheckName string
+
+const (
+	CheckSignature       CheckName = "signature"
+	CheckNotExpired      CheckName = "not_expired"
+	CheckRecipientDomain CheckName = "recipient_domain"
+	CheckTrustedIssuer   CheckName = "trusted_issuer"
+	CheckNotRevoked      CheckName = "not_revoked"
+	CheckNotSelfIssued   CheckName = "not_self_issued"
+)
+
+// CheckStatus is the outcome of a verification check
+type CheckStatus string
+
+const (
+	CheckPassed  CheckStatus = "passed"
+	CheckFailed  CheckStatus = "failed"
+	CheckSkipped CheckStatus = "skipped"
+)
+
+// CheckResult records the outcome of one verification check
+type CheckResult struct {
+	Name   CheckName   `json:"name"`
+	Status CheckStatus `json:"status"`
+	Detail string      `json:"detail,omitempty"`
 }
 
 // IntPtr is a helper to create a pointer to an int
@@ -0,0 +1,74 @@
+package humanattestation
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// CheckedIDRegex validates the format of a HAP ID carrying a check character
+var CheckedIDRegex = regexp.MustCompile(`^hap_[a-zA-Z0-9]{13}$`)
+
+// Errors returned by ValidateID
+var (
+	ErrInvalidIDFormat = errors.New("invalid HAP ID format")
+	ErrIDChecksum      = errors.New("HAP ID check character does not match; it may have been mistyped")
+)
+
+// IDOptions configures HAP ID validation
+type IDOptions struct {
+	// AllowChecked also accepts 13-character IDs whose last character is a
+	// Luhn mod 62 check character, as produced by GenerateIDChecked
+	AllowChecked bool
+}
+
+// GenerateIDChecked generates a HAP ID with a Luhn mod 62 check character
+// appended, so typos in IDs printed on mail and typed back in by hand are
+// caught before any network call. Verifiers must enable
+// IDOptions.AllowChecked (or VerifyOptions.AllowCheckedIDs) to accept them.
+func GenerateIDChecked() (string, error) {
+	id, err := GenerateID()
+	if err != nil {
+		return "", err
+	}
+	body := strings.TrimPrefix(id, "hap_")
+	return id + string(IDChars[luhnMod62CheckValue(body)]), nil
+}
+
+// ValidateIDChecksum reports whether id is a 13-character HAP ID whose check
+// character matches. It detects every single-character substitution and
+// every adjacent transposition except swapping "A" and "9".
+func ValidateIDChecksum(id string) bool {
+	if !CheckedIDRegex.MatchString(id) {
+		return false
+	}
+	body := strings.TrimPrefix(id, "hap_")
+	return IDChars[luhnMod62CheckValue(body[:len(body)-1])] == body[len(body)-1]
+}
+
+// ValidateID checks a HAP ID's format, and its check character if it has
+// one, explaining why an ID was rejected
+func ValidateID(id string, opts IDOptions) error {
+	if IDRegex.MatchString(id) {
+		return nil
+	}
+	if opts.AllowChecked && CheckedIDRegex.MatchString(id) {
+		if !ValidateIDChecksum(id) {
+			return ErrIDChecksum
+		}
+		return nil
+	}
+	return ErrInvalidIDFormat
+}
+
+// luhnMod62CheckValue computes the Luhn mod N check value of s over IDChars
+func luhnMod62CheckValue(s string) int {
+	n := len(IDChars)
+	factor, sum := 2, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		addend := factor * strings.IndexByte(IDChars, s[i])
+		sum += addend/n + addend%n
+		factor = 3 - factor
+	}
+	return (n - sum%n) % n
+}
@@ -0,0 +1,93 @@
+package humanattestation
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGenerateIDChecked(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		id, err := GenerateIDChecked()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ValidateIDChecksum(id) {
+			t.Fatalf("generated ID %s fails its checksum", id)
+		}
+		if err := ValidateID(id, IDOptions{AllowChecked: true}); err != nil {
+			t.Errorf("ValidateID(%s) = %v", id, err)
+		}
+		if err := ValidateID(id, IDOptions{}); !errors.Is(err, ErrInvalidIDFormat) {
+			t.Errorf("checked ID accepted without AllowChecked: %v", err)
+		}
+	}
+
+	if err := ValidateID("hap_abc123xyz456", IDOptions{AllowChecked: true}); err != nil {
+		t.Errorf("plain ID rejected: %v", err)
+	}
+}
+
+func TestIDChecksumCatchesTypos(t *testing.T) {
+	// Cover every character of the alphabet in every position
+	for offset := 0; offset < len(IDChars); offset++ {
+		body := make([]byte, 12)
+		for i := range body {
+			body[i] = IDChars[(offset+i*5)%len(IDChars)]
+		}
+		id := "hap_" + string(body)
+		id += string(IDChars[luhnMod62CheckValue(string(body))])
+		if !ValidateIDChecksum(id) {
+			t.Fatalf("valid ID %s fails its checksum", id)
+		}
+
+		// Every single-character substitution is caught
+		for pos := 4; pos < len(id); pos++ {
+			for j := 0; j < len(IDChars); j++ {
+				if IDChars[j] == id[pos] {
+					continue
+				}
+				typo := id[:pos] + string(IDChars[j]) + id[pos+1:]
+				if ValidateIDChecksum(typo) {
+					t.Errorf("substitution %s -> %s not caught", id, typo)
+				}
+				if err := ValidateID(typo, IDOptions{AllowChecked: true}); !errors.Is(err, ErrIDChecksum) {
+					t.Errorf("ValidateID(%s) = %v, want ErrIDChecksum", typo, err)
+				}
+			}
+		}
+
+		// Every adjacent transposition is caught, except swapping "A" and "9"
+		for pos := 4; pos < len(id)-1; pos++ {
+			a, b := id[pos], id[pos+1]
+			if a == b || (a == 'A' && b == '9') || (a == '9' && b == 'A') {
+				continue
+			}
+			typo := id[:pos] + string(b) + string(a) + id[pos+2:]
+			if ValidateIDChecksum(typo) {
+				t.Errorf("transposition %s -> %s not caught", id, typo)
+			}
+		}
+	}
+}
+
+func TestFetchClaimCheckedID(t *testing.T) {
+	va := newTestVA(t)
+	id, err := GenerateIDChecked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	va.SetResponse(VerificationResponse{Valid: true, ID: id, Issuer: va.Issuer()})
+
+	opts := va.Options()
+	resp, err := FetchClaim(context.Background(), id, va.Issuer(), opts)
+	if err != nil || resp.Error != "invalid_format" {
+		t.Errorf("checked ID without AllowCheckedIDs: %+v, %v", resp, err)
+	}
+
+	opts.AllowCheckedIDs = true
+	resp, err = FetchClaim(context.Background(), id, va.Issuer(), opts)
+	if err != nil || !resp.Valid {
+		t.Errorf("checked ID with AllowCheckedIDs: %+v, %v", resp, err)
+	}
+}
@@ -0,0 +1,90 @@
+package humanattestation
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// issuerAliasPrefix marks an issuer field holding a registered alias code.
+// The compact field encoder always escapes '@', so it cannot begin a domain.
+const issuerAliasPrefix = "@"
+
+// IssuerAliasRegex validates issuer alias codes
+var IssuerAliasRegex = regexp.MustCompile(`^[a-zA-Z0-9]{1,16}$`)
+
+// ErrUnknownIssuerAlias is returned when a compact string references an issuer
+// alias that has not been registered with RegisterIssuerAlias
+var ErrUnknownIssuerAlias = errors.New("unknown issuer alias")
+
+var (
+	issuerAliasesMu sync.RWMutex
+	issuerAliases   = map[string]string{}
+)
+
+// RegisterIssuerAlias registers a short code that compact strings may use in
+// place of the issuer domain. Signers and verifiers must register the same
+// aliases; decoding fails for codes the verifier does not know. It may be
+// called while other goroutines decode compact strings.
+func RegisterIssuerAlias(code, domain string) error {
+	if !IssuerAliasRegex.MatchString(code) {
+		return fmt.Errorf("invalid issuer alias code: %q", code)
+	}
+	if domain == "" {
+		return errors.New("issuer alias domain is empty")
+	}
+
+	issuerAliasesMu.Lock()
+	defer issuerAliasesMu.Unlock()
+	if existing, ok := issuerAliases[code]; ok && existing != domain {
+		return fmt.Errorf("issuer alias %s already registered for %s", code, existing)
+	}
+	issuerAliases[code] = domain
+	return nil
+}
+
+// ResolveIssuerAlias returns the domain registered for an alias code
+func ResolveIssuerAlias(code string) (string, bool) {
+	issuerAliasesMu.RLock()
+	defer issuerAliasesMu.RUnlock()
+	domain, ok := issuerAliases[code]
+	return domain, ok
+}
+
+// SignCompactWithIssuerAlias signs a claim in compact format, storing the
+// registered alias code instead of the full issuer domain
+func SignCompactWithIssuerAlias(claim *Claim, privateKey ed25519.PrivateKey, code string) (string, error) {
+	domain, ok := ResolveIssuerAlias(code)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownIssuerAlias, code)
+	}
+	if domain != claim.Iss {
+		return "", fmt.Errorf("issuer alias %s is registered for %s, not %s", code, domain, claim.Iss)
+	}
+
+	payload, err := buildCompactPayload(claim, issuerAliasPrefix+code)
+	if err != nil {
+		return "", err
+	}
+
+	signature := ed25519.Sign(privateKey, []byte(payload))
+	return payload + "." + base64urlEncode(signature), nil
+}
+
+// decodeIssuerField decodes a compact issuer field with the version's field
+// decoder, resolving alias codes
+func decodeIssuerField(value string, decode func(string) (string, error)) (string, error) {
+	if !strings.HasPrefix(value, issuerAliasPrefix) {
+		return decode(value)
+	}
+
+	code := strings.TrimPrefix(value, issuerAliasPrefix)
+	domain, ok := ResolveIssuerAlias(code)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownIssuerAlias, code)
+	}
+	return domain, nil
+}
@@ -0,0 +1,168 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// JWTClaimName is the private claim name under which a HAP claim is nested
+// inside a caller's own JWT payload.
+//
+// The nested value has the shape:
+//
+//	"hap": {
+//	    "claim": { ...HAP claim JSON... },
+//	    "jws":   "<original VA JWS>"
+//	}
+//
+// The VA's JWS is carried unchanged so the inner signature can still be
+// verified against the issuer's keys. Signing and verifying the outer JWT is
+// the caller's responsibility.
+const JWTClaimName = "hap"
+
+// JWTType is the typ header VerifyFromJWT requires of the outer JWT. Issuers
+// sign it with a claims key, so the type keeps other JWSs made with that key,
+// such as claims themselves, from passing as one.
+const JWTType = "hap+jwt"
+
+// ErrJWTMissingHAPClaim is returned when a JWT payload has no nested HAP claim
+var ErrJWTMissingHAPClaim = fmt.Errorf("JWT payload missing %q claim", JWTClaimName)
+
+// EmbedClaimInJWTPayload builds the nested "hap" claim for a JWT payload.
+// The JWS payload must describe the same claim as claim.
+func EmbedClaimInJWTPayload(claim *Claim, jwsString string) (map[string]interface{}, error) {
+	jws, err := jose.ParseSigned(jwsString, []jose.SignatureAlgorithm{jose.EdDSA})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWS: %w", err)
+	}
+
+	var signed Claim
+	if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &signed); err != nil {
+		return nil, fmt.Errorf("failed to parse JWS payload: %w", err)
+	}
+	if !reflect.DeepEqual(&signed, claim) {
+		return nil, errors.New("claim does not match JWS payload")
+	}
+
+	claimMap, err := toJSONMap(claim)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize claim: %w", err)
+	}
+
+	return map[string]interface{}{
+		JWTClaimName: map[string]interface{}{
+			"claim": claimMap,
+			"jws":   jwsString,
+		},
+	}, nil
+}
+
+// ExtractClaimFromJWTPayload pulls the nested HAP claim and its JWS out of a
+// decoded JWT payload and verifies the JWS against the issuer's keys.
+// The embedded claim JSON must match the verified JWS payload exactly.
+func ExtractClaimFromJWTPayload(payload map[string]interface{}, wellKnown *WellKnown) (*Claim, string, error) {
+	nested, ok := payload[JWTClaimName].(map[string]interface{})
+	if !ok {
+		return nil, "", ErrJWTMissingHAPClaim
+	}
+
+	jwsString, ok := nested["jws"].(string)
+	if !ok || jwsString == "" {
+		return nil, "", fmt.Errorf("%q claim missing jws", JWTClaimName)
+	}
+
+	claimJSON, err := json.Marshal(nested["claim"])
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to serialize embedded claim: %w", err)
+	}
+	var embedded Claim
+	if err := json.Unmarshal(claimJSON, &embedded); err != nil {
+		return nil, "", fmt.Errorf("failed to parse embedded claim: %w", err)
+	}
+
+	result := verifyJWS(jwsString, wellKnown.Issuer, wellKnown.Keys)
+	if !result.Valid {
+		return nil, "", fmt.Errorf("embedded JWS invalid: %s", result.Error)
+	}
+	if !reflect.DeepEqual(result.Claim, &embedded) {
+		return nil, "", errors.New("embedded claim does not match JWS payload")
+	}
+
+	return result.Claim, jwsString, nil
+}
+
+// VerifyFromJWT verifies a bearer JWT signed by the issuer that wraps a HAP
+// claim under the "hap" private claim, and returns the nested claim.
+//
+// The outer token must be an EdDSA JWT typed JWTType whose kid names one of
+// the issuer's claim-signing keys. If it carries "iss", it must equal
+// issuerDomain, and "exp"/"nbf" are enforced when present, with opts.Leeway.
+// The nested VA JWS is then verified against the same keys.
+func VerifyFromJWT(ctx context.Context, token, issuerDomain string, opts VerifyOptions) (*Claim, error) {
+	opts = opts.metered()
+	wellKnown, _, err := fetchPublicKeys(ctx, issuerDomain, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := jwt.ParseSigned(token, []jose.SignatureAlgorithm{jose.EdDSA})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWT: %w", err)
+	}
+	if len(parsed.Headers) == 0 || parsed.Headers[0].KeyID == "" {
+		return nil, errors.New("JWT header missing kid")
+	}
+	if typ, _ := parsed.Headers[0].ExtraHeaders[jose.HeaderType].(string); typ != JWTType {
+		return nil, fmt.Errorf("JWT typ %q is not %s", typ, JWTType)
+	}
+
+	publicKey, err := lookupKey(wellKnown.Keys, parsed.Headers[0].KeyID, KeyUseClaims)
+	if err != nil {
+		return nil, err
+	}
+
+	// The outer JWT and the nested JWS are each verified once
+	if err := opts.meter.signatureAttempt(); err != nil {
+		return nil, err
+	}
+	if err := opts.meter.signatureAttempt(); err != nil {
+		return nil, err
+	}
+
+	var std jwt.Claims
+	var payload map[string]interface{}
+	if err := parsed.Claims(publicKey, &std, &payload); err != nil {
+		return nil, fmt.Errorf("JWT signature verification failed: %w", err)
+	}
+	if std.Issuer != "" && !sameDomain(std.Issuer, issuerDomain) {
+		return nil, fmt.Errorf("issuer mismatch: expected %s, got %s", issuerDomain, std.Issuer)
+	}
+	if err := std.ValidateWithLeeway(jwt.Expected{Time: opts.now()}, opts.Leeway); err != nil {
+		return nil, fmt.Errorf("invalid JWT: %w", err)
+	}
+
+	claim, _, err := ExtractClaimFromJWTPayload(payload, &WellKnown{Issuer: issuerDomain, Keys: wellKnown.Keys})
+	if err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// toJSONMap round-trips a value through JSON into a generic map
+func toJSONMap(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,162 @@
+package humanattestation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+func TestEmbedAndExtractClaimInJWTPayload(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	claim := testClaim(t)
+	jws, err := SignClaim(claim, priv, "key_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wellKnown := &WellKnown{Issuer: claim.Iss, Keys: []JWK{jwk}}
+
+	payload, err := EmbedClaimInJWTPayload(claim, jws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload["sub"] = "user_123"
+
+	// Round-trip through JSON as the outer JWT would
+	data, _ := json.Marshal(payload)
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	got, gotJWS, err := ExtractClaimFromJWTPayload(decoded, wellKnown)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotJWS != jws || got.ID != claim.ID {
+		t.Errorf("extracted claim %s / jws mismatch", got.ID)
+	}
+
+	// Tampering with the embedded claim JSON must be detected
+	decoded[JWTClaimName].(map[string]interface{})["claim"].(map[string]interface{})["method"] = "forged"
+	if _, _, err := ExtractClaimFromJWTPayload(decoded, wellKnown); err == nil {
+		t.Error("expected error for tampered embedded claim")
+	}
+
+	if _, _, err := ExtractClaimFromJWTPayload(map[string]interface{}{"sub": "user_123"}, wellKnown); err == nil {
+		t.Error("expected error for payload without hap claim")
+	}
+}
+
+func TestEmbedClaimInJWTPayloadRejectsMismatch(t *testing.T) {
+	priv, _ := testKeys(t, "key_001")
+	claim := testClaim(t)
+	jws, err := SignClaim(claim, priv, "key_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := *claim
+	other.Method = "other_method"
+	if _, err := EmbedClaimInJWTPayload(&other, jws); err == nil {
+		t.Error("expected error when claim does not match JWS")
+	}
+}
+
+func signOuterJWT(t *testing.T, priv ed25519.PrivateKey, kid string, payload map[string]interface{}) string {
+	t.Helper()
+	return signOuterJWTWithType(t, priv, kid, JWTType, payload)
+}
+
+func signOuterJWTWithType(t *testing.T, priv ed25519.PrivateKey, kid, typ string, payload map[string]interface{}) string {
+	t.Helper()
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.EdDSA, Key: priv},
+		(&jose.SignerOptions{}).WithType(jose.ContentType(typ)).WithHeader("kid", kid),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Signed(signer).Claims(payload).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestVerifyFromJWT(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+	claim, jws := va.Issue(t, priv, "key_001")
+
+	payload, err := EmbedClaimInJWTPayload(claim, jws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload["iss"] = va.Issuer()
+	payload["exp"] = time.Now().Add(time.Hour).Unix()
+
+	got, err := VerifyFromJWT(context.Background(), signOuterJWT(t, priv, "key_001", payload), va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.ID != claim.ID {
+		t.Errorf("claim ID = %s, want %s", got.ID, claim.ID)
+	}
+}
+
+func TestVerifyFromJWTMissingHAPClaim(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+
+	token := signOuterJWT(t, priv, "key_001", map[string]interface{}{"iss": va.Issuer(), "sub": "user_123"})
+	if _, err := VerifyFromJWT(context.Background(), token, va.Issuer(), va.Options()); !errors.Is(err, ErrJWTMissingHAPClaim) {
+		t.Errorf("err = %v, want ErrJWTMissingHAPClaim", err)
+	}
+}
+
+func TestVerifyFromJWTType(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+	claim, jws := va.Issue(t, priv, "key_001")
+	payload, err := EmbedClaimInJWTPayload(claim, jws)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A JWS signed with the claims key for another purpose is not a HAP JWT
+	for _, typ := range []string{"JWT", claimJWSType, ""} {
+		token := signOuterJWTWithType(t, priv, "key_001", typ, payload)
+		if _, err := VerifyFromJWT(context.Background(), token, va.Issuer(), va.Options()); err == nil {
+			t.Errorf("typ %q: VerifyFromJWT() accepted the token", typ)
+		}
+	}
+}
+
+func TestVerifyFromJWTLeeway(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+	claim, jws := va.Issue(t, priv, "key_001")
+	payload, err := EmbedClaimInJWTPayload(claim, jws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	payload["exp"] = exp.Unix()
+	token := signOuterJWT(t, priv, "key_001", payload)
+
+	opts := va.Options()
+	opts.Clock = func() time.Time { return exp.Add(30 * time.Second) }
+	if _, err := VerifyFromJWT(context.Background(), token, va.Issuer(), opts); err == nil {
+		t.Error("VerifyFromJWT() accepted a JWT 30s past exp without leeway")
+	}
+	opts.Leeway = time.Minute
+	if _, err := VerifyFromJWT(context.Background(), token, va.Issuer(), opts); err != nil {
+		t.Errorf("VerifyFromJWT() with a minute of leeway: %v", err)
+	}
+}
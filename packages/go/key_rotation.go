@@ -0,0 +1,46 @@
+package humanattestation
+
+import (
+	"fmt"
+	"sort"
+)
+
+// keysNewestFirst orders keys by their Created time, newest first, so the
+// current key is tried before keys kept for a rotation overlap. Keys without
+// a Created time keep their order after the dated ones.
+func keysNewestFirst(keys []JWK) []JWK {
+	ordered := append([]JWK(nil), keys...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ci, cj := ordered[i].Created, ordered[j].Created
+		if ci == nil || cj == nil {
+			return ci != nil && cj == nil
+		}
+		return ci.After(cj.Time)
+	})
+	return ordered
+}
+
+// olderKeyWarning returns a warning if the key kid that verified a claim is
+// older than another claims key the issuer publishes, or "" otherwise
+func olderKeyWarning(keys []JWK, kid string) string {
+	var used *JWK
+	for i := range keys {
+		if keys[i].Kid == kid {
+			used = &keys[i]
+			break
+		}
+	}
+	if used == nil || used.Created == nil {
+		return ""
+	}
+	for _, k := range keysNewestFirst(keys) {
+		if k.Created == nil || !k.AllowsUse(KeyUseClaims) {
+			continue
+		}
+		if k.Created.After(used.Created.Time) {
+			return fmt.Sprintf("verified with older key %s while newer key %s is published", kid, k.Kid)
+		}
+		break
+	}
+	return ""
+}
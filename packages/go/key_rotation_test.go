@@ -0,0 +1,154 @@
+package humanattestation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rotationKeys returns an old and a new claims key, the new one created a month later
+func rotationKeys(t *testing.T) (oldPriv ed25519.PrivateKey, oldJWK JWK, newJWK JWK) {
+	t.Helper()
+	priv, oldJWK := testKeys(t, "key_2025")
+	_, newJWK = testKeys(t, "key_2026")
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	oldJWK.Created = &Timestamp{created.AddDate(0, -1, 0)}
+	newJWK.Created = &Timestamp{created}
+	return priv, oldJWK, newJWK
+}
+
+func TestKeysNewestFirst(t *testing.T) {
+	_, oldJWK, newJWK := rotationKeys(t)
+	_, undated := testKeys(t, "key_undated")
+
+	got := keysNewestFirst([]JWK{undated, oldJWK, newJWK})
+	var kids []string
+	for _, k := range got {
+		kids = append(kids, k.Kid)
+	}
+	if want := "key_2026,key_2025,key_undated"; strings.Join(kids, ",") != want {
+		t.Errorf("keysNewestFirst() = %v, want %s", kids, want)
+	}
+}
+
+func TestVerifyCompactOlderKeyWarning(t *testing.T) {
+	oldPriv, oldJWK, newJWK := rotationKeys(t)
+
+	// Version 1 strings have no kid hint, so key order decides which is tried
+	compact, err := SignCompact(testClaim(t), oldPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := VerifyCompact(compact, []JWK{oldJWK, newJWK})
+	if !result.Valid {
+		t.Fatalf("VerifyCompact() error = %s", result.Error)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "older key key_2025") {
+		t.Errorf("Warnings = %v, want older key warning", result.Warnings)
+	}
+
+	// No warning once the old key is the newest published
+	result = VerifyCompact(compact, []JWK{oldJWK})
+	if !result.Valid || len(result.Warnings) != 0 {
+		t.Errorf("VerifyCompact() with only the old key = %v, warnings %v", result.Valid, result.Warnings)
+	}
+
+	// Undated keys never warn
+	oldJWK.Created, newJWK.Created = nil, nil
+	if result := VerifyCompact(compact, []JWK{oldJWK, newJWK}); len(result.Warnings) != 0 {
+		t.Errorf("Warnings = %v for undated keys", result.Warnings)
+	}
+}
+
+func TestVerifySignatureOlderKeyWarning(t *testing.T) {
+	va := newTestVA(t)
+	oldPriv := va.AddKey(t, "key_2025", KeyUseClaims)
+	va.AddKey(t, "key_2026", KeyUseClaims)
+	va.mu.Lock()
+	va.wellKnown.Keys[0].Created = &Timestamp{time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	va.wellKnown.Keys[1].Created = &Timestamp{time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	va.mu.Unlock()
+
+	_, jws := va.Issue(t, oldPriv, "key_2025")
+	result, err := VerifySignature(context.Background(), jws, va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid {
+		t.Fatalf("VerifySignature() error = %s", result.Error)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "newer key key_2026") {
+		t.Errorf("Warnings = %v, want older key warning", result.Warnings)
+	}
+}
+
+func TestVerifySignatureRefreshesKeysForUnknownKid(t *testing.T) {
+	va := newTestVA(t)
+	oldPriv := va.AddKey(t, "key_001", KeyUseClaims)
+	_, oldJWS := va.Issue(t, oldPriv, "key_001")
+
+	now := time.Now()
+	opts := va.Options()
+	opts.KeyCache = NewPublicKeyCache(time.Hour)
+	opts.Clock = func() time.Time { return now }
+	ctx := context.Background()
+
+	if r, err := VerifySignature(ctx, oldJWS, va.Issuer(), opts); err != nil || !r.Valid || r.KeyRefreshAttempted {
+		t.Fatalf("VerifySignature() before rotation = %+v, %v", r, err)
+	}
+
+	// The VA rotates to a new key while the old set is still cached
+	va.mu.Lock()
+	va.wellKnown.Keys = nil
+	va.mu.Unlock()
+	newPriv := va.AddKey(t, "key_002", KeyUseClaims)
+	_, newJWS := va.Issue(t, newPriv, "key_002")
+	now = now.Add(MinKeyRefreshInterval)
+
+	r, err := VerifySignature(ctx, newJWS, va.Issuer(), opts)
+	if err != nil || !r.Valid || !r.KeyRefreshAttempted {
+		t.Fatalf("VerifySignature() after rotation = %+v, %v", r, err)
+	}
+	if r.KeyProvenance.Source != KeySourceNetwork {
+		t.Errorf("KeyProvenance.Source = %s, want keys from the refresh", r.KeyProvenance.Source)
+	}
+	if n := va.keyFetches.Load(); n != 2 {
+		t.Fatalf("key fetches = %d, want 2", n)
+	}
+
+	// The refreshed keys are cached for later calls
+	if r, err := VerifySignature(ctx, newJWS, va.Issuer(), opts); err != nil || !r.Valid || r.KeyRefreshAttempted {
+		t.Errorf("VerifySignature() with refreshed cache = %+v, %v", r, err)
+	}
+
+	// Unknown kids refetch at most once per MinKeyRefreshInterval
+	claim := testClaim(t)
+	claim.Iss = va.Issuer()
+	for i := 0; i < 5; i++ {
+		forged, err := SignClaim(claim, newPriv, fmt.Sprintf("key_9%02d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := VerifySignature(ctx, forged, va.Issuer(), opts)
+		if err != nil || r.Valid || !errors.Is(r.Err, ErrKeyNotFound) || r.KeyRefreshAttempted {
+			t.Errorf("VerifySignature() for unknown kid %d = %+v, %v", i, r, err)
+		}
+	}
+	if n := va.keyFetches.Load(); n != 2 {
+		t.Errorf("key fetches after unknown kids = %d, want 2", n)
+	}
+
+	now = now.Add(MinKeyRefreshInterval)
+	forged, _ := SignClaim(claim, newPriv, "key_999")
+	r, err = VerifySignature(ctx, forged, va.Issuer(), opts)
+	if err != nil || r.Valid || !errors.Is(r.Err, ErrKeyNotFound) || !r.KeyRefreshAttempted {
+		t.Errorf("VerifySignature() for unknown kid after interval = %+v, %v", r, err)
+	}
+	if n := va.keyFetches.Load(); n != 3 {
+		t.Errorf("key fetches = %d, want exactly one refresh", n)
+	}
+}
@@ -0,0 +1,385 @@
+package humanattestation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultKeyCacheTTL is how long keys fetched from an issuer are reused by
+// default when the issuer's response carries no Cache-Control max-age
+const DefaultKeyCacheTTL = 5 * time.Minute
+
+// maxKeyCacheEntries bounds a PublicKeyCache; when it is full the entry
+// that expires first is evicted, which is an expired one if there are any
+const maxKeyCacheEntries = 1000
+
+// MaxKeyCacheTTL caps how long an issuer's Cache-Control max-age keeps keys
+// cached by default, so a VA cannot pin stale keys in verifiers for long
+const MaxKeyCacheTTL = time.Hour
+
+// PublicKeyCache holds well-known documents fetched from issuers, keyed by
+// the issuer's ASCII host, the URL they were fetched from and whether DNS
+// discovery was enabled, so keys fetched through an IssuerBaseURL override
+// or found in DNS are never served to callers that would not have fetched
+// them. It honors the Cache-Control and ETag headers of
+// the responses and is safe for concurrent use; concurrent misses for one
+// issuer share a single fetch when they would fetch it the same way. It holds
+// at most 1000 entries, evicting the one that expires first to make room.
+//
+// Verification uses a process-wide cache unless VerifyOptions.KeyCache names
+// another one.
+type PublicKeyCache struct {
+	ttl        time.Duration
+	maxEntries int
+	mu         sync.Mutex
+	entries    map[keyCacheKey]*keyCacheEntry
+}
+
+// keyCacheKey identifies the well-known document of an issuer as fetched
+// from one URL, with or without DNS discovery
+type keyCacheKey struct {
+	host string // The issuer's ASCII host, for Flush
+	url  string // Where the document is fetched from
+	dns  bool   // Whether DNS discovery may supply the keys
+}
+
+// NewPublicKeyCache returns an empty cache. ttl is how long keys are reused
+// when the issuer sends no max-age, and caps the max-age it does send; zero
+// uses DefaultKeyCacheTTL and MaxKeyCacheTTL. A KeyCacheTTL in the options
+// of a call takes precedence.
+func NewPublicKeyCache(ttl time.Duration) *PublicKeyCache {
+	return &PublicKeyCache{ttl: ttl, maxEntries: maxKeyCacheEntries, entries: make(map[keyCacheKey]*keyCacheEntry)}
+}
+
+type keyCacheEntry struct {
+	ready      chan struct{} // Closed once the fetch completes
+	config     fetchConfig   // How the fetch was made
+	wellKnown  *WellKnown
+	provenance *KeyProvenance
+	validators cacheValidators
+	err        error
+	expires    time.Time // Stale entries are kept to be revalidated
+}
+
+// fetchConfig is what a key fetch depends on besides its URL and whether
+// DNS discovery is enabled, which are part of the cache key. A caller only
+// joins a fetch in flight made with an equal config.
+type fetchConfig struct {
+	client   *http.Client
+	timeout  time.Duration
+	insecure bool
+	resolver TXTResolver // Only set with DNS discovery
+}
+
+// fetchConfigOf returns the fetch config of opts, with defaults filled in
+func fetchConfigOf(opts VerifyOptions) fetchConfig {
+	config := fetchConfig{client: opts.HTTPClient, timeout: opts.Timeout, insecure: opts.AllowInsecureHTTP}
+	if config.client == nil {
+		config.client = http.DefaultClient
+	}
+	if config.timeout == 0 {
+		config.timeout = DefaultTimeout
+	}
+	if opts.EnableDNSDiscovery {
+		config.resolver = opts.resolver()
+	}
+	return config
+}
+
+// equal reports whether two configs fetch keys the same way. Resolvers of
+// a type that cannot be compared, such as a map, are never equal.
+func (c fetchConfig) equal(other fetchConfig) bool {
+	if c.client != other.client || c.timeout != other.timeout || c.insecure != other.insecure {
+		return false
+	}
+	if c.resolver == nil || other.resolver == nil {
+		return c.resolver == other.resolver
+	}
+	return reflect.TypeOf(c.resolver).Comparable() && c.resolver == other.resolver
+}
+
+// cacheValidators are the ETag and Last-Modified of a cached response, sent
+// back to revalidate it with a conditional request
+type cacheValidators struct {
+	etag         string
+	lastModified string
+}
+
+// validatorsOf returns the validators in a response's headers
+func validatorsOf(h http.Header) cacheValidators {
+	return cacheValidators{etag: h.Get("ETag"), lastModified: h.Get("Last-Modified")}
+}
+
+// setConditional makes req conditional on the validators
+func (v cacheValidators) setConditional(req *http.Request) {
+	if v.etag != "" {
+		req.Header.Set("If-None-Match", v.etag)
+	}
+	if v.lastModified != "" {
+		req.Header.Set("If-Modified-Since", v.lastModified)
+	}
+}
+
+// wellKnownFetch is a well-known response and its caching headers
+type wellKnownFetch struct {
+	wellKnown  *WellKnown // nil when the issuer answered 304 Not Modified
+	provenance *KeyProvenance
+	validators cacheValidators
+	cache      cacheDirectives
+}
+
+// cacheDirectives are the Cache-Control directives of a response that
+// matter to the key cache
+type cacheDirectives struct {
+	maxAge    time.Duration // Remaining freshness, already reduced by the Age header
+	hasMaxAge bool
+	noCache   bool
+	noStore   bool
+}
+
+// parseCacheDirectives reads the Cache-Control and Age headers. Unknown
+// directives are ignored; a malformed max-age is treated as absent.
+func parseCacheDirectives(h http.Header) cacheDirectives {
+	var d cacheDirectives
+	for _, value := range h.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			switch strings.ToLower(name) {
+			case "no-store":
+				d.noStore = true
+			case "no-cache":
+				d.noCache = true
+			case "max-age":
+				seconds, err := strconv.ParseInt(strings.Trim(arg, `"`), 10, 64)
+				if err != nil || seconds < 0 {
+					continue
+				}
+				d.maxAge = time.Duration(min(seconds, int64(math.MaxInt64/time.Second))) * time.Second
+				d.hasMaxAge = true
+			}
+		}
+	}
+	if age, err := strconv.ParseInt(h.Get("Age"), 10, 64); err == nil && age > 0 && d.hasMaxAge {
+		d.maxAge = max(d.maxAge-time.Duration(min(age, int64(math.MaxInt64/time.Second)))*time.Second, 0)
+	}
+	return d
+}
+
+// freshness returns how long a response may be served without
+// revalidation, given the caller's KeyCacheTTL
+func (d cacheDirectives) freshness(keyCacheTTL time.Duration) time.Duration {
+	switch {
+	case d.noCache:
+		return 0
+	case !d.hasMaxAge && keyCacheTTL > 0:
+		return keyCacheTTL
+	case !d.hasMaxAge:
+		return DefaultKeyCacheTTL
+	case keyCacheTTL > 0:
+		return min(d.maxAge, keyCacheTTL)
+	default:
+		return min(d.maxAge, MaxKeyCacheTTL)
+	}
+}
+
+var defaultKeyCache = NewPublicKeyCache(0)
+
+// FetchPublicKeys fetches an issuer's public keys through the cache. It is
+// FetchPublicKeys with opts.KeyCache set to c.
+func (c *PublicKeyCache) FetchPublicKeys(ctx context.Context, issuerDomain string, opts VerifyOptions) (*WellKnown, error) {
+	opts.KeyCache = c
+	return FetchPublicKeys(ctx, issuerDomain, opts)
+}
+
+// Flush discards the cached keys of issuerDomain, or of every issuer if
+// issuerDomain is empty
+func (c *PublicKeyCache) Flush(issuerDomain string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if issuerDomain == "" {
+		c.entries = make(map[keyCacheKey]*keyCacheEntry)
+		return
+	}
+	if host, err := hostToASCII(issuerDomain); err == nil {
+		for key := range c.entries {
+			if key.host == host {
+				delete(c.entries, key)
+			}
+		}
+	}
+}
+
+// FlushKeyCache discards the process-wide cached keys of issuerDomain, or
+// of every issuer if issuerDomain is empty
+func FlushKeyCache(issuerDomain string) {
+	defaultKeyCache.Flush(issuerDomain)
+}
+
+// get returns the cached document for key, calling fetch on a miss. A
+// stale document is revalidated by passing its validators to fetch, and
+// kept if the issuer answers 304 Not Modified.
+//
+// Concurrent callers for one key share a single fetch, including callers
+// that bypass or disable the cache, if their fetch configs are equal. The
+// fetch is detached from the caller that started it, so a caller whose
+// context ends stops waiting without failing the others.
+func (c *PublicKeyCache) get(ctx context.Context, key keyCacheKey, opts VerifyOptions, fetch func(context.Context, cacheValidators) (*wellKnownFetch, error)) (*WellKnown, *KeyProvenance, error) {
+	for {
+		e, started := c.join(ctx, key, opts, fetch)
+		select {
+		case <-e.ready:
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		if started {
+			if e.err != nil {
+				return nil, nil, e.err
+			}
+			provenance := *e.provenance
+			return cloneWellKnown(e.wellKnown), &provenance, nil
+		}
+		// A failure that belongs to the caller that started the fetch, such
+		// as its work budget running out, is not shared; fetch again
+		if e.err != nil && !cacheableKeyError(e.err) {
+			continue
+		}
+		return e.result()
+	}
+}
+
+// join returns the entry for key to wait on and whether this call started
+// its fetch
+func (c *PublicKeyCache) join(ctx context.Context, key keyCacheKey, opts VerifyOptions, fetch func(context.Context, cacheValidators) (*wellKnownFetch, error)) (*keyCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	uncached := opts.BypassKeyCache || opts.KeyCacheTTL < 0
+	config := fetchConfigOf(opts)
+	e := &keyCacheEntry{ready: make(chan struct{}), config: config}
+	prev := c.entries[key]
+	var stale, restore *keyCacheEntry
+	if prev != nil {
+		select {
+		case <-prev.ready:
+			switch {
+			case opts.KeyCacheTTL < 0:
+				restore = prev
+			case uncached:
+			case opts.now().Before(prev.expires):
+				return prev, false
+			case prev.err == nil:
+				stale = prev
+			}
+		default:
+			if prev.config.equal(config) {
+				return prev, false
+			}
+			// Fetch alongside without caching, leaving the fetch in
+			// flight to fill the entry
+			go c.fill(context.WithoutCancel(ctx), key, e, nil, nil, opts, fetch)
+			return e, true
+		}
+	}
+	if prev == nil {
+		c.evict()
+	}
+	c.entries[key] = e
+	go c.fill(context.WithoutCancel(ctx), key, e, stale, restore, opts, fetch)
+	return e, true
+}
+
+// evict makes room for one more entry if the cache is full, removing the
+// finished entry that expires first, or any entry if every fetch is still in
+// flight. Callers waiting on a removed fetch still get its result. The
+// caller must hold c.mu.
+func (c *PublicKeyCache) evict() {
+	if len(c.entries) < c.maxEntries {
+		return
+	}
+	var victim keyCacheKey
+	var first *keyCacheEntry
+	for key, e := range c.entries {
+		select {
+		case <-e.ready:
+			if first == nil || e.expires.Before(first.expires) {
+				victim, first = key, e
+			}
+		default:
+			if first == nil {
+				victim = key
+			}
+		}
+	}
+	delete(c.entries, victim)
+}
+
+// fill runs fetch for e and publishes the result. An entry that is not kept
+// is removed, putting back restore if the fetch displaced it; an entry that
+// is not in the cache is left out of it either way.
+func (c *PublicKeyCache) fill(ctx context.Context, key keyCacheKey, e, stale, restore *keyCacheEntry, opts VerifyOptions, fetch func(context.Context, cacheValidators) (*wellKnownFetch, error)) {
+	var validators cacheValidators
+	if stale != nil {
+		validators = stale.validators
+	}
+	fetched, err := fetch(ctx, validators)
+	store := opts.KeyCacheTTL >= 0
+	keep := false
+	switch {
+	case err == nil:
+		e.wellKnown, e.provenance, e.validators = fetched.wellKnown, fetched.provenance, fetched.validators
+		if e.wellKnown == nil {
+			e.wellKnown = stale.wellKnown
+		}
+		ttl := opts.KeyCacheTTL
+		if ttl == 0 {
+			ttl = c.ttl
+		}
+		e.expires = opts.now().Add(fetched.cache.freshness(ttl))
+		keep = store && !fetched.cache.noStore
+	case store && opts.NegativeKeyCacheTTL > 0 && cacheableKeyError(err):
+		e.err = err
+		e.expires = opts.now().Add(opts.NegativeKeyCacheTTL)
+		keep = true
+	default:
+		e.err = err
+	}
+	close(e.ready)
+
+	if !keep {
+		c.mu.Lock()
+		if c.entries[key] == e {
+			if restore != nil {
+				c.entries[key] = restore
+			} else {
+				delete(c.entries, key)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
+
+// result returns a copy of the entry, marked as served from the cache
+// unless it came from DNS, which callers must still be able to tell
+func (e *keyCacheEntry) result() (*WellKnown, *KeyProvenance, error) {
+	if e.err != nil {
+		return nil, nil, fmt.Errorf("%w (cached)", e.err)
+	}
+	provenance := *e.provenance
+	if provenance.Source != KeySourceDNSFallback {
+		provenance.Source = KeySourceCache
+	}
+	return cloneWellKnown(e.wellKnown), &provenance, nil
+}
+
+// cacheableKeyError reports whether a failed fetch says something about the
+// issuer rather than about this caller
+func cacheableKeyError(err error) bool {
+	return !errors.Is(err, ErrWorkBudgetExceeded) && !errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,495 @@
+package humanattestation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyCacheOneFetchPerTTL(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	_, jws := va.Issue(t, priv, "claims_001")
+	ctx := context.Background()
+
+	now := time.Now()
+	opts := va.Options()
+	opts.Clock = func() time.Time { return now }
+
+	for i := 0; i < 1000; i++ {
+		result, err := VerifySignature(ctx, jws, va.Issuer(), opts)
+		if err != nil || !result.Valid {
+			t.Fatalf("VerifySignature() = %+v, %v", result, err)
+		}
+		want := KeySourceCache
+		if i == 0 {
+			want = KeySourceNetwork
+		}
+		if result.KeyProvenance.Source != want {
+			t.Fatalf("call %d provenance = %s, want %s", i, result.KeyProvenance.Source, want)
+		}
+	}
+	if n := va.keyFetches.Load(); n != 1 {
+		t.Errorf("well-known fetches = %d, want 1", n)
+	}
+
+	// The next window fetches again
+	now = now.Add(DefaultKeyCacheTTL + time.Second)
+	VerifySignature(ctx, jws, va.Issuer(), opts)
+	VerifySignature(ctx, jws, va.Issuer(), opts)
+	if n := va.keyFetches.Load(); n != 2 {
+		t.Errorf("well-known fetches after TTL = %d, want 2", n)
+	}
+
+	bypass := opts
+	bypass.BypassKeyCache = true
+	VerifySignature(ctx, jws, va.Issuer(), bypass)
+	if n := va.keyFetches.Load(); n != 3 {
+		t.Errorf("well-known fetches after bypass = %d, want 3", n)
+	}
+
+	FlushKeyCache(va.Issuer())
+	VerifySignature(ctx, jws, va.Issuer(), opts)
+	if n := va.keyFetches.Load(); n != 4 {
+		t.Errorf("well-known fetches after flush = %d, want 4", n)
+	}
+
+	disabled := opts
+	disabled.KeyCacheTTL = -1
+	VerifySignature(ctx, jws, va.Issuer(), disabled)
+	VerifySignature(ctx, jws, va.Issuer(), disabled)
+	if n := va.keyFetches.Load(); n != 6 {
+		t.Errorf("well-known fetches with cache disabled = %d, want 6", n)
+	}
+}
+
+func TestKeyCacheConcurrentMisses(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	_, jws := va.Issue(t, priv, "claims_001")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if result, err := VerifySignature(context.Background(), jws, va.Issuer(), va.Options()); err != nil || !result.Valid {
+				t.Errorf("VerifySignature() = %+v, %v", result, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := va.keyFetches.Load(); n != 1 {
+		t.Errorf("well-known fetches = %d, want 1", n)
+	}
+}
+
+func TestKeyCacheCoalescesFetches(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts func(*VerifyOptions)
+	}{
+		{"cached", func(*VerifyOptions) {}},
+		{"bypass", func(o *VerifyOptions) { o.BypassKeyCache = true }},
+		{"disabled", func(o *VerifyOptions) { o.KeyCacheTTL = -1 }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			va := newTestVA(t)
+			va.AddKey(t, "claims_001", KeyUseClaims)
+			hold := make(chan struct{})
+			va.SetFaults(testVAFaults{HoldWellKnown: hold})
+			opts := va.Options()
+			opts.KeyCache = NewPublicKeyCache(0)
+			tc.opts(&opts)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if wk, err := FetchPublicKeys(context.Background(), va.Issuer(), opts); err != nil || len(wk.Keys) != 1 {
+						t.Errorf("FetchPublicKeys() = %+v, %v", wk, err)
+					}
+				}()
+			}
+			waitForKeyFetches(t, va, 1)
+			// Let the other callers join the fetch in flight
+			time.Sleep(50 * time.Millisecond)
+			close(hold)
+			wg.Wait()
+			if n := va.keyFetches.Load(); n != 1 {
+				t.Errorf("well-known fetches = %d, want 1", n)
+			}
+		})
+	}
+}
+
+func TestKeyCacheJoinsOnlyEqualFetches(t *testing.T) {
+	va := newTestVA(t)
+	va.AddKey(t, "claims_001", KeyUseClaims)
+	hold := make(chan struct{})
+	va.SetFaults(testVAFaults{HoldWellKnown: hold})
+	opts := va.Options()
+	opts.KeyCache = NewPublicKeyCache(0)
+
+	started := make(chan error, 1)
+	go func() {
+		_, err := FetchPublicKeys(context.Background(), va.Issuer(), opts)
+		started <- err
+	}()
+	waitForKeyFetches(t, va, 1)
+
+	// A caller with another HTTP client makes its own request rather than
+	// waiting for, and trusting, the one in flight
+	other := opts
+	other.BypassKeyCache = true
+	other.HTTPClient = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody, Request: r}, nil
+	})}
+	done := make(chan error, 1)
+	go func() {
+		_, err := FetchPublicKeys(context.Background(), va.Issuer(), other)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "HTTP 503") {
+			t.Errorf("FetchPublicKeys() with another client = %v, want HTTP 503", err)
+		}
+	case <-time.After(5 * time.Second):
+		close(hold)
+		t.Fatal("FetchPublicKeys() with another client joined the fetch in flight")
+	}
+
+	close(hold)
+	if err := <-started; err != nil {
+		t.Errorf("first caller = %v", err)
+	}
+	// The separate fetch left the cached keys alone
+	if wk, err := FetchPublicKeys(context.Background(), va.Issuer(), opts); err != nil || len(wk.Keys) != 1 || va.keyFetches.Load() != 1 {
+		t.Errorf("FetchPublicKeys() after fetch = %+v, %v with %d fetches", wk, err, va.keyFetches.Load())
+	}
+}
+
+func TestKeyCacheCancelledCaller(t *testing.T) {
+	va := newTestVA(t)
+	va.AddKey(t, "claims_001", KeyUseClaims)
+	hold := make(chan struct{})
+	va.SetFaults(testVAFaults{HoldWellKnown: hold})
+	opts := va.Options()
+	opts.KeyCache = NewPublicKeyCache(0)
+
+	// The caller that starts the fetch gives up while it is in flight
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan error, 1)
+	go func() {
+		_, err := FetchPublicKeys(ctx, va.Issuer(), opts)
+		started <- err
+	}()
+	waitForKeyFetches(t, va, 1)
+	joined := make(chan error, 1)
+	go func() {
+		_, err := FetchPublicKeys(context.Background(), va.Issuer(), opts)
+		joined <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-started; !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled caller = %v, want context.Canceled", err)
+	}
+
+	close(hold)
+	if err := <-joined; err != nil {
+		t.Errorf("other caller = %v", err)
+	}
+	if n := va.keyFetches.Load(); n != 1 {
+		t.Errorf("well-known fetches = %d, want 1", n)
+	}
+	// The shared result was cached for later callers
+	if _, err := FetchPublicKeys(context.Background(), va.Issuer(), opts); err != nil || va.keyFetches.Load() != 1 {
+		t.Errorf("FetchPublicKeys() after fetch = %v with %d fetches", err, va.keyFetches.Load())
+	}
+}
+
+// waitForKeyFetches waits until va has received n well-known requests
+func waitForKeyFetches(t *testing.T, va *testVA, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for va.keyFetches.Load() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("well-known fetches = %d, want %d", va.keyFetches.Load(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestKeyCacheErrors(t *testing.T) {
+	va := newTestVA(t)
+	va.AddKey(t, "claims_001", KeyUseClaims)
+	va.SetFaults(testVAFaults{WellKnownStatus: http.StatusServiceUnavailable})
+	ctx := context.Background()
+
+	// Failures are not cached by default
+	for i := 0; i < 3; i++ {
+		if _, err := FetchPublicKeys(ctx, va.Issuer(), va.Options()); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if n := va.keyFetches.Load(); n != 3 {
+		t.Errorf("well-known fetches = %d, want 3", n)
+	}
+
+	opts := va.Options()
+	opts.NegativeKeyCacheTTL = time.Minute
+	for i := 0; i < 3; i++ {
+		if _, err := FetchPublicKeys(ctx, va.Issuer(), opts); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+	if n := va.keyFetches.Load(); n != 4 {
+		t.Errorf("well-known fetches with negative caching = %d, want 4", n)
+	}
+
+	// A bypass recovers once the issuer does
+	va.SetFaults(testVAFaults{})
+	opts.BypassKeyCache = true
+	if _, err := FetchPublicKeys(ctx, va.Issuer(), opts); err != nil {
+		t.Errorf("FetchPublicKeys() with bypass = %v", err)
+	}
+}
+
+func TestKeyCacheConditionalRefresh(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	fetch := func(t *testing.T, va *testVA) *KeyProvenance {
+		t.Helper()
+		opts := va.Options()
+		opts.Clock = func() time.Time { return now }
+		_, provenance, err := fetchPublicKeys(ctx, va.Issuer(), opts)
+		if err != nil {
+			t.Fatalf("fetchPublicKeys() = %v", err)
+		}
+		return provenance
+	}
+
+	t.Run("200 with ETag then 304", func(t *testing.T) {
+		va := newTestVA(t)
+		va.AddKey(t, "claims_001", KeyUseClaims)
+		va.SetFaults(testVAFaults{ETag: true, CacheControl: "public, max-age=60"})
+
+		if p := fetch(t, va); p.Source != KeySourceNetwork || p.ETag == "" {
+			t.Fatalf("first fetch provenance = %+v", p)
+		}
+		now = now.Add(59 * time.Second)
+		if p := fetch(t, va); p.Source != KeySourceCache {
+			t.Errorf("fetch within max-age provenance = %s, want cache", p.Source)
+		}
+		if n := va.keyFetches.Load(); n != 1 {
+			t.Errorf("well-known fetches within max-age = %d, want 1", n)
+		}
+
+		// Stale: revalidated, and the 304 keeps the cached document
+		now = now.Add(2 * time.Second)
+		p := fetch(t, va)
+		if p.Source != KeySourceNetwork || p.FetchedAt == nil || !p.FetchedAt.Equal(now) {
+			t.Errorf("revalidated provenance = %+v", p)
+		}
+		if n := va.notModified.Load(); n != 1 {
+			t.Fatalf("304 responses = %d, want 1", n)
+		}
+		if p := fetch(t, va); p.Source != KeySourceCache {
+			t.Errorf("fetch after 304 provenance = %s, want cache", p.Source)
+		}
+
+		// A changed document is fetched in full
+		va.AddKey(t, "claims_002", KeyUseClaims)
+		now = now.Add(61 * time.Second)
+		wellKnown, _, err := fetchPublicKeys(ctx, va.Issuer(), VerifyOptions{HTTPClient: va.Client(), Clock: func() time.Time { return now }})
+		if err != nil || len(wellKnown.Keys) != 2 {
+			t.Fatalf("fetch after change = %+v, %v", wellKnown, err)
+		}
+		if n := va.notModified.Load(); n != 1 {
+			t.Errorf("304 responses after change = %d, want 1", n)
+		}
+	})
+
+	t.Run("Last-Modified", func(t *testing.T) {
+		va := newTestVA(t)
+		va.AddKey(t, "claims_001", KeyUseClaims)
+		va.SetFaults(testVAFaults{LastModified: now.Add(-time.Hour), CacheControl: "max-age=10"})
+
+		fetch(t, va)
+		now = now.Add(11 * time.Second)
+		fetch(t, va)
+		if n := va.notModified.Load(); n != 1 {
+			t.Errorf("304 responses = %d, want 1", n)
+		}
+	})
+
+	t.Run("no caching headers", func(t *testing.T) {
+		va := newTestVA(t)
+		va.AddKey(t, "claims_001", KeyUseClaims)
+
+		fetch(t, va)
+		now = now.Add(DefaultKeyCacheTTL - time.Second)
+		if p := fetch(t, va); p.Source != KeySourceCache {
+			t.Errorf("fetch within TTL provenance = %s, want cache", p.Source)
+		}
+		// Nothing to revalidate with, so the refresh is unconditional
+		now = now.Add(2 * time.Second)
+		if p := fetch(t, va); p.Source != KeySourceNetwork {
+			t.Errorf("fetch after TTL provenance = %s, want network", p.Source)
+		}
+		if n, m := va.keyFetches.Load(), va.notModified.Load(); n != 2 || m != 0 {
+			t.Errorf("well-known fetches = %d, 304 responses = %d; want 2, 0", n, m)
+		}
+	})
+
+	t.Run("no-cache and no-store", func(t *testing.T) {
+		va := newTestVA(t)
+		va.AddKey(t, "claims_001", KeyUseClaims)
+		va.SetFaults(testVAFaults{ETag: true, CacheControl: "no-cache"})
+		for i := 0; i < 3; i++ {
+			fetch(t, va)
+		}
+		if n, m := va.keyFetches.Load(), va.notModified.Load(); n != 3 || m != 2 {
+			t.Errorf("no-cache: well-known fetches = %d, 304 responses = %d; want 3, 2", n, m)
+		}
+
+		FlushKeyCache(va.Issuer())
+		va.SetFaults(testVAFaults{ETag: true, CacheControl: "no-store"})
+		for i := 0; i < 3; i++ {
+			fetch(t, va)
+		}
+		if n, m := va.keyFetches.Load(), va.notModified.Load(); n != 6 || m != 2 {
+			t.Errorf("no-store: well-known fetches = %d, 304 responses = %d; want 6, 2", n, m)
+		}
+	})
+}
+
+func TestCacheDirectivesFreshness(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		age          string
+		keyCacheTTL  time.Duration
+		want         time.Duration
+	}{
+		{"", "", 0, DefaultKeyCacheTTL},
+		{"", "", time.Minute, time.Minute},
+		{"public, max-age=120", "", 0, 2 * time.Minute},
+		{"max-age=120", "30", 0, 90 * time.Second},
+		{"max-age=120", "300", 0, 0},
+		{"max-age=86400", "", 0, MaxKeyCacheTTL},
+		{"max-age=86400", "", 2 * time.Hour, 2 * time.Hour},
+		{"max-age=60", "", 2 * time.Hour, time.Minute},
+		{"MAX-AGE=60", "", 0, time.Minute},
+		{"max-age=soon", "", 0, DefaultKeyCacheTTL},
+		{"max-age=60, no-cache", "", 0, 0},
+	}
+	for _, tt := range tests {
+		h := http.Header{}
+		if tt.cacheControl != "" {
+			h.Set("Cache-Control", tt.cacheControl)
+		}
+		if tt.age != "" {
+			h.Set("Age", tt.age)
+		}
+		if got := parseCacheDirectives(h).freshness(tt.keyCacheTTL); got != tt.want {
+			t.Errorf("freshness(%q, Age %q, %s) = %s, want %s", tt.cacheControl, tt.age, tt.keyCacheTTL, got, tt.want)
+		}
+	}
+}
+
+func TestPublicKeyCache(t *testing.T) {
+	va := newTestVA(t)
+	va.AddKey(t, "claims_001", KeyUseClaims)
+	ctx := context.Background()
+
+	now := time.Now()
+	opts := va.Options()
+	opts.Clock = func() time.Time { return now }
+	cache := NewPublicKeyCache(time.Minute)
+
+	// Concurrent misses share one fetch
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.FetchPublicKeys(ctx, va.Issuer(), opts); err != nil {
+				t.Errorf("FetchPublicKeys() = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := va.keyFetches.Load(); n != 1 {
+		t.Fatalf("well-known fetches = %d, want 1", n)
+	}
+
+	// The cache is separate from the process-wide one
+	FetchPublicKeys(ctx, va.Issuer(), opts)
+	if n := va.keyFetches.Load(); n != 2 {
+		t.Errorf("well-known fetches through the default cache = %d, want 2", n)
+	}
+
+	// Verification uses the cache given in the options, with its TTL
+	withCache := opts
+	withCache.KeyCache = cache
+	now = now.Add(59 * time.Second)
+	if _, provenance, err := fetchPublicKeys(ctx, va.Issuer(), withCache); err != nil || provenance.Source != KeySourceCache {
+		t.Errorf("fetch within cache TTL = %+v, %v", provenance, err)
+	}
+	now = now.Add(2 * time.Second)
+	if _, provenance, err := fetchPublicKeys(ctx, va.Issuer(), withCache); err != nil || provenance.Source != KeySourceNetwork {
+		t.Errorf("fetch after cache TTL = %+v, %v", provenance, err)
+	}
+
+	cache.Flush(va.Issuer())
+	cache.FetchPublicKeys(ctx, va.Issuer(), opts)
+	if n := va.keyFetches.Load(); n != 4 {
+		t.Errorf("well-known fetches after flush = %d, want 4", n)
+	}
+}
+
+func TestKeyCacheEviction(t *testing.T) {
+	vas := make([]*testVA, 3)
+	for i := range vas {
+		vas[i] = newTestVA(t)
+		vas[i].AddKey(t, "claims_001", KeyUseClaims)
+	}
+	ctx := context.Background()
+	now := time.Now()
+	cache := NewPublicKeyCache(time.Minute)
+	cache.maxEntries = 2
+	fetch := func(va *testVA) {
+		t.Helper()
+		opts := va.Options()
+		opts.Clock = func() time.Time { return now }
+		if _, err := cache.FetchPublicKeys(ctx, va.Issuer(), opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fetch(vas[0])
+	now = now.Add(10 * time.Second)
+	fetch(vas[1])
+	fetch(vas[2])
+	if n := len(cache.entries); n != 2 {
+		t.Fatalf("cache holds %d entries, want 2", n)
+	}
+
+	// The entry expiring first made room; the others are still cached
+	fetch(vas[1])
+	fetch(vas[2])
+	fetch(vas[0])
+	for i, want := range []int32{2, 1, 1} {
+		if n := vas[i].keyFetches.Load(); n != want {
+			t.Errorf("issuer %d fetched %d times, want %d", i, n, want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package humanattestation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrKeyPinMismatch is matched by a KeyPinError: a claim was signed by a key
+// not pinned for its issuer
+var ErrKeyPinMismatch = errors.New("claim not signed by a pinned key")
+
+// KeyPinError reports that a claim was signed by a key the issuer publishes
+// but that is not pinned for it. Unlike ErrSignatureInvalid, it means the
+// key set itself changed: a rotation signed only by the new key, a
+// compromised issuer or a man in the middle adding its own key. It matches
+// ErrKeyPinMismatch.
+type KeyPinError struct {
+	Issuer  string
+	Pinned  []string // Thumbprints pinned for the issuer
+	Fetched []string // Thumbprints of the keys the issuer now publishes
+}
+
+func (e *KeyPinError) Error() string {
+	return fmt.Sprintf("%s for %s: pinned %s, got %s", ErrKeyPinMismatch, e.Issuer,
+		strings.Join(e.Pinned, ","), strings.Join(e.Fetched, ","))
+}
+
+func (e *KeyPinError) Is(target error) bool {
+	return target == ErrKeyPinMismatch
+}
+
+// KeyPinStore holds the key thumbprints pinned for each issuer (see
+// JWK.Thumbprint). Get returns nil for an issuer with no pins.
+// Implementations must be safe for concurrent use.
+type KeyPinStore interface {
+	Get(issuer string) ([]string, error)
+	Put(issuer string, thumbprints []string) error
+}
+
+// pinnedKeys returns the keys whose thumbprints are in pinned
+func pinnedKeys(keys []JWK, pinned []string) []JWK {
+	pins := make(map[string]bool, len(pinned))
+	for _, tp := range pinned {
+		pins[tp] = true
+	}
+	var kept []JWK
+	for _, k := range keys {
+		if pins[k.Thumbprint()] {
+			kept = append(kept, k)
+		}
+	}
+	return kept
+}
+
+// addKeyPins returns pinned plus the thumbprints of keys not yet in it,
+// sorted, or nil if every key is already pinned. Pins are only ever added:
+// a key the issuer stops publishing stays pinned.
+func addKeyPins(pinned []string, keys []JWK) []string {
+	pins := make(map[string]bool, len(pinned))
+	for _, tp := range pinned {
+		pins[tp] = true
+	}
+	merged := append([]string(nil), pinned...)
+	for _, tp := range keyThumbprints(keys) {
+		if !pins[tp] {
+			pins[tp] = true
+			merged = append(merged, tp)
+		}
+	}
+	if len(merged) == len(pinned) {
+		return nil
+	}
+	sort.Strings(merged)
+	return merged
+}
+
+// keyThumbprints returns the sorted thumbprints of keys
+func keyThumbprints(keys []JWK) []string {
+	thumbprints := make([]string, len(keys))
+	for i, k := range keys {
+		thumbprints[i] = k.Thumbprint()
+	}
+	sort.Strings(thumbprints)
+	return thumbprints
+}
+
+// MemoryKeyPinStore is an in-memory KeyPinStore. Pins are lost when the
+// process exits. It is safe for concurrent use.
+type MemoryKeyPinStore struct {
+	mu   sync.Mutex
+	pins map[string][]string
+}
+
+// NewMemoryKeyPinStore creates an empty in-memory store
+func NewMemoryKeyPinStore() *MemoryKeyPinStore {
+	return &MemoryKeyPinStore{pins: make(map[string][]string)}
+}
+
+// Get returns the thumbprints pinned for issuer
+func (m *MemoryKeyPinStore) Get(issuer string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.pins[issuer]...), nil
+}
+
+// Put replaces the thumbprints pinned for issuer
+func (m *MemoryKeyPinStore) Put(issuer string, thumbprints []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pins[issuer] = append([]string(nil), thumbprints...)
+	return nil
+}
+
+// FileKeyPinStore is a KeyPinStore persisted as a JSON file mapping each
+// issuer to its pinned thumbprints. Every change rewrites the file
+// atomically. It is safe for concurrent use within one process; stores in
+// different processes sharing a file may lose each other's changes.
+type FileKeyPinStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileKeyPinStore creates a store backed by the file at path. The file is
+// created on first write. Delete an issuer from it to accept a new key set.
+func NewFileKeyPinStore(path string) *FileKeyPinStore {
+	return &FileKeyPinStore{path: path}
+}
+
+// Get returns the thumbprints pinned for issuer
+func (f *FileKeyPinStore) Get(issuer string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pins, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return pins[issuer], nil
+}
+
+// Put replaces the thumbprints pinned for issuer
+func (f *FileKeyPinStore) Put(issuer string, thumbprints []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	pins, err := f.load()
+	if err != nil {
+		return err
+	}
+	pins[issuer] = thumbprints
+	return f.save(pins)
+}
+
+func (f *FileKeyPinStore) load() (map[string][]string, error) {
+	pins := make(map[string][]string)
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return pins, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key pin store: %w", err)
+	}
+	if err := json.Unmarshal(data, &pins); err != nil {
+		return nil, fmt.Errorf("failed to parse key pin store: %w", err)
+	}
+	return pins, nil
+}
+
+func (f *FileKeyPinStore) save(pins map[string][]string) error {
+	data, err := json.MarshalIndent(pins, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize key pin store: %w", err)
+	}
+
+	if err := writeFileAtomic(f.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write key pin store: %w", err)
+	}
+	return nil
+}
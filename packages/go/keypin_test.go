@@ -0,0 +1,126 @@
+package humanattestation
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestKeyPinning(t *testing.T) {
+	va := newTestVA(t)
+	priv1 := va.AddKey(t, "key_001", KeyUseClaims)
+	_, jws1 := va.Issue(t, priv1, "key_001")
+
+	pins := NewMemoryKeyPinStore()
+	opts := va.Options()
+	opts.KeyCacheTTL = -1
+	opts.KeyPins = pins
+	ctx := context.Background()
+	pinned := func() []string {
+		tps, _ := pins.Get(va.Issuer())
+		return tps
+	}
+	published := func() []string {
+		va.mu.Lock()
+		defer va.mu.Unlock()
+		return keyThumbprints(va.wellKnown.Keys)
+	}
+
+	// First use pins the published keys
+	if r, err := VerifySignature(ctx, jws1, va.Issuer(), opts); err != nil || !r.Valid {
+		t.Fatalf("first VerifySignature() = %+v, %v", r, err)
+	}
+	first := published()
+	if got := pinned(); !reflect.DeepEqual(got, first) {
+		t.Fatalf("pins after first use = %v, want %v", got, first)
+	}
+
+	// A key added alongside the pinned one cannot sign until a pinned key vouches for it
+	priv2 := va.AddKey(t, "key_002", KeyUseClaims)
+	_, jws2 := va.Issue(t, priv2, "key_002")
+	r, err := VerifySignature(ctx, jws2, va.Issuer(), opts)
+	if err != nil || r.Valid || !errors.Is(r.Err, ErrKeyPinMismatch) {
+		t.Fatalf("VerifySignature() with unpinned key = %+v, %v", r, err)
+	}
+	if got := pinned(); !reflect.DeepEqual(got, first) {
+		t.Fatalf("pins after unpinned key = %v, want %v", got, first)
+	}
+
+	// A rotation overlapping the pinned key is followed once a pinned key verifies
+	if r, err := VerifySignature(ctx, jws1, va.Issuer(), opts); err != nil || !r.Valid {
+		t.Fatalf("VerifySignature() with pinned key = %+v, %v", r, err)
+	}
+	rotated := published()
+	if got := pinned(); len(got) != 2 || !reflect.DeepEqual(got, rotated) {
+		t.Fatalf("pins after rotation = %v, want %v", got, rotated)
+	}
+	if r, err := VerifySignature(ctx, jws2, va.Issuer(), opts); err != nil || !r.Valid {
+		t.Fatalf("VerifySignature() after rotation = %+v, %v", r, err)
+	}
+
+	// Retiring the old key keeps it pinned
+	va.mu.Lock()
+	va.wellKnown.Keys = va.wellKnown.Keys[1:]
+	va.mu.Unlock()
+	if r, err := VerifySignature(ctx, jws2, va.Issuer(), opts); err != nil || !r.Valid {
+		t.Fatalf("VerifySignature() after retiring key = %+v, %v", r, err)
+	}
+	if got := pinned(); !reflect.DeepEqual(got, rotated) {
+		t.Fatalf("pins after retiring key = %v, want %v", got, rotated)
+	}
+
+	// A key set sharing nothing with the pins is refused
+	va.mu.Lock()
+	va.wellKnown.Keys = nil
+	va.mu.Unlock()
+	priv3 := va.AddKey(t, "key_003", KeyUseClaims)
+	_, jws3 := va.Issue(t, priv3, "key_003")
+	r, err = VerifySignature(ctx, jws3, va.Issuer(), opts)
+	if err != nil || r.Valid {
+		t.Fatalf("VerifySignature() with replaced keys = %+v, %v", r, err)
+	}
+	var pinErr *KeyPinError
+	if !errors.Is(r.Err, ErrKeyPinMismatch) || !errors.As(r.Err, &pinErr) || errors.Is(r.Err, ErrSignatureInvalid) {
+		t.Fatalf("Err = %v, want a KeyPinError", r.Err)
+	}
+	if pinErr.Issuer != va.Issuer() || !reflect.DeepEqual(pinErr.Pinned, rotated) || len(pinErr.Fetched) != 1 {
+		t.Errorf("KeyPinError = %+v", pinErr)
+	}
+
+	// With a callback the mismatch is reported, but the new keys stay unpinned
+	var reported *KeyPinError
+	opts.OnKeyPinMismatch = func(err *KeyPinError) { reported = err }
+	if r, err := VerifySignature(ctx, jws3, va.Issuer(), opts); err != nil || !r.Valid {
+		t.Fatalf("VerifySignature() with callback = %+v, %v", r, err)
+	}
+	if reported == nil || reported.Issuer != va.Issuer() {
+		t.Errorf("OnKeyPinMismatch got %+v", reported)
+	}
+	if got := pinned(); !reflect.DeepEqual(got, rotated) {
+		t.Errorf("pins after reported mismatch = %v, want %v", got, rotated)
+	}
+}
+
+func TestFileKeyPinStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pins.json")
+	store := NewFileKeyPinStore(path)
+	if got, err := store.Get("ballista.jobs"); err != nil || got != nil {
+		t.Fatalf("Get() on missing file = %v, %v", got, err)
+	}
+	if err := store.Put("ballista.jobs", []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("other.example", []string{"c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := NewFileKeyPinStore(path)
+	if got, err := reopened.Get("ballista.jobs"); err != nil || !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("Get() after reopening = %v, %v", got, err)
+	}
+	if got, _ := reopened.Get("unknown.example"); got != nil {
+		t.Errorf("Get() for unpinned issuer = %v", got)
+	}
+}
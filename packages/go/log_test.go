@@ -0,0 +1,78 @@
+package humanattestation
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+var testLogKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestLogFields(t *testing.T) {
+	claim := testClaim(t)
+	claim.Tier = "standard"
+
+	got := logFieldMap(claim, LogOptions{RecipientKey: testLogKey})
+
+	want := map[string]string{
+		"hap_id":           claim.ID,
+		"method":           "ba_priority_mail",
+		"issuer":           "ballista.jobs",
+		"tier":             "standard",
+		"recipient_domain": "acme.com",
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("LogFields()[%s] = %q, want %q", k, got[k], v)
+		}
+	}
+	if len(got["recipient_hash"]) != 16 {
+		t.Errorf("recipient_hash = %q, want 16 hex characters", got["recipient_hash"])
+	}
+
+	// The same recipient always hashes the same, a different one does not
+	other := testClaim(t)
+	if h := logFieldMap(other, LogOptions{RecipientKey: testLogKey})["recipient_hash"]; h != got["recipient_hash"] {
+		t.Errorf("recipient_hash not stable: %s != %s", h, got["recipient_hash"])
+	}
+	if h := logFieldMap(other, LogOptions{RecipientKey: []byte("another key")})["recipient_hash"]; h == got["recipient_hash"] {
+		t.Error("different keys produced the same hash")
+	}
+	other.To.Name = "Other Corp"
+	if logFieldMap(other, LogOptions{RecipientKey: testLogKey})["recipient_hash"] == got["recipient_hash"] {
+		t.Error("different recipients produced the same hash")
+	}
+
+	// Without a key there is nothing to hash with
+	if h, ok := logFieldMap(claim)["recipient_hash"]; ok {
+		t.Errorf("recipient_hash = %q without a RecipientKey", h)
+	}
+}
+
+func logFieldMap(claim *Claim, opts ...LogOptions) map[string]string {
+	fields := make(map[string]string)
+	for _, a := range LogFields(claim, opts...) {
+		fields[a.Key] = a.Value.String()
+	}
+	return fields
+}
+
+func TestLogFieldsOmitsPII(t *testing.T) {
+	claim := testClaim(t)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.LogAttrs(context.Background(), slog.LevelInfo, "claim verified", LogFields(claim, LogOptions{RecipientKey: testLogKey})...)
+
+	out := buf.String()
+	for _, secret := range []string{claim.To.Name, "Acme", claim.Description} {
+		if strings.Contains(out, secret) {
+			t.Errorf("log output contains %q in the clear: %s", secret, out)
+		}
+	}
+	if !strings.Contains(out, `"recipient_hash":"`) {
+		t.Errorf("log output missing recipient_hash: %s", out)
+	}
+}
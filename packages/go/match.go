@@ -0,0 +1,94 @@
+package humanattestation
+
+import (
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// MatchConfidence grades how closely a claim's recipient domain matches the
+// domain checking it. Higher values are more confident.
+type MatchConfidence int
+
+const (
+	MatchConfidenceNone   MatchConfidence = iota // Unrelated domains
+	MatchConfidenceLow                           // Same organization (registrable domain) only
+	MatchConfidenceMedium                        // Recipient domain is a subdomain of the claim's
+	MatchConfidenceHigh                          // Same domain
+)
+
+func (c MatchConfidence) String() string {
+	switch c {
+	case MatchConfidenceLow:
+		return "low"
+	case MatchConfidenceMedium:
+		return "medium"
+	case MatchConfidenceHigh:
+		return "high"
+	}
+	return "none"
+}
+
+// MatchRecipientWithConfidence checks whether a claim targets recipientDomain,
+// trying the match modes in order of precedence: the same domain (High),
+// recipientDomain under the claim's domain, as MatchSubdomain accepts
+// (Medium), then both domains sharing a registrable domain per the Public
+// Suffix List (Low), e.g. a claim for hr.acme.com checked by sales.acme.com.
+// Domains on a public suffix never match organizationally.
+func MatchRecipientWithConfidence(claim *Claim, recipientDomain string) (matched bool, confidence MatchConfidence) {
+	if claim == nil || claim.To.Domain == "" || recipientDomain == "" {
+		return false, MatchConfidenceNone
+	}
+	claimDomain, err := DomainToASCII(strings.TrimSuffix(claim.To.Domain, "."))
+	if err != nil {
+		return false, MatchConfidenceNone
+	}
+	recipient, err := DomainToASCII(strings.TrimSuffix(recipientDomain, "."))
+	if err != nil {
+		return false, MatchConfidenceNone
+	}
+
+	switch {
+	case recipient == claimDomain:
+		return true, MatchConfidenceHigh
+	case matchDomain(claimDomain, recipient, MatchSubdomain):
+		return true, MatchConfidenceMedium
+	}
+
+	if !sameOrganization(claimDomain, recipient) {
+		return false, MatchConfidenceNone
+	}
+	return true, MatchConfidenceLow
+}
+
+// sameOrganization reports whether two ASCII domains share a registrable
+// domain per the Public Suffix List. Domains on a public suffix share none.
+func sameOrganization(a, b string) bool {
+	orgA, err := publicsuffix.EffectiveTLDPlusOne(a)
+	if err != nil {
+		return false
+	}
+	orgB, err := publicsuffix.EffectiveTLDPlusOne(b)
+	return err == nil && orgA == orgB
+}
+
+// IsSelfIssued reports whether a claim was issued from domain's own
+// organization: the issuer is domain, a subdomain of it, or shares its
+// registrable domain per the Public Suffix List, e.g. a claim issued by
+// va.acme.co.uk for a sender at acme.co.uk. Such a claim verifies, but
+// proves nothing about effort if domain is the sender's, since the sender
+// controls the VA.
+func IsSelfIssued(claim *Claim, domain string) bool {
+	if claim == nil || claim.Iss == "" || domain == "" {
+		return false
+	}
+	iss, err := DomainToASCII(strings.TrimSuffix(claim.Iss, "."))
+	if err != nil {
+		return false
+	}
+	other, err := DomainToASCII(strings.TrimSuffix(domain, "."))
+	if err != nil {
+		return false
+	}
+	return iss == other || sameOrganization(iss, other)
+}
@@ -0,0 +1,66 @@
+package humanattestation
+
+import "testing"
+
+func TestMatchRecipientWithConfidence(t *testing.T) {
+	tests := []struct {
+		name           string
+		claimDomain    string
+		recipient      string
+		wantMatched    bool
+		wantConfidence MatchConfidence
+	}{
+		{"exact", "acme.com", "acme.com", true, MatchConfidenceHigh},
+		{"exact, other case", "acme.com", "ACME.com.", true, MatchConfidenceHigh},
+		{"exact, punycode", "münchen.de", "xn--mnchen-3ya.de", true, MatchConfidenceHigh},
+		{"subdomain", "acme.com", "hr.acme.com", true, MatchConfidenceMedium},
+		{"sibling subdomains", "hr.acme.com", "sales.acme.com", true, MatchConfidenceLow},
+		{"parent of claim domain", "hr.acme.com", "acme.com", true, MatchConfidenceLow},
+		{"multi-label suffix", "hr.acme.co.uk", "acme.co.uk", true, MatchConfidenceLow},
+		{"unrelated", "acme.com", "globex.com", false, MatchConfidenceNone},
+		{"same public suffix", "acme.co.uk", "globex.co.uk", false, MatchConfidenceNone},
+		{"same private suffix", "acme.github.io", "globex.github.io", false, MatchConfidenceNone},
+		{"lookalike suffix", "acme.com", "notacme.com", false, MatchConfidenceNone},
+		{"empty recipient", "acme.com", "", false, MatchConfidenceNone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claim := &Claim{To: ClaimTarget{Domain: tt.claimDomain}}
+			matched, confidence := MatchRecipientWithConfidence(claim, tt.recipient)
+			if matched != tt.wantMatched || confidence != tt.wantConfidence {
+				t.Errorf("MatchRecipientWithConfidence() = %v, %s, want %v, %s", matched, confidence, tt.wantMatched, tt.wantConfidence)
+			}
+		})
+	}
+
+	if MatchConfidenceHigh <= MatchConfidenceMedium || MatchConfidenceMedium <= MatchConfidenceLow || MatchConfidenceLow <= MatchConfidenceNone {
+		t.Error("confidence levels are not ordered")
+	}
+}
+
+func TestIsSelfIssued(t *testing.T) {
+	tests := []struct {
+		name   string
+		issuer string
+		domain string
+		want   bool
+	}{
+		{"same domain", "acme.com", "acme.com", true},
+		{"subdomain VA", "va.acme.com", "acme.com", true},
+		{"VA on parent domain", "acme.com", "mail.acme.com", true},
+		{"sibling subdomains", "va.acme.com", "mail.acme.com", true},
+		{"second-level registry", "va.acme.co.uk", "acme.co.uk", true},
+		{"other case", "VA.Acme.com.", "acme.com", true},
+		{"unrelated", "ballista.jobs", "acme.com", false},
+		{"same public suffix", "acme.co.uk", "globex.co.uk", false},
+		{"same private suffix", "acme.github.io", "globex.github.io", false},
+		{"lookalike", "notacme.com", "acme.com", false},
+		{"bare public suffix", "acme.co.uk", "co.uk", false},
+		{"no domain", "acme.com", "", false},
+	}
+	for _, tt := range tests {
+		if got := IsSelfIssued(&Claim{Iss: tt.issuer}, tt.domain); got != tt.want {
+			t.Errorf("%s: IsSelfIssued(%s, %s) = %v, want %v", tt.name, tt.issuer, tt.domain, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package humanattestation
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeMethod canonicalizes a verification method name: it is trimmed and
+// lowercased, and each run of separators (spaces, hyphens, dots, underscores)
+// becomes a single underscore, so "Physical-Mail" and "physical mail" both
+// become "physical_mail".
+func NormalizeMethod(s string) string {
+	var b strings.Builder
+	pendingSep := false
+	for _, r := range strings.TrimSpace(s) {
+		if r == '_' || r == '-' || r == '.' || unicode.IsSpace(r) {
+			pendingSep = b.Len() > 0
+			continue
+		}
+		if pendingSep {
+			b.WriteByte('_')
+			pendingSep = false
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
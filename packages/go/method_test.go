@@ -0,0 +1,40 @@
+package humanattestation
+
+import "testing"
+
+func TestNormalizeMethod(t *testing.T) {
+	for _, in := range []string{
+		"physical_mail",
+		"Physical_Mail",
+		"physical-mail",
+		"PHYSICAL MAIL",
+		"physical.mail",
+		"  physical -- mail  ",
+		"_physical__mail_",
+	} {
+		if got := NormalizeMethod(in); got != "physical_mail" {
+			t.Errorf("NormalizeMethod(%q) = %q, want physical_mail", in, got)
+		}
+	}
+}
+
+func TestCreateClaimNormalizeMethod(t *testing.T) {
+	params := CreateClaimParams{Method: "Physical-Mail", RecipientName: "Acme Corp", Issuer: "ballista.jobs"}
+
+	claim, err := CreateClaim(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claim.Method != "Physical-Mail" {
+		t.Errorf("Method = %q, want it unchanged by default", claim.Method)
+	}
+
+	params.NormalizeMethod = true
+	claim, err = CreateClaim(params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claim.Method != "physical_mail" {
+		t.Errorf("Method = %q, want physical_mail", claim.Method)
+	}
+}
@@ -0,0 +1,119 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// SignatureStatus reports whether a result's claim signature was verified
+type SignatureStatus string
+
+const (
+	SignatureVerified    SignatureStatus = "verified"
+	SignatureUnavailable SignatureStatus = "unavailable" // No signed claim was disclosed to verify
+)
+
+// MinimalClaimTarget is the recipient part of a MinimalClaim
+type MinimalClaimTarget struct {
+	Domain string `json:"domain,omitempty"`
+}
+
+// MinimalClaim is the reduced claim disclosed by FetchClaimMinimal: whether a
+// claim exists, which domain it targets, when it expires and whether it was
+// revoked. The sender-chosen recipient name and method details are withheld.
+type MinimalClaim struct {
+	Valid   bool               `json:"valid"`
+	To      MinimalClaimTarget `json:"to"`
+	Exp     string             `json:"exp,omitempty"`
+	Revoked bool               `json:"revoked,omitempty"`
+	Error   string             `json:"error,omitempty"`
+	// SignatureStatus is always SignatureUnavailable: the VA does not send the
+	// signed claim in minimal mode, so the result relies on trusting the VA.
+	SignatureStatus SignatureStatus `json:"signatureStatus"`
+}
+
+// IsExpired checks if the minimal claim is expired at now, treating it as
+// still valid until leeway past its exp to allow for clock skew, as
+// IsClaimExpiredWithLeeway does. An exp that does not parse counts as
+// expired.
+func (m *MinimalClaim) IsExpired(now time.Time, leeway time.Duration) bool {
+	if m.Exp == "" {
+		return false
+	}
+	exp, err := ParseTimestamp(m.Exp)
+	if err != nil {
+		return true
+	}
+	return exp.Add(leeway).Before(now)
+}
+
+// IsForRecipient checks if the minimal claim targets the expected recipient
+func (m *MinimalClaim) IsForRecipient(recipientDomain string) bool {
+	return sameDomain(m.To.Domain, recipientDomain)
+}
+
+// FetchClaimMinimal asks a VA for a minimal-disclosure view of a claim via
+// GET /api/v1/verify/{hapId}?fields=minimal. If the VA ignores the parameter
+// and returns the full response, it is reduced client-side so callers never
+// see more than the minimal fields.
+//
+// No signature is verified in this mode; use VerifyClaim when the claim's
+// authenticity must not depend on trusting the VA's response.
+func FetchClaimMinimal(ctx context.Context, hapID, issuerDomain string, opts VerifyOptions) (*MinimalClaim, error) {
+	if err := opts.validateID(hapID); err != nil {
+		return &MinimalClaim{Valid: false, Error: "invalid_format", SignatureStatus: SignatureUnavailable}, nil
+	}
+
+	opts = opts.metered()
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	url, err := opts.endpoint(issuerDomain, "/api/v1/verify/"+hapID+"?fields=minimal")
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch claim: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := opts.meter.readAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Accept both the minimal shape and a full response from VAs without
+	// minimal support
+	var raw struct {
+		MinimalClaim
+		Claim *Claim `json:"claim"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	minimal := raw.MinimalClaim
+	if raw.Claim != nil {
+		minimal.To.Domain = raw.Claim.To.Domain
+		minimal.Exp = raw.Claim.Exp
+	}
+	minimal.SignatureStatus = SignatureUnavailable
+	return &minimal, nil
+}
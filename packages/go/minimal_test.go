@@ -0,0 +1,108 @@
+package humanattestation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchClaimMinimal(t *testing.T) {
+	var query string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(`{"valid":true,"to":{"domain":"acme.com"},"exp":"2099-01-01T00:00:00Z"}`))
+	}))
+	defer srv.Close()
+
+	m, err := FetchClaimMinimal(context.Background(), "hap_abc123xyz456", strings.TrimPrefix(srv.URL, "https://"), VerifyOptions{HTTPClient: srv.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "fields=minimal" {
+		t.Errorf("query = %q, want fields=minimal", query)
+	}
+	if !m.Valid || !m.IsForRecipient("acme.com") || m.IsExpired(time.Now(), 0) || m.Revoked {
+		t.Errorf("unexpected minimal claim: %+v", m)
+	}
+	if m.SignatureStatus != SignatureUnavailable {
+		t.Errorf("SignatureStatus = %s, want %s", m.SignatureStatus, SignatureUnavailable)
+	}
+}
+
+func TestFetchClaimMinimalReducesFullResponse(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+	claim, _ := va.Issue(t, priv, "key_001")
+
+	m, err := FetchClaimMinimal(context.Background(), claim.ID, va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := MinimalClaim{Valid: true, To: MinimalClaimTarget{Domain: "acme.com"}, Exp: claim.Exp, SignatureStatus: SignatureUnavailable}
+	if *m != want {
+		t.Errorf("got %+v, want %+v", *m, want)
+	}
+
+	va.Revoke(claim.ID, RevocationFraud)
+	m, err = FetchClaimMinimal(context.Background(), claim.ID, va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Valid || !m.Revoked {
+		t.Errorf("revoked claim reported as %+v", m)
+	}
+}
+
+func TestFetchClaimMinimalCheckedID(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+	claim := testClaim(t)
+	claim.Iss = va.Issuer()
+	id, err := GenerateIDChecked()
+	if err != nil {
+		t.Fatal(err)
+	}
+	claim.ID = id
+	jws, err := SignClaim(claim, priv, "key_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	va.SetResponse(VerificationResponse{Valid: true, ID: id, Claim: claim, JWS: jws, Issuer: va.Issuer()})
+
+	opts := va.Options()
+	m, err := FetchClaimMinimal(context.Background(), id, va.Issuer(), opts)
+	if err != nil || m.Valid || m.Error != "invalid_format" {
+		t.Fatalf("FetchClaimMinimal() without AllowCheckedIDs = %+v, %v", m, err)
+	}
+	opts.AllowCheckedIDs = true
+	m, err = FetchClaimMinimal(context.Background(), id, va.Issuer(), opts)
+	if err != nil || !m.Valid {
+		t.Fatalf("FetchClaimMinimal() with AllowCheckedIDs = %+v, %v", m, err)
+	}
+}
+
+func TestMinimalClaimIsExpired(t *testing.T) {
+	exp := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := &MinimalClaim{Exp: exp.Format(time.RFC3339)}
+	tests := []struct {
+		now    time.Time
+		leeway time.Duration
+		want   bool
+	}{
+		{exp.Add(-time.Second), 0, false},
+		{exp.Add(time.Second), 0, true},
+		{exp.Add(time.Second), time.Minute, false},
+		{exp.Add(2 * time.Minute), time.Minute, true},
+	}
+	for _, tt := range tests {
+		if got := m.IsExpired(tt.now, tt.leeway); got != tt.want {
+			t.Errorf("IsExpired(%v, %v) = %v, want %v", tt.now, tt.leeway, got, tt.want)
+		}
+	}
+	if (&MinimalClaim{}).IsExpired(exp, 0) {
+		t.Error("IsExpired() without exp = true")
+	}
+}
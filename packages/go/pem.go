@@ -0,0 +1,92 @@
+package humanattestation
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// ErrNotEd25519Key is returned when importing a PEM key of another type,
+// such as RSA, or when a JWK is not a 32-byte OKP/Ed25519 public key
+var ErrNotEd25519Key = errors.New("not an Ed25519 key")
+
+// PEM block types of PKCS #8 private keys and SPKI public keys
+const (
+	pemPrivateKey = "PRIVATE KEY"
+	pemPublicKey  = "PUBLIC KEY"
+)
+
+// ExportPrivateKeyPEM encodes a private key as a PKCS #8 "PRIVATE KEY" PEM
+// block, for storing signing keys on disk
+func ExportPrivateKeyPEM(key ed25519.PrivateKey) ([]byte, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key: %d bytes, want %d", len(key), ed25519.PrivateKeySize)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemPrivateKey, Bytes: der}), nil
+}
+
+// ImportPrivateKeyPEM decodes a private key written by ExportPrivateKeyPEM
+// or any other PKCS #8 encoder, such as openssl genpkey -algorithm ed25519
+func ImportPrivateKeyPEM(data []byte) (ed25519.PrivateKey, error) {
+	der, err := decodePEM(data, pemPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: private key is %T", ErrNotEd25519Key, key)
+	}
+	return privateKey, nil
+}
+
+// ExportPublicKeyPEM encodes a public key as an SPKI "PUBLIC KEY" PEM block
+func ExportPublicKeyPEM(key ed25519.PublicKey) ([]byte, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key: %d bytes, want %d", len(key), ed25519.PublicKeySize)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: pemPublicKey, Bytes: der}), nil
+}
+
+// ImportPublicKeyPEM decodes an SPKI "PUBLIC KEY" PEM block
+func ImportPublicKeyPEM(data []byte) (ed25519.PublicKey, error) {
+	der, err := decodePEM(data, pemPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: public key is %T", ErrNotEd25519Key, key)
+	}
+	return publicKey, nil
+}
+
+// decodePEM returns the contents of the first PEM block in data, which must
+// be of type blockType
+func decodePEM(data []byte, blockType string) ([]byte, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM: no complete PEM block found")
+	}
+	if block.Type != blockType {
+		return nil, fmt.Errorf("failed to decode PEM: block type is %q, want %q", block.Type, blockType)
+	}
+	return block.Bytes, nil
+}
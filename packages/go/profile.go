@@ -0,0 +1,111 @@
+package humanattestation
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// EffortDimension names one of a claim's effort dimensions
+type EffortDimension string
+
+const (
+	DimensionCost     EffortDimension = "cost"
+	DimensionTime     EffortDimension = "time"
+	DimensionPhysical EffortDimension = "physical"
+	DimensionEnergy   EffortDimension = "energy"
+)
+
+// RequirementsProfile describes the claims a recipient accepts. Empty fields
+// impose no requirement.
+type RequirementsProfile struct {
+	// Methods lists accepted methods, compared in NormalizeMethod form
+	Methods []string
+	// MinTier is the lowest accepted tier. TierOrder ranks tiers from lowest
+	// to highest, since tier names are chosen by each VA.
+	MinTier   string
+	TierOrder []string
+	// MaxLifetime caps the time between a claim's issue and expiry. Claims
+	// without an expiry exceed any MaxLifetime.
+	MaxLifetime time.Duration
+	// RequiredDimensions must be present on the claim with a positive value,
+	// or true for DimensionPhysical
+	RequiredDimensions []EffortDimension
+}
+
+// Meets reports whether the claim satisfies the profile, and describes each
+// requirement it fails. It does not verify the claim's signature.
+func (p RequirementsProfile) Meets(claim *Claim) (bool, []string) {
+	var failed []string
+
+	if err := ValidateClaim(claim); err != nil {
+		failed = append(failed, err.Error())
+	}
+
+	if len(p.Methods) > 0 {
+		method := NormalizeMethod(claim.Method)
+		accepted := slices.ContainsFunc(p.Methods, func(m string) bool { return NormalizeMethod(m) == method })
+		if !accepted {
+			failed = append(failed, fmt.Sprintf("method %s is not accepted", claim.Method))
+		}
+	}
+
+	if p.MinTier != "" {
+		minRank := slices.Index(p.TierOrder, p.MinTier)
+		rank := slices.Index(p.TierOrder, claim.Tier)
+		switch {
+		case minRank < 0:
+			failed = append(failed, fmt.Sprintf("minimum tier %s is not in the tier order", p.MinTier))
+		case rank < 0:
+			failed = append(failed, fmt.Sprintf("tier %q is not ranked", claim.Tier))
+		case rank < minRank:
+			failed = append(failed, fmt.Sprintf("tier %s is below %s", claim.Tier, p.MinTier))
+		}
+	}
+
+	if p.MaxLifetime > 0 {
+		if lifetime, ok := claimLifetime(claim); !ok {
+			failed = append(failed, fmt.Sprintf("claim must expire within %s of issue", p.MaxLifetime))
+		} else if lifetime > p.MaxLifetime {
+			failed = append(failed, fmt.Sprintf("lifetime %s exceeds %s", lifetime, p.MaxLifetime))
+		}
+	}
+
+	for _, dim := range p.RequiredDimensions {
+		if !hasDimension(claim, dim) {
+			failed = append(failed, fmt.Sprintf("missing %s dimension", dim))
+		}
+	}
+
+	return len(failed) == 0, failed
+}
+
+// claimLifetime returns the time between a claim's issue and expiry
+func claimLifetime(claim *Claim) (time.Duration, bool) {
+	if claim.Exp == "" {
+		return 0, false
+	}
+	at, err := claim.IssuedAt()
+	if err != nil {
+		return 0, false
+	}
+	exp, err := claim.ExpiresAt()
+	if err != nil {
+		return 0, false
+	}
+	return exp.Sub(at), true
+}
+
+func hasDimension(claim *Claim, dim EffortDimension) bool {
+	switch dim {
+	case DimensionCost:
+		return claim.Cost != nil && claim.Cost.Amount > 0
+	case DimensionTime:
+		return claim.Time != nil && *claim.Time > 0
+	case DimensionPhysical:
+		return claim.Physical != nil && *claim.Physical
+	case DimensionEnergy:
+		return claim.Energy != nil && *claim.Energy > 0
+	}
+	return false
+}
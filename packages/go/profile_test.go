@@ -0,0 +1,78 @@
+package humanattestation
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func testProfile() RequirementsProfile {
+	return RequirementsProfile{
+		Methods:            []string{"Physical Delivery", "ba_priority_mail"},
+		MinTier:            "gold",
+		TierOrder:          []string{"bronze", "silver", "gold", "platinum"},
+		MaxLifetime:        14 * 24 * time.Hour,
+		RequiredDimensions: []EffortDimension{DimensionPhysical, DimensionCost},
+	}
+}
+
+func profileClaim() *Claim {
+	return &Claim{
+		Method:   "physical_delivery",
+		Tier:     "platinum",
+		At:       "2026-01-01T00:00:00Z",
+		Exp:      "2026-01-10T00:00:00Z",
+		Physical: BoolPtr(true),
+		Cost:     &ClaimCost{Amount: 1500, Currency: "USD"},
+	}
+}
+
+func TestRequirementsProfileMeets(t *testing.T) {
+	ok, failed := testProfile().Meets(profileClaim())
+	if !ok || len(failed) != 0 {
+		t.Errorf("Meets() = %v, %v, want claim to meet every requirement", ok, failed)
+	}
+
+	if ok, failed := (RequirementsProfile{}).Meets(&Claim{At: "2026-01-01T00:00:00Z"}); !ok || len(failed) != 0 {
+		t.Errorf("empty profile Meets() = %v, %v", ok, failed)
+	}
+}
+
+func TestRequirementsProfileFailures(t *testing.T) {
+	claim := profileClaim()
+	claim.Tier = "silver"
+	claim.Physical = BoolPtr(false)
+
+	ok, failed := testProfile().Meets(claim)
+	if ok {
+		t.Fatal("Meets() = true for a claim failing two requirements")
+	}
+	if len(failed) != 2 {
+		t.Fatalf("failed = %v, want 2 requirements", failed)
+	}
+	if !strings.Contains(failed[0], "tier silver is below gold") || !strings.Contains(failed[1], "missing physical") {
+		t.Errorf("failed = %v", failed)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Claim)
+		want   string
+	}{
+		{"method", func(c *Claim) { c.Method = "email" }, "method email is not accepted"},
+		{"unranked tier", func(c *Claim) { c.Tier = "" }, "is not ranked"},
+		{"lifetime", func(c *Claim) { c.Exp = "2026-02-01T00:00:00Z" }, "exceeds"},
+		{"no expiry", func(c *Claim) { c.Exp = "" }, "must expire within"},
+		{"zero cost", func(c *Claim) { c.Cost.Amount = 0 }, "missing cost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claim := profileClaim()
+			tt.modify(claim)
+			ok, failed := testProfile().Meets(claim)
+			if ok || len(failed) != 1 || !strings.Contains(failed[0], tt.want) {
+				t.Errorf("Meets() = %v, %v, want one failure containing %q", ok, failed, tt.want)
+			}
+		})
+	}
+}
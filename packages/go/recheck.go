@@ -0,0 +1,339 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultRecheckInterval is how often RecheckScheduler.Run looks for due rechecks
+const DefaultRecheckInterval = time.Minute
+
+// DefaultRecheckRetryDelay is how long a failed recheck waits before retrying
+const DefaultRecheckRetryDelay = 5 * time.Minute
+
+// DefaultRecheckConcurrency bounds how many rechecks run at once
+const DefaultRecheckConcurrency = 4
+
+// RecheckEntry is a pending revocation recheck for a previously accepted claim
+type RecheckEntry struct {
+	HapID     string                `json:"hapId"`
+	Issuer    string                `json:"issuer"`
+	RecheckAt time.Time             `json:"recheckAt"`
+	Last      *VerificationResponse `json:"last"`
+}
+
+// RecheckStore persists pending rechecks so they survive process restarts.
+// Implementations must be safe for concurrent use: RunDue calls Put and
+// Delete from several goroutines at once.
+type RecheckStore interface {
+	Put(entry RecheckEntry) error
+	Delete(hapID string) error
+	List() ([]RecheckEntry, error)
+}
+
+// RecheckChangeFunc is called when a recheck finds the claim's status changed.
+// It may be called from several goroutines at once.
+type RecheckChangeFunc func(entry RecheckEntry, before, after *VerificationResponse)
+
+// RecheckOptions configures a RecheckScheduler
+type RecheckOptions struct {
+	// VerifyOptions is used for the verification API requests
+	VerifyOptions VerifyOptions
+	// Interval between scans in Run (default: DefaultRecheckInterval)
+	Interval time.Duration
+	// RetryDelay before retrying a recheck whose request failed (default: DefaultRecheckRetryDelay)
+	RetryDelay time.Duration
+	// Concurrency bounds simultaneous rechecks (default: DefaultRecheckConcurrency)
+	Concurrency int
+	// Now overrides the clock (default: time.Now)
+	Now func() time.Time
+}
+
+// RecheckScheduler re-checks the revocation status of claims that were
+// accepted earlier but acted on later. Only the verification API response is
+// re-fetched; signatures are not re-verified.
+//
+// Due entries are found by comparing each entry's RecheckAt against the wall
+// clock on every scan, so a clock jumping forward makes entries due at once
+// and a clock jumping backward delays them, but none are skipped.
+//
+// A RecheckScheduler is safe for concurrent use. Track may be called while
+// RunDue is running; overlapping RunDue calls may recheck an entry twice.
+type RecheckScheduler struct {
+	store    RecheckStore
+	onChange RecheckChangeFunc
+	opts     RecheckOptions
+}
+
+// NewRecheckScheduler creates a scheduler backed by store that calls onChange
+// whenever a recheck observes a different status than was tracked
+func NewRecheckScheduler(store RecheckStore, onChange RecheckChangeFunc, opts RecheckOptions) *RecheckScheduler {
+	if opts.Interval == 0 {
+		opts.Interval = DefaultRecheckInterval
+	}
+	if opts.RetryDelay == 0 {
+		opts.RetryDelay = DefaultRecheckRetryDelay
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultRecheckConcurrency
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &RecheckScheduler{store: store, onChange: onChange, opts: opts}
+}
+
+// Track schedules a recheck of a claim accepted with the given verification
+// response. issuerDomain is the issuer the claim was verified against.
+func (s *RecheckScheduler) Track(issuerDomain string, resp *VerificationResponse, recheckAt time.Time) error {
+	if resp == nil || resp.ID == "" {
+		return errors.New("verification response missing id")
+	}
+	return s.store.Put(RecheckEntry{
+		HapID:     resp.ID,
+		Issuer:    issuerDomain,
+		RecheckAt: recheckAt,
+		Last:      resp,
+	})
+}
+
+// RunDue rechecks every entry that is due and returns how many completed.
+// Completed entries are removed from the store; entries whose request failed
+// are rescheduled after RetryDelay.
+func (s *RecheckScheduler) RunDue(ctx context.Context) (int, error) {
+	entries, err := s.store.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list rechecks: %w", err)
+	}
+
+	now := s.opts.Now()
+	var due []RecheckEntry
+	for _, e := range entries {
+		if !e.RecheckAt.After(now) {
+			due = append(due, e)
+		}
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		completed int
+		errs      []error
+	)
+	sem := make(chan struct{}, s.opts.Concurrency)
+
+	for _, entry := range due {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return completed, ctx.Err()
+		}
+
+		wg.Add(1)
+		go func(entry RecheckEntry) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := s.recheck(ctx, entry)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			completed++
+		}(entry)
+	}
+	wg.Wait()
+
+	return completed, errors.Join(errs...)
+}
+
+// recheck re-fetches one entry's status and reports any change
+func (s *RecheckScheduler) recheck(ctx context.Context, entry RecheckEntry) error {
+	after, err := FetchClaim(ctx, entry.HapID, entry.Issuer, s.opts.VerifyOptions)
+	if err != nil {
+		entry.RecheckAt = s.opts.Now().Add(s.opts.RetryDelay)
+		if putErr := s.store.Put(entry); putErr != nil {
+			return fmt.Errorf("failed to reschedule %s: %w", entry.HapID, putErr)
+		}
+		return fmt.Errorf("failed to recheck %s: %w", entry.HapID, err)
+	}
+
+	if s.onChange != nil && statusChanged(entry.Last, after) {
+		s.onChange(entry, entry.Last, after)
+	}
+
+	return s.store.Delete(entry.HapID)
+}
+
+// statusChanged reports whether the validity or revocation state differs
+func statusChanged(before, after *VerificationResponse) bool {
+	if before == nil {
+		return true
+	}
+	return before.Valid != after.Valid ||
+		before.Revoked != after.Revoked ||
+		before.RevocationReason != after.RevocationReason
+}
+
+// Run calls RunDue every Interval until ctx is cancelled. Errors from
+// individual runs are passed to onError if non-nil.
+func (s *RecheckScheduler) Run(ctx context.Context, onError func(error)) {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.RunDue(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// MemoryRecheckStore is an in-memory RecheckStore. Pending rechecks are lost
+// when the process exits. It is safe for concurrent use.
+type MemoryRecheckStore struct {
+	mu      sync.Mutex
+	entries map[string]RecheckEntry
+}
+
+// NewMemoryRecheckStore creates an empty in-memory store
+func NewMemoryRecheckStore() *MemoryRecheckStore {
+	return &MemoryRecheckStore{entries: make(map[string]RecheckEntry)}
+}
+
+// Put adds or replaces the entry for entry.HapID
+func (m *MemoryRecheckStore) Put(entry RecheckEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[entry.HapID] = entry
+	return nil
+}
+
+// Delete removes the entry for hapID
+func (m *MemoryRecheckStore) Delete(hapID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, hapID)
+	return nil
+}
+
+// List returns all pending entries ordered by RecheckAt
+func (m *MemoryRecheckStore) List() ([]RecheckEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return sortedEntries(m.entries), nil
+}
+
+// FileRecheckStore is a RecheckStore persisted as a JSON file. Every change
+// rewrites the file atomically, which suits the modest volumes of claims
+// awaiting a decision. It is safe for concurrent use within one process;
+// stores in different processes sharing a file may lose each other's
+// changes.
+type FileRecheckStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileRecheckStore creates a store backed by the file at path. The file
+// is created on first write.
+func NewFileRecheckStore(path string) *FileRecheckStore {
+	return &FileRecheckStore{path: path}
+}
+
+// Put adds or replaces the entry for entry.HapID
+func (f *FileRecheckStore) Put(entry RecheckEntry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := f.load()
+	if err != nil {
+		return err
+	}
+	entries[entry.HapID] = entry
+	return f.save(entries)
+}
+
+// Delete removes the entry for hapID
+func (f *FileRecheckStore) Delete(hapID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := f.load()
+	if err != nil {
+		return err
+	}
+	delete(entries, hapID)
+	return f.save(entries)
+}
+
+// List returns all pending entries ordered by RecheckAt
+func (f *FileRecheckStore) List() ([]RecheckEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	entries, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	return sortedEntries(entries), nil
+}
+
+func (f *FileRecheckStore) load() (map[string]RecheckEntry, error) {
+	entries := make(map[string]RecheckEntry)
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recheck store: %w", err)
+	}
+
+	var list []RecheckEntry
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse recheck store: %w", err)
+	}
+	for _, e := range list {
+		entries[e.HapID] = e
+	}
+	return entries, nil
+}
+
+func (f *FileRecheckStore) save(entries map[string]RecheckEntry) error {
+	data, err := json.MarshalIndent(sortedEntries(entries), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize recheck store: %w", err)
+	}
+
+	if err := writeFileAtomic(f.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write recheck store: %w", err)
+	}
+	return nil
+}
+
+// sortedEntries returns map values ordered by RecheckAt, then HapID
+func sortedEntries(m map[string]RecheckEntry) []RecheckEntry {
+	list := make([]RecheckEntry, 0, len(m))
+	for _, e := range m {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if !list[i].RecheckAt.Equal(list[j].RecheckAt) {
+			return list[i].RecheckAt.Before(list[j].RecheckAt)
+		}
+		return list[i].HapID < list[j].HapID
+	})
+	return list
+}
@@ -0,0 +1,192 @@
+package humanattestation
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable clock for driving RecheckScheduler
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+func TestRecheckSchedulerDetectsRevocation(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	claim, _ := va.Issue(t, priv, "claims_001")
+
+	ctx := context.Background()
+	accepted, err := FetchClaim(ctx, claim.ID, va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	clock := &fakeClock{now: start}
+
+	var changes []*VerificationResponse
+	s := NewRecheckScheduler(NewMemoryRecheckStore(), func(entry RecheckEntry, before, after *VerificationResponse) {
+		if entry.HapID != claim.ID || !before.Valid {
+			t.Errorf("unexpected change for %s (before valid=%v)", entry.HapID, before.Valid)
+		}
+		changes = append(changes, after)
+	}, RecheckOptions{VerifyOptions: va.Options(), Now: clock.Now})
+
+	if err := s.Track(va.Issuer(), accepted, start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not yet due
+	if n, err := s.RunDue(ctx); err != nil || n != 0 {
+		t.Fatalf("RunDue before due = %d, %v", n, err)
+	}
+
+	va.Revoke(claim.ID, RevocationFraud)
+
+	// A clock that jumped backward only delays the recheck
+	clock.Set(start.Add(-24 * time.Hour))
+	if n, err := s.RunDue(ctx); err != nil || n != 0 {
+		t.Fatalf("RunDue after backward jump = %d, %v", n, err)
+	}
+
+	// A clock that jumped forward past RecheckAt makes it due immediately
+	clock.Set(start.Add(48 * time.Hour))
+	if n, err := s.RunDue(ctx); err != nil || n != 1 {
+		t.Fatalf("RunDue after forward jump = %d, %v", n, err)
+	}
+
+	if len(changes) != 1 {
+		t.Fatalf("got %d changes, want 1", len(changes))
+	}
+	if !changes[0].Revoked || changes[0].RevocationReason != RevocationFraud {
+		t.Errorf("change = %+v, want revoked for fraud", changes[0])
+	}
+
+	// Completed rechecks are removed
+	if n, err := s.RunDue(ctx); err != nil || n != 0 {
+		t.Fatalf("RunDue after completion = %d, %v", n, err)
+	}
+}
+
+func TestRecheckSchedulerUnchanged(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	claim, _ := va.Issue(t, priv, "claims_001")
+
+	ctx := context.Background()
+	accepted, err := FetchClaim(ctx, claim.ID, va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	called := false
+	s := NewRecheckScheduler(NewMemoryRecheckStore(), func(RecheckEntry, *VerificationResponse, *VerificationResponse) {
+		called = true
+	}, RecheckOptions{VerifyOptions: va.Options()})
+
+	if err := s.Track(va.Issuer(), accepted, time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := s.RunDue(ctx); err != nil || n != 1 {
+		t.Fatalf("RunDue = %d, %v", n, err)
+	}
+	if called {
+		t.Error("onChange called for unchanged claim")
+	}
+}
+
+func TestRecheckSchedulerRetriesOnError(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	claim, _ := va.Issue(t, priv, "claims_001")
+
+	ctx := context.Background()
+	accepted, err := FetchClaim(ctx, claim.ID, va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryRecheckStore()
+	// The default HTTP client does not trust the test VA's certificate
+	s := NewRecheckScheduler(store, nil, RecheckOptions{
+		RetryDelay: 10 * time.Minute,
+		Now:        func() time.Time { return now },
+	})
+
+	if err := s.Track(va.Issuer(), accepted, now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.RunDue(ctx); err == nil {
+		t.Fatal("expected error from failed recheck")
+	}
+
+	entries, _ := store.List()
+	if len(entries) != 1 || !entries[0].RecheckAt.Equal(now.Add(10*time.Minute)) {
+		t.Errorf("entries after failure = %+v, want one rescheduled after RetryDelay", entries)
+	}
+}
+
+func TestFileRecheckStoreSurvivesRestart(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	claim, _ := va.Issue(t, priv, "claims_001")
+
+	ctx := context.Background()
+	accepted, err := FetchClaim(ctx, claim.ID, va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "rechecks.json")
+	recheckAt := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+
+	first := NewRecheckScheduler(NewFileRecheckStore(path), nil, RecheckOptions{VerifyOptions: va.Options()})
+	if err := first.Track(va.Issuer(), accepted, recheckAt); err != nil {
+		t.Fatal(err)
+	}
+
+	va.Revoke(claim.ID, RevocationUserRequest)
+
+	// A new scheduler over the same file picks up the pending recheck
+	var revoked bool
+	second := NewRecheckScheduler(NewFileRecheckStore(path), func(_ RecheckEntry, _, after *VerificationResponse) {
+		revoked = after.Revoked
+	}, RecheckOptions{VerifyOptions: va.Options()})
+
+	entries, err := NewFileRecheckStore(path).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].HapID != claim.ID || !entries[0].RecheckAt.Equal(recheckAt) {
+		t.Fatalf("entries = %+v", entries)
+	}
+
+	if n, err := second.RunDue(ctx); err != nil || n != 1 {
+		t.Fatalf("RunDue = %d, %v", n, err)
+	}
+	if !revoked {
+		t.Error("expected revocation to be reported after restart")
+	}
+
+	entries, _ = NewFileRecheckStore(path).List()
+	if len(entries) != 0 {
+		t.Errorf("entries after recheck = %+v, want none", entries)
+	}
+}
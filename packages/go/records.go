@@ -0,0 +1,388 @@
+package humanattestation
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrStringTableFull is returned when a StringTable has reached its limit
+var ErrStringTableFull = errors.New("string table full")
+
+// Default limits of the tables a RecordSet interns strings in
+const (
+	DefaultMaxRecordMethods = 1 << 12
+	DefaultMaxRecordDomains = 1 << 20
+)
+
+// StringTable interns strings as small indexes. It is safe for concurrent
+// use and holds at most its limit of distinct strings; index 0 is always "".
+type StringTable struct {
+	mu      sync.RWMutex
+	limit   int
+	index   map[string]uint32
+	strings []string
+}
+
+// NewStringTable returns a table holding at most limit distinct non-empty
+// strings
+func NewStringTable(limit int) *StringTable {
+	return &StringTable{limit: limit, index: map[string]uint32{"": 0}, strings: []string{""}}
+}
+
+// Intern returns the index of s, adding it if there is room
+func (t *StringTable) Intern(s string) (uint32, error) {
+	t.mu.RLock()
+	i, ok := t.index[s]
+	t.mu.RUnlock()
+	if ok {
+		return i, nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if i, ok := t.index[s]; ok {
+		return i, nil
+	}
+	if len(t.strings) > t.limit {
+		return 0, fmt.Errorf("%w: %d entries", ErrStringTableFull, t.limit)
+	}
+	// Copy so the table does not pin whatever larger string s was sliced from
+	s = strings.Clone(s)
+	i = uint32(len(t.strings))
+	t.index[s] = i
+	t.strings = append(t.strings, s)
+	return i, nil
+}
+
+// Lookup returns the string at index i, or "" if there is none
+func (t *StringTable) Lookup(i uint32) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if int(i) >= len(t.strings) {
+		return ""
+	}
+	return t.strings[i]
+}
+
+// Len returns the number of strings interned, not counting ""
+func (t *StringTable) Len() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.strings) - 1
+}
+
+// packedID is a HAP ID with its base62 body packed into 78 bits
+type packedID struct {
+	lo   uint64
+	hi   uint16
+	kind uint8 // Body length, with packedTestID set for test IDs
+}
+
+const packedTestID = 0x80
+
+// packID packs a HAP ID, a checked HAP ID or a test ID
+func packID(id string) (packedID, error) {
+	var p packedID
+	body, isTest := strings.CutPrefix(id, "hap_test_")
+	switch {
+	case isTest && TestIDRegex.MatchString(id):
+		p.kind = packedTestID | uint8(len(body))
+	case IsValidID(id) || CheckedIDRegex.MatchString(id):
+		body = id[len("hap_"):]
+		p.kind = uint8(len(body))
+	default:
+		return packedID{}, fmt.Errorf("%w: %q", ErrInvalidIDFormat, id)
+	}
+
+	var hi uint64
+	for i := 0; i < len(body); i++ {
+		carry, lo := bits.Mul64(p.lo, 62)
+		lo, c := bits.Add64(lo, uint64(strings.IndexByte(IDChars, body[i])), 0)
+		p.lo, hi = lo, hi*62+carry+c
+	}
+	p.hi = uint16(hi)
+	return p, nil
+}
+
+// String unpacks the ID
+func (p packedID) String() string {
+	n := int(p.kind &^ packedTestID)
+	body := make([]byte, n)
+	hi, lo := uint64(p.hi), p.lo
+	for i := n - 1; i >= 0; i-- {
+		var r uint64
+		hi, r = hi/62, hi%62
+		var d uint64
+		lo, d = bits.Div64(r, lo, 62)
+		body[i] = IDChars[d]
+	}
+	if p.kind&packedTestID != 0 {
+		return "hap_test_" + string(body)
+	}
+	return "hap_" + string(body)
+}
+
+// CompactRecord is a packed form of the fields of a claim a recipient needs
+// to deduplicate and expire it: 40 bytes instead of several hundred for a
+// Claim. Method, recipient domain and issuer are indexes into the string
+// tables of the RecordSet that created the record.
+type CompactRecord struct {
+	idLo   uint64
+	at     int64 // Unix seconds
+	exp    int64 // Unix seconds, 0 when the claim does not expire
+	domain uint32
+	issuer uint32
+	idHi   uint16
+	method uint16
+	idKind uint8
+}
+
+func (r *CompactRecord) packedID() packedID {
+	return packedID{lo: r.idLo, hi: r.idHi, kind: r.idKind}
+}
+
+// ID returns the claim's HAP ID
+func (r CompactRecord) ID() string {
+	return r.packedID().String()
+}
+
+// IssuedAt returns when the claim was issued
+func (r CompactRecord) IssuedAt() time.Time {
+	return time.Unix(r.at, 0).UTC()
+}
+
+// ExpiresAt returns when the claim expires, or the zero time if it does not
+func (r CompactRecord) ExpiresAt() time.Time {
+	if r.exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(r.exp, 0).UTC()
+}
+
+// RecordSetOptions configures NewRecordSet
+type RecordSetOptions struct {
+	// MaxMethods caps the distinct methods interned (default:
+	// DefaultMaxRecordMethods, at most 65535)
+	MaxMethods int
+	// MaxDomains caps the distinct recipient and issuer domains interned
+	// (default: DefaultMaxRecordDomains)
+	MaxDomains int
+}
+
+// RecordSet is a memory-efficient set of claims keyed by ID, for recipients
+// that remember millions of claims to detect replays. Records are stored
+// densely and indexed by an open-addressing table with a per-set hash seed,
+// so crafted IDs cannot force collisions. It is safe for concurrent use.
+type RecordSet struct {
+	methods *StringTable
+	domains *StringTable
+	seed    maphash.Seed
+
+	mu      sync.RWMutex
+	records []CompactRecord
+	slots   []uint32 // 1 + index into records, 0 when empty; len is a power of two
+}
+
+// NewRecordSet returns an empty RecordSet
+func NewRecordSet(opts RecordSetOptions) *RecordSet {
+	if opts.MaxMethods <= 0 {
+		opts.MaxMethods = DefaultMaxRecordMethods
+	}
+	if opts.MaxDomains <= 0 {
+		opts.MaxDomains = DefaultMaxRecordDomains
+	}
+	return &RecordSet{
+		methods: NewStringTable(min(opts.MaxMethods, math.MaxUint16)),
+		domains: NewStringTable(opts.MaxDomains),
+		seed:    maphash.MakeSeed(),
+		slots:   make([]uint32, 16),
+	}
+}
+
+// FromClaim packs claim into a record, interning its method, recipient
+// domain and issuer in the set's tables. Methods are stored in
+// NormalizeMethod form. The record is not added to the set.
+func (s *RecordSet) FromClaim(claim *Claim) (CompactRecord, error) {
+	id, err := packID(claim.ID)
+	if err != nil {
+		return CompactRecord{}, err
+	}
+	at, err := claim.IssuedAt()
+	if err != nil {
+		return CompactRecord{}, fmt.Errorf("failed to parse 'at' timestamp: %w", err)
+	}
+	exp, err := claim.ExpiresAt()
+	if err != nil {
+		return CompactRecord{}, fmt.Errorf("failed to parse 'exp' timestamp: %w", err)
+	}
+
+	r := CompactRecord{idLo: id.lo, idHi: id.hi, idKind: id.kind, at: at.Unix()}
+	if !exp.IsZero() {
+		r.exp = exp.Unix()
+	}
+	method, err := s.methods.Intern(NormalizeMethod(claim.Method))
+	if err != nil {
+		return CompactRecord{}, fmt.Errorf("failed to intern method: %w", err)
+	}
+	r.method = uint16(method)
+	if r.domain, err = s.domains.Intern(strings.ToLower(claim.To.Domain)); err != nil {
+		return CompactRecord{}, fmt.Errorf("failed to intern domain: %w", err)
+	}
+	if r.issuer, err = s.domains.Intern(strings.ToLower(claim.Iss)); err != nil {
+		return CompactRecord{}, fmt.Errorf("failed to intern issuer: %w", err)
+	}
+	return r, nil
+}
+
+// Add packs claim and adds it to the set. It reports false if a claim with
+// the same ID was already present, in which case the set is unchanged.
+func (s *RecordSet) Add(claim *Claim) (bool, error) {
+	r, err := s.FromClaim(claim)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slot, found := s.find(r.packedID())
+	if found {
+		return false, nil
+	}
+	s.records = append(s.records, r)
+	s.slots[slot] = uint32(len(s.records))
+	// Keep the table at most three quarters full
+	if len(s.records)*4 > len(s.slots)*3 {
+		s.resize(len(s.slots) * 2)
+	}
+	return true, nil
+}
+
+// Contains reports whether a claim with the given ID is in the set
+func (s *RecordSet) Contains(id string) bool {
+	_, ok := s.Get(id)
+	return ok
+}
+
+// Get returns the record for the given ID
+func (s *RecordSet) Get(id string) (CompactRecord, bool) {
+	p, err := packID(id)
+	if err != nil {
+		return CompactRecord{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	slot, found := s.find(p)
+	if !found {
+		return CompactRecord{}, false
+	}
+	return s.records[s.slots[slot]-1], true
+}
+
+// Remove deletes the claim with the given ID, such as once it has expired
+func (s *RecordSet) Remove(id string) {
+	p, err := packID(id)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	slot, found := s.find(p)
+	if !found {
+		return
+	}
+
+	// Move the last record into the hole and repoint its slot
+	i := s.slots[slot] - 1
+	last := uint32(len(s.records) - 1)
+	if i != last {
+		lastSlot, _ := s.find(s.records[last].packedID())
+		s.records[i] = s.records[last]
+		s.slots[lastSlot] = i + 1
+	}
+	s.records = s.records[:last]
+
+	// Backward-shift deletion keeps linear probe chains unbroken
+	mask := uint64(len(s.slots) - 1)
+	hole := uint64(slot)
+	for next := (hole + 1) & mask; s.slots[next] != 0; next = (next + 1) & mask {
+		home := s.hash(s.records[s.slots[next]-1].packedID()) & mask
+		if (next-home)&mask >= (next-hole)&mask {
+			s.slots[hole] = s.slots[next]
+			hole = next
+		}
+	}
+	s.slots[hole] = 0
+}
+
+// Len returns the number of claims in the set
+func (s *RecordSet) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
+
+// Range calls fn for each record in no particular order until fn returns
+// false. fn must not add to or remove from the set.
+func (s *RecordSet) Range(fn func(CompactRecord) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, r := range s.records {
+		if !fn(r) {
+			return
+		}
+	}
+}
+
+// Method returns the normalized method of a record created by this set
+func (s *RecordSet) Method(r CompactRecord) string {
+	return s.methods.Lookup(uint32(r.method))
+}
+
+// Domain returns the recipient domain of a record created by this set
+func (s *RecordSet) Domain(r CompactRecord) string {
+	return s.domains.Lookup(r.domain)
+}
+
+// Issuer returns the issuer of a record created by this set
+func (s *RecordSet) Issuer(r CompactRecord) string {
+	return s.domains.Lookup(r.issuer)
+}
+
+func (s *RecordSet) hash(p packedID) uint64 {
+	var b [11]byte
+	binary.LittleEndian.PutUint64(b[:8], p.lo)
+	binary.LittleEndian.PutUint16(b[8:10], p.hi)
+	b[10] = p.kind
+	return maphash.Bytes(s.seed, b[:])
+}
+
+// find returns the slot holding p, or the empty slot where it belongs
+func (s *RecordSet) find(p packedID) (int, bool) {
+	mask := uint64(len(s.slots) - 1)
+	for i := s.hash(p) & mask; ; i = (i + 1) & mask {
+		if s.slots[i] == 0 {
+			return int(i), false
+		}
+		if s.records[s.slots[i]-1].packedID() == p {
+			return int(i), true
+		}
+	}
+}
+
+func (s *RecordSet) resize(n int) {
+	s.slots = make([]uint32, n)
+	mask := uint64(n - 1)
+	for i := range s.records {
+		j := s.hash(s.records[i].packedID()) & mask
+		for s.slots[j] != 0 {
+			j = (j + 1) & mask
+		}
+		s.slots[j] = uint32(i + 1)
+	}
+}
@@ -0,0 +1,217 @@
+package humanattestation
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPackID(t *testing.T) {
+	checked, _ := GenerateIDChecked()
+	testID, _ := GenerateTestID()
+	for _, id := range []string{"hap_abc123xyz456", "hap_AAAAAAAAAAAA", "hap_999999999999", "hap_9999999999999", checked, testID} {
+		p, err := packID(id)
+		if err != nil {
+			t.Fatalf("packID(%q) error = %v", id, err)
+		}
+		if got := p.String(); got != id {
+			t.Errorf("packID(%q).String() = %q", id, got)
+		}
+	}
+
+	// Different lengths never collide
+	short, _ := packID("hap_AAAAAAAAAAAA")
+	long, _ := packID("hap_AAAAAAAAAAAAA")
+	if short == long {
+		t.Error("12- and 13-character IDs packed to the same value")
+	}
+
+	for _, id := range []string{"", "hap_short", "hap_abc123xyz45!", "hap_test_abc"} {
+		if _, err := packID(id); !errors.Is(err, ErrInvalidIDFormat) {
+			t.Errorf("packID(%q) error = %v, want ErrInvalidIDFormat", id, err)
+		}
+	}
+}
+
+func TestRecordSet(t *testing.T) {
+	set := NewRecordSet(RecordSetOptions{})
+	claim := testClaim(t)
+
+	added, err := set.Add(claim)
+	if err != nil || !added {
+		t.Fatalf("Add() = %v, %v", added, err)
+	}
+	if added, _ := set.Add(claim); added {
+		t.Error("Add() of a duplicate reported added")
+	}
+	if !set.Contains(claim.ID) || set.Contains("hap_zzzzzzzzzzzz") || set.Len() != 1 {
+		t.Errorf("Contains/Len wrong after one Add")
+	}
+
+	r, ok := set.Get(claim.ID)
+	if !ok {
+		t.Fatal("Get() found nothing")
+	}
+	at, _ := claim.IssuedAt()
+	exp, _ := claim.ExpiresAt()
+	if r.ID() != claim.ID || !r.IssuedAt().Equal(at) || !r.ExpiresAt().Equal(exp) {
+		t.Errorf("record = %s %s %s, want %s %s %s", r.ID(), r.IssuedAt(), r.ExpiresAt(), claim.ID, at, exp)
+	}
+	if set.Method(r) != "ba_priority_mail" || set.Domain(r) != "acme.com" || set.Issuer(r) != "ballista.jobs" {
+		t.Errorf("record strings = %s %s %s", set.Method(r), set.Domain(r), set.Issuer(r))
+	}
+
+	noExp := testClaim(t)
+	noExp.Exp = ""
+	r, _ = set.FromClaim(noExp)
+	if !r.ExpiresAt().IsZero() {
+		t.Errorf("ExpiresAt() = %s, want zero", r.ExpiresAt())
+	}
+
+	var seen int
+	set.Range(func(CompactRecord) bool { seen++; return true })
+	if seen != 1 {
+		t.Errorf("Range visited %d records, want 1", seen)
+	}
+	set.Remove(claim.ID)
+	if set.Contains(claim.ID) {
+		t.Error("Contains() after Remove")
+	}
+}
+
+func TestRecordSetAddRemoveMany(t *testing.T) {
+	set := NewRecordSet(RecordSetOptions{})
+	claim := testClaim(t)
+	var ids []string
+	for i := 0; i < 5000; i++ {
+		id, _ := GenerateID()
+		claim.ID = id
+		if _, err := set.Add(claim); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	for i, id := range ids {
+		if i%2 == 0 {
+			set.Remove(id)
+		}
+	}
+	for i, id := range ids {
+		if got := set.Contains(id); got != (i%2 == 1) {
+			t.Fatalf("Contains(%s) = %v after removing even entries", id, got)
+		}
+		if r, ok := set.Get(id); ok && r.ID() != id {
+			t.Fatalf("Get(%s) returned record for %s", id, r.ID())
+		}
+	}
+	if set.Len() != len(ids)/2 {
+		t.Errorf("Len() = %d, want %d", set.Len(), len(ids)/2)
+	}
+}
+
+func TestRecordSetBoundedTables(t *testing.T) {
+	set := NewRecordSet(RecordSetOptions{MaxDomains: 3})
+	claim := testClaim(t)
+	claim.To.Domain = "a.example" // Issuer takes the second slot
+	if _, err := set.Add(claim); err != nil {
+		t.Fatal(err)
+	}
+	claim = testClaim(t)
+	claim.To.Domain = "b.example"
+	if _, err := set.Add(claim); err != nil {
+		t.Fatal(err)
+	}
+	claim = testClaim(t)
+	claim.To.Domain = "c.example"
+	if _, err := set.Add(claim); !errors.Is(err, ErrStringTableFull) {
+		t.Errorf("Add() error = %v, want ErrStringTableFull", err)
+	}
+	if set.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", set.Len())
+	}
+}
+
+func TestStringTableConcurrent(t *testing.T) {
+	table := NewStringTable(100)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				s := fmt.Sprintf("domain%d.example", i)
+				idx, err := table.Intern(s)
+				if err != nil || table.Lookup(idx) != s {
+					t.Errorf("Intern(%q) = %d, %v", s, idx, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if table.Len() != 50 {
+		t.Errorf("Len() = %d, want 50", table.Len())
+	}
+}
+
+// syntheticClaims returns n distinct claims to 1000 recipient domains, keyed by ID
+func syntheticClaims(b *testing.B, n int) map[string]*Claim {
+	b.Helper()
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	claims := make(map[string]*Claim, n)
+	for i := 0; i < n; i++ {
+		id, err := GenerateID()
+		if err != nil {
+			b.Fatal(err)
+		}
+		claims[id] = &Claim{
+			V:           Version,
+			ID:          id,
+			To:          ClaimTarget{Name: fmt.Sprintf("Company %d", i%1000), Domain: fmt.Sprintf("company%d.example", i%1000)},
+			At:          at.Add(time.Duration(i) * time.Second).Format(time.RFC3339),
+			Exp:         at.Add(time.Duration(i)*time.Second + 730*24*time.Hour).Format(time.RFC3339),
+			Iss:         "ballista.jobs",
+			Method:      "ba_priority_mail",
+			Description: "Priority mail verification",
+		}
+	}
+	return claims
+}
+
+func heapInUse() int64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapAlloc)
+}
+
+// BenchmarkRecordSetMemory compares the heap held by 1M claims kept as
+// Claim structs in a map with the same claims in a RecordSet
+func BenchmarkRecordSetMemory(b *testing.B) {
+	const n = 1_000_000
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		before := heapInUse()
+		claims := syntheticClaims(b, n)
+		claimBytes := heapInUse() - before
+		b.StartTimer()
+
+		set := NewRecordSet(RecordSetOptions{})
+		for _, c := range claims {
+			if _, err := set.Add(c); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.StopTimer()
+		claims = nil
+		recordBytes := heapInUse() - before
+
+		b.ReportMetric(float64(claimBytes)/n, "claim-B/op")
+		b.ReportMetric(float64(recordBytes)/float64(set.Len()), "record-B/op")
+		b.ReportMetric(float64(claimBytes)/float64(recordBytes), "x-smaller")
+		runtime.KeepAlive(set)
+	}
+}
@@ -0,0 +1,164 @@
+package humanattestation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// ErrNotRecorded is returned by a ReplayTransport for a request it has no
+// recorded response for
+var ErrNotRecorded = errors.New("no recorded response")
+
+// RecordedExchange is one request and the response or error it got
+type RecordedExchange struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+	Error  string      `json:"error,omitempty"` // Set instead of a response when the request failed
+}
+
+// RecordingTransport is an http.RoundTripper that passes requests on and
+// saves every exchange to a JSON file, for replaying a verification later
+// with a ReplayTransport. Use it as the Transport of
+// VerifyOptions.HTTPClient. It is safe for concurrent use; concurrent
+// exchanges are saved in the order they complete.
+type RecordingTransport struct {
+	path      string
+	next      http.RoundTripper
+	mu        sync.Mutex
+	exchanges []RecordedExchange
+}
+
+// NewRecordingTransport returns a RecordingTransport that sends requests
+// with next (default: http.DefaultTransport) and writes exchanges to path,
+// replacing any file there
+func NewRecordingTransport(path string, next http.RoundTripper) *RecordingTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &RecordingTransport{path: path, next: next}
+}
+
+// RoundTrip sends req and records the exchange. An error saving the file
+// fails the request, so a recording is never silently incomplete.
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	exchange := RecordedExchange{Method: req.Method, URL: req.URL.String()}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		exchange.Error = err.Error()
+		if saveErr := t.record(exchange); saveErr != nil {
+			return nil, saveErr
+		}
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	exchange.Status, exchange.Header, exchange.Body = resp.StatusCode, resp.Header.Clone(), string(body)
+	if err := t.record(exchange); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Exchanges returns the exchanges recorded so far
+func (t *RecordingTransport) Exchanges() []RecordedExchange {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]RecordedExchange(nil), t.exchanges...)
+}
+
+func (t *RecordingTransport) record(exchange RecordedExchange) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.exchanges = append(t.exchanges, exchange)
+
+	data, err := json.MarshalIndent(t.exchanges, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to serialize recording: %w", err)
+	}
+	if err := writeFileAtomic(t.path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write recording: %w", err)
+	}
+	return nil
+}
+
+// ReplayTransport is an http.RoundTripper that answers requests from a
+// recording made by a RecordingTransport, without touching the network.
+// Requests are matched by method and URL. Repeated requests get the
+// recorded responses in order, and the last one again once those run out.
+// It is safe for concurrent use.
+type ReplayTransport struct {
+	mu        sync.Mutex
+	exchanges map[string][]RecordedExchange
+}
+
+// NewReplayTransport loads the recording at path
+func NewReplayTransport(path string) (*ReplayTransport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+	var list []RecordedExchange
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse recording: %w", err)
+	}
+
+	t := &ReplayTransport{exchanges: make(map[string][]RecordedExchange)}
+	for _, e := range list {
+		key := e.Method + " " + e.URL
+		t.exchanges[key] = append(t.exchanges[key], e)
+	}
+	return t, nil
+}
+
+// RoundTrip returns the next recorded response for req
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	key := req.Method + " " + req.URL.String()
+
+	t.mu.Lock()
+	queue := t.exchanges[key]
+	if len(queue) == 0 {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("%w for %s", ErrNotRecorded, key)
+	}
+	e := queue[0]
+	if len(queue) > 1 {
+		t.exchanges[key] = queue[1:]
+	}
+	t.mu.Unlock()
+
+	if e.Error != "" {
+		return nil, errors.New(e.Error)
+	}
+	header := e.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+		StatusCode:    e.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(e.Body))),
+		ContentLength: int64(len(e.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,82 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+	claim, _ := va.Issue(t, priv, "key_001")
+	path := filepath.Join(t.TempDir(), "trace.json")
+	ctx := context.Background()
+
+	recorder := NewRecordingTransport(path, va.Client().Transport)
+	opts := va.Options()
+	opts.HTTPClient = &http.Client{Transport: recorder}
+	opts.KeyCache = NewPublicKeyCache(time.Minute)
+	recorded, err := VerifyClaim(ctx, claim.ID, va.Issuer(), opts)
+	if err != nil || recorded == nil {
+		t.Fatalf("VerifyClaim() = %+v, %v", recorded, err)
+	}
+	if n := len(recorder.Exchanges()); n != 2 {
+		t.Fatalf("recorded %d exchanges, want the claim and the well-known document", n)
+	}
+
+	// Replay offline, with nothing cached
+	va.Close()
+	replay, err := NewReplayTransport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.HTTPClient = &http.Client{Transport: replay}
+	opts.KeyCache = NewPublicKeyCache(time.Minute)
+	replayed, err := VerifyClaim(ctx, claim.ID, va.Issuer(), opts)
+	if err != nil || replayed == nil {
+		t.Fatalf("replayed VerifyClaim() = %+v, %v", replayed, err)
+	}
+	want, _ := json.Marshal(recorded)
+	got, _ := json.Marshal(replayed)
+	if string(got) != string(want) {
+		t.Errorf("replayed response = %s, want %s", got, want)
+	}
+
+	// Responses run out by repeating the last one
+	opts.KeyCache = NewPublicKeyCache(time.Minute)
+	if again, err := VerifyClaim(ctx, claim.ID, va.Issuer(), opts); err != nil || again == nil {
+		t.Errorf("second replay = %+v, %v", again, err)
+	}
+
+	_, err = VerifyClaim(ctx, "hap_000000000000", va.Issuer(), opts)
+	if !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("unrecorded request: error = %v, want ErrNotRecorded", err)
+	}
+}
+
+func TestReplayRecordedError(t *testing.T) {
+	va := newTestVA(t)
+	va.Close()
+	path := filepath.Join(t.TempDir(), "trace.json")
+	opts := va.Options()
+	opts.HTTPClient = &http.Client{Transport: NewRecordingTransport(path, va.Client().Transport)}
+	opts.KeyCache = NewPublicKeyCache(time.Minute)
+	if _, err := FetchPublicKeys(context.Background(), va.Issuer(), opts); err == nil {
+		t.Fatal("FetchPublicKeys() from a closed VA succeeded")
+	}
+
+	replay, err := NewReplayTransport(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.HTTPClient = &http.Client{Transport: replay}
+	_, err = FetchPublicKeys(context.Background(), va.Issuer(), opts)
+	if err == nil || errors.Is(err, ErrNotRecorded) {
+		t.Errorf("replayed error = %v, want the recorded connection error", err)
+	}
+}
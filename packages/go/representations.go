@@ -0,0 +1,45 @@
+package humanattestation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RepresentationsAgree verifies a compact string and a JWS against keys and
+// reports whether they describe the same claim, for recipients given both
+// forms of one claim who want to check them before trusting either. Only the
+// fields the compact form carries are compared: id, method, recipient,
+// issuer, at and exp to the second, and for version 2 the tier. Signatures
+// legitimately differ and are ignored. An error is returned if either fails
+// to verify.
+func RepresentationsAgree(compact, jws string, keys []JWK) (bool, error) {
+	c := VerifyCompact(compact, keys)
+	if !c.Valid {
+		return false, fmt.Errorf("failed to verify compact: %w", c.Err)
+	}
+	j := verifyJWSSignature(jws, keys)
+	if !j.Valid {
+		return false, fmt.Errorf("failed to verify JWS: %w", j.Err)
+	}
+
+	a, b := c.Claim, j.Claim
+	if a.ID != b.ID || a.Method != b.Method || a.To.Name != b.To.Name ||
+		!sameDomain(a.To.Domain, b.To.Domain) || !sameDomain(a.Iss, b.Iss) {
+		return false, nil
+	}
+	if strings.HasPrefix(compact, "HAP"+CompactVersionV2+".") && a.Tier != b.Tier {
+		return false, nil
+	}
+	return sameSecond(a.At, b.At) && sameSecond(a.Exp, b.Exp), nil
+}
+
+// sameSecond reports whether two claim timestamps name the same second, or
+// are both absent
+func sameSecond(x, y string) bool {
+	if x == "" || y == "" {
+		return x == y
+	}
+	tx, errX := isoToUnix(x)
+	ty, errY := isoToUnix(y)
+	return errX == nil && errY == nil && tx == ty
+}
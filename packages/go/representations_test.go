@@ -0,0 +1,56 @@
+package humanattestation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRepresentationsAgree(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	keys := []JWK{jwk}
+	claim := testClaim(t)
+	claim.Tier = "gold"
+	jws, err := SignClaim(claim, priv, "key_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, opt := range []CompactOptions{{}, {Version: CompactVersionV2, Kid: "key_001"}} {
+		compact, err := SignCompact(claim, priv, opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if agree, err := RepresentationsAgree(compact, jws, keys); err != nil || !agree {
+			t.Errorf("RepresentationsAgree(%s) = %v, %v, want true", compact[:4], agree, err)
+		}
+	}
+
+	// A validly signed compact for a different recipient does not agree
+	other := *claim
+	other.To.Name = "Globex"
+	mismatched, err := SignCompact(&other, priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agree, err := RepresentationsAgree(mismatched, jws, keys); err != nil || agree {
+		t.Errorf("RepresentationsAgree() for mismatched pair = %v, %v, want false", agree, err)
+	}
+
+	// Version 2 carries the tier, so it must match too
+	other = *claim
+	other.Tier = "silver"
+	retiered, err := SignCompact(&other, priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if agree, err := RepresentationsAgree(retiered, jws, keys); err != nil || agree {
+		t.Errorf("RepresentationsAgree() for different tiers = %v, %v, want false", agree, err)
+	}
+
+	// Neither form is trusted unless both verify
+	_, otherJWK := testKeys(t, "key_001")
+	compact, _ := SignCompact(claim, priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if _, err := RepresentationsAgree(compact, jws, []JWK{otherJWK}); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("RepresentationsAgree() with wrong keys: error = %v, want ErrSignatureInvalid", err)
+	}
+}
@@ -0,0 +1,200 @@
+package humanattestation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// ResultHeaderName is the header that carries a result token between
+// internal services
+const ResultHeaderName = "X-HAP-Result"
+
+// DefaultResultHeaderMaxAge is how old a result token ResultHeaderMiddleware
+// accepts by default
+const DefaultResultHeaderMaxAge = 30 * time.Second
+
+// resultTokenType is the JWS typ header of result tokens
+const resultTokenType = "hap-result+jws"
+
+// maxResultError bounds the error text carried in a result token so tokens
+// stay well under 1 KB
+const maxResultError = 200
+
+// ErrResultExpired is returned by DecodeResultHeader for tokens older than
+// the allowed age
+var ErrResultExpired = errors.New("result token expired")
+
+// resultPayload is the redacted wire form of a VerificationResult. Only the
+// claim fields downstream services route on are kept.
+type resultPayload struct {
+	Kind     InputKind `json:"k,omitempty"`
+	Valid    bool      `json:"v"`
+	Error    string    `json:"e,omitempty"`
+	ID       string    `json:"id,omitempty"`
+	Iss      string    `json:"iss,omitempty"`
+	Domain   string    `json:"d,omitempty"`
+	Method   string    `json:"m,omitempty"`
+	At       string    `json:"at,omitempty"`
+	Exp      string    `json:"exp,omitempty"`
+	IssuedAt int64     `json:"iat"`
+}
+
+// EncodeResultHeader signs a redacted copy of result as a compact token for
+// internal service hops, so services behind the one that verified a claim
+// need not verify it again. The token keeps the outcome, the claim's ID,
+// issuer, recipient domain, method and timestamps; recipient names,
+// descriptions, costs and key provenance are dropped.
+//
+// The key is the caller's own, not a VA's. Tokens carry no nonce: anyone who
+// sees one can replay it on other requests until it is older than the
+// maxAge its reader allows, so keep that short and the header internal.
+func EncodeResultHeader(result *VerificationResult, key ed25519.PrivateKey) (string, error) {
+	return encodeResultHeader(result, key, time.Now())
+}
+
+func encodeResultHeader(result *VerificationResult, key ed25519.PrivateKey, now time.Time) (string, error) {
+	p := resultPayload{Kind: result.Kind, Valid: result.Valid, Error: result.Error, IssuedAt: now.Unix()}
+	if len(p.Error) > maxResultError {
+		p.Error = strings.ToValidUTF8(p.Error[:maxResultError], "")
+	}
+	if c := result.Claim; c != nil {
+		p.ID, p.Iss, p.Domain, p.Method, p.At, p.Exp = c.ID, c.Iss, c.To.Domain, c.Method, c.At, c.Exp
+	}
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize result: %w", err)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.EdDSA, Key: key},
+		(&jose.SignerOptions{}).WithType(resultTokenType),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create signer: %w", err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign result: %w", err)
+	}
+	return jws.CompactSerialize()
+}
+
+// DecodeResultHeader verifies a token from EncodeResultHeader and returns the
+// redacted result. Tokens older than maxAge return ErrResultExpired.
+func DecodeResultHeader(token string, pub ed25519.PublicKey, maxAge time.Duration) (*VerificationResult, error) {
+	return decodeResultHeader(token, pub, maxAge, time.Now())
+}
+
+func decodeResultHeader(token string, pub ed25519.PublicKey, maxAge time.Duration, now time.Time) (*VerificationResult, error) {
+	jws, err := jose.ParseSigned(token, []jose.SignatureAlgorithm{jose.EdDSA})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWS: %w", err)
+	}
+	if typ, _ := jws.Signatures[0].Header.ExtraHeaders[jose.HeaderType].(string); typ != resultTokenType {
+		return nil, fmt.Errorf("JWS is not a result token (typ %q)", typ)
+	}
+	payload, err := jws.Verify(pub)
+	if err != nil {
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	var p resultPayload
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse result: %w", err)
+	}
+	// Allow the same skew into the future as into the past
+	age := now.Sub(time.Unix(p.IssuedAt, 0))
+	if age > maxAge || age < -maxAge {
+		return nil, ErrResultExpired
+	}
+
+	result := &VerificationResult{Kind: p.Kind, Valid: p.Valid, Error: p.Error}
+	if p.Error != "" {
+		// Only the message crosses the hop; sentinels do not match
+		result.Err = errors.New(p.Error)
+	}
+	if p.ID != "" {
+		result.Claim = &Claim{
+			V:      Version,
+			ID:     p.ID,
+			To:     ClaimTarget{Domain: p.Domain},
+			Method: p.Method,
+			At:     p.At,
+			Exp:    p.Exp,
+			Iss:    p.Iss,
+		}
+	}
+	return result, nil
+}
+
+type resultContextKey struct{}
+
+// ContextWithVerificationResult returns a copy of ctx carrying result, for
+// NewResultHeaderTransport to forward
+func ContextWithVerificationResult(ctx context.Context, result *VerificationResult) context.Context {
+	return context.WithValue(ctx, resultContextKey{}, result)
+}
+
+// VerificationResultFromContext returns the result stored by
+// ContextWithVerificationResult or ResultHeaderMiddleware
+func VerificationResultFromContext(ctx context.Context) (*VerificationResult, bool) {
+	result, ok := ctx.Value(resultContextKey{}).(*VerificationResult)
+	return result, ok && result != nil
+}
+
+// NewResultHeaderTransport returns a RoundTripper for the service that
+// verifies claims. It sets X-HAP-Result on each outgoing request from the
+// result in the request's context, and removes any X-HAP-Result the request
+// already had so clients cannot smuggle one through. base defaults to
+// http.DefaultTransport.
+func NewResultHeaderTransport(base http.RoundTripper, key ed25519.PrivateKey) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Del(ResultHeaderName)
+		if result, ok := VerificationResultFromContext(req.Context()); ok {
+			token, err := EncodeResultHeader(result, key)
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set(ResultHeaderName, token)
+		}
+		return base.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// ResultHeaderMiddleware returns middleware for downstream services. It
+// decodes X-HAP-Result and stores the result in the request context for
+// VerificationResultFromContext. Requests without a valid token are passed
+// on without a result. maxAge defaults to DefaultResultHeaderMaxAge.
+func ResultHeaderMiddleware(pub ed25519.PublicKey, maxAge time.Duration) func(http.Handler) http.Handler {
+	if maxAge <= 0 {
+		maxAge = DefaultResultHeaderMaxAge
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token := r.Header.Get(ResultHeaderName); token != "" {
+				if result, err := DecodeResultHeader(token, pub, maxAge); err == nil {
+					r = r.WithContext(ContextWithVerificationResult(r.Context(), result))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package humanattestation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResultHeaderRoundTrip(t *testing.T) {
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	claim := testClaim(t)
+	claim.Cost = &ClaimCost{Amount: 1500, Currency: "USD"}
+	result := &VerificationResult{Kind: InputCompact, Valid: true, Claim: claim, KeyProvenance: &KeyProvenance{Source: KeySourceNetwork}}
+
+	token, err := EncodeResultHeader(result, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeResultHeader(token, pub, time.Minute)
+	if err != nil {
+		t.Fatalf("DecodeResultHeader() = %v", err)
+	}
+	if !got.Valid || got.Kind != InputCompact || got.Claim == nil {
+		t.Fatalf("decoded = %+v", got)
+	}
+	c := got.Claim
+	if c.ID != claim.ID || c.Iss != claim.Iss || c.To.Domain != claim.To.Domain || c.Method != claim.Method || c.Exp != claim.Exp {
+		t.Errorf("decoded claim = %+v", c)
+	}
+	if c.To.Name != "" || c.Description != "" || c.Cost != nil || got.KeyProvenance != nil {
+		t.Errorf("decoded result not redacted: %+v", got)
+	}
+}
+
+func TestResultHeaderSize(t *testing.T) {
+	priv, _, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	claim := testClaim(t)
+	claim.To.Domain = strings.Repeat("a", 63) + "." + strings.Repeat("b", 63) + "." + strings.Repeat("c", 63) + ".com"
+	result := &VerificationResult{Kind: InputVerifyURL, Claim: claim, Error: strings.Repeat("x", 4096)}
+
+	token, err := EncodeResultHeader(result, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) >= 1024 {
+		t.Errorf("token is %d bytes, want under 1 KB", len(token))
+	}
+}
+
+func TestResultHeaderRejects(t *testing.T) {
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherPub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	token, err := encodeResultHeader(&VerificationResult{Valid: true, Claim: testClaim(t)}, priv, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("expiry", func(t *testing.T) {
+		if _, err := decodeResultHeader(token, pub, 30*time.Second, now.Add(29*time.Second)); err != nil {
+			t.Errorf("token within max age: %v", err)
+		}
+		if _, err := decodeResultHeader(token, pub, 30*time.Second, now.Add(31*time.Second)); !errors.Is(err, ErrResultExpired) {
+			t.Errorf("stale token: %v, want ErrResultExpired", err)
+		}
+		if _, err := decodeResultHeader(token, pub, 30*time.Second, now.Add(-time.Minute)); !errors.Is(err, ErrResultExpired) {
+			t.Errorf("token from the future: %v, want ErrResultExpired", err)
+		}
+	})
+
+	t.Run("tampering", func(t *testing.T) {
+		parts := strings.Split(token, ".")
+		forged, err := encodeResultHeader(&VerificationResult{Valid: false}, priv, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Swap in another token's payload under the original signature
+		parts[1] = strings.Split(forged, ".")[1]
+		if _, err := decodeResultHeader(strings.Join(parts, "."), pub, time.Minute, now); err == nil {
+			t.Error("tampered token accepted")
+		}
+	})
+
+	t.Run("key mismatch", func(t *testing.T) {
+		if _, err := decodeResultHeader(token, otherPub, time.Minute, now); err == nil {
+			t.Error("token accepted under another key")
+		}
+	})
+
+	t.Run("other token types", func(t *testing.T) {
+		jws, err := SignClaim(testClaim(t), priv, "key_001")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := decodeResultHeader(jws, pub, time.Minute, now); err == nil {
+			t.Error("claim JWS accepted as a result token")
+		}
+	})
+}
+
+func TestResultHeaderMiddleware(t *testing.T) {
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Downstream service reporting what the middleware gave it
+	downstream := httptest.NewServer(ResultHeaderMiddleware(pub, 0)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if result, ok := VerificationResultFromContext(r.Context()); ok && result.Valid {
+			w.Write([]byte(result.Claim.ID))
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	})))
+	defer downstream.Close()
+	client := &http.Client{Transport: NewResultHeaderTransport(nil, priv)}
+
+	get := func(ctx context.Context, smuggled string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, "GET", downstream.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if smuggled != "" {
+			req.Header.Set(ResultHeaderName, smuggled)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	claim := testClaim(t)
+	ctx := ContextWithVerificationResult(context.Background(), &VerificationResult{Valid: true, Claim: claim})
+	if resp := get(ctx, ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("forwarded result: HTTP %d", resp.StatusCode)
+	}
+
+	// A header set by the client is stripped, even if it is genuine
+	smuggled, err := EncodeResultHeader(&VerificationResult{Valid: true, Claim: claim}, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := get(context.Background(), smuggled); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("smuggled header: HTTP %d, want 401", resp.StatusCode)
+	}
+}
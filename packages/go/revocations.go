@@ -0,0 +1,135 @@
+package humanattestation
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RevocationEntry is a single revocation announced by a VA
+type RevocationEntry struct {
+	ID               string           `json:"id"`
+	RevocationReason RevocationReason `json:"revocationReason"`
+	RevokedAt        Timestamp        `json:"revokedAt"`
+}
+
+// Reconnect backoff bounds for StreamRevocations
+var (
+	revocationStreamMinBackoff = time.Second
+	revocationStreamMaxBackoff = 30 * time.Second
+)
+
+// StreamRevocations subscribes to a VA's revocation feed at
+// /api/v1/revocations/stream (server-sent events) and calls fn for each
+// revocation received. It reconnects with exponential backoff when the
+// connection drops, resuming from the last event ID, and runs until ctx is
+// cancelled or the VA rejects the request.
+//
+// opts.Timeout is not applied to the stream; opts.HTTPClient must not set a
+// client-wide timeout either, or the stream will be cut off.
+func StreamRevocations(ctx context.Context, issuerDomain string, opts VerifyOptions, fn func(RevocationEntry)) error {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+
+	url, err := opts.endpoint(issuerDomain, "/api/v1/revocations/stream")
+	if err != nil {
+		return err
+	}
+
+	lastEventID := ""
+	backoff := revocationStreamMinBackoff
+	for {
+		received, err := readRevocationStream(ctx, opts.HTTPClient, url, &lastEventID, fn)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var perm permanentStreamError
+		if errors.As(err, &perm) {
+			return perm.err
+		}
+		if received {
+			backoff = revocationStreamMinBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > revocationStreamMaxBackoff {
+			backoff = revocationStreamMaxBackoff
+		}
+	}
+}
+
+// permanentStreamError marks a stream failure that reconnecting will not fix
+type permanentStreamError struct{ err error }
+
+func (e permanentStreamError) Error() string { return e.err.Error() }
+
+// readRevocationStream reads one connection's worth of events, reporting
+// whether any were received
+func readRevocationStream(ctx context.Context, client *http.Client, url string, lastEventID *string, fn func(RevocationEntry)) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return false, permanentStreamError{fmt.Errorf("failed to create request: %w", err)}
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastEventID != "" {
+		req.Header.Set("Last-Event-ID", *lastEventID)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to revocation stream: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("failed to connect to revocation stream: HTTP %d", resp.StatusCode)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return false, permanentStreamError{err}
+		}
+		return false, err
+	}
+
+	received := false
+	var data []string
+	eventID := ""
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			// A blank line dispatches the buffered event
+			if len(data) > 0 {
+				var entry RevocationEntry
+				if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &entry); err == nil && entry.ID != "" {
+					fn(entry)
+					received = true
+				}
+			}
+			if eventID != "" {
+				*lastEventID = eventID
+			}
+			data, eventID = nil, ""
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			data = append(data, value)
+		case "id":
+			eventID = value
+		}
+	}
+	return received, scanner.Err()
+}
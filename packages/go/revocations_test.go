@@ -0,0 +1,83 @@
+package humanattestation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStreamRevocations(t *testing.T) {
+	revocationStreamMinBackoff = time.Millisecond
+	t.Cleanup(func() { revocationStreamMinBackoff = time.Second })
+
+	var mu sync.Mutex
+	var lastEventIDs []string
+	connections := 0
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/revocations/stream" {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		connections++
+		conn := connections
+		lastEventIDs = append(lastEventIDs, r.Header.Get("Last-Event-ID"))
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		// The first connection sends one event and drops; the second sends the next
+		switch conn {
+		case 1:
+			fmt.Fprint(w, ": keep-alive\n\nid: 1\ndata: {\"id\":\"hap_abc123xyz456\",\"revocationReason\":\"fraud\",\"revokedAt\":\"2026-02-01T12:00:00Z\"}\n\n")
+		case 2:
+			fmt.Fprint(w, "event: revocation\nid: 2\ndata: {\"id\":\"hap_def456uvw789\",\n")
+			fmt.Fprint(w, "data: \"revocationReason\":\"user_request\",\"revokedAt\":\"2026-02-02T12:00:00Z\"}\n\n")
+		}
+	}))
+	defer srv.Close()
+
+	opts := VerifyOptions{HTTPClient: srv.Client()}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var entries []RevocationEntry
+	err := StreamRevocations(ctx, strings.TrimPrefix(srv.URL, "https://"), opts, func(e RevocationEntry) {
+		entries = append(entries, e)
+		if len(entries) == 2 {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Fatalf("StreamRevocations() error = %v, want context.Canceled", err)
+	}
+
+	want := []RevocationEntry{
+		{ID: "hap_abc123xyz456", RevocationReason: RevocationFraud, RevokedAt: Timestamp{time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)}},
+		{ID: "hap_def456uvw789", RevocationReason: RevocationUserRequest, RevokedAt: Timestamp{time.Date(2026, 2, 2, 12, 0, 0, 0, time.UTC)}},
+	}
+	if len(entries) != 2 || entries[0] != want[0] || entries[1] != want[1] {
+		t.Errorf("entries = %+v, want %+v", entries, want)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lastEventIDs) < 2 || lastEventIDs[0] != "" || lastEventIDs[1] != "1" {
+		t.Errorf("Last-Event-ID headers = %q, want resume from 1", lastEventIDs)
+	}
+}
+
+func TestStreamRevocationsNotFound(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	err := StreamRevocations(context.Background(), strings.TrimPrefix(srv.URL, "https://"), VerifyOptions{HTTPClient: srv.Client()}, func(RevocationEntry) {})
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("StreamRevocations() error = %v, want HTTP 404", err)
+	}
+}
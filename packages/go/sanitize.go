@@ -0,0 +1,114 @@
+package humanattestation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// FixupKind identifies a change SanitizeCompactInput made to its input
+type FixupKind string
+
+const (
+	FixupInvisible     FixupKind = "invisible"       // Zero-width or bidi control characters removed
+	FixupWhitespace    FixupKind = "whitespace"      // Spaces and line breaks removed
+	FixupSoftLineBreak FixupKind = "soft_line_break" // Quoted-printable soft line breaks removed
+	FixupDash          FixupKind = "dash"            // Unicode dashes replaced with '-'
+	FixupSurrounding   FixupKind = "surrounding"     // Quotes, brackets or punctuation around the claim removed
+)
+
+// Fixup describes one change SanitizeCompactInput made
+type Fixup struct {
+	Kind  FixupKind
+	Count int // Characters or sequences affected
+}
+
+// ErrInvalidCompact is returned when input is not a compact string, even
+// after sanitizing
+var ErrInvalidCompact = errors.New("invalid compact string")
+
+// invisibleRunes are zero-width and bidi control characters that copy-paste
+// and chat clients insert invisibly
+var invisibleRunes = map[rune]bool{
+	'\u200b': true, '\u200c': true, '\u200d': true, '\u2060': true, '\ufeff': true, '\u00ad': true,
+	'\u200e': true, '\u200f': true, '\u061c': true,
+	'\u202a': true, '\u202b': true, '\u202c': true, '\u202d': true, '\u202e': true,
+	'\u2066': true, '\u2067': true, '\u2068': true, '\u2069': true,
+}
+
+// surroundingRunes may wrap a pasted claim: quotes (straight and smart),
+// brackets and sentence punctuation. None can start or end a compact string.
+const surroundingRunes = "\"'`“”‘’«»‹›„‚<>()[]{}.,;:!?"
+
+// SanitizeCompactInput repairs a compact string mangled by copy-paste,
+// chat clients, email or retyping, and reports every fixup applied. It
+// removes zero-width and bidi control characters, whitespace and line
+// breaks (including quoted-printable soft line breaks), quotes, brackets and
+// punctuation around the claim, and replaces Unicode dashes with '-'. The
+// result must pass IsValidCompact, or ErrInvalidCompact is returned.
+//
+// None of these characters can legally appear in a compact string, so no
+// valid claim is changed. Within the signature only whitespace and
+// invisible characters are removed; any other stray character there is an
+// error rather than a guess.
+func SanitizeCompactInput(s string) (string, []Fixup, error) {
+	var fixups []Fixup
+	note := func(kind FixupKind, count int) {
+		if count > 0 {
+			fixups = append(fixups, Fixup{Kind: kind, Count: count})
+		}
+	}
+
+	softBreaks := 0
+	for _, lineBreak := range []string{"=\r\n", "=\n"} {
+		softBreaks += strings.Count(s, lineBreak)
+		s = strings.ReplaceAll(s, lineBreak, "")
+	}
+	note(FixupSoftLineBreak, softBreaks)
+
+	var b strings.Builder
+	invisible, spaces := 0, 0
+	for _, r := range s {
+		switch {
+		case invisibleRunes[r]:
+			invisible++
+		case unicode.IsSpace(r):
+			spaces++
+		default:
+			b.WriteRune(r)
+		}
+	}
+	s = b.String()
+	note(FixupInvisible, invisible)
+	note(FixupWhitespace, spaces)
+
+	trimmed := 0
+	s = strings.TrimFunc(s, func(r rune) bool {
+		if strings.ContainsRune(surroundingRunes, r) {
+			trimmed++
+			return true
+		}
+		return false
+	})
+	note(FixupSurrounding, trimmed)
+
+	// Leave the signature alone: dashes are only normalized in the payload
+	if lastDot := strings.LastIndex(s, "."); lastDot >= 0 {
+		dashes := 0
+		payload := strings.Map(func(r rune) rune {
+			if unicode.Is(unicode.Pd, r) && r != '-' || r == '\u2212' {
+				dashes++
+				return '-'
+			}
+			return r
+		}, s[:lastDot])
+		s = payload + s[lastDot:]
+		note(FixupDash, dashes)
+	}
+
+	if !IsValidCompact(s) {
+		return "", fixups, fmt.Errorf("%w after %d fixups", ErrInvalidCompact, len(fixups))
+	}
+	return s, fixups, nil
+}
@@ -0,0 +1,141 @@
+package humanattestation
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// quotedPrintable wraps s at 20 characters with soft line breaks
+func quotedPrintable(s string) string {
+	var b strings.Builder
+	for len(s) > 20 {
+		b.WriteString(s[:20] + "=\r\n")
+		s = s[20:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+func fixupKinds(fixups []Fixup) map[FixupKind]int {
+	kinds := make(map[FixupKind]int)
+	for _, f := range fixups {
+		kinds[f.Kind] = f.Count
+	}
+	return kinds
+}
+
+func TestSanitizeCompactInput(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	compact, err := SignCompact(testClaim(t), priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Split(compact, ".")
+
+	tests := []struct {
+		name  string
+		input string
+		want  map[FixupKind]int
+	}{
+		{"clean", compact, map[FixupKind]int{}},
+		{
+			"quoted-printable, smart quotes, trailing period",
+			"\u201c" + quotedPrintable(compact) + "\u201d.",
+			map[FixupKind]int{FixupSoftLineBreak: (len(compact) - 1) / 20, FixupSurrounding: 3},
+		},
+		{
+			"zero-width and bidi characters",
+			"\u200e" + compact[:10] + "\u200b" + compact[10:] + "\ufeff",
+			map[FixupKind]int{FixupInvisible: 3},
+		},
+		{
+			"line breaks inside the signature",
+			strings.Join(fields[:10], ".") + "." + fields[10][:30] + "\n  " + fields[10][30:],
+			map[FixupKind]int{FixupWhitespace: 3},
+		},
+		{
+			"unicode dash in method",
+			strings.Replace(compact, "ba_priority_mail", "ba_priority\u2010mail", 1),
+			map[FixupKind]int{FixupDash: 1},
+		},
+		{
+			"angle brackets and chat formatting",
+			"<`" + compact + "`>",
+			map[FixupKind]int{FixupSurrounding: 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, fixups, err := SanitizeCompactInput(tt.input)
+			if err != nil {
+				t.Fatalf("SanitizeCompactInput() error = %v", err)
+			}
+			kinds := fixupKinds(fixups)
+			if len(kinds) != len(tt.want) {
+				t.Errorf("fixups = %v, want %v", fixups, tt.want)
+			}
+			for kind, n := range tt.want {
+				if kinds[kind] != n {
+					t.Errorf("fixup %s count = %d, want %d", kind, kinds[kind], n)
+				}
+			}
+			if tt.name == "unicode dash in method" {
+				// The dash was never part of the signed claim
+				return
+			}
+			if got != compact {
+				t.Errorf("SanitizeCompactInput() = %q, want original compact", got)
+			}
+			if r := VerifyCompact(got, []JWK{jwk}); !r.Valid {
+				t.Errorf("sanitized compact does not verify: %s", r.Error)
+			}
+		})
+	}
+}
+
+func TestSanitizeCompactInputLeavesSignature(t *testing.T) {
+	priv, _ := testKeys(t, "key_001")
+	compact, err := SignCompact(testClaim(t), priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastDot := strings.LastIndex(compact, ".")
+
+	// A dash lookalike inside the signature is an error, not a guess
+	mangled := compact[:lastDot+5] + "\u2010" + compact[lastDot+5:]
+	if _, _, err := SanitizeCompactInput(mangled); !errors.Is(err, ErrInvalidCompact) {
+		t.Errorf("SanitizeCompactInput() error = %v, want ErrInvalidCompact", err)
+	}
+
+	for _, input := range []string{"", "hello", "HAP2.", "hap_abc123xyz456"} {
+		if _, _, err := SanitizeCompactInput(input); !errors.Is(err, ErrInvalidCompact) {
+			t.Errorf("SanitizeCompactInput(%q) error = %v, want ErrInvalidCompact", input, err)
+		}
+	}
+}
+
+func TestLenientCompactInput(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	claim, _ := va.Issue(t, priv, "claims_001")
+	compact, err := SignCompact(claim, priv, CompactOptions{Version: CompactVersionV2, Kid: "claims_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := VerifyAnything(context.Background(), "\u201c"+quotedPrintable(compact)+"\u201d.", []string{va.Issuer()}, va.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Kind != InputCompact || !result.Valid {
+		t.Errorf("VerifyAnything() = %+v", result)
+	}
+
+	mangledURL := "https://" + va.Issuer() + "/v?c=" + url.QueryEscape(compact+"\u200b.")
+	if got := ExtractCompactFromURL(mangledURL); got != compact {
+		t.Errorf("ExtractCompactFromURL() = %q, want sanitized compact", got)
+	}
+}
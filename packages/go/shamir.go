@@ -0,0 +1,158 @@
+package humanattestation
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+)
+
+// Share layout: version | threshold | index | key ID (8) | seed share (32) | checksum (4)
+const (
+	shareVersion     = 1
+	shareKeyIDSize   = 8
+	shareChecksumLen = 4
+	shareHeaderSize  = 3 + shareKeyIDSize
+	shareSize        = shareHeaderSize + ed25519.SeedSize + shareChecksumLen
+)
+
+// Errors returned by CombinePrivateKey
+var (
+	ErrInvalidShare       = errors.New("invalid key share")
+	ErrShareMismatch      = errors.New("key shares do not belong to the same split")
+	ErrInsufficientShares = errors.New("not enough key shares")
+)
+
+// SplitPrivateKey splits a private key into parts shares with Shamir secret
+// sharing over GF(256), any threshold of which reconstruct the key with
+// CombinePrivateKey. Fewer than threshold shares reveal nothing about it.
+//
+// Each share records its index, the threshold and a fingerprint of the public
+// key, and carries a checksum, so corrupted shares and shares of different
+// keys are rejected rather than silently combined into the wrong key.
+func SplitPrivateKey(priv ed25519.PrivateKey, parts, threshold int) ([][]byte, error) {
+	if len(priv) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("private key must be %d bytes, got %d", ed25519.PrivateKeySize, len(priv))
+	}
+	if threshold < 2 || threshold > parts || parts > 255 {
+		return nil, fmt.Errorf("need 2 <= threshold <= parts <= 255, got threshold %d of %d parts", threshold, parts)
+	}
+
+	seed := priv.Seed()
+	keyID := shareKeyID(priv.Public().(ed25519.PublicKey))
+
+	// One random polynomial per seed byte, with the byte as constant term
+	coeffs := make([]byte, ed25519.SeedSize*(threshold-1))
+	if _, err := rand.Read(coeffs); err != nil {
+		return nil, fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+
+	shares := make([][]byte, parts)
+	for i := range shares {
+		x := byte(i + 1)
+		share := make([]byte, 0, shareSize)
+		share = append(share, shareVersion, byte(threshold), x)
+		share = append(share, keyID...)
+		for b, secret := range seed {
+			// Horner's rule from the highest coefficient down
+			y := byte(0)
+			for k := threshold - 2; k >= 0; k-- {
+				y = gfMul(y, x) ^ coeffs[b*(threshold-1)+k]
+			}
+			share = append(share, gfMul(y, x)^secret)
+		}
+		shares[i] = append(share, shareChecksum(share)...)
+	}
+	return shares, nil
+}
+
+// CombinePrivateKey reconstructs a private key from at least threshold of the
+// shares produced by SplitPrivateKey. It fails if a share is corrupted, the
+// shares come from different keys, or the result does not match the key that
+// was split.
+func CombinePrivateKey(shares [][]byte) (ed25519.PrivateKey, error) {
+	if len(shares) == 0 {
+		return nil, ErrInsufficientShares
+	}
+
+	seen := make(map[byte]bool, len(shares))
+	for i, share := range shares {
+		if len(share) != shareSize || share[0] != shareVersion || share[2] == 0 {
+			return nil, fmt.Errorf("%w: share %d is malformed", ErrInvalidShare, i)
+		}
+		body := share[:shareSize-shareChecksumLen]
+		if !bytes.Equal(share[len(body):], shareChecksum(body)) {
+			return nil, fmt.Errorf("%w: share %d is corrupted", ErrInvalidShare, i)
+		}
+		if share[1] != shares[0][1] || !bytes.Equal(share[3:shareHeaderSize], shares[0][3:shareHeaderSize]) {
+			return nil, fmt.Errorf("%w: share %d", ErrShareMismatch, i)
+		}
+		if seen[share[2]] {
+			return nil, fmt.Errorf("%w: share %d repeats index %d", ErrInvalidShare, i, share[2])
+		}
+		seen[share[2]] = true
+	}
+	if threshold := int(shares[0][1]); len(shares) < threshold {
+		return nil, fmt.Errorf("%w: have %d, need %d", ErrInsufficientShares, len(shares), threshold)
+	}
+
+	priv := ed25519.NewKeyFromSeed(interpolateShares(shares))
+	if !bytes.Equal(shareKeyID(priv.Public().(ed25519.PublicKey)), shares[0][3:shareHeaderSize]) {
+		return nil, fmt.Errorf("%w: shares do not reconstruct the split key", ErrShareMismatch)
+	}
+	return priv, nil
+}
+
+// interpolateShares evaluates the Lagrange polynomial through the shares at
+// x = 0, recovering the seed
+func interpolateShares(shares [][]byte) []byte {
+	seed := make([]byte, ed25519.SeedSize)
+	for i, si := range shares {
+		// Lagrange basis for share i at 0: prod xj / (xj - xi); subtraction is XOR
+		basis := byte(1)
+		for j, sj := range shares {
+			if i != j {
+				basis = gfMul(basis, gfMul(sj[2], gfInv(sj[2]^si[2])))
+			}
+		}
+		for b := range seed {
+			seed[b] ^= gfMul(basis, si[shareHeaderSize+b])
+		}
+	}
+	return seed
+}
+
+// shareKeyID fingerprints the public key a split belongs to
+func shareKeyID(pub ed25519.PublicKey) []byte {
+	sum := sha256.Sum256(append([]byte("hap-key-share\x00"), pub...))
+	return sum[:shareKeyIDSize]
+}
+
+func shareChecksum(body []byte) []byte {
+	sum := sha256.Sum256(body)
+	return sum[:shareChecksumLen]
+}
+
+// gfMul multiplies in GF(2^8) with the AES polynomial x^8 + x^4 + x^3 + x + 1,
+// without data-dependent branches
+func gfMul(a, b byte) byte {
+	var p byte
+	for i := 0; i < 8; i++ {
+		p ^= a & -(b & 1)
+		carry := a >> 7
+		a = a<<1 ^ 0x1b&-carry
+		b >>= 1
+	}
+	return p
+}
+
+// gfInv returns the multiplicative inverse of a non-zero a as a^254
+func gfInv(a byte) byte {
+	result := byte(1)
+	for i := 0; i < 254; i++ {
+		result = gfMul(result, a)
+	}
+	return result
+}
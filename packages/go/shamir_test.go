@@ -0,0 +1,132 @@
+package humanattestation
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestGFArithmetic(t *testing.T) {
+	// Known AES field products
+	if got := gfMul(0x57, 0x83); got != 0xc1 {
+		t.Errorf("gfMul(0x57, 0x83) = %#x, want 0xc1", got)
+	}
+	for a := 1; a < 256; a++ {
+		if got := gfMul(byte(a), gfInv(byte(a))); got != 1 {
+			t.Fatalf("%#x * inverse = %#x, want 1", a, got)
+		}
+	}
+}
+
+func TestSplitCombinePrivateKey(t *testing.T) {
+	priv, _, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	shares, err := SplitPrivateKey(priv, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 5 {
+		t.Fatalf("got %d shares, want 5", len(shares))
+	}
+
+	for name, subset := range map[string][][]byte{
+		"exactly threshold":    {shares[0], shares[2], shares[4]},
+		"threshold, any order": {shares[3], shares[1], shares[0]},
+		"more than threshold":  {shares[0], shares[1], shares[2], shares[3]},
+		"all shares":           shares,
+	} {
+		got, err := CombinePrivateKey(subset)
+		if err != nil {
+			t.Errorf("%s: CombinePrivateKey() error = %v", name, err)
+			continue
+		}
+		if !bytes.Equal(got, priv) {
+			t.Errorf("%s: reconstructed a different key", name)
+		}
+	}
+}
+
+func TestCombinePrivateKeyRejects(t *testing.T) {
+	priv, _, _ := GenerateKeyPair()
+	other, _, _ := GenerateKeyPair()
+	shares, err := SplitPrivateKey(priv, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherShares, _ := SplitPrivateKey(other, 5, 3)
+	// A second split of the same key uses different polynomials
+	resplit, _ := SplitPrivateKey(priv, 5, 3)
+
+	corrupted := bytes.Clone(shares[1])
+	corrupted[shareHeaderSize] ^= 0x01
+
+	tests := []struct {
+		name   string
+		shares [][]byte
+		want   error
+	}{
+		{"none", nil, ErrInsufficientShares},
+		{"below threshold", shares[:2], ErrInsufficientShares},
+		{"corrupted share", [][]byte{shares[0], corrupted, shares[2]}, ErrInvalidShare},
+		{"truncated share", [][]byte{shares[0], shares[1][:10], shares[2]}, ErrInvalidShare},
+		{"duplicate share", [][]byte{shares[0], shares[0], shares[2]}, ErrInvalidShare},
+		{"share of another key", [][]byte{shares[0], otherShares[1], shares[2]}, ErrShareMismatch},
+		{"share of another split", [][]byte{shares[0], resplit[1], shares[2]}, ErrShareMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CombinePrivateKey(tt.shares)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("CombinePrivateKey() error = %v, want %v", err, tt.want)
+			}
+			if got != nil {
+				t.Error("CombinePrivateKey() returned a key alongside an error")
+			}
+		})
+	}
+}
+
+func TestSplitPrivateKeyArgs(t *testing.T) {
+	priv, _, _ := GenerateKeyPair()
+	for _, tt := range []struct{ parts, threshold int }{{3, 1}, {2, 3}, {256, 2}, {0, 0}} {
+		if _, err := SplitPrivateKey(priv, tt.parts, tt.threshold); err == nil {
+			t.Errorf("SplitPrivateKey(%d of %d) expected error", tt.threshold, tt.parts)
+		}
+	}
+	if _, err := SplitPrivateKey(priv[:10], 3, 2); err == nil {
+		t.Error("SplitPrivateKey() accepted a short key")
+	}
+}
+
+// Fewer than threshold shares must not determine the key: interpolating them
+// never recovers the seed, and CombinePrivateKey refuses them outright.
+func TestSplitBelowThresholdRevealsNothing(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		priv, _, _ := GenerateKeyPair()
+		shares, err := SplitPrivateKey(priv, 5, 4)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subset := shares[i%2 : i%2+3]
+
+		if _, err := CombinePrivateKey(subset); !errors.Is(err, ErrInsufficientShares) {
+			t.Fatalf("CombinePrivateKey(t-1 shares) error = %v, want ErrInsufficientShares", err)
+		}
+		if bytes.Equal(interpolateShares(subset), priv.Seed()) {
+			t.Fatal("t-1 shares interpolated to the secret seed")
+		}
+
+		// Lying about the threshold does not help either
+		forged := make([][]byte, len(subset))
+		for j, s := range subset {
+			forged[j] = bytes.Clone(s)
+			forged[j][1] = 3
+			copy(forged[j][shareSize-shareChecksumLen:], shareChecksum(forged[j][:shareSize-shareChecksumLen]))
+		}
+		if _, err := CombinePrivateKey(forged); !errors.Is(err, ErrShareMismatch) {
+			t.Fatalf("CombinePrivateKey(forged threshold) error = %v, want ErrShareMismatch", err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package humanattestation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// VersionShim upgrades the JSON payload of a claim issued under an older
+// protocol version to the shape of the current Claim struct
+type VersionShim func(payload []byte) ([]byte, error)
+
+var (
+	versionShimsMu sync.RWMutex
+	versionShims   = map[string]VersionShim{
+		Version: func(payload []byte) ([]byte, error) { return payload, nil },
+	}
+)
+
+// RegisterVersionShim registers a shim applied to verified claims whose "v"
+// field equals version before they are unmarshaled. Registering a shim for a
+// version that already has one replaces it. It may be called while other
+// goroutines verify claims, which then use the old or the new shim.
+func RegisterVersionShim(version string, shim VersionShim) {
+	versionShimsMu.Lock()
+	defer versionShimsMu.Unlock()
+	versionShims[version] = shim
+}
+
+// upgradeClaimPayload applies the registered shim for the payload's protocol
+// version. Payloads with no registered shim are returned unchanged.
+func upgradeClaimPayload(payload []byte) ([]byte, error) {
+	var header struct {
+		V string `json:"v"`
+	}
+	if err := json.Unmarshal(payload, &header); err != nil {
+		return nil, err
+	}
+
+	versionShimsMu.RLock()
+	shim, ok := versionShims[header.V]
+	versionShimsMu.RUnlock()
+	if !ok {
+		return payload, nil
+	}
+
+	upgraded, err := shim(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade claim from version %s: %w", header.V, err)
+	}
+	return upgraded, nil
+}
@@ -0,0 +1,67 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+func TestVerifySignatureAppliesVersionShim(t *testing.T) {
+	// A hypothetical 0.0 claim named the recipient "recipient" instead of "to"
+	RegisterVersionShim("0.0", func(payload []byte) ([]byte, error) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(payload, &fields); err != nil {
+			return nil, err
+		}
+		fields["to"] = fields["recipient"]
+		delete(fields, "recipient")
+		fields["v"] = json.RawMessage(`"` + Version + `"`)
+		return json.Marshal(fields)
+	})
+	t.Cleanup(func() {
+		versionShimsMu.Lock()
+		delete(versionShims, "0.0")
+		versionShimsMu.Unlock()
+	})
+
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"v":           "0.0",
+		"id":          "hap_abc123xyz456",
+		"recipient":   map[string]string{"name": "Acme Corp", "domain": "acme.com"},
+		"at":          "2026-01-19T06:00:00Z",
+		"iss":         va.Issuer(),
+		"method":      "ba_priority_mail",
+		"description": "Priority mail packet",
+	})
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.EdDSA, Key: priv},
+		(&jose.SignerOptions{}).WithHeader("kid", "key_001"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, _ := signed.CompactSerialize()
+
+	result, err := VerifySignature(context.Background(), jws, va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid {
+		t.Fatalf("expected valid signature, got %s", result.Error)
+	}
+	if result.Claim.To.Name != "Acme Corp" || result.Claim.To.Domain != "acme.com" {
+		t.Errorf("shim did not map recipient: got %+v", result.Claim.To)
+	}
+	if result.Claim.V != Version {
+		t.Errorf("V = %q, want %q", result.Claim.V, Version)
+	}
+}
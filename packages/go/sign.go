@@ -66,6 +66,28 @@ func GenerateKeyPair() (ed25519.PrivateKey, ed25519.PublicKey, error) {
 	return privateKey, publicKey, nil
 }
 
+// KeyPairFromSeed derives the Ed25519 key pair for a seed of exactly
+// ed25519.SeedSize bytes, such as one committed for test fixtures or
+// provided by an HSM. The same seed always gives the same keys, so keep a
+// production seed as secret as the private key.
+func KeyPairFromSeed(seed []byte) (ed25519.PrivateKey, ed25519.PublicKey, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, nil, fmt.Errorf("invalid seed length: got %d bytes, want %d", len(seed), ed25519.SeedSize)
+	}
+	privateKey := ed25519.NewKeyFromSeed(seed)
+	return privateKey, privateKey.Public().(ed25519.PublicKey), nil
+}
+
+// SeedFromPrivateKey returns the seed a private key was derived from, which
+// KeyPairFromSeed turns back into the same key pair. It returns nil if key
+// is not ed25519.PrivateKeySize bytes.
+func SeedFromPrivateKey(key ed25519.PrivateKey) []byte {
+	if len(key) != ed25519.PrivateKeySize {
+		return nil
+	}
+	return key.Seed()
+}
+
 // ExportPublicKeyJWK exports a public key to JWK format suitable for /.well-known/hap.json
 func ExportPublicKeyJWK(publicKey ed25519.PublicKey, kid string) JWK {
 	x := base64.RawURLEncoding.EncodeToString(publicKey)
@@ -77,8 +99,35 @@ func ExportPublicKeyJWK(publicKey ed25519.PublicKey, kid string) JWK {
 	}
 }
 
-// SignClaim signs a HAP claim with an Ed25519 private key
-func SignClaim(claim *Claim, privateKey ed25519.PrivateKey, kid string) (string, error) {
+// ExportPublicKeyJWKWithUse exports a public key to JWK format restricted to the given use
+func ExportPublicKeyJWKWithUse(publicKey ed25519.PublicKey, kid string, use KeyUse) JWK {
+	jwk := ExportPublicKeyJWK(publicKey, kid)
+	jwk.Use = use
+	return jwk
+}
+
+// SignOptions configures SignClaim
+type SignOptions struct {
+	// At overrides the claim's issued-at time in the signed payload, for
+	// reproducible signatures. The caller's claim is not modified.
+	At time.Time
+}
+
+// claimJWSType is the JWS typ header of claims. Verifiers reject claim JWSs
+// typed as anything else, so other HAP tokens signed with the same key
+// cannot pass as claims.
+const claimJWSType = "hap+jws"
+
+// SignClaim signs a HAP claim with an Ed25519 private key. The claim is signed
+// exactly as given: its timestamps are never re-stamped, so signing the same
+// claim twice yields the same JWS. The JWS is typed "hap+jws".
+func SignClaim(claim *Claim, privateKey ed25519.PrivateKey, kid string, opts ...SignOptions) (string, error) {
+	if len(opts) > 0 && !opts[0].At.IsZero() {
+		stamped := *claim
+		stamped.At = opts[0].At.UTC().Format(time.RFC3339)
+		claim = &stamped
+	}
+
 	// Serialize the claim
 	payload, err := json.Marshal(claim)
 	if err != nil {
@@ -88,7 +137,7 @@ func SignClaim(claim *Claim, privateKey ed25519.PrivateKey, kid string) (string,
 	// Create the signer
 	signer, err := jose.NewSigner(
 		jose.SigningKey{Algorithm: jose.EdDSA, Key: privateKey},
-		(&jose.SignerOptions{}).WithHeader("kid", kid),
+		(&jose.SignerOptions{}).WithHeader("kid", kid).WithType(claimJWSType),
 	)
 	if err != nil {
 		return "", fmt.Errorf("failed to create signer: %w", err)
@@ -122,20 +171,41 @@ type CreateClaimParams struct {
 	Time          *int
 	Physical      *bool
 	Energy        *int
+	// SkipDomainValidation allows intentionally internal recipient domains
+	// (e.g. single-label intranet hosts) that ValidateDomain would reject
+	SkipDomainValidation bool
+	// NormalizeMethod stores Method in its NormalizeMethod form
+	NormalizeMethod bool
 }
 
 // CreateClaim creates a complete HAP claim with all required fields
 func CreateClaim(params CreateClaimParams) (*Claim, error) {
+	if params.Domain != "" && !params.SkipDomainValidation {
+		if err := ValidateDomain(params.Domain); err != nil {
+			return nil, err
+		}
+	}
+	if params.Cost != nil {
+		if err := ValidateClaimCost(*params.Cost); err != nil {
+			return nil, err
+		}
+	}
+
 	id, err := GenerateID()
 	if err != nil {
 		return nil, err
 	}
 
+	method := params.Method
+	if params.NormalizeMethod {
+		method = NormalizeMethod(method)
+	}
+
 	now := time.Now().UTC()
 	claim := &Claim{
 		V:           Version,
 		ID:          id,
-		Method:      params.Method,
+		Method:      method,
 		Description: params.Description,
 		To: ClaimTarget{
 			Name:   params.RecipientName,
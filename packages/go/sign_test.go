@@ -0,0 +1,126 @@
+package humanattestation
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+func TestSignClaimFixedAt(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	claim := testClaim(t)
+	originalAt := claim.At
+	at := time.Date(2026, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	first, err := SignClaim(claim, priv, "key_001", SignOptions{At: at})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := SignClaim(claim, priv, "key_001", SignOptions{At: at})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Errorf("signing with a fixed At produced different JWS:\n%s\n%s", first, second)
+	}
+	if claim.At != originalAt {
+		t.Errorf("SignClaim modified the caller's claim: At = %s", claim.At)
+	}
+
+	result := verifyJWSSignature(first, []JWK{jwk})
+	if !result.Valid || result.Claim.At != "2026-01-15T10:30:00Z" {
+		t.Errorf("signed claim At = %v (valid %v, error %s)", result.Claim, result.Valid, result.Error)
+	}
+}
+
+func TestClaimJWSType(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	keys := []JWK{jwk}
+	claim := testClaim(t)
+
+	signed, err := SignClaim(claim, priv, "key_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := jose.ParseSigned(signed, []jose.SignatureAlgorithm{jose.EdDSA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ := parsed.Signatures[0].Header.ExtraHeaders[jose.HeaderType]; typ != "hap+jws" {
+		t.Errorf("typ = %v, want hap+jws", typ)
+	}
+	if r := verifyJWSSignature(signed, keys); !r.Valid {
+		t.Fatalf("verifyJWSSignature() = %s", r.Error)
+	}
+
+	payload, err := json.Marshal(claim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(opts *jose.SignerOptions) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.EdDSA, Key: priv}, opts.WithHeader("kid", "key_001"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		jws, err := signer.Sign(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s, err := jws.CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	// Claims signed before typ was set still verify
+	if r := verifyJWSSignature(sign(&jose.SignerOptions{}), keys); !r.Valid {
+		t.Errorf("untyped claim: %s", r.Error)
+	}
+	// A claim payload under another HAP type, signed by the same key, does not
+	for _, typ := range []string{verificationTokenType, statsJWSType, "JWT"} {
+		r := verifyJWSSignature(sign((&jose.SignerOptions{}).WithType(jose.ContentType(typ))), keys)
+		if r.Valid || !errors.Is(r.Err, ErrInvalidFormat) {
+			t.Errorf("typ %s: Valid = %v, Err = %v", typ, r.Valid, r.Err)
+		}
+	}
+}
+
+func TestKeyPairFromSeed(t *testing.T) {
+	seed := []byte("hap-fixture-seed-exactly-32bytes")
+	priv, pub, err := KeyPairFromSeed(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _, err := KeyPairFromSeed(seed)
+	if err != nil || !priv.Equal(again) {
+		t.Fatalf("same seed gave different keys: %v", err)
+	}
+	if !pub.Equal(priv.Public()) {
+		t.Error("public key does not match private key")
+	}
+	if got := SeedFromPrivateKey(priv); !bytes.Equal(got, seed) {
+		t.Errorf("SeedFromPrivateKey() = %x, want %x", got, seed)
+	}
+
+	generated, _, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roundTrip, _, err := KeyPairFromSeed(SeedFromPrivateKey(generated)); err != nil || !roundTrip.Equal(generated) {
+		t.Errorf("generated key did not round-trip through its seed: %v", err)
+	}
+
+	for _, n := range []int{0, 31, 33, 64} {
+		if _, _, err := KeyPairFromSeed(make([]byte, n)); err == nil {
+			t.Errorf("KeyPairFromSeed() with %d bytes: expected error", n)
+		}
+	}
+	if got := SeedFromPrivateKey(priv[:40]); got != nil {
+		t.Errorf("SeedFromPrivateKey() of truncated key = %x, want nil", got)
+	}
+}
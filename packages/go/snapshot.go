@@ -0,0 +1,55 @@
+package humanattestation
+
+// OptionsSnapshot records the policy a VerifyOptions applies, for audit
+// logs that must show how a claim was verified. It leaves out the HTTP
+// client, resolver and caches, which may carry credentials or state, and
+// notes only whether hooks such as Clock were replaced. Durations are in
+// milliseconds. An unset Timeout is recorded as DefaultTimeout; other zero
+// durations mean their documented defaults.
+type OptionsSnapshot struct {
+	VerifySignatures      bool        `json:"verifySignatures"`
+	TimeoutMs             int64       `json:"timeoutMs"`
+	LeewayMs              int64       `json:"leewayMs"`
+	AllowCheckedIDs       bool        `json:"allowCheckedIds"`
+	StrictConsistency     bool        `json:"strictConsistency"`
+	KeyCacheTTLMs         int64       `json:"keyCacheTtlMs"`
+	NegativeKeyCacheTTLMs int64       `json:"negativeKeyCacheTtlMs"`
+	BypassKeyCache        bool        `json:"bypassKeyCache"`
+	ClaimCache            bool        `json:"claimCache"`
+	Budget                *WorkBudget `json:"budget,omitempty"`
+	CustomClock           bool        `json:"customClock"`
+	CustomResolver        bool        `json:"customResolver"`
+	CustomIssuerBaseURL   bool        `json:"customIssuerBaseUrl"`
+	AllowInsecureHTTP     bool        `json:"allowInsecureHttp"`
+	DNSDiscovery          bool        `json:"dnsDiscovery"`
+	KeyPinning            bool        `json:"keyPinning"`
+}
+
+// Snapshot returns the options' policy in a form safe to serialize
+func (o VerifyOptions) Snapshot() OptionsSnapshot {
+	s := OptionsSnapshot{
+		VerifySignatures:      !o.SkipSignatureVerification,
+		TimeoutMs:             o.Timeout.Milliseconds(),
+		LeewayMs:              o.Leeway.Milliseconds(),
+		AllowCheckedIDs:       o.AllowCheckedIDs,
+		StrictConsistency:     o.StrictConsistency,
+		KeyCacheTTLMs:         o.KeyCacheTTL.Milliseconds(),
+		NegativeKeyCacheTTLMs: o.NegativeKeyCacheTTL.Milliseconds(),
+		BypassKeyCache:        o.BypassKeyCache,
+		ClaimCache:            o.ClaimCache != nil,
+		CustomClock:           o.Clock != nil,
+		CustomResolver:        o.Resolver != nil,
+		CustomIssuerBaseURL:   o.IssuerBaseURL != nil,
+		AllowInsecureHTTP:     o.AllowInsecureHTTP,
+		DNSDiscovery:          o.EnableDNSDiscovery,
+		KeyPinning:            o.KeyPins != nil,
+	}
+	if s.TimeoutMs == 0 {
+		s.TimeoutMs = DefaultTimeout.Milliseconds()
+	}
+	if o.Budget != nil {
+		budget := *o.Budget
+		s.Budget = &budget
+	}
+	return s
+}
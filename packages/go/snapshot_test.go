@@ -0,0 +1,62 @@
+package humanattestation
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptionsSnapshot(t *testing.T) {
+	opts := VerifyOptions{
+		HTTPClient:          &http.Client{Transport: http.DefaultTransport},
+		Leeway:              30 * time.Second,
+		AllowCheckedIDs:     true,
+		StrictConsistency:   true,
+		KeyCacheTTL:         -time.Second,
+		NegativeKeyCacheTTL: time.Minute,
+		Budget:              &WorkBudget{MaxKeyFetches: 2},
+		ClaimCache:          NewClaimCache(0),
+		Clock:               time.Now,
+	}
+	s := opts.Snapshot()
+	want := OptionsSnapshot{
+		VerifySignatures:      true,
+		TimeoutMs:             DefaultTimeout.Milliseconds(),
+		LeewayMs:              30000,
+		AllowCheckedIDs:       true,
+		StrictConsistency:     true,
+		KeyCacheTTLMs:         -1000,
+		NegativeKeyCacheTTLMs: 60000,
+		ClaimCache:            true,
+		Budget:                &WorkBudget{MaxKeyFetches: 2},
+		CustomClock:           true,
+	}
+	budget := s.Budget
+	s.Budget, want.Budget = nil, nil
+	if s != want || budget == nil || budget.MaxKeyFetches != 2 {
+		t.Fatalf("Snapshot() = %+v with budget %+v, want %+v", s, budget, want)
+	}
+
+	// The snapshot does not share the caller's budget
+	opts.Budget.MaxKeyFetches = 5
+	if budget.MaxKeyFetches != 2 {
+		t.Error("snapshot budget changed with the options")
+	}
+
+	data, err := json.Marshal(opts.Snapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"verifySignatures":true`, `"leewayMs":30000`, `"budget":{"maxKeyFetches":5}`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("JSON %s missing %s", data, field)
+		}
+	}
+	for _, field := range []string{"httpClient", "HTTPClient", "Transport", "resolver\""} {
+		if strings.Contains(string(data), field) {
+			t.Errorf("JSON %s contains %s", data, field)
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// InputKind identifies what SniffClaimInput found in a pasted value
+type InputKind string
+
+const (
+	InputCompact   InputKind = "compact"    // Compact format string
+	InputJWS       InputKind = "jws"        // Signed claim JWS
+	InputClaimJSON InputKind = "claim_json" // Unsigned claim JSON
+	InputVerifyURL InputKind = "verify_url" // Verification URL ending in a HAP ID
+	InputID        InputKind = "id"         // Bare HAP ID
+)
+
+// MaxSniffInputSize is the largest input SniffClaimInput will examine
+const MaxSniffInputSize = 64 * 1024
+
+// maxSniffDepth is how many layers of data URI or base64 encoding
+// SniffClaimInput will unwrap
+const maxSniffDepth = 2
+
+var (
+	// ErrInputTooLarge is returned for inputs over MaxSniffInputSize
+	ErrInputTooLarge = errors.New("input too large")
+	// ErrInputTooDeep is returned when an input is encoded more than twice
+	ErrInputTooDeep = errors.New("input nested too deeply")
+	// ErrUnrecognizedInput is returned when no claim form is found
+	ErrUnrecognizedInput = errors.New("unrecognized claim input")
+)
+
+// jwsRegex matches a compact-serialized JWS
+var jwsRegex = regexp.MustCompile(`^[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+$`)
+
+// SniffClaimInput identifies a claim in a pasted value and returns its kind
+// and normalized form. It recognizes compact strings, JWS, claim JSON,
+// verification URLs and HAP IDs, unwrapping up to two layers of data URI
+// (base64 or percent-encoded) or bare base64 around them. Mangled compact
+// strings are repaired with SanitizeCompactInput.
+func SniffClaimInput(s string) (InputKind, string, error) {
+	if len(s) > MaxSniffInputSize {
+		return "", "", ErrInputTooLarge
+	}
+
+	for depth := 0; ; depth++ {
+		s = strings.TrimSpace(s)
+		if kind, value, ok := classifyClaimInput(s); ok {
+			return kind, value, nil
+		}
+		// Repair compact strings mangled by copy-paste before unwrapping
+		if compact, _, err := SanitizeCompactInput(s); err == nil {
+			return InputCompact, compact, nil
+		}
+
+		inner, ok, err := unwrapClaimInput(s)
+		if err != nil {
+			return "", "", err
+		}
+		if !ok {
+			return "", "", ErrUnrecognizedInput
+		}
+		if depth == maxSniffDepth {
+			return "", "", ErrInputTooDeep
+		}
+		s = inner
+	}
+}
+
+// classifyClaimInput recognizes an unencoded claim form
+func classifyClaimInput(s string) (InputKind, string, bool) {
+	switch {
+	case IsValidCompact(s):
+		return InputCompact, s, true
+	case IsValidID(s) || TestIDRegex.MatchString(s):
+		return InputID, s, true
+	case jwsRegex.MatchString(s):
+		return InputJWS, s, true
+	case strings.HasPrefix(s, "{") && json.Valid([]byte(s)):
+		return InputClaimJSON, s, true
+	case strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://"):
+		if compact := ExtractCompactFromURL(s); compact != "" {
+			return InputCompact, compact, true
+		}
+		if ExtractIDFromURL(s) != "" {
+			return InputVerifyURL, s, true
+		}
+	}
+	return "", "", false
+}
+
+// unwrapClaimInput removes one layer of data URI or base64 encoding. It
+// reports false if s is not encoded.
+func unwrapClaimInput(s string) (string, bool, error) {
+	if strings.HasPrefix(s, "data:") {
+		inner, err := decodeDataURI(s)
+		if err != nil {
+			return "", false, err
+		}
+		return inner, true, nil
+	}
+
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		decoded, err := enc.DecodeString(s)
+		if err == nil && len(decoded) > 0 && utf8.Valid(decoded) && isPrintable(decoded) {
+			return string(decoded), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// decodeDataURI returns the payload of a data: URI
+func decodeDataURI(s string) (string, error) {
+	header, payload, ok := strings.Cut(strings.TrimPrefix(s, "data:"), ",")
+	if !ok {
+		return "", errors.New("malformed data URI")
+	}
+
+	if strings.HasSuffix(header, ";base64") {
+		decoded, err := base64.StdEncoding.DecodeString(payload)
+		if err != nil {
+			return "", fmt.Errorf("failed to decode data URI: %w", err)
+		}
+		return string(decoded), nil
+	}
+
+	decoded, err := url.PathUnescape(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode data URI: %w", err)
+	}
+	return decoded, nil
+}
+
+// isPrintable reports whether b contains no control characters other than whitespace
+func isPrintable(b []byte) bool {
+	for _, r := range string(b) {
+		if r < 0x20 && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// VerificationResult is the result of VerifyAnything
+type VerificationResult struct {
+	Kind          InputKind
+	Valid         bool
+	Claim         *Claim
+	Error         string
+	Err           error // Why Valid is false; test with errors.Is
+	KeyProvenance *KeyProvenance
+}
+
+// VerifyAnything sniffs a pasted value with SniffClaimInput and verifies it
+// along the matching path:
+//
+//   - compact strings and JWS are verified offline against the issuer's keys
+//   - verification URLs and IDs are checked with the issuing VA
+//   - claim JSON is unsigned, so it is only valid if the VA returns the same claim
+//
+// The claim must have been issued by one of trustedIssuers. Inputs name their
+// own issuer, so with no trusted issuers every input fails rather than being
+// verified against keys from whatever host it names. A bare ID carries no
+// issuer and needs exactly one trusted issuer.
+func VerifyAnything(ctx context.Context, s string, trustedIssuers []string, opts VerifyOptions) (*VerificationResult, error) {
+	kind, value, err := SniffClaimInput(s)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = opts.metered()
+	result, err := verifySniffed(ctx, kind, value, trustedIssuers, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.meter.Err(); err != nil {
+		return nil, err
+	}
+	result.Kind = kind
+	return result, nil
+}
+
+func verifySniffed(ctx context.Context, kind InputKind, value string, trustedIssuers []string, opts VerifyOptions) (*VerificationResult, error) {
+	switch kind {
+	case InputCompact:
+		decoded, err := DecodeCompact(value)
+		if err != nil {
+			return sniffFailure(nil, newVerificationError(ErrInvalidFormat, err, "%v", err)), nil
+		}
+		issuer, mismatch := sniffedIssuer(decoded.Claim.Iss, trustedIssuers)
+		if mismatch != nil {
+			return mismatch, nil
+		}
+		wellKnown, provenance, err := fetchPublicKeys(ctx, issuer, opts)
+		if err != nil {
+			return nil, err
+		}
+		r := verifyCompact(value, wellKnown.Keys, opts.meter)
+		return &VerificationResult{Valid: r.Valid, Claim: r.Claim, Error: r.Error, Err: r.Err, KeyProvenance: provenance.at(opts.now())}, nil
+
+	case InputJWS:
+		claim, err := unverifiedJWSClaim(value)
+		if err != nil {
+			return sniffFailure(nil, newVerificationError(ErrInvalidFormat, err, "%v", err)), nil
+		}
+		issuer, mismatch := sniffedIssuer(claim.Iss, trustedIssuers)
+		if mismatch != nil {
+			return mismatch, nil
+		}
+		r, err := VerifySignature(ctx, value, issuer, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &VerificationResult{Valid: r.Valid, Claim: r.Claim, Error: r.Error, Err: r.Err, KeyProvenance: r.KeyProvenance}, nil
+
+	case InputVerifyURL:
+		parsed, err := url.Parse(value)
+		if err != nil {
+			return sniffFailure(nil, newVerificationError(ErrInvalidFormat, err, "%v", err)), nil
+		}
+		issuer, mismatch := sniffedIssuer(parsed.Host, trustedIssuers)
+		if mismatch != nil {
+			return mismatch, nil
+		}
+		return verifyByID(ctx, ExtractIDFromURL(value), issuer, nil, opts)
+
+	case InputID:
+		if len(trustedIssuers) != 1 {
+			return sniffFailure(nil, newVerificationError(ErrIssuerMismatch, nil, "a single trusted issuer is required to verify a bare ID")), nil
+		}
+		return verifyByID(ctx, value, trustedIssuers[0], nil, opts)
+
+	case InputClaimJSON:
+		var claim Claim
+		if err := json.Unmarshal([]byte(value), &claim); err != nil {
+			return sniffFailure(nil, newVerificationError(ErrInvalidFormat, err, "failed to parse claim: %v", err)), nil
+		}
+		issuer, mismatch := sniffedIssuer(claim.Iss, trustedIssuers)
+		if mismatch != nil {
+			return mismatch, nil
+		}
+		return verifyByID(ctx, claim.ID, issuer, &claim, opts)
+	}
+
+	return nil, ErrUnrecognizedInput
+}
+
+// sniffedIssuer picks the trusted issuer to verify against, returning a
+// failed result if the input names an issuer the caller does not trust. The
+// input's own issuer is never trusted on its word, so nothing is fetched from
+// it unless it is one of trusted.
+func sniffedIssuer(claimed string, trusted []string) (string, *VerificationResult) {
+	for _, iss := range trusted {
+		if sameDomain(claimed, iss) {
+			return iss, nil
+		}
+	}
+	if len(trusted) == 0 {
+		return "", sniffFailure(nil, newVerificationError(ErrIssuerMismatch, nil, "no trusted issuers given for claim from %s", claimed))
+	}
+	return "", sniffFailure(nil, newVerificationError(ErrIssuerMismatch, nil, "issuer %s is not trusted", claimed))
+}
+
+// verifyByID checks a claim with its VA, requiring the VA's claim to equal
+// want if non-nil
+func verifyByID(ctx context.Context, hapID, issuerDomain string, want *Claim, opts VerifyOptions) (*VerificationResult, error) {
+	resp, err := FetchClaim(ctx, hapID, issuerDomain, opts)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Revoked {
+		return sniffFailure(resp.Claim, newVerificationError(ErrRevoked, nil, "claim revoked: %s", resp.RevocationReason)), nil
+	}
+	if !resp.Valid {
+		return sniffFailure(resp.Claim, errors.New(resp.Error)), nil
+	}
+	if want != nil && !reflect.DeepEqual(want, resp.Claim) {
+		return sniffFailure(resp.Claim, errors.New("claim does not match the issuer's record")), nil
+	}
+
+	result := &VerificationResult{Valid: true, Claim: resp.Claim}
+	if !opts.SkipSignatureVerification {
+		if resp.JWS == "" {
+			return sniffFailure(resp.Claim, errMissingSignature(issuerDomain)), nil
+		}
+		sig, err := VerifySignature(ctx, resp.JWS, issuerDomain, opts)
+		if err != nil {
+			return nil, err
+		}
+		result.Valid, result.Error, result.Err, result.KeyProvenance = sig.Valid, sig.Error, sig.Err, sig.KeyProvenance
+	}
+	return result, nil
+}
+
+// sniffFailure returns an invalid result for err
+func sniffFailure(claim *Claim, err error) *VerificationResult {
+	return &VerificationResult{Claim: claim, Error: err.Error(), Err: err}
+}
+
+// unverifiedJWSClaim reads the claim from a JWS payload without checking the
+// signature, to learn which issuer's keys to verify it with
+func unverifiedJWSClaim(jws string) (*Claim, error) {
+	parts := strings.Split(jws, ".")
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode JWS payload: %w", err)
+	}
+	var claim Claim
+	if err := json.Unmarshal(payload, &claim); err != nil {
+		return nil, fmt.Errorf("failed to parse claim: %w", err)
+	}
+	return &claim, nil
+}
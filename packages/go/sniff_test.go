@@ -0,0 +1,177 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSniffClaimInput(t *testing.T) {
+	claim := testClaim(t)
+	priv, _ := testKeys(t, "test_001")
+	compact, err := SignCompact(claim, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := SignClaim(claim, priv, "test_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimJSON, _ := json.Marshal(claim)
+
+	b64 := base64.StdEncoding.EncodeToString
+
+	tests := []struct {
+		name      string
+		input     string
+		wantKind  InputKind
+		wantValue string
+	}{
+		{"compact", compact, InputCompact, compact},
+		{"compact with whitespace", "\n  " + compact + "\t", InputCompact, compact},
+		{"jws", jws, InputJWS, jws},
+		{"id", "hap_abc123xyz456", InputID, "hap_abc123xyz456"},
+		{"verify url", "https://ballista.jobs/v/hap_abc123xyz456", InputVerifyURL, "https://ballista.jobs/v/hap_abc123xyz456"},
+		{"url with compact", GenerateVerificationURL("https://ballista.jobs/v", compact), InputCompact, compact},
+		{"claim json", string(claimJSON), InputClaimJSON, string(claimJSON)},
+		{"base64 data uri", "data:text/plain;base64," + b64([]byte(compact)), InputCompact, compact},
+		{"percent data uri", "data:text/plain," + url.PathEscape(compact), InputCompact, compact},
+		{"json data uri", "data:application/json;base64," + b64(claimJSON), InputClaimJSON, string(claimJSON)},
+		{"bare base64 json", b64(claimJSON), InputClaimJSON, string(claimJSON)},
+		{"url-safe base64 jws", base64.RawURLEncoding.EncodeToString([]byte(jws)), InputJWS, jws},
+		{"base64 inside data uri", "data:;base64," + b64([]byte(b64(claimJSON))), InputClaimJSON, string(claimJSON)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, value, err := SniffClaimInput(tt.input)
+			if err != nil {
+				t.Fatalf("SniffClaimInput() error = %v", err)
+			}
+			if kind != tt.wantKind || value != tt.wantValue {
+				t.Errorf("SniffClaimInput() = %s %q, want %s %q", kind, value, tt.wantKind, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestSniffClaimInputAdversarial(t *testing.T) {
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	id := "hap_abc123xyz456"
+
+	tests := []struct {
+		name    string
+		input   string
+		wantErr error
+	}{
+		{"empty", "", ErrUnrecognizedInput},
+		{"plain text", "hello there, please verify me", ErrUnrecognizedInput},
+		{"oversized", strings.Repeat("A", MaxSniffInputSize+1), ErrInputTooLarge},
+		{"oversized base64 of id", strings.Repeat(b64(id), MaxSniffInputSize/len(b64(id))+1), ErrInputTooLarge},
+		{"three layers of base64", b64(b64(b64(id))), ErrInputTooDeep},
+		{"nested data uris", "data:;base64," + b64("data:;base64,"+b64("data:,"+id)), ErrInputTooDeep},
+		{"binary base64", base64.StdEncoding.EncodeToString([]byte{0x00, 0x01, 0xff, 0xfe, 0x10}), ErrUnrecognizedInput},
+		{"base64 of garbage", b64("not a claim"), ErrUnrecognizedInput},
+		{"url without id", "https://ballista.jobs/about", ErrUnrecognizedInput},
+		{"invalid json", "{\"id\": ", ErrUnrecognizedInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, _, err := SniffClaimInput(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("SniffClaimInput() = %s, %v, want error %v", kind, err, tt.wantErr)
+			}
+		})
+	}
+
+	// Two layers are still accepted
+	if kind, _, err := SniffClaimInput(b64(b64(id))); err != nil || kind != InputID {
+		t.Errorf("two layers: SniffClaimInput() = %s, %v", kind, err)
+	}
+
+	for _, input := range []string{"data:no-comma", "data:;base64,!!!", "data:,%zz"} {
+		if _, _, err := SniffClaimInput(input); err == nil {
+			t.Errorf("SniffClaimInput(%q) expected error", input)
+		}
+	}
+}
+
+func TestVerifyAnything(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	claim, jws := va.Issue(t, priv, "claims_001")
+
+	compact, err := SignCompact(claim, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimJSON, _ := json.Marshal(claim)
+
+	tampered := *claim
+	tampered.To.Name = "Someone Else"
+	tamperedJSON, _ := json.Marshal(&tampered)
+
+	ctx := context.Background()
+	trusted := []string{va.Issuer()}
+	tests := []struct {
+		name      string
+		input     string
+		issuers   []string
+		wantKind  InputKind
+		wantValid bool
+	}{
+		{"compact", compact, trusted, InputCompact, true},
+		{"jws in data uri", "data:;base64," + base64.StdEncoding.EncodeToString([]byte(jws)), trusted, InputJWS, true},
+		{"id", claim.ID, trusted, InputID, true},
+		{"id without issuer", claim.ID, nil, InputID, false},
+		{"id with several issuers", claim.ID, []string{va.Issuer(), "other.example"}, InputID, false},
+		{"verify url", "https://" + va.Issuer() + "/v/" + claim.ID, trusted, InputVerifyURL, true},
+		{"claim json", string(claimJSON), trusted, InputClaimJSON, true},
+		{"tampered claim json", string(tamperedJSON), trusted, InputClaimJSON, false},
+		{"issuer mismatch", compact, []string{"other.example"}, InputCompact, false},
+		{"one of several issuers", compact, []string{"other.example", va.Issuer()}, InputCompact, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := VerifyAnything(ctx, tt.input, tt.issuers, va.Options())
+			if err != nil {
+				t.Fatalf("VerifyAnything() error = %v", err)
+			}
+			if result.Kind != tt.wantKind || result.Valid != tt.wantValid {
+				t.Errorf("VerifyAnything() = %s valid=%v (%s), want %s valid=%v", result.Kind, result.Valid, result.Error, tt.wantKind, tt.wantValid)
+			}
+		})
+	}
+}
+
+func TestVerifyAnythingNeedsTrustedIssuers(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	claim, jws := va.Issue(t, priv, "claims_001")
+	compact, err := SignCompact(claim, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without trusted issuers a claim cannot vouch for itself, and the host
+	// it names is never contacted
+	ctx := context.Background()
+	for _, input := range []string{compact, jws, "https://" + va.Issuer() + "/v/" + claim.ID} {
+		result, err := VerifyAnything(ctx, input, nil, va.Options())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Valid || !errors.Is(result.Err, ErrIssuerMismatch) {
+			t.Errorf("VerifyAnything(%s) = valid=%v (%v), want ErrIssuerMismatch", result.Kind, result.Valid, result.Err)
+		}
+	}
+	if n := va.keyFetches.Load(); n != 0 {
+		t.Errorf("keys fetched %d times without a trusted issuer", n)
+	}
+}
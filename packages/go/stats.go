@@ -0,0 +1,306 @@
+package humanattestation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// StatsOtherBucket aggregates recipient domains too small to report individually
+const StatsOtherBucket = "other"
+
+// DefaultStatsMinBucket is the smallest per-domain count reported by default
+const DefaultStatsMinBucket = 10
+
+// statsJWSType is the JWS typ header of signed statistics documents
+const statsJWSType = "hap-stats+jws"
+
+// ErrInvalidStatsPeriod is returned for periods ParseStatsPeriod rejects
+var ErrInvalidStatsPeriod = errors.New("invalid stats period")
+
+// IssuerStats is a VA's signed count of the claims it issued in a period.
+// Recipient domains with fewer than the VA's minimum bucket size are counted
+// under StatsOtherBucket, so small recipients cannot be singled out.
+type IssuerStats struct {
+	Issuer            string         `json:"issuer"`
+	Period            string         `json:"period"`
+	Start             Timestamp      `json:"start"`
+	End               Timestamp      `json:"end"` // Exclusive
+	Total             int            `json:"total"`
+	ByMethod          map[string]int `json:"byMethod"`
+	ByRecipientDomain map[string]int `json:"byRecipientDomain"`
+	GeneratedAt       Timestamp      `json:"generatedAt"`
+}
+
+// statsResponse is the body served at /api/v1/stats
+type statsResponse struct {
+	JWS   string `json:"jws,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ParseStatsPeriod parses a statistics period, a year ("2026"), quarter
+// ("2026-Q3") or month ("2026-07"), into its UTC start and exclusive end
+func ParseStatsPeriod(period string) (start, end time.Time, err error) {
+	year, rest, hasRest := strings.Cut(period, "-")
+	y, err := strconv.Atoi(year)
+	if err != nil || len(year) != 4 {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %q", ErrInvalidStatsPeriod, period)
+	}
+
+	month, months := 1, 12
+	if hasRest {
+		if q, ok := strings.CutPrefix(rest, "Q"); ok {
+			n, err := strconv.Atoi(q)
+			if err != nil || len(q) != 1 || n < 1 || n > 4 {
+				return time.Time{}, time.Time{}, fmt.Errorf("%w: %q", ErrInvalidStatsPeriod, period)
+			}
+			month, months = (n-1)*3+1, 3
+		} else {
+			n, err := strconv.Atoi(rest)
+			if err != nil || len(rest) != 2 || n < 1 || n > 12 {
+				return time.Time{}, time.Time{}, fmt.Errorf("%w: %q", ErrInvalidStatsPeriod, period)
+			}
+			month, months = n, 1
+		}
+	}
+
+	start = time.Date(y, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, months, 0), nil
+}
+
+// ComputeIssuerStats counts the claims issued in period, merging recipient
+// domains with fewer than minBucket claims (and claims without a domain) into
+// StatsOtherBucket. Claims issued outside the period are ignored.
+func ComputeIssuerStats(issuerDomain, period string, claims []*Claim, minBucket int) (*IssuerStats, error) {
+	start, end, err := ParseStatsPeriod(period)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &IssuerStats{
+		Issuer:            issuerDomain,
+		Period:            period,
+		Start:             Timestamp{start},
+		End:               Timestamp{end},
+		ByMethod:          make(map[string]int),
+		ByRecipientDomain: make(map[string]int),
+		GeneratedAt:       Timestamp{time.Now().UTC().Truncate(time.Second)},
+	}
+
+	domains := make(map[string]int)
+	for _, claim := range claims {
+		at, err := claim.IssuedAt()
+		if err != nil || at.Before(start) || !at.Before(end) {
+			continue
+		}
+		stats.Total++
+		stats.ByMethod[claim.Method]++
+		domains[strings.ToLower(claim.To.Domain)]++
+	}
+
+	for domain, n := range domains {
+		if domain == "" || n < minBucket {
+			domain = StatsOtherBucket
+		}
+		stats.ByRecipientDomain[domain] += n
+	}
+	return stats, nil
+}
+
+// SignIssuerStats signs a statistics document as a JWS. The key should be
+// published with KeyUseStats.
+func SignIssuerStats(stats *IssuerStats, privateKey ed25519.PrivateKey, kid string) (string, error) {
+	payload, err := json.Marshal(stats)
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize stats: %w", err)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.EdDSA, Key: privateKey},
+		(&jose.SignerOptions{}).WithHeader("kid", kid).WithType(statsJWSType),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create signer: %w", err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign stats: %w", err)
+	}
+	return jws.CompactSerialize()
+}
+
+// ClaimLister lists the claims a VA issued in [start, end)
+type ClaimLister interface {
+	ListClaims(ctx context.Context, start, end time.Time) ([]*Claim, error)
+}
+
+// ClaimListerFunc adapts a function to a ClaimLister
+type ClaimListerFunc func(ctx context.Context, start, end time.Time) ([]*Claim, error)
+
+// ListClaims calls f
+func (f ClaimListerFunc) ListClaims(ctx context.Context, start, end time.Time) ([]*Claim, error) {
+	return f(ctx, start, end)
+}
+
+// StatsHandlerOptions configures NewStatsHandler
+type StatsHandlerOptions struct {
+	Issuer string
+	Key    ed25519.PrivateKey
+	Kid    string
+	// MinBucketSize is the smallest per-domain count reported individually
+	// (default: DefaultStatsMinBucket)
+	MinBucketSize int
+}
+
+// NewStatsHandler returns an http.Handler serving signed issuance statistics
+// at GET /api/v1/stats?period={period}
+func NewStatsHandler(lister ClaimLister, opts StatsHandlerOptions) http.Handler {
+	if opts.MinBucketSize <= 0 {
+		opts.MinBucketSize = DefaultStatsMinBucket
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		period := r.URL.Query().Get("period")
+		start, end, err := ParseStatsPeriod(period)
+		if err != nil {
+			writeVerifyJSON(w, http.StatusBadRequest, statsResponse{Error: "invalid_period"})
+			return
+		}
+
+		claims, err := lister.ListClaims(r.Context(), start, end)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		stats, err := ComputeIssuerStats(opts.Issuer, period, claims, opts.MinBucketSize)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		jws, err := SignIssuerStats(stats, opts.Key, opts.Kid)
+		if err != nil {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		writeVerifyJSON(w, http.StatusOK, statsResponse{JWS: jws})
+	})
+}
+
+// FetchIssuerStats fetches an issuer's statistics for period from
+// /api/v1/stats and verifies the document's signature against the issuer's
+// stats keys. The document must name the issuer and period requested, and
+// its per-method and per-domain counts must add up to its total.
+func FetchIssuerStats(ctx context.Context, issuerDomain, period string, opts VerifyOptions) (*IssuerStats, error) {
+	if _, _, err := ParseStatsPeriod(period); err != nil {
+		return nil, err
+	}
+
+	opts = opts.metered()
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultTimeout
+	}
+
+	statsURL, err := opts.endpoint(issuerDomain, "/api/v1/stats?period="+url.QueryEscape(period))
+	if err != nil {
+		return nil, err
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(fetchCtx, "GET", statsURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := opts.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch stats: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := opts.meter.readAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	var sr statsResponse
+	if err := json.Unmarshal(body, &sr); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch stats: HTTP %d %s", resp.StatusCode, sr.Error)
+	}
+
+	wellKnown, _, err := fetchPublicKeys(ctx, issuerDomain, opts)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := verifyStatsJWS(sr.JWS, wellKnown.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !sameDomain(stats.Issuer, issuerDomain):
+		return nil, fmt.Errorf("issuer mismatch: expected %s, got %s", issuerDomain, stats.Issuer)
+	case stats.Period != period:
+		return nil, fmt.Errorf("period mismatch: expected %s, got %s", period, stats.Period)
+	case sumCounts(stats.ByMethod) != stats.Total || sumCounts(stats.ByRecipientDomain) != stats.Total:
+		return nil, fmt.Errorf("stats counts do not add up to total %d", stats.Total)
+	}
+	return stats, nil
+}
+
+// verifyStatsJWS verifies a signed statistics document against keys allowed for KeyUseStats
+func verifyStatsJWS(jwsString string, keys []JWK) (*IssuerStats, error) {
+	jws, err := jose.ParseSigned(jwsString, []jose.SignatureAlgorithm{jose.EdDSA})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWS: %w", err)
+	}
+	header := jws.Signatures[0].Header
+	if typ, _ := header.ExtraHeaders[jose.HeaderType].(string); typ != statsJWSType {
+		return nil, fmt.Errorf("JWS is not a stats document (typ %q)", typ)
+	}
+
+	publicKey, err := lookupKey(keys, header.KeyID, KeyUseStats)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := jws.Verify(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	var stats IssuerStats
+	if err := json.Unmarshal(payload, &stats); err != nil {
+		return nil, fmt.Errorf("failed to parse stats: %w", err)
+	}
+	return &stats, nil
+}
+
+func sumCounts(counts map[string]int) int {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total
+}
@@ -0,0 +1,177 @@
+package humanattestation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStatsPeriod(t *testing.T) {
+	tests := []struct {
+		period     string
+		start, end string
+	}{
+		{"2026", "2026-01-01", "2027-01-01"},
+		{"2026-Q1", "2026-01-01", "2026-04-01"},
+		{"2026-Q4", "2026-10-01", "2027-01-01"},
+		{"2026-07", "2026-07-01", "2026-08-01"},
+		{"2026-12", "2026-12-01", "2027-01-01"},
+	}
+	for _, tt := range tests {
+		start, end, err := ParseStatsPeriod(tt.period)
+		if err != nil {
+			t.Errorf("ParseStatsPeriod(%q) error = %v", tt.period, err)
+			continue
+		}
+		if start.Format(time.DateOnly) != tt.start || end.Format(time.DateOnly) != tt.end {
+			t.Errorf("ParseStatsPeriod(%q) = %s, %s, want %s, %s", tt.period, start, end, tt.start, tt.end)
+		}
+	}
+
+	for _, period := range []string{"", "26", "2026-Q5", "2026-Q0", "2026-13", "2026-7", "2026-W01", "2026-Q1-01"} {
+		if _, _, err := ParseStatsPeriod(period); !errors.Is(err, ErrInvalidStatsPeriod) {
+			t.Errorf("ParseStatsPeriod(%q) error = %v, want ErrInvalidStatsPeriod", period, err)
+		}
+	}
+}
+
+// statsClaims returns n claims to domain issued at at
+func statsClaims(n int, domain, method string, at time.Time) []*Claim {
+	claims := make([]*Claim, n)
+	for i := range claims {
+		claims[i] = &Claim{To: ClaimTarget{Domain: domain}, Method: method, At: at.Format(time.RFC3339)}
+	}
+	return claims
+}
+
+func TestComputeIssuerStats(t *testing.T) {
+	inQ3 := time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC)
+	var claims []*Claim
+	claims = append(claims, statsClaims(12, "acme.com", "ba_priority_mail", inQ3)...)
+	claims = append(claims, statsClaims(3, "Small.example", "ba_priority_mail", inQ3)...)
+	claims = append(claims, statsClaims(2, "small.example", "ba_certified_mail", inQ3)...)
+	claims = append(claims, statsClaims(1, "", "ba_certified_mail", inQ3)...)
+	claims = append(claims, statsClaims(4, "acme.com", "ba_priority_mail", inQ3.AddDate(0, 3, 0))...)
+
+	stats, err := ComputeIssuerStats("ballista.jobs", "2026-Q3", claims, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Total != 18 {
+		t.Errorf("Total = %d, want 18", stats.Total)
+	}
+	if stats.ByMethod["ba_priority_mail"] != 15 || stats.ByMethod["ba_certified_mail"] != 3 {
+		t.Errorf("ByMethod = %v", stats.ByMethod)
+	}
+	want := map[string]int{"acme.com": 12, StatsOtherBucket: 6}
+	if len(stats.ByRecipientDomain) != len(want) {
+		t.Errorf("ByRecipientDomain = %v, want %v", stats.ByRecipientDomain, want)
+	}
+	for domain, n := range want {
+		if stats.ByRecipientDomain[domain] != n {
+			t.Errorf("ByRecipientDomain[%s] = %d, want %d", domain, stats.ByRecipientDomain[domain], n)
+		}
+	}
+}
+
+// newStatsVA serves a well-known document with wellKnownKeys and a stats
+// endpoint signing with priv/kid
+func newStatsVA(t *testing.T, priv ed25519.PrivateKey, kid string, wellKnownKeys []JWK, claims []*Claim) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/.well-known/hap.json", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(WellKnown{Issuer: strings.TrimPrefix(srv.URL, "https://"), Keys: wellKnownKeys})
+	})
+	mux.HandleFunc("/api/v1/stats", func(w http.ResponseWriter, r *http.Request) {
+		lister := ClaimListerFunc(func(ctx context.Context, start, end time.Time) ([]*Claim, error) {
+			return claims, nil
+		})
+		NewStatsHandler(lister, StatsHandlerOptions{
+			Issuer:        strings.TrimPrefix(srv.URL, "https://"),
+			Key:           priv,
+			Kid:           kid,
+			MinBucketSize: 5,
+		}).ServeHTTP(w, r)
+	})
+	srv = httptest.NewTLSServer(mux)
+	t.Cleanup(func() {
+		srv.Close()
+		FlushKeyCache(strings.TrimPrefix(srv.URL, "https://"))
+	})
+	return srv
+}
+
+func TestFetchIssuerStats(t *testing.T) {
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statsKey := ExportPublicKeyJWKWithUse(pub, "stats_001", KeyUseStats)
+	claimsKey := ExportPublicKeyJWKWithUse(pub, "stats_001", KeyUseClaims)
+	claims := statsClaims(7, "acme.com", "ba_priority_mail", time.Date(2026, 8, 1, 0, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+
+	t.Run("verified", func(t *testing.T) {
+		srv := newStatsVA(t, priv, "stats_001", []JWK{statsKey}, claims)
+		opts := DefaultVerifyOptions()
+		opts.HTTPClient = srv.Client()
+
+		stats, err := FetchIssuerStats(ctx, strings.TrimPrefix(srv.URL, "https://"), "2026-Q3", opts)
+		if err != nil {
+			t.Fatalf("FetchIssuerStats() error = %v", err)
+		}
+		if stats.Total != 7 || stats.ByRecipientDomain["acme.com"] != 7 || stats.Period != "2026-Q3" {
+			t.Errorf("FetchIssuerStats() = %+v", stats)
+		}
+	})
+
+	t.Run("key not published", func(t *testing.T) {
+		_, otherPub, _ := GenerateKeyPair()
+		srv := newStatsVA(t, priv, "stats_001", []JWK{ExportPublicKeyJWKWithUse(otherPub, "stats_001", KeyUseStats)}, claims)
+		opts := DefaultVerifyOptions()
+		opts.HTTPClient = srv.Client()
+
+		if _, err := FetchIssuerStats(ctx, strings.TrimPrefix(srv.URL, "https://"), "2026-Q3", opts); err == nil {
+			t.Error("FetchIssuerStats() accepted stats signed by an unpublished key")
+		}
+	})
+
+	t.Run("claims-only key", func(t *testing.T) {
+		srv := newStatsVA(t, priv, "stats_001", []JWK{claimsKey}, claims)
+		opts := DefaultVerifyOptions()
+		opts.HTTPClient = srv.Client()
+
+		if _, err := FetchIssuerStats(ctx, strings.TrimPrefix(srv.URL, "https://"), "2026-Q3", opts); !errors.Is(err, ErrWrongKeyUse) {
+			t.Errorf("FetchIssuerStats() error = %v, want ErrWrongKeyUse", err)
+		}
+	})
+
+	t.Run("invalid period", func(t *testing.T) {
+		if _, err := FetchIssuerStats(ctx, "ballista.jobs", "Q3", DefaultVerifyOptions()); !errors.Is(err, ErrInvalidStatsPeriod) {
+			t.Errorf("FetchIssuerStats() error = %v, want ErrInvalidStatsPeriod", err)
+		}
+	})
+}
+
+func TestVerifyStatsJWSRejectsClaims(t *testing.T) {
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := SignClaim(testClaim(t), priv, "key_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An unrestricted key may sign both, so the typ header keeps them apart
+	keys := []JWK{ExportPublicKeyJWK(pub, "key_001")}
+	if _, err := verifyStatsJWS(jws, keys); err == nil {
+		t.Error("verifyStatsJWS() accepted a claim JWS")
+	}
+}
@@ -0,0 +1,80 @@
+package humanattestation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// WSConn is the subset of a WebSocket connection needed by VerifyWSStream.
+// It matches the ReadMessage method of common WebSocket libraries, so their
+// connections can be passed directly or with a thin adapter.
+type WSConn interface {
+	ReadMessage() (messageType int, p []byte, err error)
+}
+
+// VerifyWSStream reads messages from conn until it closes or ctx is done,
+// verifying each one against keys and passing the result to fn. Messages may
+// be compact strings or JWS; JWS issuers are not checked, since keys already
+// identify the issuer.
+//
+// It returns nil when the connection closes cleanly (io.EOF or
+// net.ErrClosed), ctx.Err() on cancellation, and the read error otherwise.
+// On cancellation conn is closed if it implements io.Closer, so the pending
+// read is released.
+func VerifyWSStream(ctx context.Context, conn WSConn, keys []JWK, fn func(*CompactVerificationResult)) error {
+	type message struct {
+		data []byte
+		err  error
+	}
+
+	messages := make(chan message)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage()
+			select {
+			case messages <- message{data: data, err: err}:
+			case <-done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if closer, ok := conn.(io.Closer); ok {
+				closer.Close()
+			}
+			return ctx.Err()
+		case msg := <-messages:
+			if msg.err != nil {
+				if errors.Is(msg.err, io.EOF) || errors.Is(msg.err, net.ErrClosed) {
+					return nil
+				}
+				return fmt.Errorf("failed to read message: %w", msg.err)
+			}
+			fn(verifyStreamMessage(string(msg.data), keys))
+		}
+	}
+}
+
+// verifyStreamMessage verifies a single compact or JWS message
+func verifyStreamMessage(msg string, keys []JWK) *CompactVerificationResult {
+	msg = strings.TrimSpace(msg)
+	if strings.HasPrefix(msg, "HAP") {
+		return verifyCompact(msg, keys, nil)
+	}
+
+	result := verifyJWSSignature(msg, keys)
+	return &CompactVerificationResult{Valid: result.Valid, Claim: result.Claim, Error: result.Error, Err: result.Err}
+}
@@ -0,0 +1,116 @@
+package humanattestation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+// fakeWSConn feeds queued messages and then blocks or returns a final error
+type fakeWSConn struct {
+	messages chan []byte
+	err      error
+	closed   chan struct{}
+}
+
+func newFakeWSConn(err error, messages ...string) *fakeWSConn {
+	c := &fakeWSConn{messages: make(chan []byte, len(messages)), err: err, closed: make(chan struct{})}
+	for _, m := range messages {
+		c.messages <- []byte(m)
+	}
+	return c
+}
+
+func (c *fakeWSConn) ReadMessage() (int, []byte, error) {
+	select {
+	case m := <-c.messages:
+		return 1, m, nil
+	default:
+	}
+	if c.err != nil {
+		return 0, nil, c.err
+	}
+	<-c.closed
+	return 0, nil, errors.New("use of closed connection")
+}
+
+func (c *fakeWSConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestVerifyWSStream(t *testing.T) {
+	claim := testClaim(t)
+	priv, jwk := testKeys(t, "test_001")
+
+	compact, err := SignCompact(claim, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jws, err := SignClaim(claim, priv, "test_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn := newFakeWSConn(io.EOF, compact, jws+"\n", "garbage")
+
+	var results []*CompactVerificationResult
+	err = VerifyWSStream(context.Background(), conn, []JWK{jwk}, func(r *CompactVerificationResult) {
+		results = append(results, r)
+	})
+	if err != nil {
+		t.Fatalf("VerifyWSStream() error = %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for i, r := range results[:2] {
+		if !r.Valid || r.Claim.ID != claim.ID {
+			t.Errorf("result %d = %+v, want valid claim %s", i, r, claim.ID)
+		}
+	}
+	if results[2].Valid {
+		t.Error("garbage message verified")
+	}
+}
+
+func TestVerifyWSStreamReadError(t *testing.T) {
+	readErr := errors.New("connection reset")
+	conn := newFakeWSConn(readErr)
+
+	err := VerifyWSStream(context.Background(), conn, nil, func(*CompactVerificationResult) {
+		t.Error("unexpected callback")
+	})
+	if !errors.Is(err, readErr) {
+		t.Errorf("VerifyWSStream() error = %v, want %v", err, readErr)
+	}
+}
+
+func TestVerifyWSStreamCancel(t *testing.T) {
+	conn := newFakeWSConn(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- VerifyWSStream(ctx, conn, nil, func(*CompactVerificationResult) {})
+	}()
+	cancel()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("VerifyWSStream() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("VerifyWSStream did not return after cancellation")
+	}
+
+	select {
+	case <-conn.closed:
+	default:
+		t.Error("conn was not closed on cancellation")
+	}
+}
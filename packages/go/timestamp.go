@@ -0,0 +1,119 @@
+package humanattestation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrMalformedTimestamp is returned for a claim whose at timestamp, or exp
+// timestamp when present, does not parse
+var ErrMalformedTimestamp = errors.New("malformed timestamp")
+
+// ErrInvalidTimeRange is returned when a compact claim expires at or before
+// the time it was issued
+var ErrInvalidTimeRange = errors.New("claim expires before it was issued")
+
+// timestampLayouts are the layouts ParseTimestamp accepts, in order. The
+// layouts after RFC 3339 cover timestamps emitted by early VAs.
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05Z0700",  // offset without colon
+	"2006-01-02 15:04:05Z07:00", // space separator
+	"2006-01-02 15:04:05Z0700",
+	"2006-01-02T15:04:05", // no timezone, taken as UTC
+	"2006-01-02 15:04:05",
+}
+
+// ParseTimestamp parses a claim timestamp. It accepts RFC 3339 as well as the
+// legacy forms some early VAs emitted: a space instead of "T", an offset
+// without a colon, or no timezone at all, which is taken as UTC.
+func ParseTimestamp(s string) (time.Time, error) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp: %q", s)
+}
+
+// Timestamp is a point in time that unmarshals from an RFC 3339 string (with
+// or without fractional seconds, or any form ParseTimestamp accepts) or from
+// integer epoch seconds, and always marshals as RFC 3339 in UTC, with as
+// many fractional digits as it needs to round-trip exactly. A zero
+// Timestamp marshals as null.
+type Timestamp struct {
+	time.Time
+}
+
+// MarshalJSON implements json.Marshaler
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.UTC().Format(time.RFC3339Nano))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		t.Time = time.Time{}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		parsed, err := ParseTimestamp(s)
+		if err != nil {
+			return err
+		}
+		t.Time = parsed
+		return nil
+	}
+
+	var epoch int64
+	if err := json.Unmarshal(data, &epoch); err != nil {
+		return fmt.Errorf("invalid timestamp: %s", data)
+	}
+	t.Time = time.Unix(epoch, 0).UTC()
+	return nil
+}
+
+// IssuedAt parses the claim's issue time
+func (c *Claim) IssuedAt() (time.Time, error) {
+	return ParseTimestamp(c.At)
+}
+
+// ExpiresAt parses the claim's expiry time. It returns the zero time and no
+// error when the claim does not expire.
+func (c *Claim) ExpiresAt() (time.Time, error) {
+	if c.Exp == "" {
+		return time.Time{}, nil
+	}
+	return ParseTimestamp(c.Exp)
+}
+
+// ValidateClaim checks the invariants a claim must satisfy before it can be
+// treated as verified: its at timestamp parses, and so does its exp timestamp
+// if present. Errors wrap ErrMalformedTimestamp and name the field. Every
+// verification path calls it, so a claim with an unreadable timestamp is
+// never reported valid.
+func ValidateClaim(claim *Claim) error {
+	if _, err := claim.IssuedAt(); err != nil {
+		return fmt.Errorf("%w: 'at' %q", ErrMalformedTimestamp, claim.At)
+	}
+	if _, err := claim.ExpiresAt(); err != nil {
+		return fmt.Errorf("%w: 'exp' %q", ErrMalformedTimestamp, claim.Exp)
+	}
+	return nil
+}
+
+// checkCompactTimeRange checks that a compact claim's exp, unless zero for
+// a claim that does not expire, is after its at
+func checkCompactTimeRange(atUnix, expUnix int64) error {
+	if expUnix != 0 && expUnix <= atUnix {
+		return fmt.Errorf("%w: exp %d is not after at %d", ErrInvalidTimeRange, expUnix, atUnix)
+	}
+	return nil
+}
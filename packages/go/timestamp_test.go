@@ -0,0 +1,262 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2026, 1, 15, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"rfc3339", "2026-01-15T10:30:00Z"},
+		{"rfc3339 offset", "2026-01-15T12:30:00+02:00"},
+		{"rfc3339 fractional", "2026-01-15T10:30:00.000Z"},
+		{"offset without colon", "2026-01-15T12:30:00+0200"},
+		{"space separator", "2026-01-15 10:30:00Z"},
+		{"space separator with offset", "2026-01-15 05:30:00-05:00"},
+		{"space separator offset without colon", "2026-01-15 05:30:00-0500"},
+		{"no timezone", "2026-01-15T10:30:00"},
+		{"space separator no timezone", "2026-01-15 10:30:00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTimestamp(tt.input)
+			if err != nil {
+				t.Fatalf("ParseTimestamp(%q) error = %v", tt.input, err)
+			}
+			if !got.Equal(want) {
+				t.Errorf("ParseTimestamp(%q) = %v, want %v", tt.input, got, want)
+			}
+		})
+	}
+
+	for _, input := range []string{"", "2026-01-15", "15/01/2026 10:30", "yesterday"} {
+		if _, err := ParseTimestamp(input); err == nil {
+			t.Errorf("ParseTimestamp(%q) expected error", input)
+		}
+	}
+}
+
+func TestLegacyTimestampsInClaims(t *testing.T) {
+	claim := testClaim(t)
+	claim.At = "2019-01-15 10:30:00"
+	claim.Exp = "2020-01-15 10:30:00+0000"
+
+	if !IsClaimExpired(claim) {
+		t.Error("IsClaimExpired() = false for legacy timestamp in the past")
+	}
+
+	priv, jwk := testKeys(t, "key_001")
+	compact, err := SignCompact(claim, priv, CompactOptions{Version: CompactVersionV2})
+	if err != nil {
+		t.Fatalf("signing claim with legacy timestamps: %v", err)
+	}
+	result := VerifyCompact(compact, []JWK{jwk})
+	if !result.Valid {
+		t.Fatalf("VerifyCompact() error = %s", result.Error)
+	}
+	if result.Claim.At != "2019-01-15T10:30:00Z" {
+		t.Errorf("At = %q, want normalized RFC 3339", result.Claim.At)
+	}
+}
+
+func TestTimestampUnmarshalJSON(t *testing.T) {
+	want := time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		input string
+		want  time.Time
+	}{
+		{"RFC 3339", `"2026-02-01T12:00:00Z"`, want},
+		{"RFC 3339 with offset", `"2026-02-01T13:00:00+01:00"`, want},
+		{"fractional seconds", `"2026-02-01T12:00:00.250Z"`, want.Add(250 * time.Millisecond)},
+		{"epoch seconds", `1769947200`, want},
+		{"null", `null`, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ts Timestamp
+			if err := json.Unmarshal([]byte(tt.input), &ts); err != nil {
+				t.Fatal(err)
+			}
+			if !ts.Equal(tt.want) {
+				t.Errorf("got %v, want %v", ts.Time, tt.want)
+			}
+		})
+	}
+
+	for _, garbage := range []string{`"yesterday"`, `""`, `1.5`, `true`, `{}`} {
+		var ts Timestamp
+		if err := json.Unmarshal([]byte(garbage), &ts); err == nil {
+			t.Errorf("Unmarshal(%s) = %v, want error", garbage, ts.Time)
+		}
+	}
+}
+
+func TestTimestampMarshalJSON(t *testing.T) {
+	ts := Timestamp{time.Date(2026, 2, 1, 13, 0, 0, 500, time.FixedZone("CET", 3600))}
+	data, err := json.Marshal(ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"2026-02-01T12:00:00.0000005Z"` {
+		t.Errorf("Marshal = %s, want canonical UTC", data)
+	}
+	// Whole seconds carry no fraction
+	if data, _ := json.Marshal(Timestamp{ts.Truncate(time.Second)}); string(data) != `"2026-02-01T12:00:00Z"` {
+		t.Errorf("Marshal = %s, want no fractional seconds", data)
+	}
+
+	var resp VerificationResponse
+	if err := json.Unmarshal([]byte(`{"valid":false,"revoked":true,"revokedAt":1769947200}`), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.RevokedAt == nil || !resp.RevokedAt.Equal(time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("RevokedAt = %v", resp.RevokedAt)
+	}
+	data, _ = json.Marshal(VerificationResponse{Valid: true})
+	if strings.Contains(string(data), "revokedAt") {
+		t.Errorf("unrevoked response marshals revokedAt: %s", data)
+	}
+}
+
+func TestTimestampRoundTrip(t *testing.T) {
+	for _, want := range []time.Time{
+		time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC),
+		time.Date(2026, 2, 1, 12, 0, 0, 123000000, time.UTC),
+		time.Date(2026, 2, 1, 13, 0, 0, 123456789, time.FixedZone("CET", 3600)),
+	} {
+		data, err := json.Marshal(Timestamp{want})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Timestamp
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(want) {
+			t.Errorf("round trip of %v through %s = %v", want, data, got.Time)
+		}
+	}
+}
+
+func TestClaimTimeAccessors(t *testing.T) {
+	claim := &Claim{At: "2026-02-01 12:00:00", Exp: "2027-02-01T12:00:00Z"}
+	if at, err := claim.IssuedAt(); err != nil || !at.Equal(time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("IssuedAt() = %v, %v", at, err)
+	}
+	if exp, err := claim.ExpiresAt(); err != nil || exp.Year() != 2027 {
+		t.Errorf("ExpiresAt() = %v, %v", exp, err)
+	}
+
+	claim.Exp = ""
+	if exp, err := claim.ExpiresAt(); err != nil || !exp.IsZero() {
+		t.Errorf("ExpiresAt() without expiry = %v, %v", exp, err)
+	}
+}
+
+func TestValidateClaim(t *testing.T) {
+	tests := []struct {
+		name    string
+		at, exp string
+		wantErr string
+	}{
+		{"valid", "2026-01-01T00:00:00Z", "2026-02-01T00:00:00Z", ""},
+		{"no expiry", "2026-01-01T00:00:00Z", "", ""},
+		{"non-UTC offsets", "2026-01-01T05:00:00+05:00", "2026-02-01T00:00:00-08:00", ""},
+		{"garbage at", "garbage", "2026-02-01T00:00:00Z", "'at'"},
+		{"empty at", "", "2026-02-01T00:00:00Z", "'at'"},
+		{"garbage exp", "2026-01-01T00:00:00Z", "garbage", "'exp'"},
+		{"whitespace exp", "2026-01-01T00:00:00Z", " ", "'exp'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateClaim(&Claim{At: tt.at, Exp: tt.exp})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateClaim() = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrMalformedTimestamp) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateClaim() = %v, want ErrMalformedTimestamp naming %s", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMalformedTimestampsFailClosed(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+
+	for _, field := range []string{"at", "exp"} {
+		claim := testClaim(t)
+		if field == "at" {
+			claim.At = "garbage"
+		} else {
+			claim.Exp = "garbage"
+		}
+
+		jws, err := SignClaim(claim, priv, "key_001")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := verifyJWSSignature(jws, []JWK{jwk}); r.Valid || !strings.Contains(r.Error, "malformed timestamp") {
+			t.Errorf("%s: verifyJWSSignature() = %+v, want malformed timestamp", field, r)
+		}
+		if ok, _ := (RequirementsProfile{}).Meets(claim); ok {
+			t.Errorf("%s: Meets() accepted malformed claim", field)
+		}
+		if status, ok := claimStatus(claim, time.Now()); ok {
+			t.Errorf("%s: claimStatus() = %q, valid", field, status)
+		}
+	}
+
+	if !IsClaimExpiredAt(&Claim{Exp: "garbage"}, time.Now()) {
+		t.Error("IsClaimExpiredAt() treated garbage exp as unexpired")
+	}
+	if !(&MinimalClaim{Exp: "garbage"}).IsExpired(time.Now(), time.Hour) {
+		t.Error("MinimalClaim.IsExpired() treated garbage exp as unexpired")
+	}
+
+	// Offsets are compared as instants
+	offset := &Claim{At: "2026-01-01T00:00:00+05:00", Exp: "2026-01-02T00:00:00-08:00"}
+	if IsClaimExpiredAt(offset, time.Date(2026, 1, 2, 7, 59, 59, 0, time.UTC)) || !IsClaimExpiredAt(offset, time.Date(2026, 1, 2, 8, 0, 1, 0, time.UTC)) {
+		t.Error("IsClaimExpiredAt() mishandled a non-UTC exp")
+	}
+
+	// Compact timestamps too large to represent do not decode
+	compact, err := SignCompact(testClaim(t), priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(compact, ".")
+	parts[6] = "999999999999999"
+	if _, err := DecodeCompact(strings.Join(parts, ".")); !errors.Is(err, ErrMalformedTimestamp) {
+		t.Errorf("DecodeCompact() with out-of-range at = %v, want ErrMalformedTimestamp", err)
+	}
+}
+
+func TestVerifyClaimMalformedTimestamp(t *testing.T) {
+	va := newTestVA(t)
+	claim := testClaim(t)
+	claim.Iss = va.Issuer()
+	claim.Exp = "garbage"
+	va.SetResponse(VerificationResponse{Valid: true, ID: claim.ID, Claim: claim, Issuer: va.Issuer()})
+
+	opts := va.Options()
+	opts.SkipSignatureVerification = true
+	if got, err := VerifyClaim(context.Background(), claim.ID, va.Issuer(), opts); got != nil || !errors.Is(err, ErrMalformedTimestamp) {
+		t.Errorf("VerifyClaim() = %v, %v, want ErrMalformedTimestamp", got, err)
+	}
+}
@@ -0,0 +1,120 @@
+package humanattestation
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// verificationTokenType is the JWS typ header of verification tokens
+const verificationTokenType = "hap-verified+jws"
+
+// ErrTokenExpired is returned by ValidateVerificationToken for expired tokens
+var ErrTokenExpired = errors.New("verification token expired")
+
+// VerificationToken asserts that a gateway verified the claim with the given
+// ID. It carries no claim content: clients that need it must fetch the claim.
+type VerificationToken struct {
+	ID         string    `json:"id"`
+	Issuer     string    `json:"iss"`
+	VerifiedAt Timestamp `json:"verifiedAt"`
+	Exp        Timestamp `json:"exp"`
+}
+
+// MintVerificationToken signs a short-lived token asserting that claim was
+// verified, so thin clients can skip re-verifying it. The caller must have
+// verified the claim first. The token expires after ttl, or when the claim
+// does, whichever is sooner. The key should be published with KeyUseTokens.
+func MintVerificationToken(claim *Claim, ttl time.Duration, privateKey ed25519.PrivateKey, kid string) (string, error) {
+	return mintVerificationToken(claim, time.Now(), ttl, privateKey, kid)
+}
+
+func mintVerificationToken(claim *Claim, now time.Time, ttl time.Duration, privateKey ed25519.PrivateKey, kid string) (string, error) {
+	if ttl <= 0 {
+		return "", fmt.Errorf("ttl must be positive: %s", ttl)
+	}
+	if ValidateID(claim.ID, IDOptions{AllowChecked: true}) != nil {
+		return "", fmt.Errorf("invalid claim ID: %q", claim.ID)
+	}
+
+	now = now.UTC().Truncate(time.Second)
+	exp := now.Add(ttl)
+	claimExp, err := claim.ExpiresAt()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse claim expiry: %w", err)
+	}
+	if !claimExp.IsZero() {
+		if !claimExp.After(now) {
+			return "", errors.New("claim has expired")
+		}
+		if claimExp.Before(exp) {
+			exp = claimExp
+		}
+	}
+
+	payload, err := json.Marshal(VerificationToken{
+		ID:         claim.ID,
+		Issuer:     claim.Iss,
+		VerifiedAt: Timestamp{now},
+		Exp:        Timestamp{exp},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize token: %w", err)
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.EdDSA, Key: privateKey},
+		(&jose.SignerOptions{}).WithHeader("kid", kid).WithType(verificationTokenType),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create signer: %w", err)
+	}
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return jws.CompactSerialize()
+}
+
+// ValidateVerificationToken checks a token's signature against keys allowed
+// for KeyUseTokens and returns its contents. Expired tokens return
+// ErrTokenExpired.
+func ValidateVerificationToken(token string, keys []JWK) (*VerificationToken, error) {
+	return validateVerificationToken(token, keys, time.Now())
+}
+
+func validateVerificationToken(token string, keys []JWK, now time.Time) (*VerificationToken, error) {
+	jws, err := jose.ParseSigned(token, []jose.SignatureAlgorithm{jose.EdDSA})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JWS: %w", err)
+	}
+	header := jws.Signatures[0].Header
+	if typ, _ := header.ExtraHeaders[jose.HeaderType].(string); typ != verificationTokenType {
+		return nil, fmt.Errorf("JWS is not a verification token (typ %q)", typ)
+	}
+
+	publicKey, err := lookupKey(keys, header.KeyID, KeyUseTokens)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := jws.Verify(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	var vt VerificationToken
+	if err := json.Unmarshal(payload, &vt); err != nil {
+		return nil, fmt.Errorf("failed to parse token: %w", err)
+	}
+	if ValidateID(vt.ID, IDOptions{AllowChecked: true}) != nil {
+		return nil, fmt.Errorf("invalid claim ID in token: %q", vt.ID)
+	}
+	if vt.Exp.IsZero() || !vt.Exp.After(now) {
+		return nil, ErrTokenExpired
+	}
+	return &vt, nil
+}
@@ -0,0 +1,88 @@
+package humanattestation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerificationTokenRoundTrip(t *testing.T) {
+	priv, pub, _ := GenerateKeyPair()
+	keys := []JWK{ExportPublicKeyJWKWithUse(pub, "tokens_001", KeyUseTokens)}
+	claim := testClaim(t)
+
+	token, err := MintVerificationToken(claim, 5*time.Minute, priv, "tokens_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vt, err := ValidateVerificationToken(token, keys)
+	if err != nil {
+		t.Fatalf("ValidateVerificationToken() error = %v", err)
+	}
+	if vt.ID != claim.ID || vt.Issuer != claim.Iss {
+		t.Errorf("token = %+v, want claim %s from %s", vt, claim.ID, claim.Iss)
+	}
+	if d := vt.Exp.Sub(vt.VerifiedAt.Time); d != 5*time.Minute {
+		t.Errorf("token lifetime = %s, want 5m", d)
+	}
+
+	// Tampering with the payload breaks the signature
+	parts := strings.Split(token, ".")
+	other, _ := MintVerificationToken(&Claim{ID: "hap_zzzzzzzzzzzz", Iss: claim.Iss}, time.Minute, priv, "tokens_001")
+	forged := parts[0] + "." + strings.Split(other, ".")[1] + "." + parts[2]
+	if _, err := ValidateVerificationToken(forged, keys); err == nil {
+		t.Error("expected error for forged token")
+	}
+
+	// Keys published for another use are refused
+	claimsKeys := []JWK{ExportPublicKeyJWKWithUse(pub, "tokens_001", KeyUseClaims)}
+	if _, err := ValidateVerificationToken(token, claimsKeys); !errors.Is(err, ErrWrongKeyUse) {
+		t.Errorf("error = %v, want ErrWrongKeyUse", err)
+	}
+
+	// A signed claim is not a token
+	jws, _ := SignClaim(claim, priv, "tokens_001")
+	if _, err := ValidateVerificationToken(jws, keys); err == nil {
+		t.Error("expected error for claim JWS")
+	}
+}
+
+func TestVerificationTokenExpiry(t *testing.T) {
+	priv, pub, _ := GenerateKeyPair()
+	keys := []JWK{ExportPublicKeyJWKWithUse(pub, "tokens_001", KeyUseTokens)}
+	claim := testClaim(t)
+
+	minted := time.Now().Add(-10 * time.Minute)
+	token, err := mintVerificationToken(claim, minted, 5*time.Minute, priv, "tokens_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ValidateVerificationToken(token, keys); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("error = %v, want ErrTokenExpired", err)
+	}
+	if _, err := validateVerificationToken(token, keys, minted.Add(time.Minute)); err != nil {
+		t.Errorf("token rejected within its lifetime: %v", err)
+	}
+
+	// Tokens never outlive the claim
+	exp, _ := claim.ExpiresAt()
+	token, err = mintVerificationToken(claim, exp.Add(-time.Minute), time.Hour, priv, "tokens_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vt, err := validateVerificationToken(token, keys, exp.Add(-time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !vt.Exp.Equal(exp) {
+		t.Errorf("token exp = %s, want claim exp %s", vt.Exp, exp)
+	}
+
+	if _, err := mintVerificationToken(claim, exp.Add(time.Minute), time.Hour, priv, "tokens_001"); err == nil {
+		t.Error("expected error minting a token for an expired claim")
+	}
+	if _, err := MintVerificationToken(claim, 0, priv, "tokens_001"); err == nil {
+		t.Error("expected error for zero ttl")
+	}
+}
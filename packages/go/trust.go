@@ -0,0 +1,70 @@
+package humanattestation
+
+// TrustLevel is a single trust grade derived from several verification
+// signals, for UIs that show one badge. Higher values are more trusted.
+type TrustLevel int
+
+const (
+	TrustLevelUntrusted TrustLevel = iota // Forged, revoked or meant for someone else
+	TrustLevelLow                         // Genuine, but from an issuer the recipient does not trust
+	TrustLevelMedium                      // Genuine and from a trusted issuer
+	TrustLevelHigh                        // Also addressed closely to the recipient and backed by real effort
+)
+
+func (l TrustLevel) String() string {
+	switch l {
+	case TrustLevelLow:
+		return "low"
+	case TrustLevelMedium:
+		return "medium"
+	case TrustLevelHigh:
+		return "high"
+	}
+	return "untrusted"
+}
+
+// HighEffortScore is the effort score ComputeTrustLevel requires for
+// TrustLevelHigh
+const HighEffortScore = 2
+
+// TrustInputs are the signals ComputeTrustLevel combines
+type TrustInputs struct {
+	SignatureValid bool            // The claim's signature verified
+	IssuerTrusted  bool            // The issuer is one the recipient trusts
+	Revoked        bool            // The issuer reports the claim revoked
+	RecipientMatch MatchConfidence // From MatchRecipientWithConfidence
+	EffortScore    int             // From ClaimEffortScore
+}
+
+// ComputeTrustLevel grades a claim from inputs, applying these rules in
+// order:
+//
+//   - an invalid signature, a revoked claim or a recipient that does not
+//     match at all is TrustLevelUntrusted
+//   - an issuer the recipient does not trust caps the claim at TrustLevelLow
+//   - a recipient match of at least MatchConfidenceMedium and an effort
+//     score of at least HighEffortScore is TrustLevelHigh
+//   - anything else is TrustLevelMedium
+func ComputeTrustLevel(inputs TrustInputs) TrustLevel {
+	switch {
+	case !inputs.SignatureValid, inputs.Revoked, inputs.RecipientMatch == MatchConfidenceNone:
+		return TrustLevelUntrusted
+	case !inputs.IssuerTrusted:
+		return TrustLevelLow
+	case inputs.RecipientMatch >= MatchConfidenceMedium && inputs.EffortScore >= HighEffortScore:
+		return TrustLevelHigh
+	}
+	return TrustLevelMedium
+}
+
+// ClaimEffortScore counts the effort dimensions a claim carries with a
+// positive value: cost, time, physical and energy, so 0 to 4
+func ClaimEffortScore(claim *Claim) int {
+	score := 0
+	for _, dim := range []EffortDimension{DimensionCost, DimensionTime, DimensionPhysical, DimensionEnergy} {
+		if hasDimension(claim, dim) {
+			score++
+		}
+	}
+	return score
+}
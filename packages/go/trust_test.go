@@ -0,0 +1,51 @@
+package humanattestation
+
+import "testing"
+
+func TestComputeTrustLevel(t *testing.T) {
+	good := TrustInputs{SignatureValid: true, IssuerTrusted: true, RecipientMatch: MatchConfidenceHigh, EffortScore: 3}
+	tests := []struct {
+		name   string
+		modify func(*TrustInputs)
+		want   TrustLevel
+	}{
+		{"valid, trusted, high effort", func(*TrustInputs) {}, TrustLevelHigh},
+		{"subdomain recipient", func(in *TrustInputs) { in.RecipientMatch = MatchConfidenceMedium }, TrustLevelHigh},
+		{"minimum high effort", func(in *TrustInputs) { in.EffortScore = HighEffortScore }, TrustLevelHigh},
+		{"low effort", func(in *TrustInputs) { in.EffortScore = 1 }, TrustLevelMedium},
+		{"organization match only", func(in *TrustInputs) { in.RecipientMatch = MatchConfidenceLow }, TrustLevelMedium},
+		{"untrusted issuer", func(in *TrustInputs) { in.IssuerTrusted = false }, TrustLevelLow},
+		{"untrusted issuer, low effort", func(in *TrustInputs) { in.IssuerTrusted, in.EffortScore = false, 0 }, TrustLevelLow},
+		{"invalid signature", func(in *TrustInputs) { in.SignatureValid = false }, TrustLevelUntrusted},
+		{"revoked", func(in *TrustInputs) { in.Revoked = true }, TrustLevelUntrusted},
+		{"other recipient", func(in *TrustInputs) { in.RecipientMatch = MatchConfidenceNone }, TrustLevelUntrusted},
+		{"revoked, untrusted issuer", func(in *TrustInputs) { in.Revoked, in.IssuerTrusted = true, false }, TrustLevelUntrusted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := good
+			tt.modify(&in)
+			if got := ComputeTrustLevel(in); got != tt.want {
+				t.Errorf("ComputeTrustLevel(%+v) = %s, want %s", in, got, tt.want)
+			}
+		})
+	}
+
+	if TrustLevelHigh <= TrustLevelMedium || TrustLevelMedium <= TrustLevelLow || TrustLevelLow <= TrustLevelUntrusted {
+		t.Error("trust levels are not ordered")
+	}
+}
+
+func TestClaimEffortScore(t *testing.T) {
+	claim := testClaim(t)
+	minutes, physical, zero := 90, true, 0
+	claim.Cost, claim.Time, claim.Physical, claim.Energy = nil, nil, nil, nil
+	if got := ClaimEffortScore(claim); got != 0 {
+		t.Errorf("no dimensions = %d", got)
+	}
+	claim.Cost = &ClaimCost{Amount: 1500, Currency: "USD"}
+	claim.Time, claim.Physical, claim.Energy = &minutes, &physical, &zero
+	if got := ClaimEffortScore(claim); got != 3 {
+		t.Errorf("cost, time and physical = %d, want 3", got)
+	}
+}
@@ -5,8 +5,8 @@ import (
 	"crypto/ed25519"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
@@ -18,22 +18,118 @@ import (
 // DefaultTimeout is the default HTTP request timeout
 const DefaultTimeout = 10 * time.Second
 
-// VerifyOptions configures verification behavior
+// ErrInconsistentResponse is returned by FetchClaim under StrictConsistency
+// when a VA response's advisory fields disagree with the request
+var ErrInconsistentResponse = errors.New("inconsistent verification response")
+
+// ErrWrongKeyUse is reported when a signature verifies under a key published for a different use
+var ErrWrongKeyUse = errors.New("key is not valid for this use")
+
+// VerifyOptions configures verification behavior. One VerifyOptions may be
+// shared by concurrent calls as long as none of them modifies it.
 type VerifyOptions struct {
 	// HTTPClient allows using a custom HTTP client
 	HTTPClient *http.Client
 	// Timeout for HTTP requests (default: 10s)
 	Timeout time.Duration
-	// VerifySignature controls whether to verify the cryptographic signature
+	// VerifySignature is ignored. Signatures are verified unless
+	// SkipSignatureVerification is set, so that options structs filled in
+	// field by field keep signature checking on. VerifyClaim reports setting
+	// it to the handler installed with SetDeprecationHandler.
+	//
+	// Deprecated: Set SkipSignatureVerification to opt out of signature checks.
 	VerifySignature bool
+	// SkipSignatureVerification disables verifying the cryptographic signature
+	// of claims returned by the VA. Only set this if the VA is otherwise trusted.
+	SkipSignatureVerification bool
+	// Resolver for DNS lookups (default: net.DefaultResolver)
+	Resolver TXTResolver
+	// Budget caps the total work of one verification call (default: unlimited)
+	Budget *WorkBudget
+	// AllowCheckedIDs accepts IDs carrying a check character (see GenerateIDChecked)
+	AllowCheckedIDs bool
+	// StrictConsistency makes FetchClaim fail when the VA's response names a
+	// different issuer or a verify URL off the issuer's domain, instead of
+	// correcting the fields and recording a warning
+	StrictConsistency bool
+	// Clock returns the current time for expiry checks and key ages
+	// (default: time.Now)
+	Clock func() time.Time
+	// Leeway tolerates clock skew between the verifier and the issuer when
+	// checking claim times: a claim stays unexpired until Leeway past its
+	// exp, and counts as issued once now is within Leeway of its at
+	// (default: 0, no tolerance)
+	Leeway time.Duration
+	// KeyCacheTTL is how long keys fetched from an issuer are reused by
+	// later calls in this process when the issuer sends no Cache-Control
+	// max-age (default: the KeyCache's TTL, or DefaultKeyCacheTTL). A
+	// max-age is honored up to that TTL if one is set, or MaxKeyCacheTTL if
+	// not. Negative disables the cache, though concurrent calls still share
+	// a fetch in flight.
+	KeyCacheTTL time.Duration
+	// NegativeKeyCacheTTL, if positive, also caches failed key fetches for
+	// this long, so a dead issuer is probed once rather than once per claim
+	NegativeKeyCacheTTL time.Duration
+	// KeyCache, if set, caches keys instead of the process-wide cache
+	KeyCache *PublicKeyCache
+	// BypassKeyCache fetches keys from the issuer even if they are cached,
+	// without a conditional request. The fetched keys still replace the
+	// cached ones. A fetch already in flight is shared.
+	BypassKeyCache bool
+	// ClaimCache, if set, caches FetchClaim responses and revalidates them
+	// with conditional requests
+	ClaimCache *ClaimCache
+	// IssuerBaseURL, if set, maps an issuer domain to the base URL of its
+	// VA, such as "https://localhost:8443", for local development. Endpoint
+	// paths are appended to it. An empty result uses the default,
+	// https://{issuer domain}.
+	IssuerBaseURL func(issuerDomain string) string
+	// AllowInsecureHTTP permits http:// base URLs from IssuerBaseURL. Never
+	// set it in production: claims and keys fetched over plain HTTP can be
+	// forged by anyone on the path.
+	AllowInsecureHTTP bool
+	// EnableDNSDiscovery looks an issuer's keys up in a TXT record at
+	// _hap.<issuer> when its well-known document is not found (HTTP 404).
+	// Keys found this way have KeySourceDNSFallback provenance. DNS answers
+	// are not authenticated: there is no TLS and DNSSEC is not checked, so
+	// anyone who can spoof or poison the issuer's DNS can supply keys that
+	// verify forged claims. A record's keys-url is only followed over HTTPS
+	// to the issuer's host or a subdomain of it. Only enable this for
+	// issuers whose DNS you trust.
+	EnableDNSDiscovery bool
+	// KeyPins, if set, pins each issuer's keys on first use: VerifySignature
+	// records the thumbprints of the keys published when a claim first
+	// verifies, and after that fails with a *KeyPinError for a claim signed
+	// by any other key. Keys published alongside a pinned key that verifies
+	// a claim are added to the pins, so a rotation with an overlap period
+	// passes; pins are never removed.
+	KeyPins KeyPinStore
+	// OnKeyPinMismatch, if set, is called instead of failing when KeyPins
+	// detects a claim signed by an unpinned key. Verification then proceeds
+	// against all published keys, and no keys are pinned.
+	OnKeyPinMismatch func(err *KeyPinError)
+
+	meter *workMeter
+}
+
+// now returns the current time from the options' Clock
+func (o VerifyOptions) now() time.Time {
+	if o.Clock != nil {
+		return o.Clock()
+	}
+	return time.Now()
+}
+
+// validateID checks hapID against the ID formats the options accept
+func (o VerifyOptions) validateID(hapID string) error {
+	return ValidateID(hapID, IDOptions{AllowChecked: o.AllowCheckedIDs})
 }
 
 // DefaultVerifyOptions returns options with sensible defaults
 func DefaultVerifyOptions() VerifyOptions {
 	return VerifyOptions{
-		HTTPClient:      http.DefaultClient,
-		Timeout:         DefaultTimeout,
-		VerifySignature: true,
+		HTTPClient: http.DefaultClient,
+		Timeout:    DefaultTimeout,
 	}
 }
 
@@ -42,8 +138,45 @@ func IsValidID(id string) bool {
 	return IDRegex.MatchString(id)
 }
 
-// FetchPublicKeys fetches the public keys from a VA's well-known endpoint
+// FetchPublicKeys fetches the public keys from a VA's well-known endpoint.
+// Keys are served from an in-process cache while fresh (see
+// VerifyOptions.KeyCacheTTL), and stale keys are revalidated with
+// If-None-Match or If-Modified-Since so an unchanged document costs the VA
+// a 304 Not Modified.
 func FetchPublicKeys(ctx context.Context, issuerDomain string, opts VerifyOptions) (*WellKnown, error) {
+	wellKnown, _, err := fetchPublicKeys(ctx, issuerDomain, opts)
+	return wellKnown, err
+}
+
+// fetchPublicKeys fetches the well-known document, through the key cache
+// unless it is disabled, and records its provenance
+func fetchPublicKeys(ctx context.Context, issuerDomain string, opts VerifyOptions) (*WellKnown, *KeyProvenance, error) {
+	opts = opts.metered()
+	host, err := hostToASCII(issuerDomain)
+	if err != nil {
+		return nil, nil, err
+	}
+	url, err := opts.endpoint(issuerDomain, "/.well-known/hap.json")
+	if err != nil {
+		return nil, nil, err
+	}
+	cache := opts.KeyCache
+	if cache == nil {
+		cache = defaultKeyCache
+	}
+	return cache.get(ctx, keyCacheKey{host: host, url: url, dns: opts.EnableDNSDiscovery}, opts, func(ctx context.Context, validators cacheValidators) (*wellKnownFetch, error) {
+		return fetchWellKnown(ctx, issuerDomain, opts, validators)
+	})
+}
+
+// fetchWellKnown fetches an issuer's well-known document along with its
+// caching headers. With validators it makes a conditional request, and a
+// 304 Not Modified answer returns no document.
+func fetchWellKnown(ctx context.Context, issuerDomain string, opts VerifyOptions, validators cacheValidators) (*wellKnownFetch, error) {
+	if err := opts.meter.keyFetch(); err != nil {
+		return nil, err
+	}
+
 	if opts.HTTPClient == nil {
 		opts.HTTPClient = http.DefaultClient
 	}
@@ -54,12 +187,16 @@ func FetchPublicKeys(ctx context.Context, issuerDomain string, opts VerifyOption
 	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
 	defer cancel()
 
-	url := fmt.Sprintf("https://%s/.well-known/hap.json", issuerDomain)
+	url, err := opts.endpoint(issuerDomain, "/.well-known/hap.json")
+	if err != nil {
+		return nil, err
+	}
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Accept", "application/json")
+	validators.setConditional(req)
 
 	resp, err := opts.HTTPClient.Do(req)
 	if err != nil {
@@ -67,11 +204,34 @@ func FetchPublicKeys(ctx context.Context, issuerDomain string, opts VerifyOption
 	}
 	defer resp.Body.Close()
 
+	fetchedAt := opts.now()
+	fetched := &wellKnownFetch{
+		provenance: &KeyProvenance{
+			Source:    KeySourceNetwork,
+			URL:       url,
+			FetchedAt: &fetchedAt,
+			ETag:      resp.Header.Get("ETag"),
+		},
+		validators: validatorsOf(resp.Header),
+		cache:      parseCacheDirectives(resp.Header),
+	}
+
+	if resp.StatusCode == http.StatusNotModified && validators != (cacheValidators{}) {
+		// A 304 need not repeat the validators of the document it confirms
+		if fetched.validators == (cacheValidators{}) {
+			fetched.validators = validators
+		}
+		fetched.provenance.ETag = fetched.validators.etag
+		return fetched, nil
+	}
+	if resp.StatusCode == http.StatusNotFound && opts.EnableDNSDiscovery {
+		return fetchKeysFromDNS(ctx, issuerDomain, opts)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("failed to fetch public keys: HTTP %d", resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := opts.meter.readAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
@@ -80,16 +240,26 @@ func FetchPublicKeys(ctx context.Context, issuerDomain string, opts VerifyOption
 	if err := json.Unmarshal(body, &wellKnown); err != nil {
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
+	wellKnown.Discovery = KeySourceNetwork
+	fetched.wellKnown = &wellKnown
 
-	return &wellKnown, nil
+	return fetched, nil
 }
 
-// FetchClaim fetches and verifies a HAP claim from a VA
+// FetchClaim fetches and verifies a HAP claim from a VA.
+//
+// The response's Issuer and VerifyURL fields are advisory and never used to
+// resolve keys; verification always fetches keys from issuerDomain. An Issuer
+// naming another domain is replaced with issuerDomain, and a VerifyURL that is
+// not HTTPS on issuerDomain is dropped, each with a warning in
+// VerificationResponse.Warnings. With StrictConsistency set, either is an
+// ErrInconsistentResponse error instead.
 func FetchClaim(ctx context.Context, hapID, issuerDomain string, opts VerifyOptions) (*VerificationResponse, error) {
-	if !IsValidID(hapID) {
+	if err := opts.validateID(hapID); err != nil {
 		return &VerificationResponse{Valid: false, Error: "invalid_format"}, nil
 	}
 
+	opts = opts.metered()
 	if opts.HTTPClient == nil {
 		opts.HTTPClient = http.DefaultClient
 	}
@@ -100,12 +270,31 @@ func FetchClaim(ctx context.Context, hapID, issuerDomain string, opts VerifyOpti
 	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
 	defer cancel()
 
-	url := fmt.Sprintf("https://%s/api/v1/verify/%s", issuerDomain, hapID)
+	host, err := hostToASCII(issuerDomain)
+	if err != nil {
+		return nil, err
+	}
+	url, err := opts.endpoint(issuerDomain, "/api/v1/verify/"+hapID)
+	if err != nil {
+		return nil, err
+	}
+	cache, key := opts.ClaimCache, claimCacheKey(host, hapID)
+	var cached *claimCacheEntry
+	if cache != nil {
+		cached = cache.lookup(key)
+		if cached != nil && opts.now().Before(cached.expires) {
+			return checkedClaimResponse(cached.resp, issuerDomain, opts)
+		}
+	}
+
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Accept", "application/json")
+	if cached != nil {
+		cached.validators.setConditional(req)
+	}
 
 	resp, err := opts.HTTPClient.Do(req)
 	if err != nil {
@@ -113,7 +302,12 @@ func FetchClaim(ctx context.Context, hapID, issuerDomain string, opts VerifyOpti
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		cache.store(key, cached.resp, cached.validators, opts.now())
+		return checkedClaimResponse(cached.resp, issuerDomain, opts)
+	}
+
+	body, err := opts.meter.readAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
@@ -123,75 +317,311 @@ func FetchClaim(ctx context.Context, hapID, issuerDomain string, opts VerifyOpti
 		return nil, fmt.Errorf("failed to parse response: %w", err)
 	}
 
-	return &verifyResp, nil
+	if cache != nil && resp.StatusCode == http.StatusOK {
+		// Without validators or a TTL the entry could never be reused
+		if validators := validatorsOf(resp.Header); validators != (cacheValidators{}) || cache.ttl > 0 {
+			cache.store(key, &verifyResp, validators, opts.now())
+		}
+	}
+	return checkedClaimResponse(&verifyResp, issuerDomain, opts)
 }
 
-// VerifySignature verifies a JWS signature against a VA's public keys
+// checkedClaimResponse returns a copy of resp with its advisory fields
+// checked, leaving resp as it came from the VA
+func checkedClaimResponse(resp *VerificationResponse, issuerDomain string, opts VerifyOptions) (*VerificationResponse, error) {
+	checked := cloneVerificationResponse(resp)
+	if err := checkResponseConsistency(checked, issuerDomain, opts.StrictConsistency); err != nil {
+		return nil, err
+	}
+	return checked, nil
+}
+
+// checkResponseConsistency validates the advisory Issuer and VerifyURL fields
+// of a VA response against the issuer the caller asked
+func checkResponseConsistency(resp *VerificationResponse, issuerDomain string, strict bool) error {
+	var problems []string
+	if resp.Issuer != "" && !sameDomain(resp.Issuer, issuerDomain) {
+		problems = append(problems, fmt.Sprintf("response issuer %s does not match %s", resp.Issuer, issuerDomain))
+		resp.Issuer = issuerDomain
+	}
+	if resp.VerifyURL != "" {
+		u, err := url.Parse(resp.VerifyURL)
+		if err != nil || u.Scheme != "https" || !sameDomain(u.Host, issuerDomain) {
+			problems = append(problems, fmt.Sprintf("verify URL %s is not HTTPS on %s", resp.VerifyURL, issuerDomain))
+			resp.VerifyURL = ""
+		}
+	}
+
+	if strict && len(problems) > 0 {
+		return fmt.Errorf("%w: %s", ErrInconsistentResponse, strings.Join(problems, "; "))
+	}
+	resp.Warnings = append(resp.Warnings, problems...)
+	return nil
+}
+
+// MinKeyRefreshInterval is how old cached keys must be before a JWS naming
+// an unknown kid makes VerifySignature refetch them, so tokens with made-up
+// kids cannot make it hammer an issuer
+const MinKeyRefreshInterval = 30 * time.Second
+
+// VerifySignature verifies a JWS signature against a VA's public keys. If
+// the JWS names a kid missing from cached keys, as after the issuer rotates
+// keys, the keys are refetched once, bypassing the cache, and the JWS is
+// verified again; see SignatureVerificationResult.KeyRefreshAttempted. Keys
+// fetched less than MinKeyRefreshInterval ago are not refetched.
 func VerifySignature(ctx context.Context, jwsString, issuerDomain string, opts VerifyOptions) (*SignatureVerificationResult, error) {
+	opts = opts.metered()
+
 	// Fetch public keys
-	wellKnown, err := FetchPublicKeys(ctx, issuerDomain, opts)
+	wellKnown, provenance, err := fetchPublicKeys(ctx, issuerDomain, opts)
+	if errors.Is(err, ErrWorkBudgetExceeded) {
+		return nil, err
+	}
+	if err != nil {
+		return signatureFailure(err), nil
+	}
+
+	result, err := verifyFetchedSignature(jwsString, issuerDomain, wellKnown, provenance, opts)
+	if err != nil || !needsKeyRefresh(result, provenance, opts) {
+		return result, err
+	}
+
+	// The kid may belong to a key published since the cached keys were fetched
+	opts.BypassKeyCache = true
+	wellKnown, provenance, err = fetchPublicKeys(ctx, issuerDomain, opts)
+	if errors.Is(err, ErrWorkBudgetExceeded) {
+		return nil, err
+	}
+	if err != nil {
+		result.KeyRefreshAttempted = true
+		result.Warnings = append(result.Warnings, fmt.Sprintf("key refresh failed: %v", err))
+		return result, nil
+	}
+	result, err = verifyFetchedSignature(jwsString, issuerDomain, wellKnown, provenance, opts)
 	if err != nil {
-		return &SignatureVerificationResult{Valid: false, Error: err.Error()}, nil
+		return nil, err
+	}
+	result.KeyRefreshAttempted = true
+	return result, nil
+}
+
+// needsKeyRefresh reports whether a failed verification could succeed with
+// refetched keys: the JWS names a kid missing from keys fetched at least
+// MinKeyRefreshInterval ago
+func needsKeyRefresh(result *SignatureVerificationResult, provenance *KeyProvenance, opts VerifyOptions) bool {
+	// Keys fetched just now are never that old, whatever their source
+	return errors.Is(result.Err, ErrKeyNotFound) && provenance.FetchedAt != nil &&
+		opts.now().Sub(*provenance.FetchedAt) >= MinKeyRefreshInterval
+}
+
+// verifyFetchedSignature verifies a JWS against keys fetched from
+// issuerDomain, checking and updating key pins if the options set them
+func verifyFetchedSignature(jwsString, issuerDomain string, wellKnown *WellKnown, provenance *KeyProvenance, opts VerifyOptions) (*SignatureVerificationResult, error) {
+	if err := opts.meter.signatureAttempt(); err != nil {
+		return nil, err
+	}
+
+	// Pins are keyed by the issuer's ASCII host, which fetching already checked
+	pinIssuer, _ := hostToASCII(issuerDomain)
+	var pinned []string
+	if opts.KeyPins != nil {
+		var err error
+		if pinned, err = opts.KeyPins.Get(pinIssuer); err != nil {
+			return nil, fmt.Errorf("failed to read key pins: %w", err)
+		}
+	}
+
+	// Check the issuer the caller asked for, not the one the document names
+	verify := func(keys []JWK) (*SignatureVerificationResult, error) {
+		result, err := VerifySignatureWithKeys(jwsString, &WellKnown{Issuer: issuerDomain, Keys: keys})
+		if err != nil {
+			return nil, err
+		}
+		result.KeyProvenance = provenance.at(opts.now())
+		return result, nil
 	}
 
+	if len(pinned) == 0 {
+		result, err := verify(wellKnown.Keys)
+		if err == nil && result.Valid && opts.KeyPins != nil {
+			// Trust on first use
+			if err := opts.KeyPins.Put(pinIssuer, keyThumbprints(wellKnown.Keys)); err != nil {
+				return nil, fmt.Errorf("failed to pin keys: %w", err)
+			}
+		}
+		return result, err
+	}
+
+	// Only a pinned key may verify the claim. Once one has, keys published
+	// alongside it are vouched for by the issuer and pinned too.
+	result, err := verify(pinnedKeys(wellKnown.Keys, pinned))
+	if err != nil {
+		return nil, err
+	}
+	if result.Valid {
+		if repin := addKeyPins(pinned, wellKnown.Keys); repin != nil {
+			if err := opts.KeyPins.Put(pinIssuer, repin); err != nil {
+				return nil, fmt.Errorf("failed to pin keys: %w", err)
+			}
+		}
+		return result, nil
+	}
+	if !errors.Is(result.Err, ErrKeyNotFound) {
+		return result, nil
+	}
+
+	// The kid is not pinned; if the issuer publishes it, its keys changed
+	unpinned, err := verify(wellKnown.Keys)
+	if err != nil || errors.Is(unpinned.Err, ErrKeyNotFound) {
+		return unpinned, err
+	}
+	pinErr := &KeyPinError{Issuer: pinIssuer, Pinned: pinned, Fetched: keyThumbprints(wellKnown.Keys)}
+	if opts.OnKeyPinMismatch == nil {
+		return signatureFailure(pinErr), nil
+	}
+	opts.OnKeyPinMismatch(pinErr)
+	return unpinned, nil
+}
+
+// VerifySignatureWithKeys verifies a JWS signature against a well-known
+// document the caller already has, without any network access. The claim's
+// issuer must match wellKnown.Issuer. An error is returned only when
+// wellKnown is unusable; a bad signature is reported in the result.
+func VerifySignatureWithKeys(jwsString string, wellKnown *WellKnown) (*SignatureVerificationResult, error) {
+	if wellKnown == nil || wellKnown.Issuer == "" {
+		return nil, errors.New("well-known document has no issuer")
+	}
+	result := verifyJWS(jwsString, wellKnown.Issuer, wellKnown.Keys)
+	result.KeyProvenance = &KeyProvenance{Source: KeySourceStatic}
+	return result, nil
+}
+
+// at returns a copy of the provenance with Age computed for keys used at now
+func (p *KeyProvenance) at(now time.Time) *KeyProvenance {
+	used := *p
+	if used.FetchedAt != nil {
+		// Callers get their own copy, not the cache's
+		fetchedAt := *used.FetchedAt
+		used.FetchedAt = &fetchedAt
+		used.Age = now.Sub(fetchedAt)
+	}
+	return &used
+}
+
+// signatureFailure returns an invalid result for err
+func signatureFailure(err error) *SignatureVerificationResult {
+	return &SignatureVerificationResult{Valid: false, Error: err.Error(), Err: err}
+}
+
+// verifyJWS verifies a JWS against the given keys and checks the claim issuer
+func verifyJWS(jwsString, issuerDomain string, keys []JWK) *SignatureVerificationResult {
+	result := verifyJWSSignature(jwsString, keys)
+	if !result.Valid {
+		return result
+	}
+
+	// Verify issuer matches
+	if !sameDomain(result.Claim.Iss, issuerDomain) {
+		return signatureFailure(newVerificationError(ErrIssuerMismatch, nil, "issuer mismatch: expected %s, got %s", issuerDomain, result.Claim.Iss))
+	}
+
+	return result
+}
+
+// verifyJWSSignature verifies a JWS against the given keys without checking
+// the claim issuer, for callers whose keys already pin a single issuer
+func verifyJWSSignature(jwsString string, keys []JWK) *SignatureVerificationResult {
 	// Parse the JWS
 	jws, err := jose.ParseSigned(jwsString, []jose.SignatureAlgorithm{jose.EdDSA})
 	if err != nil {
-		return &SignatureVerificationResult{Valid: false, Error: fmt.Sprintf("failed to parse JWS: %v", err)}, nil
+		return signatureFailure(newVerificationError(ErrInvalidFormat, err, "failed to parse JWS: %v", err))
 	}
 
 	// Get the key ID from the header
 	if len(jws.Signatures) == 0 {
-		return &SignatureVerificationResult{Valid: false, Error: "no signatures in JWS"}, nil
+		return signatureFailure(newVerificationError(ErrInvalidFormat, nil, "no signatures in JWS"))
 	}
 	kid := jws.Signatures[0].Header.KeyID
 	if kid == "" {
-		return &SignatureVerificationResult{Valid: false, Error: "JWS header missing kid"}, nil
-	}
-
-	// Find the matching key
-	var jwk *JWK
-	for _, k := range wellKnown.Keys {
-		if k.Kid == kid {
-			jwk = &k
-			break
-		}
+		return signatureFailure(newVerificationError(ErrInvalidFormat, nil, "JWS header missing kid"))
 	}
-	if jwk == nil {
-		return &SignatureVerificationResult{Valid: false, Error: fmt.Sprintf("key not found: %s", kid)}, nil
+	// Claims signed before typ was set carry none
+	if typ, ok := jws.Signatures[0].Header.ExtraHeaders[jose.HeaderType]; ok && typ != claimJWSType {
+		return signatureFailure(newVerificationError(ErrInvalidFormat, nil, "JWS typ %v is not %s", typ, claimJWSType))
 	}
 
-	// Decode the public key
-	xBytes, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	// Find the matching key
+	publicKey, err := lookupKey(keys, kid, KeyUseClaims)
 	if err != nil {
-		return &SignatureVerificationResult{Valid: false, Error: fmt.Sprintf("failed to decode public key: %v", err)}, nil
+		return signatureFailure(err)
 	}
-	publicKey := ed25519.PublicKey(xBytes)
 
 	// Verify the signature
 	payload, err := jws.Verify(publicKey)
 	if err != nil {
-		return &SignatureVerificationResult{Valid: false, Error: fmt.Sprintf("signature verification failed: %v", err)}, nil
+		return signatureFailure(newVerificationError(ErrSignatureInvalid, err, "signature verification failed: %v", err))
+	}
+
+	// Upgrade claims issued under older protocol versions
+	payload, err = upgradeClaimPayload(payload)
+	if err != nil {
+		return signatureFailure(newVerificationError(ErrInvalidFormat, err, "failed to parse claim: %v", err))
 	}
 
 	// Parse the payload
 	var claim Claim
 	if err := json.Unmarshal(payload, &claim); err != nil {
-		return &SignatureVerificationResult{Valid: false, Error: fmt.Sprintf("failed to parse claim: %v", err)}, nil
+		return signatureFailure(newVerificationError(ErrInvalidFormat, err, "failed to parse claim: %v", err))
+	}
+	if err := ValidateClaim(&claim); err != nil {
+		return signatureFailure(newVerificationError(ErrInvalidFormat, err, "%v", err))
 	}
 
-	// Verify issuer matches
-	if claim.Iss != issuerDomain {
-		return &SignatureVerificationResult{
-			Valid: false,
-			Error: fmt.Sprintf("issuer mismatch: expected %s, got %s", issuerDomain, claim.Iss),
-		}, nil
+	result := &SignatureVerificationResult{Valid: true, Claim: &claim}
+	if warning := olderKeyWarning(keys, kid); warning != "" {
+		result.Warnings = append(result.Warnings, warning)
 	}
+	return result
+}
+
+// lookupKey finds the key with the given kid and decodes it, checking it may be used for use
+func lookupKey(keys []JWK, kid string, use KeyUse) (ed25519.PublicKey, error) {
+	var jwk *JWK
+	for _, k := range keys {
+		if k.Kid == kid {
+			jwk = &k
+			break
+		}
+	}
+	if jwk == nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, kid)
+	}
+	if !jwk.AllowsUse(use) {
+		return nil, fmt.Errorf("%w: %s has use %q", ErrWrongKeyUse, kid, jwk.Use)
+	}
+
+	return decodePublicKey(*jwk)
+}
 
-	return &SignatureVerificationResult{Valid: true, Claim: &claim}, nil
+// decodePublicKey decodes an OKP/Ed25519 JWK. ed25519.Verify panics on a key
+// of the wrong length, so anything else is an error rather than a key.
+func decodePublicKey(jwk JWK) (ed25519.PublicKey, error) {
+	if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" {
+		return nil, fmt.Errorf("%w: %s is %s/%s", ErrNotEd25519Key, jwk.Kid, jwk.Kty, jwk.Crv)
+	}
+	xBytes, err := base64.RawURLEncoding.DecodeString(jwk.X)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key: %w", err)
+	}
+	if len(xBytes) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: %s is %d bytes, want %d", ErrNotEd25519Key, jwk.Kid, len(xBytes), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(xBytes), nil
 }
 
-// VerifyClaim fully verifies a HAP claim: fetches from VA and optionally verifies signature
+// VerifyClaim fully verifies a HAP claim: fetches from VA and optionally verifies signature.
+// Unless SkipSignatureVerification is set, a VA response without a signed
+// claim fails with an error matching ErrSignatureInvalid.
 func VerifyClaim(ctx context.Context, hapID, issuerDomain string, opts ...VerifyOptions) (*Claim, error) {
 	var opt VerifyOptions
 	if len(opts) > 0 {
@@ -199,6 +629,10 @@ func VerifyClaim(ctx context.Context, hapID, issuerDomain string, opts ...Verify
 	} else {
 		opt = DefaultVerifyOptions()
 	}
+	if opt.VerifySignature {
+		deprecated("VerifyOptions.VerifySignature", "VerifyOptions.SkipSignatureVerification")
+	}
+	opt = opt.metered()
 
 	// Fetch the claim
 	resp, err := FetchClaim(ctx, hapID, issuerDomain, opt)
@@ -210,9 +644,17 @@ func VerifyClaim(ctx context.Context, hapID, issuerDomain string, opts ...Verify
 	if !resp.Valid {
 		return nil, nil
 	}
+	if resp.Claim != nil {
+		if err := ValidateClaim(resp.Claim); err != nil {
+			return nil, err
+		}
+	}
 
 	// Optionally verify the signature
-	if opt.VerifySignature && resp.JWS != "" {
+	if !opt.SkipSignatureVerification {
+		if resp.JWS == "" {
+			return nil, errMissingSignature(issuerDomain)
+		}
 		sigResult, err := VerifySignature(ctx, resp.JWS, issuerDomain, opt)
 		if err != nil {
 			return nil, err
@@ -225,6 +667,31 @@ func VerifyClaim(ctx context.Context, hapID, issuerDomain string, opts ...Verify
 	return resp.Claim, nil
 }
 
+// errMissingSignature reports a VA response with no signed claim to verify
+func errMissingSignature(issuerDomain string) error {
+	return newVerificationError(ErrSignatureInvalid, nil, "VA %s returned no signature", issuerDomain)
+}
+
+// VerifyAcrossIssuers verifies a claim against each of several equivalent
+// issuer domains in order, such as a VA's primary and backup domains, and
+// returns the claim with the issuer that verified it. It stops at the first
+// success. If none verifies, the claim is nil and the error joins any
+// errors from issuers that could not be reached.
+func VerifyAcrossIssuers(ctx context.Context, hapID string, issuers []string, opts VerifyOptions) (*Claim, string, error) {
+	var errs []error
+	for _, issuer := range issuers {
+		claim, err := VerifyClaim(ctx, hapID, issuer, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", issuer, err))
+			continue
+		}
+		if claim != nil {
+			return claim, issuer, nil
+		}
+	}
+	return nil, "", errors.Join(errs...)
+}
+
 // ExtractIDFromURL extracts the HAP ID from a verification URL
 func ExtractIDFromURL(urlStr string) string {
 	parsed, err := url.Parse(urlStr)
@@ -247,19 +714,44 @@ func ExtractIDFromURL(urlStr string) string {
 
 // IsClaimExpired checks if a claim is expired
 func IsClaimExpired(claim *Claim) bool {
+	return IsClaimExpiredAt(claim, time.Now())
+}
+
+// IsClaimExpiredAt checks if a claim is expired at now. A claim is still
+// valid at the instant of its exp timestamp. An exp that does not parse
+// counts as expired.
+func IsClaimExpiredAt(claim *Claim, now time.Time) bool {
+	return IsClaimExpiredWithLeeway(claim, now, 0)
+}
+
+// IsClaimExpiredWithLeeway checks if a claim is expired at now, treating it
+// as still valid until leeway past its exp to allow for clock skew
+func IsClaimExpiredWithLeeway(claim *Claim, now time.Time, leeway time.Duration) bool {
 	if claim.Exp == "" {
 		return false
 	}
 
-	expTime, err := time.Parse(time.RFC3339, claim.Exp)
+	expTime, err := ParseTimestamp(claim.Exp)
 	if err != nil {
-		return false
+		return true
 	}
 
-	return expTime.Before(time.Now())
+	return expTime.Add(leeway).Before(now)
+}
+
+// IsClaimNotYetValid reports whether a claim's at is more than leeway after
+// now, as when the issuer's clock runs ahead. An at that does not parse
+// counts as not yet valid.
+func IsClaimNotYetValid(claim *Claim, now time.Time, leeway time.Duration) bool {
+	at, err := ParseTimestamp(claim.At)
+	if err != nil {
+		return true
+	}
+	return at.After(now.Add(leeway))
 }
 
-// IsClaimForRecipient checks if the claim target matches the expected recipient
+// IsClaimForRecipient checks if the claim target matches the expected recipient.
+// Internationalized domains match whether given in Unicode or punycode form.
 func IsClaimForRecipient(claim *Claim, recipientDomain string) bool {
-	return claim.To.Domain == recipientDomain
+	return sameDomain(claim.To.Domain, recipientDomain)
 }
@@ -0,0 +1,539 @@
+package humanattestation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testVA is a fake Verification Authority serving the well-known document
+// and verification API over TLS
+type testVA struct {
+	*httptest.Server
+	keyFetches  atomic.Int32
+	notModified atomic.Int32 // Well-known fetches answered with 304
+
+	mu        sync.Mutex
+	wellKnown WellKnown
+	responses map[string]VerificationResponse
+	faults    testVAFaults
+}
+
+// testVAFaults simulates misbehaving VAs
+type testVAFaults struct {
+	WellKnownStatus int           // Non-zero replaces the well-known response with this status
+	CacheControl    string        // Cache-Control header on the well-known document
+	ETag            bool          // Serve an ETag and honor If-None-Match
+	LastModified    time.Time     // Non-zero serves Last-Modified and honors If-Modified-Since
+	ClockOffset     time.Duration // Added to the Date header
+	BrokenVerify    bool          // Verify API answers with a non-JSON body
+	HoldWellKnown   chan struct{} // Non-nil holds well-known responses until closed
+}
+
+func newTestVA(t *testing.T) *testVA {
+	t.Helper()
+	va := &testVA{responses: make(map[string]VerificationResponse)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/hap.json", func(w http.ResponseWriter, r *http.Request) {
+		va.keyFetches.Add(1)
+		va.mu.Lock()
+		hold := va.faults.HoldWellKnown
+		va.mu.Unlock()
+		if hold != nil {
+			<-hold
+		}
+		va.mu.Lock()
+		defer va.mu.Unlock()
+		if va.faults.CacheControl != "" {
+			w.Header().Set("Cache-Control", va.faults.CacheControl)
+		}
+		if va.faults.ClockOffset != 0 {
+			w.Header().Set("Date", time.Now().Add(va.faults.ClockOffset).UTC().Format(http.TimeFormat))
+		}
+		if va.faults.WellKnownStatus != 0 {
+			w.WriteHeader(va.faults.WellKnownStatus)
+			return
+		}
+		notModified := false
+		if va.faults.ETag {
+			etag := WellKnownETag(va.wellKnown)
+			w.Header().Set("ETag", etag)
+			notModified = etagMatches(r.Header.Get("If-None-Match"), etag)
+		}
+		if lm := va.faults.LastModified; !lm.IsZero() {
+			w.Header().Set("Last-Modified", lm.UTC().Format(http.TimeFormat))
+			since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+			notModified = notModified || err == nil && !lm.Truncate(time.Second).After(since)
+		}
+		if notModified {
+			va.notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(va.wellKnown)
+	})
+	mux.HandleFunc("/api/v1/verify/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/api/v1/verify/")
+		va.mu.Lock()
+		resp, ok := va.responses[id]
+		broken := va.faults.BrokenVerify
+		va.mu.Unlock()
+		if broken {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>Bad Gateway</html>"))
+			return
+		}
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(VerificationResponse{Valid: false, Error: "not_found"})
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	})
+
+	va.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(func() {
+		va.Close()
+		// Test servers reuse ports, so keys cached for this VA must go with it
+		FlushKeyCache(va.Issuer())
+	})
+	va.wellKnown.Issuer = va.Issuer()
+	return va
+}
+
+// Issuer returns the host:port the fake VA is reachable at, used as its issuer domain
+func (va *testVA) Issuer() string {
+	return strings.TrimPrefix(va.URL, "https://")
+}
+
+// Options returns verify options whose HTTP client trusts the fake VA
+func (va *testVA) Options() VerifyOptions {
+	opts := DefaultVerifyOptions()
+	opts.HTTPClient = va.Client()
+	return opts
+}
+
+// AddKey generates a key pair, publishes its public half and returns the private key
+func (va *testVA) AddKey(t *testing.T, kid string, use KeyUse) ed25519.PrivateKey {
+	t.Helper()
+	priv, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	va.mu.Lock()
+	defer va.mu.Unlock()
+	va.wellKnown.Keys = append(va.wellKnown.Keys, ExportPublicKeyJWKWithUse(pub, kid, use))
+	return priv
+}
+
+// Issue creates, signs and stores a valid claim for the fake VA
+func (va *testVA) Issue(t *testing.T, priv ed25519.PrivateKey, kid string) (*Claim, string) {
+	t.Helper()
+	claim := testClaim(t)
+	claim.Iss = va.Issuer()
+	jws, err := SignClaim(claim, priv, kid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	va.SetResponse(VerificationResponse{
+		Valid:  true,
+		ID:     claim.ID,
+		Claim:  claim,
+		JWS:    jws,
+		Issuer: va.Issuer(),
+	})
+	return claim, jws
+}
+
+// SetResponse sets the verification API response served for resp.ID
+func (va *testVA) SetResponse(resp VerificationResponse) {
+	va.mu.Lock()
+	defer va.mu.Unlock()
+	va.responses[resp.ID] = resp
+}
+
+// SetFaults makes the fake VA misbehave as described by f
+func (va *testVA) SetFaults(f testVAFaults) {
+	va.mu.Lock()
+	defer va.mu.Unlock()
+	va.faults = f
+}
+
+// Revoke marks a previously issued claim as revoked
+func (va *testVA) Revoke(id string, reason RevocationReason) {
+	va.SetResponse(VerificationResponse{
+		Valid:            false,
+		ID:               id,
+		Revoked:          true,
+		RevocationReason: reason,
+		RevokedAt:        &Timestamp{time.Date(2026, 2, 1, 12, 0, 0, 0, time.UTC)},
+		Issuer:           va.Issuer(),
+	})
+}
+
+func TestVerifySignatureKeyUse(t *testing.T) {
+	va := newTestVA(t)
+	claimsKey := va.AddKey(t, "claims_001", KeyUseClaims)
+	webhooksKey := va.AddKey(t, "webhooks_001", KeyUseWebhooks)
+	legacyKey := va.AddKey(t, "legacy_001", "")
+
+	ctx := context.Background()
+
+	for _, kid := range []string{"claims_001", "legacy_001"} {
+		priv := claimsKey
+		if kid == "legacy_001" {
+			priv = legacyKey
+		}
+		_, jws := va.Issue(t, priv, kid)
+		result, err := VerifySignature(ctx, jws, va.Issuer(), va.Options())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !result.Valid {
+			t.Errorf("%s: expected valid signature, got %s", kid, result.Error)
+		}
+	}
+
+	_, jws := va.Issue(t, webhooksKey, "webhooks_001")
+	result, err := VerifySignature(ctx, jws, va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid || !strings.Contains(result.Error, ErrWrongKeyUse.Error()) {
+		t.Errorf("claim signed by webhooks key: valid=%v error=%q, want %v", result.Valid, result.Error, ErrWrongKeyUse)
+	}
+}
+
+func TestVerifySignatureKeyProvenance(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+	_, jws := va.Issue(t, priv, "key_001")
+
+	result, err := VerifySignature(context.Background(), jws, va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := result.KeyProvenance
+	if p == nil {
+		t.Fatal("missing key provenance")
+	}
+	if p.Source != KeySourceNetwork || p.URL != va.URL+"/.well-known/hap.json" || p.FetchedAt == nil {
+		t.Errorf("provenance = %+v", p)
+	}
+}
+
+func TestVerifySignatureWithKeys(t *testing.T) {
+	claim := testClaim(t)
+	priv, jwk := testKeys(t, "claims_001")
+	jws, err := SignClaim(claim, priv, "claims_001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wellKnown := &WellKnown{Issuer: claim.Iss, Keys: []JWK{jwk}}
+
+	result, err := VerifySignatureWithKeys(jws, wellKnown)
+	if err != nil || !result.Valid || result.Claim.ID != claim.ID {
+		t.Fatalf("VerifySignatureWithKeys() = %+v, %v", result, err)
+	}
+	if result.KeyProvenance == nil || result.KeyProvenance.Source != KeySourceStatic {
+		t.Errorf("provenance = %+v, want static", result.KeyProvenance)
+	}
+	if data, err := json.Marshal(result.KeyProvenance); err != nil || strings.Contains(string(data), "fetchedAt") {
+		t.Errorf("static provenance marshals as %s, %v, want no fetchedAt", data, err)
+	}
+
+	other := &WellKnown{Issuer: "other.example", Keys: []JWK{jwk}}
+	if result, err := VerifySignatureWithKeys(jws, other); err != nil || result.Valid || !errors.Is(result.Err, ErrIssuerMismatch) {
+		t.Errorf("other issuer = %+v, %v", result, err)
+	}
+	if result, err := VerifySignatureWithKeys(jws, &WellKnown{Issuer: claim.Iss}); err != nil || !errors.Is(result.Err, ErrKeyNotFound) {
+		t.Errorf("no keys = %+v, %v", result, err)
+	}
+	for _, wk := range []*WellKnown{nil, {Keys: []JWK{jwk}}} {
+		if _, err := VerifySignatureWithKeys(jws, wk); err == nil {
+			t.Errorf("VerifySignatureWithKeys(%+v) succeeded", wk)
+		}
+	}
+}
+
+func TestPartialOptionsVerifySignatures(t *testing.T) {
+	va := newTestVA(t)
+	va.AddKey(t, "claims_001", KeyUseClaims)
+
+	// The VA's API vouches for a claim whose JWS was signed by an unpublished key
+	forger, _, _ := GenerateKeyPair()
+	claim, _ := va.Issue(t, forger, "claims_001")
+
+	partial := VerifyOptions{HTTPClient: va.Client(), Timeout: 5 * time.Second}
+	ctx := context.Background()
+
+	if got, err := VerifyClaim(ctx, claim.ID, va.Issuer(), partial); err != nil || got != nil {
+		t.Errorf("VerifyClaim() = %v, %v, want forged claim rejected", got, err)
+	}
+
+	partial.Resolver = fakeResolver{}
+	if got, err := VerifyWithDiscovery(ctx, claim.ID, va.Issuer(), partial); err != nil || got != nil {
+		t.Errorf("VerifyWithDiscovery() = %v, %v, want forged claim rejected", got, err)
+	}
+
+	if result, err := VerifyAnything(ctx, claim.ID, []string{va.Issuer()}, partial); err != nil || result.Valid {
+		t.Errorf("VerifyAnything() = %+v, %v, want forged claim rejected", result, err)
+	}
+
+	// Opting out is explicit
+	partial.SkipSignatureVerification = true
+	if got, err := VerifyClaim(ctx, claim.ID, va.Issuer(), partial); err != nil || got == nil {
+		t.Errorf("VerifyClaim() with SkipSignatureVerification = %v, %v, want claim", got, err)
+	}
+}
+
+func TestVerifyClaimRequiresSignature(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "key_001", KeyUseClaims)
+	claim, _ := va.Issue(t, priv, "key_001")
+	// The VA vouches for the claim without signing it
+	va.SetResponse(VerificationResponse{Valid: true, ID: claim.ID, Claim: claim, Issuer: va.Issuer()})
+	opts := va.Options()
+	ctx := context.Background()
+
+	if got, err := VerifyClaim(ctx, claim.ID, va.Issuer(), opts); got != nil || !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("VerifyClaim() = %v, %v, want ErrSignatureInvalid", got, err)
+	}
+	if result, err := VerifyAnything(ctx, claim.ID, []string{va.Issuer()}, opts); err != nil || result.Valid || !errors.Is(result.Err, ErrSignatureInvalid) {
+		t.Errorf("VerifyAnything() = %+v, %v, want ErrSignatureInvalid", result, err)
+	}
+
+	opts.SkipSignatureVerification = true
+	if got, err := VerifyClaim(ctx, claim.ID, va.Issuer(), opts); err != nil || got == nil {
+		t.Errorf("VerifyClaim() with SkipSignatureVerification = %v, %v, want claim", got, err)
+	}
+}
+
+func TestVerifyAcrossIssuers(t *testing.T) {
+	primary := newTestVA(t)
+	primary.AddKey(t, "key_001", KeyUseClaims)
+	backup := newTestVA(t)
+	priv := backup.AddKey(t, "key_001", KeyUseClaims)
+	claim, _ := backup.Issue(t, priv, "key_001")
+
+	// Both fake VAs share the httptest certificate, so one client trusts both
+	opts := backup.Options()
+	got, issuer, err := VerifyAcrossIssuers(context.Background(), claim.ID, []string{primary.Issuer(), backup.Issuer()}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.ID != claim.ID || issuer != backup.Issuer() {
+		t.Errorf("got claim %v from %q, want %s from %s", got, issuer, claim.ID, backup.Issuer())
+	}
+
+	// An unreachable issuer is skipped
+	unreachable := "127.0.0.1:1"
+	got, issuer, err = VerifyAcrossIssuers(context.Background(), claim.ID, []string{unreachable, backup.Issuer()}, opts)
+	if err != nil || got == nil || issuer != backup.Issuer() {
+		t.Errorf("with unreachable issuer: claim %v from %q, err %v", got, issuer, err)
+	}
+
+	// No issuer verifies
+	got, _, err = VerifyAcrossIssuers(context.Background(), claim.ID, []string{unreachable, primary.Issuer()}, opts)
+	if got != nil || err == nil || !strings.Contains(err.Error(), unreachable) {
+		t.Errorf("no issuer verifies: claim %v, err %v", got, err)
+	}
+}
+
+func TestFetchClaimAdvisoryFields(t *testing.T) {
+	va := newTestVA(t)
+	priv := va.AddKey(t, "claims_001", KeyUseClaims)
+	claim, jws := va.Issue(t, priv, "claims_001")
+
+	// A malicious VA points Issuer and VerifyURL at a host it controls
+	va.SetResponse(VerificationResponse{
+		Valid:     true,
+		ID:        claim.ID,
+		Claim:     claim,
+		JWS:       jws,
+		Issuer:    "attacker.example",
+		VerifyURL: "https://attacker.example/v/" + claim.ID,
+	})
+
+	resp, err := FetchClaim(context.Background(), claim.ID, va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatalf("FetchClaim() error = %v", err)
+	}
+	if resp.Issuer != va.Issuer() {
+		t.Errorf("Issuer = %s, want it replaced with %s", resp.Issuer, va.Issuer())
+	}
+	if resp.VerifyURL != "" {
+		t.Errorf("VerifyURL = %s, want it dropped", resp.VerifyURL)
+	}
+	if len(resp.Warnings) != 2 {
+		t.Errorf("Warnings = %v, want 2", resp.Warnings)
+	}
+
+	// Verification still uses the caller's issuer for keys
+	got, err := VerifyClaim(context.Background(), claim.ID, va.Issuer(), va.Options())
+	if err != nil || got == nil {
+		t.Errorf("VerifyClaim() = %v, %v", got, err)
+	}
+
+	strict := va.Options()
+	strict.StrictConsistency = true
+	if _, err := FetchClaim(context.Background(), claim.ID, va.Issuer(), strict); !errors.Is(err, ErrInconsistentResponse) {
+		t.Errorf("FetchClaim() strict error = %v, want ErrInconsistentResponse", err)
+	}
+
+	// Plain HTTP on the right host is still rejected
+	va.SetResponse(VerificationResponse{Valid: true, ID: claim.ID, Claim: claim, JWS: jws, VerifyURL: "http://" + va.Issuer() + "/v/" + claim.ID})
+	if _, err := FetchClaim(context.Background(), claim.ID, va.Issuer(), strict); !errors.Is(err, ErrInconsistentResponse) {
+		t.Errorf("FetchClaim() http verify URL error = %v, want ErrInconsistentResponse", err)
+	}
+
+	// Consistent fields pass untouched
+	verifyURL := "https://" + va.Issuer() + "/v/" + claim.ID
+	va.SetResponse(VerificationResponse{Valid: true, ID: claim.ID, Claim: claim, JWS: jws, Issuer: va.Issuer(), VerifyURL: verifyURL})
+	resp, err = FetchClaim(context.Background(), claim.ID, va.Issuer(), strict)
+	if err != nil || resp.VerifyURL != verifyURL || len(resp.Warnings) != 0 {
+		t.Errorf("FetchClaim() consistent = %+v, %v", resp, err)
+	}
+}
+
+func TestIsClaimExpiredAt(t *testing.T) {
+	claim := testClaim(t)
+	exp, err := claim.ExpiresAt()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"one second before exp", exp.Add(-time.Second), false},
+		{"exactly at exp", exp, false},
+		{"one second after exp", exp.Add(time.Second), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsClaimExpiredAt(claim, tt.now); got != tt.want {
+				t.Errorf("IsClaimExpiredAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if IsClaimExpiredAt(&Claim{}, exp.Add(time.Hour)) {
+		t.Error("claim without exp reported expired")
+	}
+}
+
+func TestClaimTimesWithLeeway(t *testing.T) {
+	claim := testClaim(t)
+	at, _ := claim.IssuedAt()
+	exp, _ := claim.ExpiresAt()
+	leeway := 30 * time.Second
+
+	expiry := []struct {
+		name string
+		now  time.Time
+		want bool
+	}{
+		{"at exp", exp, false},
+		{"just inside leeway", exp.Add(leeway - time.Second), false},
+		{"at the leeway boundary", exp.Add(leeway), false},
+		{"just past leeway", exp.Add(leeway + time.Second), true},
+	}
+	for _, tt := range expiry {
+		if got := IsClaimExpiredWithLeeway(claim, tt.now, leeway); got != tt.want {
+			t.Errorf("IsClaimExpiredWithLeeway(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !IsClaimExpiredWithLeeway(claim, exp.Add(time.Second), 0) {
+		t.Error("zero leeway did not expire a claim one second past exp")
+	}
+
+	issued := []struct {
+		name   string
+		now    time.Time
+		leeway time.Duration
+		want   bool
+	}{
+		{"at issue time", at, 0, false},
+		{"issued one second in the future", at.Add(-time.Second), 0, true},
+		{"future issue within leeway", at.Add(-leeway), leeway, false},
+		{"future issue past leeway", at.Add(-leeway - time.Second), leeway, true},
+	}
+	for _, tt := range issued {
+		if got := IsClaimNotYetValid(claim, tt.now, tt.leeway); got != tt.want {
+			t.Errorf("IsClaimNotYetValid(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if !IsClaimNotYetValid(&Claim{At: "yesterday"}, at, time.Hour) {
+		t.Error("unparseable at counted as valid")
+	}
+}
+
+func TestVerifyOptionsLeeway(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	claim := testClaim(t)
+	compact, err := SignCompact(claim, priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at, _ := claim.IssuedAt()
+	exp, _ := claim.ExpiresAt()
+	req := StrictRequirements{Keys: []JWK{jwk}, NotExpired: true}
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		leeway time.Duration
+		want   FailureReason
+	}{
+		{"past exp without leeway", exp.Add(5 * time.Second), 0, FailureExpired},
+		{"past exp within leeway", exp.Add(5 * time.Second), 10 * time.Second, ""},
+		{"past exp beyond leeway", exp.Add(11 * time.Second), 10 * time.Second, FailureExpired},
+		{"issued in the future", at.Add(-5 * time.Second), 0, FailureNotYetValid},
+		{"issued in the future within leeway", at.Add(-5 * time.Second), 10 * time.Second, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultVerifyOptions()
+			opts.Clock = func() time.Time { return tt.now }
+			opts.Leeway = tt.leeway
+			r := VerifyCompactStrict(context.Background(), compact, req, opts)
+			if r.FailureReason != tt.want || r.Valid != (tt.want == "") {
+				t.Errorf("VerifyCompactStrict() = valid %v, reason %q (error %q); want reason %q", r.Valid, r.FailureReason, r.Error, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyOptionsClock(t *testing.T) {
+	priv, jwk := testKeys(t, "key_001")
+	claim := testClaim(t)
+	compact, err := SignCompact(claim, priv, CompactOptions{Version: CompactVersionV2, Kid: "key_001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp, _ := claim.ExpiresAt()
+
+	req := StrictRequirements{Keys: []JWK{jwk}, NotExpired: true}
+	opts := DefaultVerifyOptions()
+	opts.Clock = func() time.Time { return exp.Add(time.Second) }
+	if r := VerifyCompactStrict(context.Background(), compact, req, opts); r.Valid || r.FailureReason != FailureExpired {
+		t.Errorf("VerifyCompactStrict() with clock past exp = %+v", r)
+	}
+	opts.Clock = func() time.Time { return exp.Add(-time.Second) }
+	if r := VerifyCompactStrict(context.Background(), compact, req, opts); !r.Valid {
+		t.Errorf("VerifyCompactStrict() with clock before exp = %+v", r)
+	}
+}
@@ -0,0 +1,162 @@
+package humanattestation
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// DiffWellKnown compares two well-known documents by key ID and returns the
+// kids that were added and removed between them.
+//
+// A kid present in both documents whose key material changed is reported as
+// both removed and added, since reusing a kid for a different key is an error
+// that breaks verification of claims signed under the old key.
+func DiffWellKnown(oldDoc, newDoc WellKnown) (added, removed []string) {
+	oldKeys := make(map[string]JWK, len(oldDoc.Keys))
+	for _, k := range oldDoc.Keys {
+		oldKeys[k.Kid] = k
+	}
+	newKeys := make(map[string]JWK, len(newDoc.Keys))
+	for _, k := range newDoc.Keys {
+		newKeys[k.Kid] = k
+	}
+
+	for _, k := range oldDoc.Keys {
+		nk, ok := newKeys[k.Kid]
+		if !ok || !sameKeyMaterial(k, nk) {
+			removed = append(removed, k.Kid)
+		}
+	}
+	for _, k := range newDoc.Keys {
+		prev, ok := oldKeys[k.Kid]
+		if !ok || !sameKeyMaterial(prev, k) {
+			added = append(added, k.Kid)
+		}
+	}
+
+	return added, removed
+}
+
+// sameKeyMaterial reports whether two JWKs describe the same public key
+func sameKeyMaterial(a, b JWK) bool {
+	return a.Kty == b.Kty && a.Crv == b.Crv && a.X == b.X
+}
+
+// ValidateWellKnown checks that a well-known document is well-formed and
+// publishes at least one key usable for verifying claims
+func ValidateWellKnown(wk WellKnown) error {
+	if wk.Issuer == "" {
+		return errors.New("well-known document missing issuer")
+	}
+	if len(wk.Keys) == 0 {
+		return errors.New("well-known document has no keys")
+	}
+
+	seen := make(map[string]bool, len(wk.Keys))
+	hasClaimsKey := false
+	for i, k := range wk.Keys {
+		if k.Kid == "" {
+			return fmt.Errorf("key %d missing kid", i)
+		}
+		if seen[k.Kid] {
+			return fmt.Errorf("duplicate kid: %s", k.Kid)
+		}
+		seen[k.Kid] = true
+
+		if k.Kty != "OKP" || k.Crv != "Ed25519" {
+			return fmt.Errorf("key %s: unsupported key type %s/%s", k.Kid, k.Kty, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return fmt.Errorf("key %s: failed to decode public key: %w", k.Kid, err)
+		}
+		if len(x) != 32 {
+			return fmt.Errorf("key %s: public key must be 32 bytes, got %d", k.Kid, len(x))
+		}
+
+		switch k.Use {
+		case "", KeyUseClaims:
+			hasClaimsKey = true
+		case KeyUseWebhooks, KeyUseReceipts, KeyUseStats, KeyUseTokens, KeyUseExtensions:
+		default:
+			return fmt.Errorf("key %s: unknown use %q", k.Kid, k.Use)
+		}
+	}
+
+	if !hasClaimsKey {
+		return errors.New("well-known document has no claims-use key")
+	}
+	if wk.MaxBatchSize < 0 {
+		return fmt.Errorf("maxBatchSize must not be negative: %d", wk.MaxBatchSize)
+	}
+
+	methods := make(map[string]bool, len(wk.Methods))
+	for i, m := range wk.Methods {
+		if m.Method == "" {
+			return fmt.Errorf("method %d missing name", i)
+		}
+		if methods[m.Method] {
+			return fmt.Errorf("duplicate method: %s", m.Method)
+		}
+		methods[m.Method] = true
+
+		if m.TypicalCostCents < 0 {
+			return fmt.Errorf("method %s: negative typical cost", m.Method)
+		}
+		if m.TypicalDurationSeconds < 0 {
+			return fmt.Errorf("method %s: negative typical duration", m.Method)
+		}
+		tiers := make(map[string]bool, len(m.Tiers))
+		for _, tier := range m.Tiers {
+			if tier == "" {
+				return fmt.Errorf("method %s: empty tier", m.Method)
+			}
+			if tiers[tier] {
+				return fmt.Errorf("method %s: duplicate tier %s", m.Method, tier)
+			}
+			tiers[tier] = true
+		}
+	}
+
+	return nil
+}
+
+// FetchIssuerMethods fetches the verification methods an issuer publishes in
+// its well-known document. It returns an empty list if none are published.
+func FetchIssuerMethods(ctx context.Context, issuerDomain string, opts VerifyOptions) ([]IssuerMethod, error) {
+	wellKnown, err := FetchPublicKeys(ctx, issuerDomain, opts)
+	if err != nil {
+		return nil, err
+	}
+	return wellKnown.Methods, nil
+}
+
+// ValidateClaimMethod checks that a claim's method and tier are among those
+// its issuer publishes, comparing methods by their NormalizeMethod form.
+// Mismatches are returned as warnings, since issuers may retire methods after
+// issuing claims; no warnings are returned when methods is empty.
+func ValidateClaimMethod(claim *Claim, methods []IssuerMethod) []string {
+	if len(methods) == 0 {
+		return nil
+	}
+
+	for _, m := range methods {
+		if NormalizeMethod(m.Method) != NormalizeMethod(claim.Method) {
+			continue
+		}
+
+		var warnings []string
+		if !m.Enabled {
+			warnings = append(warnings, fmt.Sprintf("method %s is not enabled by issuer", claim.Method))
+		}
+		if claim.Tier != "" && len(m.Tiers) > 0 && !slices.Contains(m.Tiers, claim.Tier) {
+			warnings = append(warnings, fmt.Sprintf("tier %s is not offered for method %s", claim.Tier, claim.Method))
+		}
+		return warnings
+	}
+
+	return []string{fmt.Sprintf("method %s is not offered by issuer", claim.Method)}
+}
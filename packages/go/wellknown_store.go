@@ -0,0 +1,164 @@
+package humanattestation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// wellKnownSnapshot is an immutable, pre-serialized well-known document
+type wellKnownSnapshot struct {
+	doc  *WellKnown
+	body []byte
+	etag string
+}
+
+// WellKnownStore holds the well-known document a VA serves and lets it be
+// changed at runtime, for example to add or retire keys, without racing
+// readers. Each Update publishes a new immutable snapshot; readers only ever
+// see complete snapshots. It is safe for concurrent use, and concurrent
+// Updates are applied one at a time.
+//
+// WellKnownStore is an http.Handler serving the current snapshot, suitable
+// for mounting at /.well-known/hap.json.
+type WellKnownStore struct {
+	mu       sync.Mutex // serializes updates
+	snapshot atomic.Pointer[wellKnownSnapshot]
+	updates  atomic.Uint64
+}
+
+// NewWellKnownStore creates a store serving a copy of wk, which must pass
+// ValidateWellKnown. Later changes to wk's slices do not reach the store.
+func NewWellKnownStore(wk WellKnown) (*WellKnownStore, error) {
+	s := &WellKnownStore{}
+	snap, err := newWellKnownSnapshot(cloneWellKnown(&wk))
+	if err != nil {
+		return nil, err
+	}
+	s.snapshot.Store(snap)
+	return s, nil
+}
+
+// Current returns the current document. It is shared with other readers and
+// must not be modified; use Update to make changes.
+func (s *WellKnownStore) Current() *WellKnown {
+	return s.snapshot.Load().doc
+}
+
+// ETag returns the entity tag of the current document
+func (s *WellKnownStore) ETag() string {
+	return s.snapshot.Load().etag
+}
+
+// Updates returns how many updates have been applied since the store was created
+func (s *WellKnownStore) Updates() uint64 {
+	return s.updates.Load()
+}
+
+// Update applies fn to a copy of the current document and publishes the
+// result. If fn returns an error, or the result fails ValidateWellKnown, the
+// current document is left unchanged.
+func (s *WellKnownStore) Update(fn func(*WellKnown) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	doc := cloneWellKnown(s.snapshot.Load().doc)
+	if err := fn(doc); err != nil {
+		return err
+	}
+
+	snap, err := newWellKnownSnapshot(doc)
+	if err != nil {
+		return err
+	}
+	s.snapshot.Store(snap)
+	s.updates.Add(1)
+	return nil
+}
+
+// ServeHTTP serves the current document, answering conditional requests
+// whose If-None-Match matches the current ETag with 304 Not Modified
+func (s *WellKnownStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	snap := s.snapshot.Load()
+	w.Header().Set("ETag", snap.etag)
+	if etagMatches(r.Header.Get("If-None-Match"), snap.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(snap.body)
+}
+
+// newWellKnownSnapshot validates and serializes doc
+func newWellKnownSnapshot(doc *WellKnown) (*wellKnownSnapshot, error) {
+	if err := ValidateWellKnown(*doc); err != nil {
+		return nil, fmt.Errorf("invalid well-known document: %w", err)
+	}
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize well-known document: %w", err)
+	}
+	return &wellKnownSnapshot{doc: doc, body: body, etag: etagForBody(body)}, nil
+}
+
+// WellKnownETag returns a strong ETag for wk computed from its JSON
+// serialization, so equal documents always produce the same ETag
+func WellKnownETag(wk WellKnown) string {
+	body, err := json.Marshal(wk)
+	if err != nil {
+		return ""
+	}
+	return etagForBody(body)
+}
+
+func etagForBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using
+// the weak comparison RFC 9110 requires for If-None-Match
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cloneWellKnown returns a deep copy of wk
+func cloneWellKnown(wk *WellKnown) *WellKnown {
+	c := &WellKnown{Issuer: wk.Issuer, MaxBatchSize: wk.MaxBatchSize, Discovery: wk.Discovery}
+	c.Keys = append([]JWK(nil), wk.Keys...)
+	for i, k := range c.Keys {
+		if k.Created != nil {
+			created := *k.Created
+			c.Keys[i].Created = &created
+		}
+	}
+	if wk.Methods != nil {
+		c.Methods = make([]IssuerMethod, len(wk.Methods))
+		for i, m := range wk.Methods {
+			m.Tiers = append([]string(nil), m.Tiers...)
+			c.Methods[i] = m
+		}
+	}
+	return c
+}
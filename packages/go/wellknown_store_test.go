@@ -0,0 +1,228 @@
+package humanattestation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestWellKnownStoreUpdate(t *testing.T) {
+	store, err := NewWellKnownStore(WellKnown{Issuer: "va.example", Keys: []JWK{testJWK(t, "key_001")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := store.Current()
+	etag := store.ETag()
+
+	if err := store.Update(func(wk *WellKnown) error {
+		wk.Keys = append(wk.Keys, testJWK(t, "key_002"))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(before.Keys) != 1 {
+		t.Errorf("previous snapshot was mutated: %d keys", len(before.Keys))
+	}
+	if len(store.Current().Keys) != 2 {
+		t.Errorf("current snapshot has %d keys, want 2", len(store.Current().Keys))
+	}
+	if store.ETag() == etag {
+		t.Error("ETag not regenerated after update")
+	}
+	if store.Updates() != 1 {
+		t.Errorf("Updates() = %d, want 1", store.Updates())
+	}
+
+	// Failed and invalid updates leave the document unchanged
+	errBoom := errors.New("boom")
+	if err := store.Update(func(wk *WellKnown) error {
+		wk.Keys = nil
+		return errBoom
+	}); !errors.Is(err, errBoom) {
+		t.Errorf("Update() error = %v, want %v", err, errBoom)
+	}
+	if err := store.Update(func(wk *WellKnown) error {
+		wk.Keys = nil
+		return nil
+	}); err == nil {
+		t.Error("expected error for update removing all keys")
+	}
+	if len(store.Current().Keys) != 2 || store.Updates() != 1 {
+		t.Error("rejected update changed the document")
+	}
+}
+
+func TestNewWellKnownStoreCopies(t *testing.T) {
+	created := Timestamp{}
+	key := testJWK(t, "key_001")
+	key.Created = &created
+	wk := WellKnown{
+		Issuer:  "va.example",
+		Keys:    []JWK{key},
+		Methods: []IssuerMethod{{Method: "ba_priority_mail", Tiers: []string{"standard"}, Enabled: true}},
+	}
+	store, err := NewWellKnownStore(wk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := func() string {
+		rec := httptest.NewRecorder()
+		store.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/hap.json", nil))
+		return rec.Body.String()
+	}
+	served := body()
+
+	// The caller keeps using its own document
+	wk.Keys[0].Kid = "key_evil"
+	wk.Keys[0].Created.Time = created.AddDate(1, 0, 0)
+	wk.Methods[0].Tiers[0] = "premium"
+
+	cur := store.Current()
+	if cur.Keys[0].Kid != "key_001" || !cur.Keys[0].Created.IsZero() || cur.Methods[0].Tiers[0] != "standard" {
+		t.Errorf("store shares the caller's slices: %+v", cur)
+	}
+	if body() != served {
+		t.Error("served document changed without an update")
+	}
+}
+
+func TestWellKnownStoreServeHTTP(t *testing.T) {
+	store, err := NewWellKnownStore(WellKnown{Issuer: "va.example", Keys: []JWK{testJWK(t, "key_001")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	store.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/hap.json", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != store.ETag() {
+		t.Fatalf("GET = %d, ETag %q", rec.Code, rec.Header().Get("ETag"))
+	}
+	var wk WellKnown
+	if err := json.Unmarshal(rec.Body.Bytes(), &wk); err != nil || wk.Issuer != "va.example" {
+		t.Errorf("body = %s, err %v", rec.Body.String(), err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/hap.json", nil)
+	req.Header.Set("If-None-Match", store.ETag())
+	rec = httptest.NewRecorder()
+	store.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("conditional GET = %d, want 304", rec.Code)
+	}
+}
+
+func TestWellKnownETag(t *testing.T) {
+	wk := WellKnown{Issuer: "va.example", Keys: []JWK{testJWK(t, "key_001")}}
+	store, err := NewWellKnownStore(wk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if WellKnownETag(wk) != WellKnownETag(wk) || WellKnownETag(wk) != store.ETag() {
+		t.Errorf("ETag not stable: %s, %s", WellKnownETag(wk), store.ETag())
+	}
+
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/.well-known/hap.json", nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rec := httptest.NewRecorder()
+		store.ServeHTTP(rec, req)
+		return rec
+	}
+
+	oldETag := store.ETag()
+	for _, header := range []string{oldETag, "W/" + oldETag, `"other", ` + oldETag, "*"} {
+		if rec := get(header); rec.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %s: got %d, want 304", header, rec.Code)
+		}
+	}
+
+	if err := store.Update(func(wk *WellKnown) error {
+		wk.Keys = append(wk.Keys, testJWK(t, "key_002"))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if store.ETag() == oldETag {
+		t.Fatal("changed document kept its ETag")
+	}
+	if store.ETag() != WellKnownETag(*store.Current()) {
+		t.Error("store ETag differs from WellKnownETag")
+	}
+
+	rec := get(oldETag)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != store.ETag() {
+		t.Errorf("stale If-None-Match: got %d with ETag %s, want 200 with %s", rec.Code, rec.Header().Get("ETag"), store.ETag())
+	}
+}
+
+func TestWellKnownStoreConcurrent(t *testing.T) {
+	store, err := NewWellKnownStore(WellKnown{Issuer: "va.example", Keys: []JWK{testJWK(t, "key_000")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(store)
+	defer srv.Close()
+
+	const writers, readers, iterations = 4, 8, 50
+	var wg sync.WaitGroup
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				kid := fmt.Sprintf("key_%d_%d", w, i)
+				jwk := testJWK(t, kid)
+				if err := store.Update(func(wk *WellKnown) error {
+					wk.Keys = append(wk.Keys, jwk)
+					return nil
+				}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < iterations; i++ {
+				resp, err := srv.Client().Get(srv.URL)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				var wk WellKnown
+				err = json.NewDecoder(resp.Body).Decode(&wk)
+				resp.Body.Close()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if err := ValidateWellKnown(wk); err != nil {
+					t.Errorf("served invalid document: %v", err)
+				}
+				_ = store.Current().Keys[0]
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if got := store.Updates(); got != writers*iterations {
+		t.Errorf("Updates() = %d, want %d", got, writers*iterations)
+	}
+	if got := len(store.Current().Keys); got != 1+writers*iterations {
+		t.Errorf("document has %d keys, want %d", got, 1+writers*iterations)
+	}
+}
@@ -0,0 +1,160 @@
+package humanattestation
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func testJWK(t *testing.T, kid string) JWK {
+	t.Helper()
+	_, pub, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ExportPublicKeyJWK(pub, kid)
+}
+
+func TestDiffWellKnown(t *testing.T) {
+	k1 := testJWK(t, "key_001")
+	k2 := testJWK(t, "key_002")
+	k1Reused := testJWK(t, "key_001")
+
+	tests := []struct {
+		name        string
+		old, new    WellKnown
+		wantAdded   []string
+		wantRemoved []string
+	}{
+		{
+			name:      "added key",
+			old:       WellKnown{Issuer: "va.example", Keys: []JWK{k1}},
+			new:       WellKnown{Issuer: "va.example", Keys: []JWK{k1, k2}},
+			wantAdded: []string{"key_002"},
+		},
+		{
+			name:        "removed key",
+			old:         WellKnown{Issuer: "va.example", Keys: []JWK{k1, k2}},
+			new:         WellKnown{Issuer: "va.example", Keys: []JWK{k2}},
+			wantRemoved: []string{"key_001"},
+		},
+		{
+			name:        "reused kid with changed material",
+			old:         WellKnown{Issuer: "va.example", Keys: []JWK{k1}},
+			new:         WellKnown{Issuer: "va.example", Keys: []JWK{k1Reused}},
+			wantAdded:   []string{"key_001"},
+			wantRemoved: []string{"key_001"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, removed := DiffWellKnown(tt.old, tt.new)
+			if !reflect.DeepEqual(added, tt.wantAdded) {
+				t.Errorf("added = %v, want %v", added, tt.wantAdded)
+			}
+			if !reflect.DeepEqual(removed, tt.wantRemoved) {
+				t.Errorf("removed = %v, want %v", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestValidateWellKnownRequiresClaimsKey(t *testing.T) {
+	_, pub, _ := GenerateKeyPair()
+	webhooks := ExportPublicKeyJWKWithUse(pub, "webhooks_001", KeyUseWebhooks)
+	receipts := ExportPublicKeyJWKWithUse(pub, "receipts_001", KeyUseReceipts)
+	claims := ExportPublicKeyJWKWithUse(pub, "claims_001", KeyUseClaims)
+
+	if err := ValidateWellKnown(WellKnown{Issuer: "va.example", Keys: []JWK{webhooks, receipts}}); err == nil {
+		t.Error("expected error for document without claims-use key")
+	}
+	if err := ValidateWellKnown(WellKnown{Issuer: "va.example", Keys: []JWK{webhooks, receipts, claims}}); err != nil {
+		t.Errorf("unexpected error for mixed-use document: %v", err)
+	}
+	if err := ValidateWellKnown(WellKnown{Issuer: "va.example", Keys: []JWK{testJWK(t, "legacy_001")}}); err != nil {
+		t.Errorf("unexpected error for key without use: %v", err)
+	}
+}
+
+func TestValidateWellKnownMaxBatchSize(t *testing.T) {
+	keys := []JWK{testJWK(t, "key_001")}
+	if err := ValidateWellKnown(WellKnown{Issuer: "va.example", Keys: keys, MaxBatchSize: 500}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := ValidateWellKnown(WellKnown{Issuer: "va.example", Keys: keys, MaxBatchSize: -1}); err == nil {
+		t.Error("expected error for negative maxBatchSize")
+	}
+}
+
+func TestValidateWellKnownMethods(t *testing.T) {
+	keys := []JWK{testJWK(t, "key_001")}
+	mail := IssuerMethod{Method: "ba_priority_mail", TypicalCostCents: 800, TypicalDurationSeconds: 259200, Tiers: []string{"standard"}, Enabled: true}
+
+	tests := []struct {
+		name    string
+		methods []IssuerMethod
+		wantErr bool
+	}{
+		{name: "valid", methods: []IssuerMethod{mail}},
+		{name: "missing name", methods: []IssuerMethod{{Enabled: true}}, wantErr: true},
+		{name: "duplicate method", methods: []IssuerMethod{mail, mail}, wantErr: true},
+		{name: "negative cost", methods: []IssuerMethod{{Method: "m", TypicalCostCents: -1}}, wantErr: true},
+		{name: "duplicate tier", methods: []IssuerMethod{{Method: "m", Tiers: []string{"a", "a"}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateWellKnown(WellKnown{Issuer: "va.example", Keys: keys, Methods: tt.methods})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateWellKnown() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateClaimMethod(t *testing.T) {
+	methods := []IssuerMethod{
+		{Method: "ba_priority_mail", Tiers: []string{"standard", "express"}, Enabled: true},
+		{Method: "ba_retired", Enabled: false},
+	}
+
+	tests := []struct {
+		name         string
+		method, tier string
+		methods      []IssuerMethod
+		wantWarnings int
+	}{
+		{name: "offered", method: "ba_priority_mail", tier: "express", methods: methods},
+		{name: "offered, other spelling", method: "BA-Priority-Mail", tier: "express", methods: methods},
+		{name: "no methods published", method: "anything", methods: nil},
+		{name: "unknown method", method: "ba_unknown", methods: methods, wantWarnings: 1},
+		{name: "disabled method", method: "ba_retired", methods: methods, wantWarnings: 1},
+		{name: "unknown tier", method: "ba_priority_mail", tier: "overnight", methods: methods, wantWarnings: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claim := &Claim{Method: tt.method, Tier: tt.tier}
+			if got := ValidateClaimMethod(claim, tt.methods); len(got) != tt.wantWarnings {
+				t.Errorf("ValidateClaimMethod() = %v, want %d warnings", got, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestFetchIssuerMethods(t *testing.T) {
+	va := newTestVA(t)
+	va.AddKey(t, "claims_001", KeyUseClaims)
+	va.mu.Lock()
+	va.wellKnown.Methods = []IssuerMethod{{Method: "ba_priority_mail", TypicalCostCents: 800, Enabled: true}}
+	va.mu.Unlock()
+
+	methods, err := FetchIssuerMethods(context.Background(), va.Issuer(), va.Options())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(methods) != 1 || methods[0].Method != "ba_priority_mail" || methods[0].TypicalCostCents != 800 {
+		t.Errorf("FetchIssuerMethods() = %+v", methods)
+	}
+}
@@ -111,6 +111,16 @@ if [ -f "packages/csharp/HumanAttestation.csproj" ]; then
     echo "✓ Updated packages/csharp/HumanAttestation.csproj"
 fi
 
+# Go (packages/go/hapotel/go.mod requires the SDK tagged packages/go/v$VERSION)
+if [ -f "packages/go/hapotel/go.mod" ]; then
+    if [[ "$OSTYPE" == "darwin"* ]]; then
+        sed -i '' "s|^\(	github.com/Blue-Scroll/hap/packages/go\) v[^ ]*$|\1 v$VERSION|" packages/go/hapotel/go.mod
+    else
+        sed -i "s|^\(	github.com/Blue-Scroll/hap/packages/go\) v[^ ]*$|\1 v$VERSION|" packages/go/hapotel/go.mod
+    fi
+    echo "✓ Updated packages/go/hapotel/go.mod"
+fi
+
 echo ""
 echo "✅ Version synced to $VERSION across all packages"
 echo ""